@@ -26,7 +26,7 @@ func handleAICompletion(r *fastglue.Request) error {
 		return sendErrorEnvelope(r, envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil))
 	}
 
-	resp, err := app.ai.Completion(req.PromptKey, req.Content)
+	resp, err := app.ai.Completion(requestContext(r), req.PromptKey, req.Content)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/abhinavxd/libredesk/internal/blockrule"
+	bmodels "github.com/abhinavxd/libredesk/internal/blockrule/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// handleGetBlockRules returns all sender block rules.
+func handleGetBlockRules(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	rules, err := app.blockRule.GetAll()
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(rules)
+}
+
+// handleGetBlockRule returns a sender block rule.
+func handleGetBlockRule(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	rule, err := app.blockRule.Get(id)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(rule)
+}
+
+// handleCreateBlockRule creates a sender block rule.
+func handleCreateBlockRule(r *fastglue.Request) error {
+	var (
+		app  = r.Context.(*App)
+		rule bmodels.Rule
+	)
+	if err := r.Decode(&rule, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), err.Error(), envelope.InputError)
+	}
+	if err := validateBlockRule(app, &rule); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	created, err := app.blockRule.Create(rule)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	broadcastBlockRuleReload(app)
+	return r.SendEnvelope(created)
+}
+
+// handleUpdateBlockRule updates a sender block rule.
+func handleUpdateBlockRule(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		rule  bmodels.Rule
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := r.Decode(&rule, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), err.Error(), envelope.InputError)
+	}
+	if err := validateBlockRule(app, &rule); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if _, err := app.blockRule.Get(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	updated, err := app.blockRule.Update(id, rule)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	broadcastBlockRuleReload(app)
+	return r.SendEnvelope(updated)
+}
+
+// handleDeleteBlockRule deletes a sender block rule.
+func handleDeleteBlockRule(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := app.blockRule.Delete(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	broadcastBlockRuleReload(app)
+	return r.SendEnvelope(true)
+}
+
+// broadcastBlockRuleReload makes the other instances reload the block rules, as incoming messages are processed on
+// all of them. The rules are already reloaded on this instance by the change.
+func broadcastBlockRuleReload(app *App) {
+	if _, err := publishAdminRequest(ctx, app.redis, adminRequest{Action: adminActionBlockRuleReload, Node: app.leader.NodeID()}); err != nil {
+		app.lo.Error("error publishing block rule reload to other instances", "error", err)
+	}
+}
+
+// validateBlockRule validates a sender block rule and trims its name and pattern.
+func validateBlockRule(app *App, rule *bmodels.Rule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if rule.Name == "" {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.empty", "name", "`name`"), nil)
+	}
+	if !slices.Contains(bmodels.Types, rule.Type) {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.invalid", "name", "`type`"), nil)
+	}
+	if !slices.Contains(bmodels.Actions, rule.Action) {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.invalid", "name", "`action`"), nil)
+	}
+	if rule.Pattern == "" {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.empty", "name", "`pattern`"), nil)
+	}
+	if err := blockrule.Validate(*rule); err != nil {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.invalid", "name", "`pattern`"), err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	rmodels "github.com/abhinavxd/libredesk/internal/role/models"
+	"github.com/abhinavxd/libredesk/internal/setting"
+	"github.com/abhinavxd/libredesk/internal/stringutil"
+	"github.com/abhinavxd/libredesk/internal/user"
+	umodels "github.com/abhinavxd/libredesk/internal/user/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/go-i18n"
+	"github.com/knadh/stuffbin"
+	"github.com/redis/go-redis/v9"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	// adminChannel is the Redis channel admin commands and instances publish requests to, every running instance
+	// acts on them.
+	adminChannel = "libredesk:admin"
+
+	adminActionInboxFetch      = "inbox_fetch"
+	adminActionInboxReload     = "inbox_reload"
+	adminActionInvalidateAgent = "invalidate_agent"
+	adminActionBlockRuleReload = "blockrule_reload"
+	adminActionSpamReload      = "spam_reload"
+
+	adminPublishTimeout = 5 * time.Second
+
+	// minAdminResubscribeDelay and maxAdminResubscribeDelay bound the exponential backoff between attempts to
+	// resubscribe to admin requests.
+	minAdminResubscribeDelay = time.Second
+	maxAdminResubscribeDelay = 30 * time.Second
+)
+
+// adminRequest is a request published by an admin command or an instance to the running instances.
+type adminRequest struct {
+	Action string `json:"action"`
+	ID     int    `json:"id"`
+	// Node is the ID of the instance that published the request, which has already acted on it.
+	Node string `json:"node,omitempty"`
+}
+
+// cli holds the dependencies of the admin commands.
+type cli struct {
+	ctx      context.Context
+	db       *sqlx.DB
+	fs       stuffbin.FileSystem
+	i18n     *i18n.I18n
+	settings *setting.Manager
+}
+
+// cliCommand is an admin command run from the command line, e.g. `libredesk agent create --email ...`.
+type cliCommand struct {
+	name  string
+	usage string
+	// noSettings skips loading settings, for commands that must work on a schema that is not upgraded yet.
+	noSettings bool
+	run        func(c *cli, args []string) (any, error)
+}
+
+// cliCommands is the list of admin commands. Their results are printed to stdout as JSON.
+var cliCommands = []cliCommand{
+	{name: "agent list", usage: "list all agents", run: cmdAgentList},
+	{name: "agent create", usage: "--email E --first-name F [--last-name L] --role R... [--password P]; create an agent, a password is generated if not passed", run: cmdAgentCreate},
+	{name: "agent disable", usage: "--email E; disable an agent", run: cmdAgentSetEnabled(false)},
+	{name: "agent enable", usage: "--email E; enable an agent", run: cmdAgentSetEnabled(true)},
+	{name: "agent set-roles", usage: "--email E --role R...; replace the roles of an agent", run: cmdAgentSetRoles},
+	{name: "agent reset-password", usage: "--email E [--password P]; set the password of an agent, a password is generated if not passed", run: cmdAgentResetPassword},
+	{name: "inbox fetch", usage: "--id N; ask the instance running the inbox receivers to fetch new messages of an inbox right away", run: cmdInboxFetch},
+	{name: "search reindex", usage: "rebuild the search indexes", run: cmdSearchReindex},
+	{name: "secrets reencrypt", usage: "re-encrypt stored secrets with app.encryption_key, after which app.previous_encryption_keys can be removed", run: cmdSecretsReEncrypt},
+	{name: "settings export", usage: "print all settings, secrets are decrypted", run: cmdSettingsExport},
+	{name: "settings import", usage: "[--file F]; update settings from a JSON object read from the file or stdin", run: cmdSettingsImport},
+	{name: "migrations list", usage: "list the pending database migrations", noSettings: true, run: cmdMigrationsList},
+}
+
+// cliUsage returns the usage of the admin commands.
+func cliUsage() string {
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, c := range cliCommands {
+		fmt.Fprintf(&b, "  %-22s %s\n", c.name, c.usage)
+	}
+	return b.String()
+}
+
+// runCommand runs the admin command in args, prints its result as JSON and returns the process exit code.
+func runCommand(ctx context.Context, db *sqlx.DB, fs stuffbin.FileSystem, args []string) int {
+	var cmd *cliCommand
+	if len(args) >= 2 {
+		name := args[0] + " " + args[1]
+		for i := range cliCommands {
+			if cliCommands[i].name == name {
+				cmd = &cliCommands[i]
+				break
+			}
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", strings.Join(args, " "), cliUsage())
+		return 2
+	}
+
+	c := &cli{ctx: ctx, db: db, fs: fs}
+	if !cmd.noSettings {
+		checkPendingUpgrade(db)
+		c.settings = initSettings(db)
+		loadSettings(c.settings)
+		validateConfig(ko)
+		c.i18n = initI18n(fs)
+	}
+
+	out, err := cmd.run(c, args[2:])
+	if err != nil {
+		printJSON(os.Stderr, map[string]string{"error": err.Error()})
+		return 1
+	}
+	printJSON(os.Stdout, out)
+	return 0
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "error encoding output: %v\n", err)
+	}
+}
+
+// parseFlags parses the flags of a command registered by fn.
+func parseFlags(name string, args []string, fn func(f *flag.FlagSet)) error {
+	f := flag.NewFlagSet(name, flag.ContinueOnError)
+	f.SetOutput(io.Discard)
+	fn(f)
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if f.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected arguments: %s", name, strings.Join(f.Args(), " "))
+	}
+	return nil
+}
+
+func cmdAgentList(c *cli, args []string) (any, error) {
+	if err := parseFlags("agent list", args, func(f *flag.FlagSet) {}); err != nil {
+		return nil, err
+	}
+	return initUser(c.i18n, c.db).GetAgents()
+}
+
+func cmdAgentCreate(c *cli, args []string) (any, error) {
+	var (
+		email, firstName, lastName, password string
+		roles                                []string
+	)
+	if err := parseFlags("agent create", args, func(f *flag.FlagSet) {
+		f.StringVar(&email, "email", "", "email of the agent")
+		f.StringVar(&firstName, "first-name", "", "first name of the agent")
+		f.StringVar(&lastName, "last-name", "", "last name of the agent")
+		f.StringSliceVar(&roles, "role", nil, "role of the agent, can be repeated")
+		f.StringVar(&password, "password", "", "password of the agent")
+	}); err != nil {
+		return nil, err
+	}
+	if !stringutil.ValidEmail(email) {
+		return nil, errors.New("a valid --email is required")
+	}
+	if firstName == "" {
+		return nil, errors.New("--first-name is required")
+	}
+	if err := c.validateRoles(roles); err != nil {
+		return nil, err
+	}
+
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = generatePassword(); err != nil {
+			return nil, err
+		}
+	}
+	if !user.IsStrongPassword(password) {
+		return nil, errors.New(user.PasswordHint)
+	}
+
+	users := initUser(c.i18n, c.db)
+	agent, err := users.CreateAgentWithPassword(firstName, lastName, email, roles, password)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]any{"agent": agent}
+	if generated {
+		out["password"] = password
+	}
+	return out, nil
+}
+
+func cmdAgentSetEnabled(enabled bool) func(c *cli, args []string) (any, error) {
+	name := "agent disable"
+	if enabled {
+		name = "agent enable"
+	}
+	return func(c *cli, args []string) (any, error) {
+		var email string
+		if err := parseFlags(name, args, func(f *flag.FlagSet) {
+			f.StringVar(&email, "email", "", "email of the agent")
+		}); err != nil {
+			return nil, err
+		}
+
+		users := initUser(c.i18n, c.db)
+		agent, err := getAgentByEmail(users, email)
+		if err != nil {
+			return nil, err
+		}
+		if err := users.ToggleEnabled(agent.ID, umodels.UserTypeAgent, enabled); err != nil {
+			return nil, err
+		}
+		c.invalidateAgent(agent.ID)
+		return users.GetAgent(agent.ID, "")
+	}
+}
+
+func cmdAgentSetRoles(c *cli, args []string) (any, error) {
+	var (
+		email string
+		roles []string
+	)
+	if err := parseFlags("agent set-roles", args, func(f *flag.FlagSet) {
+		f.StringVar(&email, "email", "", "email of the agent")
+		f.StringSliceVar(&roles, "role", nil, "role of the agent, can be repeated")
+	}); err != nil {
+		return nil, err
+	}
+	if err := c.validateRoles(roles); err != nil {
+		return nil, err
+	}
+
+	users := initUser(c.i18n, c.db)
+	agent, err := getAgentByEmail(users, email)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.UpdateAgent(agent.ID, agent.FirstName, agent.LastName, agent.Email.String, roles, agent.Enabled, agent.AvailabilityStatus, ""); err != nil {
+		return nil, err
+	}
+	c.invalidateAgent(agent.ID)
+	return users.GetAgent(agent.ID, "")
+}
+
+func cmdAgentResetPassword(c *cli, args []string) (any, error) {
+	var email, password string
+	if err := parseFlags("agent reset-password", args, func(f *flag.FlagSet) {
+		f.StringVar(&email, "email", "", "email of the agent")
+		f.StringVar(&password, "password", "", "new password of the agent")
+	}); err != nil {
+		return nil, err
+	}
+
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = generatePassword(); err != nil {
+			return nil, err
+		}
+	}
+
+	users := initUser(c.i18n, c.db)
+	agent, err := getAgentByEmail(users, email)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.UpdateAgent(agent.ID, agent.FirstName, agent.LastName, agent.Email.String, agent.Roles, agent.Enabled, agent.AvailabilityStatus, password); err != nil {
+		return nil, err
+	}
+	c.invalidateAgent(agent.ID)
+
+	out := map[string]any{"id": agent.ID, "email": agent.Email.String}
+	if generated {
+		out["password"] = password
+	}
+	return out, nil
+}
+
+func cmdInboxFetch(c *cli, args []string) (any, error) {
+	var id int
+	if err := parseFlags("inbox fetch", args, func(f *flag.FlagSet) {
+		f.IntVar(&id, "id", 0, "ID of the inbox")
+	}); err != nil {
+		return nil, err
+	}
+
+	inb, err := initInbox(c.db, c.i18n).GetDBRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	if !inb.Enabled {
+		return nil, fmt.Errorf("inbox %d is disabled", id)
+	}
+
+	// The request is broadcast to all instances but only the one running the inbox receivers fetches, and it does
+	// not reply, so all that is known here is whether any instance got the request.
+	n, err := c.publish(adminRequest{Action: adminActionInboxFetch, ID: id})
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errors.New("no running instance received the request")
+	}
+	return map[string]any{"inbox_id": id, "status": "requested", "message": "fetch requested, the instance running the inbox receivers fetches new messages"}, nil
+}
+
+func cmdSearchReindex(c *cli, args []string) (any, error) {
+	if err := parseFlags("search reindex", args, func(f *flag.FlagSet) {}); err != nil {
+		return nil, err
+	}
+	indexes, err := initSearch(c.db, c.i18n).Reindex(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"indexes": indexes}, nil
+}
+
+func cmdSecretsReEncrypt(c *cli, args []string) (any, error) {
+	if err := parseFlags("secrets reencrypt", args, func(f *flag.FlagSet) {}); err != nil {
+		return nil, err
+	}
+
+	var (
+		out = make(map[string]int)
+		err error
+	)
+	if out["inboxes"], err = initInbox(c.db, c.i18n).ReEncrypt(); err != nil {
+		return nil, err
+	}
+	if out["webhooks"], err = initWebhook(c.db, c.i18n).ReEncrypt(); err != nil {
+		return nil, err
+	}
+	if out["oidc"], err = initOIDC(c.db, c.settings, c.i18n).ReEncrypt(); err != nil {
+		return nil, err
+	}
+	if out["ai_providers"], err = initAI(c.db, c.i18n).ReEncrypt(); err != nil {
+		return nil, err
+	}
+	if out["settings"], err = c.settings.ReEncrypt(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func cmdSettingsExport(c *cli, args []string) (any, error) {
+	if err := parseFlags("settings export", args, func(f *flag.FlagSet) {}); err != nil {
+		return nil, err
+	}
+	b, err := c.settings.GetAllJSON()
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	// Migrations are recorded by upgrades, not configuration.
+	delete(out, "migrations")
+	return out, nil
+}
+
+func cmdSettingsImport(c *cli, args []string) (any, error) {
+	var file string
+	if err := parseFlags("settings import", args, func(f *flag.FlagSet) {
+		f.StringVar(&file, "file", "", "path to the JSON file, stdin if not passed")
+	}); err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var in map[string]any
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("decoding settings: %w", err)
+	}
+	delete(in, "migrations")
+	if len(in) == 0 {
+		return nil, errors.New("no settings to import")
+	}
+
+	if err := c.settings.Update(in); err != nil {
+		return nil, err
+	}
+	return map[string]any{"keys": len(in), "restart_required": true}, nil
+}
+
+func cmdMigrationsList(c *cli, args []string) (any, error) {
+	if err := parseFlags("migrations list", args, func(f *flag.FlagSet) {}); err != nil {
+		return nil, err
+	}
+	lastVer, toRun, err := getPendingMigrations(c.db)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]string, 0, len(toRun))
+	for _, m := range toRun {
+		pending = append(pending, m.version)
+	}
+	return map[string]any{"last_version": lastVer, "pending": pending}, nil
+}
+
+// validateRoles checks that at least one role is passed and all of them exist.
+func (c *cli) validateRoles(roles []string) error {
+	if len(roles) == 0 {
+		return errors.New("at least one --role is required")
+	}
+	all, err := initRole(c.db, c.i18n).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if !slices.ContainsFunc(all, func(role rmodels.Role) bool { return role.Name == r }) {
+			return fmt.Errorf("unknown role: %s", r)
+		}
+	}
+	return nil
+}
+
+// getAgentByEmail returns the agent with the passed email.
+func getAgentByEmail(users *user.Manager, email string) (umodels.User, error) {
+	if email == "" {
+		return umodels.User{}, errors.New("--email is required")
+	}
+	return users.GetAgent(0, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// invalidateAgent makes the running instances drop their cached copy of an agent. Failures are only printed as a
+// warning as the change is already saved.
+func (c *cli) invalidateAgent(id int) {
+	if _, err := c.publish(adminRequest{Action: adminActionInvalidateAgent, ID: id}); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: error notifying running instances, restart them for the change to take effect: %v\n", err)
+	}
+}
+
+// generatePassword generates a random password that passes user.IsStrongPassword.
+func generatePassword() (string, error) {
+	for {
+		p, err := stringutil.RandomAlphanumeric(24)
+		if err != nil {
+			return "", err
+		}
+		// The alphabet has no special characters, add one.
+		if p = p[:12] + "-" + p[12:]; user.IsStrongPassword(p) {
+			return p, nil
+		}
+	}
+}
+
+// publish publishes a request to the running instances and returns the number of instances that received it.
+func (c *cli) publish(req adminRequest) (int64, error) {
+	rdb := initRedis()
+	defer rdb.Close()
+	return publishAdminRequest(c.ctx, rdb, req)
+}
+
+// publishAdminRequest publishes a request to the running instances and returns the number of instances that
+// received it.
+func publishAdminRequest(ctx context.Context, rdb *redis.Client, req adminRequest) (int64, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, adminPublishTimeout)
+	defer cancel()
+	n, err := rdb.Publish(ctx, adminChannel, b).Result()
+	if err != nil {
+		return 0, fmt.Errorf("publishing to redis: %w", err)
+	}
+	return n, nil
+}
+
+// listenAdminRequests acts on the requests published by admin commands and other instances, resubscribing with
+// backoff if the subscription fails or is lost. It blocks until the context is cancelled.
+func listenAdminRequests(ctx context.Context, rdb *redis.Client, app *App) {
+	delay := minAdminResubscribeDelay
+	for {
+		subscribed, err := receiveAdminRequests(ctx, rdb, app)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			delay = minAdminResubscribeDelay
+		}
+		app.lo.Error("admin request subscription lost", "error", err, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxAdminResubscribeDelay)
+	}
+}
+
+// receiveAdminRequests subscribes to admin requests and acts on them until the context is cancelled or the
+// subscription fails. It returns whether subscribing succeeded and the error that ended the subscription.
+func receiveAdminRequests(ctx context.Context, rdb *redis.Client, app *App) (bool, error) {
+	sub := rdb.Subscribe(ctx, adminChannel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return false, fmt.Errorf("subscribing: %w", err)
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case m, ok := <-ch:
+			if !ok {
+				return true, errors.New("subscription channel closed")
+			}
+			var req adminRequest
+			if err := json.Unmarshal([]byte(m.Payload), &req); err != nil {
+				app.lo.Error("error decoding admin request", "error", err)
+				continue
+			}
+			switch req.Action {
+			case adminActionInboxFetch:
+				if app.inbox.Fetch(req.ID) {
+					app.lo.Info("fetching inbox on admin request", "inbox_id", req.ID)
+				}
+			case adminActionInboxReload:
+				if req.Node == app.leader.NodeID() {
+					continue
+				}
+				app.lo.Info("reloading inboxes changed on another instance")
+				if err := app.inbox.Reload(makeInboxInitializer(app.inbox)); err != nil {
+					app.lo.Error("error reloading inboxes", "error", err)
+				}
+			case adminActionInvalidateAgent:
+				app.user.InvalidateAgentCache(req.ID)
+				app.authz.InvalidateUserCache(req.ID)
+			case adminActionBlockRuleReload:
+				if req.Node == app.leader.NodeID() {
+					continue
+				}
+				app.lo.Info("reloading block rules changed on another instance")
+				if err := app.blockRule.Reload(); err != nil {
+					app.lo.Error("error reloading block rules", "error", err)
+				}
+			case adminActionSpamReload:
+				if req.Node == app.leader.NodeID() {
+					continue
+				}
+				app.lo.Info("reloading spam filter changed on another instance")
+				if err := reloadSpamFilter(app); err != nil {
+					app.lo.Error("error reloading spam filter", "error", err)
+				}
+			default:
+				app.lo.Warn("unknown admin request", "action", req.Action)
+			}
+		}
+	}
+}
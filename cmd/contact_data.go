@@ -0,0 +1,181 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	amodels "github.com/abhinavxd/libredesk/internal/auth/models"
+	"github.com/abhinavxd/libredesk/internal/conversation"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/export"
+	umodels "github.com/abhinavxd/libredesk/internal/user/models"
+	realip "github.com/ferluci/fast-realip"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const contactDataExportKind = "contact_data"
+
+// handleExportContactData starts a background job that exports all personal data of a contact into a zip file:
+// the contact record with custom attributes, notes, CSAT responses, conversations with their messages and media.
+func handleExportContactData(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+		ip    = realip.FromRequest(r.RequestCtx)
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	contact, err := app.user.GetContact(id, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	fileName := fmt.Sprintf("contact-%d-%s.zip", contact.ID, time.Now().Format("20060102-150405"))
+	job, err := app.export.Submit(contactDataExportKind, auser.ID, fileName, func(t *export.Tracker, w io.Writer) error {
+		return processContactDataExport(app, t, w, contact)
+	})
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	if err := app.activityLog.ContactDataExported(auser.ID, auser.Email, ip, contact.ID, contact.Email.String); err != nil {
+		app.lo.Error("error creating activity log", "error", err)
+	}
+	return r.SendEnvelope(job)
+}
+
+// processContactDataExport writes the personal data of a contact to a zip archive.
+func processContactDataExport(app *App, t *export.Tracker, w io.Writer, contact umodels.User) error {
+	notes, err := app.user.GetNotes(contact.ID)
+	if err != nil {
+		return fmt.Errorf("fetching notes: %w", err)
+	}
+	csats, err := app.csat.GetByContact(contact.ID)
+	if err != nil {
+		return fmt.Errorf("fetching CSAT responses: %w", err)
+	}
+	uuids, err := app.conversation.GetContactConversationUUIDs(contact.ID)
+	if err != nil {
+		return fmt.Errorf("fetching conversations: %w", err)
+	}
+	media, err := app.media.GetContactMedia(contact.ID)
+	if err != nil {
+		return fmt.Errorf("fetching media: %w", err)
+	}
+	t.SetTotal(len(uuids) + len(media))
+
+	zw := zip.NewWriter(w)
+	if err := writeZipJSON(zw, "contact.json", contact); err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "notes.json", notes); err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "csat.json", csats); err != nil {
+		return err
+	}
+
+	for _, uuid := range uuids {
+		if err := t.Context().Err(); err != nil {
+			return err
+		}
+		exp, err := app.conversation.GetConversationExport(uuid)
+		if err != nil {
+			t.Error(fmt.Sprintf("Conversation %s: Error - %v", uuid, err))
+			continue
+		}
+		f, err := zw.Create(path.Join("conversations", conversation.ExportFileName(exp.Conversation.ReferenceNumber, conversation.ExportFormatJSON)))
+		if err != nil {
+			return fmt.Errorf("writing zip archive: %w", err)
+		}
+		if err := app.conversation.WriteConversationExport(f, exp, conversation.ExportFormatJSON); err != nil {
+			t.Error(fmt.Sprintf("Conversation #%s: Error - %v", exp.Conversation.ReferenceNumber, err))
+			continue
+		}
+		t.Success()
+	}
+
+	for _, m := range media {
+		if err := t.Context().Err(); err != nil {
+			return err
+		}
+		blob, err := app.media.GetBlob(m.UUID)
+		if err != nil {
+			t.Error(fmt.Sprintf("Media %s: Error - %v", m.UUID, err))
+			continue
+		}
+		f, err := zw.Create(path.Join("media", m.UUID+"-"+path.Base(m.Filename)))
+		if err != nil {
+			return fmt.Errorf("writing zip archive: %w", err)
+		}
+		if _, err := f.Write(blob); err != nil {
+			return fmt.Errorf("writing zip archive: %w", err)
+		}
+		t.Success()
+	}
+	return zw.Close()
+}
+
+// writeZipJSON writes data as an indented JSON file to a zip archive.
+func writeZipJSON(zw *zip.Writer, name string, data any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("writing zip archive: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// handleEraseContactData erases all personal data of a contact. The contact is anonymized, the messages of their
+// conversations are redacted and their media is deleted, while conversations are kept for reports.
+func handleEraseContactData(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+		ip    = realip.FromRequest(r.RequestCtx)
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	contact, err := app.user.GetContact(id, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	// Fetch the media before erasing, the messages it is linked to are matched by the contact.
+	media, err := app.media.GetContactMedia(contact.ID)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	app.lo.Info("erasing contact data", "contact_id", contact.ID, "actor_id", auser.ID)
+
+	if err := app.user.EraseContact(contact.ID); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	for _, m := range media {
+		if err := app.media.DeleteWithThumbnail(m); err != nil {
+			app.lo.Error("error deleting erased contact media", "contact_id", contact.ID, "media_uuid", m.UUID, "error", err)
+		}
+	}
+
+	if err := app.activityLog.ContactDataErased(auser.ID, auser.Email, ip, contact.ID); err != nil {
+		app.lo.Error("error creating activity log", "error", err)
+	}
+
+	contact, err = app.user.GetContact(contact.ID, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(contact)
+}
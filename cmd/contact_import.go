@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	customAttribute "github.com/abhinavxd/libredesk/internal/custom_attribute"
+	cmodels "github.com/abhinavxd/libredesk/internal/custom_attribute/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/stringutil"
+	"github.com/abhinavxd/libredesk/internal/user/models"
+	"github.com/valyala/fasthttp"
+	"github.com/volatiletech/null/v9"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	contactImportNamespace = "contacts"
+
+	// Prefix of mapping fields that map a CSV column to a contact custom attribute.
+	customAttributeFieldPrefix = "custom_attributes."
+)
+
+// contactImportFields are the contact fields CSV columns can be mapped to.
+var contactImportFields = []string{"first_name", "last_name", "email", "phone_number", "phone_number_country_code"}
+
+// handleImportContacts handles CSV upload and starts the contact import job.
+// The optional `mapping` form field is a JSON object of contact field to CSV column header,
+// e.g. {"email": "E-mail", "custom_attributes.plan": "Plan"}. Columns named after a contact field or a
+// contact custom attribute key are mapped automatically. With `dry_run` set, rows are only validated.
+func handleImportContacts(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+
+	file, err := r.RequestCtx.FormFile("file")
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.required", "name", "{globals.terms.file}"), nil, envelope.InputError)
+	}
+
+	var mapping map[string]string
+	if m := r.RequestCtx.FormValue("mapping"); len(m) > 0 {
+		if err := json.Unmarshal(m, &mapping); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`mapping`"), nil, envelope.InputError)
+		}
+	}
+	dryRun, _ := strconv.ParseBool(string(r.RequestCtx.FormValue("dry_run")))
+
+	fileContent, err := file.Open()
+	if err != nil {
+		app.lo.Error("error opening uploaded file", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, app.i18n.Ts("globals.messages.errorReading", "name", "{globals.terms.file}"), nil, envelope.GeneralError)
+	}
+	defer fileContent.Close()
+
+	reader := csv.NewReader(fileContent)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		app.lo.Error("error parsing CSV", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "{globals.terms.csvFile}"), nil, envelope.InputError)
+	}
+
+	if len(records) < 2 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.T("importer.csvMustContainHeadersAndData"), nil, envelope.InputError)
+	}
+
+	err = app.importer.Submit(contactImportNamespace, func() error {
+		return processContactImport(app, records, mapping, dryRun)
+	})
+
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, app.i18n.T("importer.importAlreadyInProgress"), nil, envelope.GeneralError)
+	}
+
+	return r.SendEnvelope(true)
+}
+
+// handleGetContactImportStatus returns current contact import status.
+func handleGetContactImportStatus(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	status, err := app.importer.GetStatus(contactImportNamespace)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(status)
+}
+
+// handleGetContactImportReport returns the rejected rows of the last contact import as a CSV file.
+func handleGetContactImportReport(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	report, err := app.importer.GetReport(contactImportNamespace)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(report); err != nil {
+		app.lo.Error("error writing contact import report", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, app.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.import}"), nil, envelope.GeneralError)
+	}
+
+	r.RequestCtx.SetContentType("text/csv; charset=utf-8")
+	r.RequestCtx.Response.Header.Set("Content-Disposition", `attachment; filename="contact-import-errors.csv"`)
+	r.RequestCtx.SetBody(buf.Bytes())
+	return nil
+}
+
+func processContactImport(app *App, records [][]string, mapping map[string]string, dryRun bool) error {
+	const ns = contactImportNamespace
+
+	// Fetch contact custom attribute definitions once.
+	attrs, err := app.customAttribute.GetAll(models.UserTypeContact)
+	if err != nil {
+		return fmt.Errorf("failed to fetch custom attributes: %v", err)
+	}
+	attrDefs := make(map[string]cmodels.CustomAttribute, len(attrs))
+	for _, a := range attrs {
+		attrDefs[a.Key] = a
+	}
+
+	columns, err := contactImportColumns(records[0], mapping, attrDefs)
+	if err != nil {
+		return err
+	}
+	if _, ok := columns["email"]; !ok {
+		return fmt.Errorf("missing required column: email")
+	}
+
+	// Rejected rows are reported with the original header and an extra error column.
+	app.importer.AddReportRow(ns, append(append([]string{}, records[0]...), "error"))
+	reject := func(rowNum int, record []string, reason string) {
+		app.importer.UpdateCounts(ns, 0, 0, 1)
+		app.importer.AddLog(ns, fmt.Sprintf("Row %d: Error - %s", rowNum, reason))
+		app.importer.AddReportRow(ns, append(append([]string{}, record...), reason))
+	}
+
+	// Initialize import
+	total := len(records) - 1
+	app.importer.UpdateCounts(ns, total, 0, 0)
+	if dryRun {
+		app.importer.AddLog(ns, fmt.Sprintf("Starting dry run of %d contacts, no changes will be made", total))
+	} else {
+		app.importer.AddLog(ns, fmt.Sprintf("Starting import of %d contacts", total))
+	}
+
+	// Process each row
+	seen := make(map[string]int)
+	for i, record := range records[1:] {
+		rowNum := i + 1
+
+		// Parse fields
+		field := func(name string) string {
+			if idx, ok := columns[name]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+		email := strings.ToLower(field("email"))
+		phoneNumber := field("phone_number")
+		phoneNumberCountryCode := field("phone_number_country_code")
+
+		// Validate fields
+		if email == "" {
+			reject(rowNum, record, "missing required field: email")
+			continue
+		}
+		if !stringutil.ValidEmail(email) {
+			reject(rowNum, record, fmt.Sprintf("invalid email format: %s", email))
+			continue
+		}
+		if prev, ok := seen[email]; ok {
+			reject(rowNum, record, fmt.Sprintf("duplicate of row %d: %s", prev, email))
+			continue
+		}
+		seen[email] = rowNum
+		if len(phoneNumber) > 20 {
+			reject(rowNum, record, fmt.Sprintf("phone number too long: %s", phoneNumber))
+			continue
+		}
+		if len(phoneNumberCountryCode) > 10 {
+			reject(rowNum, record, fmt.Sprintf("phone number country code too long: %s", phoneNumberCountryCode))
+			continue
+		}
+
+		// Parse and validate custom attributes, empty cells are skipped.
+		var (
+			customAttributes = make(map[string]any)
+			attrErrs         []string
+		)
+		for key, def := range attrDefs {
+			raw := field(customAttributeFieldPrefix + key)
+			if raw == "" {
+				continue
+			}
+			v, err := customAttribute.ParseValue(def, raw)
+			if err != nil {
+				attrErrs = append(attrErrs, err.Error())
+				continue
+			}
+			customAttributes[key] = v
+		}
+		if len(attrErrs) > 0 {
+			reject(rowNum, record, fmt.Sprintf("invalid custom attribute(s): %s", strings.Join(attrErrs, "; ")))
+			continue
+		}
+
+		if dryRun {
+			action := "create"
+			if _, err := app.user.GetContact(0, email); err == nil {
+				action = "update"
+			}
+			app.importer.UpdateCounts(ns, 0, 1, 0)
+			app.importer.AddLog(ns, fmt.Sprintf("Row %d: Would %s contact %s", rowNum, action, email))
+			continue
+		}
+
+		contact := models.User{
+			FirstName:              field("first_name"),
+			LastName:               field("last_name"),
+			Email:                  null.StringFrom(email),
+			PhoneNumber:            null.NewString(phoneNumber, phoneNumber != ""),
+			PhoneNumberCountryCode: null.NewString(phoneNumberCountryCode, phoneNumberCountryCode != ""),
+		}
+		_, created, err := app.user.UpsertContact(contact, customAttributes)
+		if err != nil {
+			reject(rowNum, record, fmt.Sprintf("failed to save contact: %v", err))
+			continue
+		}
+
+		app.importer.UpdateCounts(ns, 0, 1, 0)
+		if created {
+			app.importer.AddLog(ns, fmt.Sprintf("Row %d: Created contact %s", rowNum, email))
+		} else {
+			app.importer.AddLog(ns, fmt.Sprintf("Row %d: Updated contact %s", rowNum, email))
+		}
+	}
+
+	// Final summary
+	status, _ := app.importer.GetStatus(ns)
+	app.importer.AddLog(ns, fmt.Sprintf("Import completed: %d of %d successful, %d failed",
+		status.Success, status.Total, status.Errors))
+
+	return nil
+}
+
+// contactImportColumns returns the CSV column index of each mapped contact field. Without an explicit mapping,
+// columns are matched by contact field name or custom attribute key.
+func contactImportColumns(header []string, mapping map[string]string, attrDefs map[string]cmodels.CustomAttribute) (map[string]int, error) {
+	headerMap := make(map[string]int)
+	for i, h := range header {
+		headerMap[strings.TrimSpace(strings.ToLower(h))] = i
+	}
+
+	columns := make(map[string]int)
+	for _, f := range contactImportFields {
+		if idx, ok := headerMap[f]; ok {
+			columns[f] = idx
+		}
+	}
+	for key := range attrDefs {
+		if idx, ok := headerMap[strings.ToLower(key)]; ok {
+			columns[customAttributeFieldPrefix+key] = idx
+		}
+	}
+
+	for f, h := range mapping {
+		if key, ok := strings.CutPrefix(f, customAttributeFieldPrefix); ok {
+			if _, ok := attrDefs[key]; !ok {
+				return nil, fmt.Errorf("unknown contact custom attribute in mapping: %s", key)
+			}
+		} else if !slices.Contains(contactImportFields, f) {
+			return nil, fmt.Errorf("unknown field in mapping: %s", f)
+		}
+		idx, ok := headerMap[strings.TrimSpace(strings.ToLower(h))]
+		if !ok {
+			return nil, fmt.Errorf("mapped column not found in CSV: %s", h)
+		}
+		columns[f] = idx
+	}
+	return columns, nil
+}
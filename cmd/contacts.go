@@ -22,6 +22,11 @@ type blockContactReq struct {
 	Enabled bool `json:"enabled"`
 }
 
+type mergeContactsReq struct {
+	ContactID        int            `json:"contact_id"`
+	CustomAttributes map[string]any `json:"custom_attributes"`
+}
+
 // handleGetContacts returns a list of contacts from the database.
 func handleGetContacts(r *fastglue.Request) error {
 	var (
@@ -285,3 +290,46 @@ func handleBlockContact(r *fastglue.Request) error {
 	}
 	return r.SendEnvelope(contact)
 }
+
+// handleGetContactDuplicates returns possible duplicates of a contact.
+func handleGetContactDuplicates(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	duplicates, err := app.user.GetContactDuplicates(id)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(duplicates)
+}
+
+// handleMergeContacts merges another contact into a contact.
+func handleMergeContacts(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+		req   = mergeContactsReq{}
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := r.Decode(&req, "json"); err != nil {
+		return sendErrorEnvelope(r, envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil))
+	}
+	if req.ContactID <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`contact_id`"), nil, envelope.InputError)
+	}
+
+	app.lo.Info("merging contacts", "primary_id", id, "secondary_id", req.ContactID, "actor_id", auser.ID)
+
+	contact, err := app.user.MergeContacts(id, req.ContactID, req.CustomAttributes)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(contact)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"slices"
 	"strconv"
 	"time"
@@ -41,6 +42,15 @@ type tagsUpdateReq struct {
 	Tags []string `json:"tags"`
 }
 
+type mergeConversationsReq struct {
+	ConversationUUIDs []string `json:"conversation_uuids"`
+}
+
+type splitConversationReq struct {
+	Subject      string `json:"subject"`
+	IncludeLater bool   `json:"include_later"`
+}
+
 type createConversationRequest struct {
 	InboxID         int    `json:"inbox_id"`
 	AssignedAgentID int    `json:"agent_id"`
@@ -66,7 +76,7 @@ func handleGetAllConversations(r *fastglue.Request) error {
 	)
 	page, pageSize := getPagination(r)
 
-	conversations, err := app.conversation.GetAllConversationsList(user.ID, order, orderBy, filters, page, pageSize)
+	conversations, err := app.conversation.GetAllConversationsList(requestContext(r), user.ID, order, orderBy, filters, page, pageSize)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -95,7 +105,7 @@ func handleGetAssignedConversations(r *fastglue.Request) error {
 		total   = 0
 	)
 	page, pageSize := getPagination(r)
-	conversations, err := app.conversation.GetAssignedConversationsList(user.ID, user.ID, order, orderBy, filters, page, pageSize)
+	conversations, err := app.conversation.GetAssignedConversationsList(requestContext(r), user.ID, user.ID, order, orderBy, filters, page, pageSize)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -124,7 +134,7 @@ func handleGetUnassignedConversations(r *fastglue.Request) error {
 	)
 	page, pageSize := getPagination(r)
 
-	conversations, err := app.conversation.GetUnassignedConversationsList(user.ID, order, orderBy, filters, page, pageSize)
+	conversations, err := app.conversation.GetUnassignedConversationsList(requestContext(r), user.ID, order, orderBy, filters, page, pageSize)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -153,7 +163,7 @@ func handleGetMentionedConversations(r *fastglue.Request) error {
 	)
 	page, pageSize := getPagination(r)
 
-	conversations, err := app.conversation.GetMentionedConversationsList(user.ID, order, orderBy, filters, page, pageSize)
+	conversations, err := app.conversation.GetMentionedConversationsList(requestContext(r), user.ID, order, orderBy, filters, page, pageSize)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -241,7 +251,7 @@ func handleGetViewConversations(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusForbidden, app.i18n.Ts("globals.messages.denied", "name", "{globals.terms.permission}"), nil, envelope.PermissionError)
 	}
 
-	conversations, err := app.conversation.GetViewConversationsList(user.ID, user.ID, user.Teams.IDs(), lists, order, orderBy, string(view.Filters), page, pageSize)
+	conversations, err := app.conversation.GetViewConversationsList(requestContext(r), user.ID, user.ID, user.Teams.IDs(), lists, order, orderBy, string(view.Filters), page, pageSize)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -285,7 +295,7 @@ func handleGetTeamUnassignedConversations(r *fastglue.Request) error {
 		return sendErrorEnvelope(r, envelope.NewError(envelope.PermissionError, app.i18n.T("conversation.notMemberOfTeam"), nil))
 	}
 
-	conversations, err := app.conversation.GetTeamUnassignedConversationsList(auser.ID, teamID, order, orderBy, filters, page, pageSize)
+	conversations, err := app.conversation.GetTeamUnassignedConversationsList(requestContext(r), auser.ID, teamID, order, orderBy, filters, page, pageSize)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -315,7 +325,7 @@ func handleGetConversation(r *fastglue.Request) error {
 		return sendErrorEnvelope(r, err)
 	}
 
-	conv, err := enforceConversationAccess(app, uuid, user)
+	conv, err := enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -336,7 +346,7 @@ func handleUpdateConversationAssigneeLastSeen(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -358,7 +368,7 @@ func handleMarkConversationAsUnread(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -380,7 +390,7 @@ func handleGetConversationParticipants(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -410,7 +420,7 @@ func handleUpdateUserAssignee(r *fastglue.Request) error {
 		return sendErrorEnvelope(r, err)
 	}
 
-	conversation, err := enforceConversationAccess(app, uuid, user)
+	conversation, err := enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -453,7 +463,7 @@ func handleUpdateTeamAssignee(r *fastglue.Request) error {
 		return sendErrorEnvelope(r, err)
 	}
 
-	conversation, err := enforceConversationAccess(app, uuid, user)
+	conversation, err := enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -492,7 +502,7 @@ func handleUpdateConversationPriority(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -539,7 +549,7 @@ func handleUpdateConversationStatus(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	conversation, err := enforceConversationAccess(app, uuid, user)
+	conversation, err := enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -570,6 +580,27 @@ func handleUpdateConversationStatus(r *fastglue.Request) error {
 	return r.SendEnvelope(true)
 }
 
+// handleReleaseConversation releases a conversation quarantined as spam, opening it and running the
+// new conversation automations and webhooks.
+func handleReleaseConversation(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		uuid  = r.RequestCtx.UserValue("uuid").(string)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+	)
+	user, err := app.user.GetAgent(auser.ID, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if _, err := enforceConversationAccess(requestContext(r), app, uuid, user); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if err := app.conversation.ReleaseConversation(uuid, user); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(true)
+}
+
 // handleUpdateConversationtags updates conversation tags.
 func handleUpdateConversationtags(r *fastglue.Request) error {
 	var (
@@ -590,7 +621,7 @@ func handleUpdateConversationtags(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -601,6 +632,74 @@ func handleUpdateConversationtags(r *fastglue.Request) error {
 	return r.SendEnvelope(true)
 }
 
+// handleMergeConversations merges one or more conversations into the conversation in the URL.
+func handleMergeConversations(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+		uuid  = r.RequestCtx.UserValue("uuid").(string)
+		req   = mergeConversationsReq{}
+	)
+
+	if err := r.Decode(&req, "json"); err != nil {
+		app.lo.Error("error decoding merge conversations request", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil, envelope.InputError)
+	}
+
+	if len(req.ConversationUUIDs) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.empty", "name", "`conversation_uuids`"), nil, envelope.InputError)
+	}
+
+	user, err := app.user.GetAgent(auser.ID, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	// User needs access to the primary and all the secondary conversations.
+	for _, cuuid := range append([]string{uuid}, req.ConversationUUIDs...) {
+		if _, err := enforceConversationAccess(requestContext(r), app, cuuid, user); err != nil {
+			return sendErrorEnvelope(r, err)
+		}
+	}
+
+	conversation, err := app.conversation.MergeConversations(uuid, req.ConversationUUIDs, user)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(conversation)
+}
+
+// handleSplitConversation splits a message, and optionally all later messages, out of a conversation into a new conversation.
+func handleSplitConversation(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+		cuuid = r.RequestCtx.UserValue("cuuid").(string)
+		uuid  = r.RequestCtx.UserValue("uuid").(string)
+		req   = splitConversationReq{}
+	)
+
+	if err := r.Decode(&req, "json"); err != nil {
+		app.lo.Error("error decoding split conversation request", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil, envelope.InputError)
+	}
+
+	user, err := app.user.GetAgent(auser.ID, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	_, err = enforceConversationAccess(requestContext(r), app, cuuid, user)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	conversation, err := app.conversation.SplitConversation(cuuid, uuid, req.Subject, req.IncludeLater, user)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(conversation)
+}
+
 // handleUpdateConversationCustomAttributes updates custom attributes of a conversation.
 func handleUpdateConversationCustomAttributes(r *fastglue.Request) error {
 	var (
@@ -619,7 +718,7 @@ func handleUpdateConversationCustomAttributes(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -649,7 +748,7 @@ func handleUpdateContactCustomAttributes(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	conversation, err := enforceConversationAccess(app, uuid, user)
+	conversation, err := enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -662,8 +761,8 @@ func handleUpdateContactCustomAttributes(r *fastglue.Request) error {
 }
 
 // enforceConversationAccess fetches the conversation and checks if the user has access to it.
-func enforceConversationAccess(app *App, uuid string, user umodels.User) (*cmodels.Conversation, error) {
-	conversation, err := app.conversation.GetConversation(0, uuid, "")
+func enforceConversationAccess(ctx context.Context, app *App, uuid string, user umodels.User) (*cmodels.Conversation, error) {
+	conversation, err := app.conversation.GetConversationContext(ctx, 0, uuid, "")
 	if err != nil {
 		return nil, err
 	}
@@ -688,7 +787,7 @@ func handleRemoveUserAssignee(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -709,7 +808,7 @@ func handleRemoveTeamAssignee(r *fastglue.Request) error {
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -795,7 +894,7 @@ func handleCreateConversation(r *fastglue.Request) error {
 	switch req.Initiator {
 	case umodels.UserTypeAgent:
 		// Queue reply.
-		if _, err := app.conversation.QueueReply(media, req.InboxID, auser.ID /**sender_id**/, conversationUUID, req.Content, to, nil /**cc**/, nil /**bcc**/, map[string]any{} /**meta**/); err != nil {
+		if _, err := app.conversation.QueueReply(media, req.InboxID, auser.ID /**sender_id**/, conversationUUID, req.Content, to, nil /**cc**/, nil /**bcc**/, map[string]any{} /**meta**/, null.Time{} /**send_at**/); err != nil {
 			// Delete the conversation if msg queue fails.
 			if err := app.conversation.DeleteConversation(conversationUUID); err != nil {
 				app.lo.Error("error deleting conversation", "error", err)
@@ -825,7 +924,7 @@ func handleCreateConversation(r *fastglue.Request) error {
 	}
 
 	// Trigger webhook event for conversation created.
-	conversation, err := app.conversation.GetConversation(conversationID, "", "")
+	conversation, err := app.conversation.GetConversationContext(requestContext(r), conversationID, "", "")
 	if err == nil {
 		app.webhook.TriggerEvent(wmodels.EventConversationCreated, conversation)
 	}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	amodels "github.com/abhinavxd/libredesk/internal/auth/models"
+	"github.com/abhinavxd/libredesk/internal/conversation"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/export"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	conversationExportKind = "conversations"
+
+	// conversationExportPageSize is the number of conversations fetched at a time by the bulk export job.
+	conversationExportPageSize = 100
+)
+
+// conversationExportContentTypes maps export formats to their content types.
+var conversationExportContentTypes = map[string]string{
+	conversation.ExportFormatJSON: "application/json; charset=utf-8",
+	conversation.ExportFormatEML:  "message/rfc822",
+	conversation.ExportFormatHTML: "text/html; charset=utf-8",
+}
+
+type conversationExportReq struct {
+	Format string `json:"format"`
+	// Filters are conversation filters in the same format as view filters.
+	Filters json.RawMessage `json:"filters"`
+}
+
+// handleExportConversation exports a conversation as a JSON bundle, an .eml thread or a printable HTML transcript,
+// picked with the `format` query param.
+func handleExportConversation(r *fastglue.Request) error {
+	var (
+		app    = r.Context.(*App)
+		uuid   = r.RequestCtx.UserValue("uuid").(string)
+		auser  = r.RequestCtx.UserValue("user").(amodels.User)
+		format = string(r.RequestCtx.QueryArgs().Peek("format"))
+	)
+	if format == "" {
+		format = conversation.ExportFormatJSON
+	}
+	if !slices.Contains(conversation.ExportFormats, format) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`format`"), nil, envelope.InputError)
+	}
+
+	user, err := app.user.GetAgent(auser.ID, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if _, err := enforceConversationAccess(requestContext(r), app, uuid, user); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	exp, err := app.conversation.GetConversationExport(uuid)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	var buf bytes.Buffer
+	if err := app.conversation.WriteConversationExport(&buf, exp, format); err != nil {
+		app.lo.Error("error writing conversation export", "uuid", uuid, "format", format, "error", err)
+		return sendErrorEnvelope(r, envelope.NewError(envelope.GeneralError, app.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.export}"), nil))
+	}
+
+	r.RequestCtx.SetStatusCode(fasthttp.StatusOK)
+	r.RequestCtx.SetContentType(conversationExportContentTypes[format])
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", conversation.ExportFileName(exp.Conversation.ReferenceNumber, format)))
+	r.RequestCtx.SetBody(buf.Bytes())
+	return nil
+}
+
+// handleExportConversations starts a background job that exports all conversations matching the filters
+// into a zip file with one export per conversation.
+func handleExportConversations(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+		req   conversationExportReq
+	)
+	if err := r.Decode(&req, "json"); err != nil {
+		return sendErrorEnvelope(r, envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil))
+	}
+	if req.Format == "" {
+		req.Format = conversation.ExportFormatJSON
+	}
+	if !slices.Contains(conversation.ExportFormats, req.Format) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`format`"), nil, envelope.InputError)
+	}
+	filters := string(req.Filters)
+	if filters == "" || filters == "null" {
+		filters = "[]"
+	}
+
+	// Validate the filters before starting the job.
+	if _, err := app.conversation.GetAllConversationsList(requestContext(r), auser.ID, "", "", filters, 1, 1); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	fileName := fmt.Sprintf("conversations-%s.zip", time.Now().Format("20060102-150405"))
+	job, err := app.export.Submit(conversationExportKind, auser.ID, fileName, func(t *export.Tracker, w io.Writer) error {
+		return processConversationExport(app, t, w, auser.ID, filters, req.Format)
+	})
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(job)
+}
+
+// processConversationExport writes the exports of all conversations matching the filters to a zip archive.
+func processConversationExport(app *App, t *export.Tracker, w io.Writer, userID int, filters, format string) error {
+	zw := zip.NewWriter(w)
+	for page := 1; ; page++ {
+		if err := t.Context().Err(); err != nil {
+			return err
+		}
+
+		conversations, err := app.conversation.GetAllConversationsList(context.Background(), userID, "ASC", "conversations.created_at", filters, page, conversationExportPageSize)
+		if err != nil {
+			return fmt.Errorf("fetching conversations: %w", err)
+		}
+		if len(conversations) == 0 {
+			break
+		}
+		if page == 1 {
+			t.SetTotal(conversations[0].Total)
+		}
+
+		for _, c := range conversations {
+			exp, err := app.conversation.GetConversationExport(c.UUID)
+			if err != nil {
+				t.Error(fmt.Sprintf("Conversation %s: Error - %v", c.UUID, err))
+				continue
+			}
+			f, err := zw.Create(conversation.ExportFileName(exp.Conversation.ReferenceNumber, format))
+			if err != nil {
+				return fmt.Errorf("writing zip archive: %w", err)
+			}
+			if err := app.conversation.WriteConversationExport(f, exp, format); err != nil {
+				t.Error(fmt.Sprintf("Conversation #%s: Error - %v", exp.Conversation.ReferenceNumber, err))
+				continue
+			}
+			t.Success()
+		}
+
+		if len(conversations) < conversationExportPageSize {
+			break
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/conversation"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/inbox/channel/email"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const conversationImportNamespace = "conversations"
+
+// handleImportConversations handles an email archive upload and starts importing it into an email inbox.
+// The archive can be an mbox file, a single .eml file or a .zip of .eml files.
+func handleImportConversations(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+
+	inbox, err := app.inbox.GetDBRecord(id)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if inbox.Channel != email.ChannelEmail {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.T("importer.onlyEmailInboxes"), nil, envelope.InputError)
+	}
+	var inboxAddress string
+	if addr, err := mail.ParseAddress(inbox.From); err == nil {
+		inboxAddress = addr.Address
+	}
+
+	file, err := r.RequestCtx.FormFile("file")
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.required", "name", "{globals.terms.file}"), nil, envelope.InputError)
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".mbox" && ext != ".mbx" && ext != ".eml" && ext != ".zip" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.T("importer.unsupportedArchive"), nil, envelope.InputError)
+	}
+
+	// Uploaded files are removed once the request is done, copy the archive to a temporary file for the import job.
+	src, err := file.Open()
+	if err != nil {
+		app.lo.Error("error opening uploaded file", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, app.i18n.Ts("globals.messages.errorReading", "name", "{globals.terms.file}"), nil, envelope.GeneralError)
+	}
+	defer src.Close()
+	path, err := copyToTempFile(src)
+	if err != nil {
+		app.lo.Error("error copying uploaded archive", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, app.i18n.Ts("globals.messages.errorReading", "name", "{globals.terms.file}"), nil, envelope.GeneralError)
+	}
+
+	err = app.importer.Submit(conversationImportNamespace, func() error {
+		defer os.Remove(path)
+		return processConversationImport(app, path, ext, inbox.ID, inboxAddress)
+	})
+	if err != nil {
+		os.Remove(path)
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, app.i18n.T("importer.importAlreadyInProgress"), nil, envelope.GeneralError)
+	}
+
+	return r.SendEnvelope(true)
+}
+
+// handleGetConversationImportStatus returns current conversation import status.
+func handleGetConversationImportStatus(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	status, err := app.importer.GetStatus(conversationImportNamespace)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(status)
+}
+
+// processConversationImport imports the messages of an email archive into an inbox.
+func processConversationImport(app *App, path, ext string, inboxID int, inboxAddress string) error {
+	const ns = conversationImportNamespace
+
+	var (
+		n        int
+		imported = make(map[int]bool)
+		// readErr stops reading the archive, the conversations imported until then are still finished.
+		readErr error
+	)
+	importRaw := func(name string, raw io.Reader) {
+		n++
+		app.importer.UpdateCounts(ns, n, 0, 0)
+
+		msg, err := email.ParseArchivedMessage(raw, inboxID, inboxAddress)
+		if err != nil {
+			app.importer.UpdateCounts(ns, 0, 0, 1)
+			app.importer.AddLog(ns, fmt.Sprintf("%s: Error - %v", name, err))
+			return
+		}
+		importArchivedMessage(app, name, msg, imported)
+	}
+
+	app.importer.AddLog(ns, "Starting import")
+	switch ext {
+	case ".eml":
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening archive: %w", err)
+		}
+		defer f.Close()
+		importRaw("Message 1", f)
+
+	case ".zip":
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("opening zip archive: %w", err)
+		}
+		defer zr.Close()
+
+		// Read only the dates of the messages first so they can be imported in chronological order, then parse and
+		// import them one at a time so only a single message is held in memory.
+		type zipMessage struct {
+			file *zip.File
+			date time.Time
+		}
+		var msgs []zipMessage
+		for _, zf := range zr.File {
+			if zf.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(zf.Name), ".eml") {
+				continue
+			}
+			date, err := readZipMessageDate(zf)
+			if err != nil {
+				n++
+				app.importer.UpdateCounts(ns, n, 0, 1)
+				app.importer.AddLog(ns, fmt.Sprintf("%s: Error - %v", zf.Name, err))
+				continue
+			}
+			msgs = append(msgs, zipMessage{file: zf, date: date})
+		}
+		sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].date.Before(msgs[j].date) })
+		for _, zm := range msgs {
+			n++
+			app.importer.UpdateCounts(ns, n, 0, 0)
+			msg, err := parseZipMessage(zm.file, inboxID, inboxAddress)
+			if err != nil {
+				app.importer.UpdateCounts(ns, 0, 0, 1)
+				app.importer.AddLog(ns, fmt.Sprintf("%s: Error - %v", zm.file.Name, err))
+				continue
+			}
+			importArchivedMessage(app, zm.file.Name, msg, imported)
+		}
+
+	default:
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening archive: %w", err)
+		}
+		defer f.Close()
+		if err := email.ReadMbox(f, func(raw []byte) error {
+			importRaw(fmt.Sprintf("Message %d", n+1), bytes.NewReader(raw))
+			return nil
+		}); err != nil {
+			readErr = fmt.Errorf("reading mbox after message %d: %w", n, err)
+		}
+	}
+
+	for id, created := range imported {
+		if err := app.conversation.FinishImportedConversation(id, created); err != nil {
+			app.importer.AddLog(ns, fmt.Sprintf("Error finishing conversation %d: %v", id, err))
+		}
+	}
+
+	// Final summary
+	result := "completed"
+	if readErr != nil {
+		result = "stopped"
+	}
+	status, _ := app.importer.GetStatus(ns)
+	app.importer.AddLog(ns, fmt.Sprintf("Import %s: %d of %d messages imported into %d conversations, %d already existed, %d failed",
+		result, status.Success, status.Total, len(imported), status.Skipped, status.Errors))
+	return readErr
+}
+
+// importArchivedMessage imports a parsed archive message and records the conversation it landed in.
+func importArchivedMessage(app *App, name string, msg email.ArchivedMessage, imported map[int]bool) {
+	const ns = conversationImportNamespace
+
+	conversationID, created, err := app.conversation.ImportMessage(msg.IncomingMessage, msg.Date)
+	if err != nil {
+		if errors.Is(err, conversation.ErrMessageExists) {
+			app.importer.AddSkipped(ns, 1)
+			app.importer.AddLog(ns, fmt.Sprintf("%s: Skipped - message %s already exists", name, msg.Message.SourceID.String))
+			return
+		}
+		app.importer.UpdateCounts(ns, 0, 0, 1)
+		app.importer.AddLog(ns, fmt.Sprintf("%s: Error - %v", name, err))
+		return
+	}
+	imported[conversationID] = imported[conversationID] || created
+	app.importer.UpdateCounts(ns, 0, 1, 0)
+}
+
+// readZipMessageDate reads the date of an .eml file in a zip archive from its headers.
+func readZipMessageDate(zf *zip.File) (time.Time, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer rc.Close()
+	return email.ReadArchivedMessageDate(rc), nil
+}
+
+// parseZipMessage parses an .eml file in a zip archive.
+func parseZipMessage(zf *zip.File, inboxID int, inboxAddress string) (email.ArchivedMessage, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return email.ArchivedMessage{}, err
+	}
+	defer rc.Close()
+	return email.ParseArchivedMessage(rc, inboxID, inboxAddress)
+}
+
+// copyToTempFile copies src to a new temporary file and returns its path.
+func copyToTempFile(src io.Reader) (string, error) {
+	dst, err := os.CreateTemp("", "libredesk-import-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// handleGetDeadLetters returns the incoming messages that failed processing and are pending a retry or discard.
+func handleGetDeadLetters(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	msgs, err := app.deadLetter.GetAll()
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(msgs)
+}
+
+// handleRetryDeadLetter processes a dead-lettered message again, removing it from the queue on success.
+func handleRetryDeadLetter(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := app.deadLetter.Retry(id, app.conversation.ProcessIncomingMessage); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(true)
+}
+
+// handleDiscardDeadLetter discards a dead-lettered message.
+func handleDiscardDeadLetter(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if _, err := app.deadLetter.Get(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if err := app.deadLetter.Discard(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(true)
+}
+
+// handleGetIncomingQueueStats returns the depth and age of the queue of incoming messages waiting to be processed.
+func handleGetIncomingQueueStats(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	stats, err := app.conversation.IncomingQueueStats()
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(stats)
+}
@@ -32,7 +32,7 @@ func handleUpsertConversationDraft(r *fastglue.Request) error {
 	}
 
 	// Check access to conversation.
-	conv, err := enforceConversationAccess(app, uuid, user)
+	conv, err := enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
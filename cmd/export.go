@@ -0,0 +1,34 @@
+package main
+
+import (
+	amodels "github.com/abhinavxd/libredesk/internal/auth/models"
+	"github.com/zerodha/fastglue"
+)
+
+// handleGetExport returns the status of an export job of the current user.
+func handleGetExport(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		uuid  = r.RequestCtx.UserValue("uuid").(string)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+	)
+	job, err := app.export.Get(uuid, auser.ID)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(job)
+}
+
+// handleDownloadExport serves the file of a completed export job of the current user.
+func handleDownloadExport(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		uuid  = r.RequestCtx.UserValue("uuid").(string)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+	)
+	media, _, err := app.export.GetFile(uuid, auser.ID)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return serveMediaFile(r, app, media.UUID, &media)
+}
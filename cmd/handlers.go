@@ -36,6 +36,8 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 	g.PUT("/api/v1/settings/general", perm(handleUpdateGeneralSettings, "general_settings:manage"))
 	g.GET("/api/v1/settings/notifications/email", perm(handleGetEmailNotificationSettings, "notification_settings:manage"))
 	g.PUT("/api/v1/settings/notifications/email", perm(handleUpdateEmailNotificationSettings, "notification_settings:manage"))
+	g.GET("/api/v1/settings/spam", perm(handleGetSpamSettings, "general_settings:manage"))
+	g.PUT("/api/v1/settings/spam", perm(handleUpdateSpamSettings, "general_settings:manage"))
 
 	// OpenID connect single sign-on.
 	g.GET("/api/v1/oidc", perm(handleGetAllOIDC, "oidc:manage"))
@@ -49,23 +51,31 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 	g.GET("/api/v1/conversations/unassigned", perm(handleGetUnassignedConversations, "conversations:read_unassigned"))
 	g.GET("/api/v1/conversations/assigned", perm(handleGetAssignedConversations, "conversations:read_assigned"))
 	g.GET("/api/v1/conversations/mentioned", perm(handleGetMentionedConversations, "conversations:read"))
+	g.POST("/api/v1/conversations/export", perm(handleExportConversations, "conversations:read_all"))
 	g.GET("/api/v1/teams/{id}/conversations/unassigned", perm(handleGetTeamUnassignedConversations, "conversations:read_team_inbox"))
 	g.GET("/api/v1/views/{id}/conversations", perm(handleGetViewConversations, "conversations:read"))
 	g.GET("/api/v1/conversations/{uuid}", perm(handleGetConversation, "conversations:read"))
 	g.GET("/api/v1/conversations/{uuid}/participants", perm(handleGetConversationParticipants, "conversations:read"))
+	g.GET("/api/v1/conversations/{uuid}/export", perm(handleExportConversation, "conversations:read"))
 	g.PUT("/api/v1/conversations/{uuid}/assignee/user", perm(handleUpdateUserAssignee, "conversations:update_user_assignee"))
 	g.PUT("/api/v1/conversations/{uuid}/assignee/team", perm(handleUpdateTeamAssignee, "conversations:update_team_assignee"))
 	g.PUT("/api/v1/conversations/{uuid}/assignee/user/remove", perm(handleRemoveUserAssignee, "conversations:update_user_assignee"))
 	g.PUT("/api/v1/conversations/{uuid}/assignee/team/remove", perm(handleRemoveTeamAssignee, "conversations:update_team_assignee"))
 	g.PUT("/api/v1/conversations/{uuid}/priority", perm(handleUpdateConversationPriority, "conversations:update_priority"))
 	g.PUT("/api/v1/conversations/{uuid}/status", perm(handleUpdateConversationStatus, "conversations:update_status"))
+	g.POST("/api/v1/conversations/{uuid}/release", perm(handleReleaseConversation, "conversations:update_status"))
 	g.PUT("/api/v1/conversations/{uuid}/last-seen", perm(handleUpdateConversationAssigneeLastSeen, "conversations:read"))
 	g.PUT("/api/v1/conversations/{uuid}/mark-unread", perm(handleMarkConversationAsUnread, "conversations:read"))
 	g.POST("/api/v1/conversations/{uuid}/tags", perm(handleUpdateConversationtags, "conversations:update_tags"))
+	g.POST("/api/v1/conversations/{uuid}/merge", perm(handleMergeConversations, "conversations:merge"))
 	g.GET("/api/v1/conversations/{cuuid}/messages/{uuid}", perm(handleGetMessage, "messages:read"))
 	g.GET("/api/v1/conversations/{uuid}/messages", perm(handleGetMessages, "messages:read"))
 	g.POST("/api/v1/conversations/{cuuid}/messages", perm(handleSendMessage, "messages:write"))
 	g.PUT("/api/v1/conversations/{cuuid}/messages/{uuid}/retry", perm(handleRetryMessage, "messages:write"))
+	g.PUT("/api/v1/conversations/{cuuid}/messages/{uuid}/cancel", perm(handleCancelScheduledMessage, "messages:write"))
+	g.GET("/api/v1/conversations/{cuuid}/messages/{uuid}/source", perm(handleGetMessageSource, "messages:reprocess"))
+	g.POST("/api/v1/conversations/{cuuid}/messages/{uuid}/reprocess", perm(handleReprocessMessage, "messages:reprocess"))
+	g.POST("/api/v1/conversations/{cuuid}/messages/{uuid}/split", perm(handleSplitConversation, "conversations:write"))
 	g.POST("/api/v1/conversations", perm(handleCreateConversation, "conversations:write"))
 	g.PUT("/api/v1/conversations/{uuid}/custom-attributes", auth(handleUpdateConversationCustomAttributes))
 	g.PUT("/api/v1/conversations/{uuid}/contacts/custom-attributes", auth(handleUpdateContactCustomAttributes))
@@ -74,6 +84,10 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 	g.POST("/api/v1/conversations/{uuid}/draft", auth(handleUpsertConversationDraft))
 	g.DELETE("/api/v1/conversations/{uuid}/draft", auth(handleDeleteConversationDraft))
 
+	// Exports.
+	g.GET("/api/v1/exports/{uuid}", auth(handleGetExport))
+	g.GET("/api/v1/exports/{uuid}/download", auth(handleDownloadExport))
+
 	// Search.
 	g.GET("/api/v1/conversations/search", perm(handleSearchConversations, "conversations:read"))
 	g.GET("/api/v1/messages/search", perm(handleSearchMessages, "messages:read"))
@@ -122,6 +136,9 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 	g.DELETE("/api/v1/agents/me/avatar", auth(handleDeleteCurrentAgentAvatar))
 
 	g.GET("/api/v1/agents/compact", auth(handleGetAgentsCompact))
+	g.GET("/api/v1/agents/online", auth(func(r *fastglue.Request) error {
+		return handleGetOnlineAgents(r, hub)
+	}))
 	g.GET("/api/v1/agents", perm(handleGetAgents, "users:manage"))
 	g.GET("/api/v1/agents/{id}", perm(handleGetAgent, "users:manage"))
 	g.POST("/api/v1/agents", perm(handleCreateAgent, "users:manage"))
@@ -136,9 +153,29 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 
 	// Contacts.
 	g.GET("/api/v1/contacts", perm(handleGetContacts, "contacts:read_all"))
+	g.POST("/api/v1/contacts/import", perm(handleImportContacts, "contacts:write"))
+	g.GET("/api/v1/contacts/import/status", perm(handleGetContactImportStatus, "contacts:write"))
+	g.GET("/api/v1/contacts/import/report", perm(handleGetContactImportReport, "contacts:write"))
 	g.GET("/api/v1/contacts/{id}", perm(handleGetContact, "contacts:read"))
 	g.PUT("/api/v1/contacts/{id}", perm(handleUpdateContact, "contacts:write"))
 	g.PUT("/api/v1/contacts/{id}/block", perm(handleBlockContact, "contacts:block"))
+	g.GET("/api/v1/contacts/{id}/duplicates", perm(handleGetContactDuplicates, "contacts:read"))
+	g.POST("/api/v1/contacts/{id}/merge", perm(handleMergeContacts, "contacts:write"))
+	g.PUT("/api/v1/contacts/{id}/organization", perm(handleSetContactOrganization, "contacts:write"))
+	g.POST("/api/v1/contacts/{id}/export", perm(handleExportContactData, "contacts:export"))
+	g.POST("/api/v1/contacts/{id}/erase", perm(handleEraseContactData, "contacts:erase"))
+
+	// Organizations.
+	g.GET("/api/v1/organizations", perm(handleGetOrganizations, "contacts:read_all"))
+	g.POST("/api/v1/organizations", perm(handleCreateOrganization, "contacts:write"))
+	g.GET("/api/v1/organizations/{id}", perm(handleGetOrganization, "contacts:read"))
+	g.PUT("/api/v1/organizations/{id}", perm(handleUpdateOrganization, "contacts:write"))
+	g.DELETE("/api/v1/organizations/{id}", perm(handleDeleteOrganization, "contacts:write"))
+	g.GET("/api/v1/organizations/{id}/contacts", perm(handleGetOrganizationContacts, "contacts:read"))
+	g.GET("/api/v1/organizations/{id}/conversations", perm(handleGetOrganizationConversations, "conversations:read_all"))
+	g.GET("/api/v1/organizations/{id}/notes", perm(handleGetOrganizationNotes, "contact_notes:read"))
+	g.POST("/api/v1/organizations/{id}/notes", perm(handleCreateOrganizationNote, "contact_notes:write"))
+	g.DELETE("/api/v1/organizations/{id}/notes/{note_id}", perm(handleDeleteOrganizationNote, "contact_notes:delete"))
 
 	// Contact notes.
 	g.GET("/api/v1/contacts/{id}/notes", perm(handleGetContactNotes, "contact_notes:read"))
@@ -170,6 +207,8 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 	g.PUT("/api/v1/inboxes/{id}/toggle", perm(handleToggleInbox, "inboxes:manage"))
 	g.PUT("/api/v1/inboxes/{id}", perm(handleUpdateInbox, "inboxes:manage"))
 	g.DELETE("/api/v1/inboxes/{id}", perm(handleDeleteInbox, "inboxes:manage"))
+	g.POST("/api/v1/inboxes/{id}/import", perm(handleImportConversations, "inboxes:manage"))
+	g.GET("/api/v1/inboxes/import/status", perm(handleGetConversationImportStatus, "inboxes:manage"))
 
 	// OAuth endpoints for email inboxes.
 	g.POST("/api/v1/inboxes/oauth/{provider}/authorize", perm(handleOAuthAuthorize, "inboxes:manage"))
@@ -220,6 +259,28 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 	g.PUT("/api/v1/sla/{id}", perm(handleUpdateSLA, "sla:manage"))
 	g.DELETE("/api/v1/sla/{id}", perm(handleDeleteSLA, "sla:manage"))
 
+	// Data retention.
+	g.GET("/api/v1/retention-policies", perm(handleGetRetentionPolicies, "retention:manage"))
+	g.POST("/api/v1/retention-policies", perm(handleCreateRetentionPolicy, "retention:manage"))
+	g.POST("/api/v1/retention-policies/preview", perm(handlePreviewRetentionPolicy, "retention:manage"))
+	g.GET("/api/v1/retention-policies/logs", perm(handleGetRetentionLogs, "retention:manage"))
+	g.GET("/api/v1/retention-policies/{id}", perm(handleGetRetentionPolicy, "retention:manage"))
+	g.PUT("/api/v1/retention-policies/{id}", perm(handleUpdateRetentionPolicy, "retention:manage"))
+	g.DELETE("/api/v1/retention-policies/{id}", perm(handleDeleteRetentionPolicy, "retention:manage"))
+
+	// Sender block rules.
+	g.GET("/api/v1/block-rules", perm(handleGetBlockRules, "block_rules:manage"))
+	g.POST("/api/v1/block-rules", perm(handleCreateBlockRule, "block_rules:manage"))
+	g.GET("/api/v1/block-rules/{id}", perm(handleGetBlockRule, "block_rules:manage"))
+	g.PUT("/api/v1/block-rules/{id}", perm(handleUpdateBlockRule, "block_rules:manage"))
+	g.DELETE("/api/v1/block-rules/{id}", perm(handleDeleteBlockRule, "block_rules:manage"))
+
+	// Dead-lettered incoming messages.
+	g.GET("/api/v1/dead-letters", perm(handleGetDeadLetters, "dead_letters:manage"))
+	g.GET("/api/v1/dead-letters/incoming-queue", perm(handleGetIncomingQueueStats, "dead_letters:manage"))
+	g.POST("/api/v1/dead-letters/{id}/retry", perm(handleRetryDeadLetter, "dead_letters:manage"))
+	g.DELETE("/api/v1/dead-letters/{id}", perm(handleDiscardDeadLetter, "dead_letters:manage"))
+
 	// AI completions.
 	g.GET("/api/v1/ai/prompts", auth(handleGetAIPrompts))
 	g.POST("/api/v1/ai/completion", auth(handleAICompletion))
@@ -268,8 +329,9 @@ func initHandlers(g *fastglue.Fastglue, hub *ws.Hub) {
 	g.GET("/csat/{uuid}", handleShowCSAT)
 	g.POST("/csat/{uuid}", handleUpdateCSATResponse)
 
-	// Health check.
+	// Health checks, liveness and readiness.
 	g.GET("/health", handleHealthCheck)
+	g.GET("/health/ready", handleReadinessCheck)
 }
 
 // serveIndexPage serves the main index page of the application.
@@ -369,7 +431,7 @@ func sendErrorEnvelope(r *fastglue.Request, err error) error {
 	return r.SendErrorEnvelope(e.Code, e.Error(), e.Data, fastglue.ErrorType(e.ErrorType))
 }
 
-// handleHealthCheck handles the health check endpoint.
+// handleHealthCheck handles the liveness check endpoint, it only tells the process is serving requests.
 func handleHealthCheck(r *fastglue.Request) error {
 	return r.SendEnvelope(true)
 }
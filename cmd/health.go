@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	imodels "github.com/abhinavxd/libredesk/internal/inbox/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthDown     = "down"
+
+	readinessTimeout = 10 * time.Second
+
+	// maxIncomingQueueAge is the age of the oldest queued incoming message above which the incoming queue is
+	// reported degraded.
+	maxIncomingQueueAge = 15 * time.Minute
+	// maxOutgoingQueueUsage is the share of the outgoing queue in use above which it is reported degraded.
+	maxOutgoingQueueUsage = 0.9
+)
+
+// componentHealth is the health of a component checked for readiness.
+type componentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Critical components make the node not ready when down, others only degrade it.
+	Critical bool `json:"critical"`
+	Details  any  `json:"details,omitempty"`
+}
+
+// readinessReport is the health of all components checked for readiness.
+type readinessReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentHealth `json:"components"`
+}
+
+// handleReadinessCheck checks the database, Redis, pending migrations, inbox connections, queues and background
+// jobs. It responds with 503 if a critical component is down, so the node is taken out of rotation, and with 200
+// otherwise, reporting the status of each component. The endpoint is public, so errors and details, which
+// include hostnames and internal state, are only logged.
+func handleReadinessCheck(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	report := readinessReport{
+		Status: healthOK,
+		Components: map[string]componentHealth{
+			"database":       checkDatabase(ctx, app),
+			"redis":          checkRedis(ctx, app),
+			"migrations":     checkMigrations(app),
+			"incoming_queue": checkIncomingQueue(app),
+			"outgoing_queue": checkOutgoingQueue(app),
+			"workers":        checkWorkers(ctx, app),
+		},
+	}
+	for id, conns := range app.inbox.Health(ctx) {
+		report.Components["inbox_"+strconv.Itoa(id)] = checkInbox(conns)
+	}
+
+	for name, c := range report.Components {
+		switch {
+		case c.Status == healthDown && c.Critical:
+			report.Status = healthDown
+		case c.Status != healthOK && report.Status == healthOK:
+			report.Status = healthDegraded
+		}
+		if c.Status != healthOK {
+			app.lo.Warn("readiness check component not healthy", "component", name, "status", c.Status, "error", c.Error, "details", c.Details)
+		}
+		report.Components[name] = componentHealth{Status: c.Status, Critical: c.Critical}
+	}
+	if report.Status == healthDown {
+		return r.SendErrorEnvelope(fasthttp.StatusServiceUnavailable, "not ready", report, envelope.GeneralError)
+	}
+	return r.SendEnvelope(report)
+}
+
+// checkDatabase checks the connectivity to Postgres.
+func checkDatabase(ctx context.Context, app *App) componentHealth {
+	if err := app.db.PingContext(ctx); err != nil {
+		return componentHealth{Status: healthDown, Error: err.Error(), Critical: true}
+	}
+	return componentHealth{Status: healthOK, Critical: true}
+}
+
+// checkRedis checks the connectivity to Redis.
+func checkRedis(ctx context.Context, app *App) componentHealth {
+	if err := app.redis.Ping(ctx).Err(); err != nil {
+		return componentHealth{Status: healthDown, Error: err.Error(), Critical: true}
+	}
+	return componentHealth{Status: healthOK, Critical: true}
+}
+
+// checkMigrations checks that there are no pending database upgrades, e.g. after a new version is deployed
+// before running --upgrade.
+func checkMigrations(app *App) componentHealth {
+	_, toRun, err := getPendingMigrations(app.db)
+	if err != nil {
+		return componentHealth{Status: healthDown, Error: err.Error(), Critical: true}
+	}
+	if len(toRun) > 0 {
+		vers := make([]string, 0, len(toRun))
+		for _, m := range toRun {
+			vers = append(vers, m.version)
+		}
+		return componentHealth{Status: healthDown, Error: "pending database upgrades: " + strings.Join(vers, ", "), Critical: true}
+	}
+	return componentHealth{Status: healthOK, Critical: true}
+}
+
+// checkIncomingQueue checks that incoming messages are not piling up.
+func checkIncomingQueue(app *App) componentHealth {
+	stats, err := app.conversation.IncomingQueueStats()
+	if err != nil {
+		return componentHealth{Status: healthDown, Error: err.Error()}
+	}
+	h := componentHealth{Status: healthOK, Details: stats}
+	if age := time.Duration(stats.OldestAgeSeconds * float64(time.Second)); age > maxIncomingQueueAge {
+		h.Status = healthDegraded
+		h.Error = fmt.Sprintf("oldest message has been waiting for %s", age.Round(time.Second))
+	}
+	return h
+}
+
+// checkOutgoingQueue checks that the outgoing queue of this node is not saturated.
+func checkOutgoingQueue(app *App) componentHealth {
+	var (
+		length   = app.conversation.OutgoingQueueLength()
+		capacity = app.conversation.OutgoingQueueCapacity()
+		h        = componentHealth{Status: healthOK, Details: map[string]int{"length": length, "capacity": capacity}}
+	)
+	if capacity > 0 && float64(length) >= maxOutgoingQueueUsage*float64(capacity) {
+		h.Status = healthDegraded
+		h.Error = "outgoing queue is saturated"
+	}
+	return h
+}
+
+// checkWorkers checks that every singleton background job has a leader renewing its lock on some node.
+func checkWorkers(ctx context.Context, app *App) componentHealth {
+	leaders, err := app.leader.Leaders(ctx)
+	if err != nil {
+		return componentHealth{Status: healthDown, Error: err.Error()}
+	}
+	var (
+		h       = componentHealth{Status: healthOK}
+		details = make(map[string]string, len(leaders))
+		missing []string
+	)
+	for job, ok := range leaders {
+		details[job] = healthOK
+		if !ok {
+			details[job] = "no leader"
+			missing = append(missing, job)
+		}
+	}
+	h.Details = details
+	if len(missing) > 0 {
+		h.Status = healthDegraded
+		h.Error = "jobs without a leader: " + strings.Join(missing, ", ")
+	}
+	return h
+}
+
+// checkInbox checks the IMAP and SMTP connections of an inbox.
+func checkInbox(conns []imodels.ConnectionHealth) componentHealth {
+	h := componentHealth{Status: healthOK, Details: conns}
+	for _, c := range conns {
+		if c.Status == imodels.HealthDown {
+			h.Status = healthDegraded
+			h.Error = fmt.Sprintf("%s connection to %s is down", c.Type, c.Host)
+		}
+	}
+	return h
+}
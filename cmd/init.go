@@ -2,7 +2,6 @@ package main
 
 import (
 	"cmp"
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -19,17 +18,23 @@ import (
 	"github.com/abhinavxd/libredesk/internal/authz"
 	"github.com/abhinavxd/libredesk/internal/autoassigner"
 	"github.com/abhinavxd/libredesk/internal/automation"
+	"github.com/abhinavxd/libredesk/internal/blockrule"
 	businesshours "github.com/abhinavxd/libredesk/internal/business_hours"
+	"github.com/abhinavxd/libredesk/internal/clamav"
 	"github.com/abhinavxd/libredesk/internal/colorlog"
 	"github.com/abhinavxd/libredesk/internal/conversation"
 	"github.com/abhinavxd/libredesk/internal/conversation/priority"
 	"github.com/abhinavxd/libredesk/internal/conversation/status"
+	"github.com/abhinavxd/libredesk/internal/crypto"
 	"github.com/abhinavxd/libredesk/internal/csat"
 	customAttribute "github.com/abhinavxd/libredesk/internal/custom_attribute"
+	"github.com/abhinavxd/libredesk/internal/deadletter"
+	"github.com/abhinavxd/libredesk/internal/export"
 	"github.com/abhinavxd/libredesk/internal/importer"
 	"github.com/abhinavxd/libredesk/internal/inbox"
 	"github.com/abhinavxd/libredesk/internal/inbox/channel/email"
 	imodels "github.com/abhinavxd/libredesk/internal/inbox/models"
+	"github.com/abhinavxd/libredesk/internal/leader"
 	"github.com/abhinavxd/libredesk/internal/macro"
 	"github.com/abhinavxd/libredesk/internal/media"
 	fs "github.com/abhinavxd/libredesk/internal/media/stores/localfs"
@@ -37,11 +42,14 @@ import (
 	notifier "github.com/abhinavxd/libredesk/internal/notification"
 	emailnotifier "github.com/abhinavxd/libredesk/internal/notification/providers/email"
 	"github.com/abhinavxd/libredesk/internal/oidc"
+	"github.com/abhinavxd/libredesk/internal/organization"
 	"github.com/abhinavxd/libredesk/internal/report"
+	"github.com/abhinavxd/libredesk/internal/retention"
 	"github.com/abhinavxd/libredesk/internal/role"
 	"github.com/abhinavxd/libredesk/internal/search"
 	"github.com/abhinavxd/libredesk/internal/setting"
 	"github.com/abhinavxd/libredesk/internal/sla"
+	"github.com/abhinavxd/libredesk/internal/spam"
 	"github.com/abhinavxd/libredesk/internal/tag"
 	"github.com/abhinavxd/libredesk/internal/team"
 	tmpl "github.com/abhinavxd/libredesk/internal/template"
@@ -89,15 +97,64 @@ func initConfig(ko *koanf.Koanf) {
 			log.Fatalf("error loading config from file: %v.", err)
 		}
 	}
-	// Load environment variables with `LIBREDESK_` prefix.
-	ko.Load(env.Provider(".", env.Opt{
-		Prefix: "LIBREDESK_",
+	// Load environment variables with `LIBREDESK_` prefix, overriding the config files.
+	// eg: LIBREDESK_DB__PASSWORD -> db.password
+	if err := ko.Load(env.Provider(".", env.Opt{
+		Prefix: envPrefix,
 		TransformFunc: func(key, val string) (string, any) {
-			// Transform the key.
-			key = strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(key, "LIBREDESK_")), "__", ".")
-			return key, val
+			// Secrets read from files are loaded below.
+			if strings.HasSuffix(key, envFileSuffix) {
+				return "", nil
+			}
+			return envConfigKey(key), val
 		},
-	}), nil)
+	}), nil); err != nil {
+		log.Fatalf("error loading config from environment: %v", err)
+	}
+
+	// Load secrets from the files named by environment variables with the `_FILE` suffix, eg: Docker or
+	// Kubernetes secrets mounted as files.
+	// eg: LIBREDESK_DB__PASSWORD_FILE=/run/secrets/db_password -> db.password
+	secrets, err := readEnvFileSecrets(os.Environ())
+	if err != nil {
+		log.Fatalf("error loading config from environment: %v", err)
+	}
+	if err := ko.Load(confmap.Provider(secrets, "."), nil); err != nil {
+		log.Fatalf("error loading config from environment: %v", err)
+	}
+}
+
+// envConfigKey returns the config key set by an environment variable.
+func envConfigKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(name, envPrefix)), "__", ".")
+}
+
+// readEnvFileSecrets returns the config keys set by `LIBREDESK_*_FILE` environment variables with the contents
+// of the files they name, without the trailing newline. Setting both a variable and its `_FILE` variant is an error.
+func readEnvFileSecrets(environ []string) (map[string]any, error) {
+	names := make(map[string]struct{}, len(environ))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		names[name] = struct{}{}
+	}
+
+	out := make(map[string]any)
+	for _, kv := range environ {
+		name, path, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envPrefix) || !strings.HasSuffix(name, envFileSuffix) {
+			continue
+		}
+		varName := strings.TrimSuffix(name, envFileSuffix)
+		if _, ok := names[varName]; ok {
+			return nil, fmt.Errorf("both %s and %s are set, only one is allowed", varName, name)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		out[envConfigKey(varName)] = strings.TrimRight(string(b), "\r\n")
+	}
+	return out, nil
 }
 
 // validateConfig logs warnings/fatals for invalid config values.
@@ -112,17 +169,36 @@ func validateConfig(ko *koanf.Koanf) {
 	if encKey == sampleEncKey {
 		colorlog.Red("WARNING: You are using the sample encryption_key from config.sample.toml. Change it immediately. Generate a secure key with `openssl rand -hex 16`")
 	}
+
+	for i, key := range ko.Strings("app.previous_encryption_keys") {
+		if len(key) != 32 {
+			log.Fatalf("previous_encryption_keys[%d] must be exactly 32 characters, got %d", i, len(key))
+		}
+	}
+}
+
+// initKeyring inits the keyring secrets are encrypted with. Secrets are encrypted with the encryption key and
+// decrypted with it or any of the previous keys, which are kept until `secrets reencrypt` is run after a rotation.
+func initKeyring() *crypto.Keyring {
+	k, err := crypto.NewKeyring(ko.MustString("app.encryption_key"), ko.Strings("app.previous_encryption_keys")...)
+	if err != nil {
+		log.Fatalf("error initializing encryption keyring: %v", err)
+	}
+	return k
 }
 
-// initFlags initializes the commandline flags.
-func initFlags() {
+// initFlags initializes the commandline flags and returns the positional arguments, i.e. the admin command to run.
+func initFlags() []string {
 	f := flag.NewFlagSet("config", flag.ContinueOnError)
 
 	// Registering `--help` handler.
 	f.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage of %s: [flags] [command]\n", os.Args[0])
 		f.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n%s", cliUsage())
 	}
+	// Flags after the command belong to the command.
+	f.SetInterspersed(false)
 
 	// Register the commandline flags and parse them.
 	f.StringSlice("config", []string{"config.toml"},
@@ -141,6 +217,7 @@ func initFlags() {
 	if err := ko.Load(posflag.Provider(f, ".", ko), nil); err != nil {
 		log.Fatalf("loading config: %v", err)
 	}
+	return f.Args()
 }
 
 // initConstants initializes the app constants.
@@ -210,9 +287,9 @@ func loadSettings(m *setting.Manager) {
 // initSettings inits setting manager.
 func initSettings(db *sqlx.DB) *setting.Manager {
 	s, err := setting.New(setting.Opts{
-		DB:            db,
-		Lo:            initLogger("settings"),
-		EncryptionKey: ko.MustString("app.encryption_key"),
+		DB:      db,
+		Lo:      initLogger("settings"),
+		Keyring: initKeyring(),
 	})
 	if err != nil {
 		log.Fatalf("error initializing setting manager: %v", err)
@@ -250,12 +327,18 @@ func initConversations(
 	template *tmpl.Manager,
 	webhook *webhook.Manager,
 	dispatcher *notifier.Dispatcher,
+	spamFilter *spam.Filter,
+	blockRule *blockrule.Manager,
+	deadLetter *deadletter.Manager,
 ) *conversation.Manager {
-	c, err := conversation.New(hub, i18n, sla, status, priority, inboxStore, userStore, teamStore, mediaStore, settings, csat, automationEngine, template, webhook, dispatcher, conversation.Opts{
-		DB:                       db,
-		Lo:                       initLogger("conversation_manager"),
-		OutgoingMessageQueueSize: ko.MustInt("message.outgoing_queue_size"),
-		IncomingMessageQueueSize: ko.MustInt("message.incoming_queue_size"),
+	c, err := conversation.New(hub, i18n, sla, status, priority, inboxStore, userStore, teamStore, mediaStore, settings, csat, automationEngine, template, webhook, dispatcher, spamFilter, blockRule, deadLetter, conversation.Opts{
+		DB:                        db,
+		Lo:                        initLogger("conversation_manager"),
+		OutgoingMessageQueueSize:  ko.MustInt("message.outgoing_queue_size"),
+		IncomingVisibilityTimeout: ko.Duration("message.incoming_visibility_timeout"),
+		IncomingMaxAttempts:       ko.Int("message.incoming_max_attempts"),
+		UndoSendWindow:            ko.Duration("message.undo_send_window"),
+		OutgoingClaimLease:        ko.Duration("message.outgoing_claim_lease"),
 	})
 	if err != nil {
 		log.Fatalf("error initializing conversation manager: %v", err)
@@ -277,6 +360,84 @@ func initTag(db *sqlx.DB, i18n *i18n.I18n) *tag.Manager {
 	return mgr
 }
 
+// initOrganization inits organization manager.
+func initOrganization(db *sqlx.DB, i18n *i18n.I18n) *organization.Manager {
+	var lo = initLogger("organization_manager")
+	mgr, err := organization.New(organization.Opts{
+		DB:   db,
+		Lo:   lo,
+		I18n: i18n,
+	})
+	if err != nil {
+		log.Fatalf("error initializing organization manager: %v", err)
+	}
+	return mgr
+}
+
+// initRetention inits retention policy manager.
+func initRetention(db *sqlx.DB, i18n *i18n.I18n, mediaStore *media.Manager) *retention.Manager {
+	var lo = initLogger("retention_manager")
+	mgr, err := retention.New(mediaStore, retention.Opts{
+		DB:   db,
+		Lo:   lo,
+		I18n: i18n,
+	})
+	if err != nil {
+		log.Fatalf("error initializing retention manager: %v", err)
+	}
+	return mgr
+}
+
+// initBlockRule inits sender block rule manager.
+func initBlockRule(db *sqlx.DB, i18n *i18n.I18n) *blockrule.Manager {
+	var lo = initLogger("block_rule_manager")
+	mgr, err := blockrule.New(blockrule.Opts{
+		DB:   db,
+		Lo:   lo,
+		I18n: i18n,
+	})
+	if err != nil {
+		log.Fatalf("error initializing block rule manager: %v", err)
+	}
+	return mgr
+}
+
+// initDeadLetter inits the dead-letter queue manager for incoming messages that failed processing.
+func initDeadLetter(db *sqlx.DB, i18n *i18n.I18n, dispatcher *notifier.Dispatcher) *deadletter.Manager {
+	var lo = initLogger("dead_letter_manager")
+	mgr, err := deadletter.New(dispatcher, deadletter.Opts{
+		DB:             db,
+		Lo:             lo,
+		I18n:           i18n,
+		AlertThreshold: ko.Int("message.dead_letter_alert_threshold"),
+	})
+	if err != nil {
+		log.Fatalf("error initializing dead-letter manager: %v", err)
+	}
+	return mgr
+}
+
+// initSpam inits the spam filter with the spam settings.
+func initSpam() *spam.Filter {
+	opts := spamOpts()
+	opts.Lo = initLogger("spam_filter")
+	return spam.New(opts)
+}
+
+// spamOpts returns the spam filter options from the spam settings loaded in koanf.
+func spamOpts() spam.Opts {
+	return spam.Opts{
+		Enabled:        ko.Bool("spam.enabled"),
+		Threshold:      ko.Float64("spam.threshold"),
+		Allowlist:      ko.Strings("spam.allowlist"),
+		Blocklist:      ko.Strings("spam.blocklist"),
+		AuthservIDs:    ko.Strings("spam.authserv_ids"),
+		Scanner:        ko.String("spam.scanner"),
+		ScannerAddress: ko.String("spam.scanner_address"),
+		ScannerTimeout: ko.Duration("spam.scanner_timeout"),
+	}
+}
+
 // initViews inits view manager.
 func initView(db *sqlx.DB, i18n *i18n.I18n) *view.Manager {
 	var lo = initLogger("view_manager")
@@ -347,9 +508,12 @@ func initCSAT(db *sqlx.DB, i18n *i18n.I18n) *csat.Manager {
 	return m
 }
 
-// initWS inits websocket hub.
-func initWS(user *user.Manager) *ws.Hub {
-	return ws.NewHub(user)
+// initWS inits websocket hub, broadcasts are fanned out to the hubs of all replicas through Redis.
+func initWS(user *user.Manager, rd *redis.Client) *ws.Hub {
+	return ws.NewHub(user, ws.Opts{
+		Redis: rd,
+		Lo:    initLogger("websocket_hub"),
+	})
 }
 
 // initTemplates inits template manager.
@@ -507,11 +671,25 @@ func initMedia(db *sqlx.DB, i18n *i18n.I18n, settings *setting.Manager) *media.M
 		log.Fatalf("unknown media store: %s", s)
 	}
 
+	// Scan uploads and attachments for malware with clamd if enabled.
+	var scanner *clamav.Client
+	if ko.Bool("clamav.enabled") {
+		scanner, err = clamav.New(clamav.Opts{
+			Address: ko.String("clamav.address"),
+			Timeout: ko.Duration("clamav.timeout"),
+		})
+		if err != nil {
+			log.Fatalf("error initializing clamav scanner: %v", err)
+		}
+	}
+
 	media, err := media.New(media.Opts{
-		Store: store,
-		Lo:    lo,
-		DB:    db,
-		I18n:  i18n,
+		Store:                 store,
+		Scanner:               scanner,
+		QuarantineOnScanError: ko.Bool("clamav.quarantine_on_error"),
+		Lo:                    lo,
+		DB:                    db,
+		I18n:                  i18n,
 	})
 	if err != nil {
 		log.Fatalf("error initializing media: %v", err)
@@ -522,7 +700,7 @@ func initMedia(db *sqlx.DB, i18n *i18n.I18n, settings *setting.Manager) *media.M
 // initInbox initializes the inbox manager without registering inboxes.
 func initInbox(db *sqlx.DB, i18n *i18n.I18n) *inbox.Manager {
 	var lo = initLogger("inbox-manager")
-	mgr, err := inbox.New(lo, db, i18n, ko.MustString("app.encryption_key"))
+	mgr, err := inbox.New(lo, db, i18n, initKeyring())
 	if err != nil {
 		log.Fatalf("error initializing inbox manager: %v", err)
 	}
@@ -652,24 +830,36 @@ func makeInboxInitializer(mgr *inbox.Manager) func(imodels.Inbox, inbox.MessageS
 	}
 }
 
-// reloadInboxes reloads all inboxes.
+// reloadInboxes reloads all inboxes on this instance and makes the other instances reload them too, so the elected
+// node restarts the receivers with the changed configs.
 func reloadInboxes(app *App) error {
 	app.lo.Info("reloading inboxes")
-	return app.inbox.Reload(ctx, makeInboxInitializer(app.inbox))
+	if err := app.inbox.Reload(makeInboxInitializer(app.inbox)); err != nil {
+		return err
+	}
+	if _, err := publishAdminRequest(ctx, app.redis, adminRequest{Action: adminActionInboxReload, Node: app.leader.NodeID()}); err != nil {
+		app.lo.Error("error publishing inbox reload to other instances", "error", err)
+	}
+	return nil
 }
 
-// startInboxes registers the active inboxes and starts receiver for each.
-func startInboxes(ctx context.Context, mgr *inbox.Manager, msgStore inbox.MessageStore, usrStore inbox.UserStore) {
+// initInboxes registers the active inboxes, receivers are started on the elected node.
+func initInboxes(mgr *inbox.Manager, msgStore inbox.MessageStore, usrStore inbox.UserStore) {
 	mgr.SetMessageStore(msgStore)
 	mgr.SetUserStore(usrStore)
 
 	if err := mgr.InitInboxes(makeInboxInitializer(mgr)); err != nil {
 		log.Fatalf("error initializing inboxes: %v", err)
 	}
+}
 
-	if err := mgr.Start(ctx); err != nil {
-		log.Fatalf("error starting inboxes: %v", err)
-	}
+// initLeader inits the leader elector that runs singleton background jobs on a single node.
+func initLeader(rdb *redis.Client) *leader.Elector {
+	return leader.New(leader.Opts{
+		Redis: rdb,
+		Lo:    initLogger("leader"),
+		TTL:   ko.Duration("app.leader_lock_ttl"),
+	})
 }
 
 // initAuthz initializes authorization enforcer.
@@ -741,10 +931,10 @@ func buildProviders(o *oidc.Manager) ([]auth_.Provider, error) {
 func initOIDC(db *sqlx.DB, settings *setting.Manager, i18n *i18n.I18n) *oidc.Manager {
 	lo := initLogger("oidc")
 	o, err := oidc.New(oidc.Opts{
-		DB:            db,
-		Lo:            lo,
-		I18n:          i18n,
-		EncryptionKey: ko.MustString("app.encryption_key"),
+		DB:      db,
+		Lo:      lo,
+		I18n:    i18n,
+		Keyring: initKeyring(),
 	}, settings)
 	if err != nil {
 		log.Fatalf("error initializing oidc: %v", err)
@@ -800,7 +990,15 @@ func initDB() *sqlx.DB {
 		ko.String("db.params"),
 	)
 
-	db, err := sqlx.Connect("postgres", dsn)
+	var (
+		db  *sqlx.DB
+		err error
+	)
+	if ko.Bool("tracing.enabled") {
+		db, err = connectTracedDB("postgres", dsn)
+	} else {
+		db, err = sqlx.Connect("postgres", dsn)
+	}
 	if err != nil {
 		log.Fatalf("error connecting to DB: %v", err)
 	}
@@ -856,10 +1054,10 @@ func initPriority(db *sqlx.DB, i18n *i18n.I18n) *priority.Manager {
 func initAI(db *sqlx.DB, i18n *i18n.I18n) *ai.Manager {
 	lo := initLogger("ai")
 	m, err := ai.New(ai.Opts{
-		DB:            db,
-		Lo:            lo,
-		I18n:          i18n,
-		EncryptionKey: ko.MustString("app.encryption_key"),
+		DB:      db,
+		Lo:      lo,
+		I18n:    i18n,
+		Keyring: initKeyring(),
 	})
 	if err != nil {
 		log.Fatalf("error initializing AI manager: %v", err)
@@ -927,13 +1125,13 @@ func initReport(db *sqlx.DB, i18n *i18n.I18n) *report.Manager {
 func initWebhook(db *sqlx.DB, i18n *i18n.I18n) *webhook.Manager {
 	var lo = initLogger("webhook")
 	m, err := webhook.New(webhook.Opts{
-		DB:            db,
-		Lo:            lo,
-		I18n:          i18n,
-		Workers:       ko.MustInt("webhook.workers"),
-		QueueSize:     ko.MustInt("webhook.queue_size"),
-		Timeout:       ko.MustDuration("webhook.timeout"),
-		EncryptionKey: ko.MustString("app.encryption_key"),
+		DB:        db,
+		Lo:        lo,
+		I18n:      i18n,
+		Workers:   ko.MustInt("webhook.workers"),
+		QueueSize: ko.MustInt("webhook.queue_size"),
+		Timeout:   ko.MustDuration("webhook.timeout"),
+		Keyring:   initKeyring(),
 	})
 	if err != nil {
 		log.Fatalf("error initializing webhook manager: %v", err)
@@ -963,6 +1161,19 @@ func initImporter(i18n *i18n.I18n) *importer.Importer {
 	})
 }
 
+// initExport inits the export manager.
+func initExport(db *sqlx.DB, i18n *i18n.I18n, media *media.Manager) *export.Manager {
+	mgr, err := export.New(media, export.Opts{
+		DB:   db,
+		Lo:   initLogger("export"),
+		I18n: i18n,
+	})
+	if err != nil {
+		log.Fatalf("error initializing export manager: %v", err)
+	}
+	return mgr
+}
+
 // initNotifDispatcher initializes the notification dispatcher.
 func initNotifDispatcher(userNotification *notifier.UserNotificationManager, outbound *notifier.Service, wsHub *ws.Hub) *notifier.Dispatcher {
 	return notifier.NewDispatcher(notifier.DispatcherOpts{
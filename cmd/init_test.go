@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadEnvFileSecrets(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	password := writeFile("password", "s3cret\n")
+	crlf := writeFile("crlf", "s3cret\r\n")
+	inner := writeFile("inner", "line one\nline two\n\n")
+
+	tests := []struct {
+		name    string
+		environ []string
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name:    "file suffix sets the config key",
+			environ: []string{"LIBREDESK_DB__PASSWORD_FILE=" + password},
+			want:    map[string]any{"db.password": "s3cret"},
+		},
+		{
+			name:    "trailing CRLF is trimmed",
+			environ: []string{"LIBREDESK_APP__ENCRYPTION_KEY_FILE=" + crlf},
+			want:    map[string]any{"app.encryption_key": "s3cret"},
+		},
+		{
+			name:    "only trailing newlines are trimmed",
+			environ: []string{"LIBREDESK_REDIS__PASSWORD_FILE=" + inner},
+			want:    map[string]any{"redis.password": "line one\nline two"},
+		},
+		{
+			name:    "variables without the suffix or prefix are ignored",
+			environ: []string{"LIBREDESK_DB__PASSWORD=plain", "OTHER_FILE=" + password, "LIBREDESK_DB__PASSWORD_FILES=" + password},
+			want:    map[string]any{},
+		},
+		{
+			name:    "variable and its file variant conflict",
+			environ: []string{"LIBREDESK_DB__PASSWORD=plain", "LIBREDESK_DB__PASSWORD_FILE=" + password},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			environ: []string{"LIBREDESK_DB__PASSWORD_FILE=" + filepath.Join(dir, "missing")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readEnvFileSecrets(tt.environ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readEnvFileSecrets() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("readEnvFileSecrets() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("readEnvFileSecrets()[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
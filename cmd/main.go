@@ -18,10 +18,12 @@ import (
 	"github.com/abhinavxd/libredesk/internal/ai"
 	auth_ "github.com/abhinavxd/libredesk/internal/auth"
 	"github.com/abhinavxd/libredesk/internal/authz"
+	"github.com/abhinavxd/libredesk/internal/blockrule"
 	businesshours "github.com/abhinavxd/libredesk/internal/business_hours"
 	"github.com/abhinavxd/libredesk/internal/colorlog"
 	"github.com/abhinavxd/libredesk/internal/csat"
 	customAttribute "github.com/abhinavxd/libredesk/internal/custom_attribute"
+	"github.com/abhinavxd/libredesk/internal/deadletter"
 	"github.com/abhinavxd/libredesk/internal/macro"
 	notifier "github.com/abhinavxd/libredesk/internal/notification"
 	"github.com/abhinavxd/libredesk/internal/report"
@@ -34,17 +36,23 @@ import (
 	"github.com/abhinavxd/libredesk/internal/conversation"
 	"github.com/abhinavxd/libredesk/internal/conversation/priority"
 	"github.com/abhinavxd/libredesk/internal/conversation/status"
+	"github.com/abhinavxd/libredesk/internal/export"
 	"github.com/abhinavxd/libredesk/internal/importer"
 	"github.com/abhinavxd/libredesk/internal/inbox"
+	"github.com/abhinavxd/libredesk/internal/leader"
 	"github.com/abhinavxd/libredesk/internal/media"
 	"github.com/abhinavxd/libredesk/internal/oidc"
+	"github.com/abhinavxd/libredesk/internal/organization"
+	"github.com/abhinavxd/libredesk/internal/retention"
 	"github.com/abhinavxd/libredesk/internal/role"
 	"github.com/abhinavxd/libredesk/internal/setting"
+	"github.com/abhinavxd/libredesk/internal/spam"
 	"github.com/abhinavxd/libredesk/internal/tag"
 	"github.com/abhinavxd/libredesk/internal/team"
 	"github.com/abhinavxd/libredesk/internal/template"
 	"github.com/abhinavxd/libredesk/internal/user"
 	"github.com/abhinavxd/libredesk/internal/webhook"
+	"github.com/jmoiron/sqlx"
 	"github.com/knadh/go-i18n"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/stuffbin"
@@ -66,10 +74,16 @@ var (
 
 const (
 	sampleEncKey = "your-32-char-random-string-here!"
+
+	// envPrefix is the prefix of environment variables overriding config keys, envFileSuffix marks the ones naming
+	// a file to read the value from.
+	envPrefix     = "LIBREDESK_"
+	envFileSuffix = "_FILE"
 )
 
 // App is the global app context which is passed and injected in the http handlers.
 type App struct {
+	db               *sqlx.DB
 	redis            *redis.Client
 	fs               stuffbin.FileSystem
 	consts           atomic.Value
@@ -86,6 +100,11 @@ type App struct {
 	status           *status.Manager
 	priority         *priority.Manager
 	tag              *tag.Manager
+	organization     *organization.Manager
+	retention        *retention.Manager
+	spam             *spam.Filter
+	blockRule        *blockrule.Manager
+	deadLetter       *deadletter.Manager
 	inbox            *inbox.Manager
 	tmpl             *template.Manager
 	macro            *macro.Manager
@@ -104,6 +123,8 @@ type App struct {
 	report           *report.Manager
 	webhook          *webhook.Manager
 	importer         *importer.Importer
+	export           *export.Manager
+	leader           *leader.Elector
 
 	// Global state that stores data on an available app update.
 	update *AppUpdate
@@ -118,7 +139,7 @@ func main() {
 	defer stop()
 
 	// Load command line flags into Koanf.
-	initFlags()
+	args := initFlags()
 
 	// Version flag.
 	if ko.Bool("version") {
@@ -135,6 +156,9 @@ func main() {
 	// Init stuffbin fs.
 	fs := initFS()
 
+	// Init tracing before the DB so queries are traced.
+	shutdownTracing := initTracing(ctx)
+
 	// Init DB.
 	db := initDB()
 
@@ -166,6 +190,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Admin command.
+	if len(args) > 0 {
+		os.Exit(runCommand(ctx, db, fs, args))
+	}
+
 	// Check for pending upgrade.
 	checkPendingUpgrade(db)
 
@@ -190,6 +219,7 @@ func main() {
 		autoAssignInterval          = ko.MustDuration("autoassigner.autoassign_interval")
 		unsnoozeInterval            = ko.MustDuration("conversation.unsnooze_interval")
 		draftRetentionDuration      = cmp.Or(ko.Duration("conversation.draft_retention_duration"), 360*time.Hour)
+		retentionInterval           = cmp.Or(ko.Duration("retention.interval"), time.Hour)
 		automationWorkers           = ko.MustInt("automation.worker_count")
 		messageOutgoingQWorkers     = ko.MustDuration("message.outgoing_queue_workers")
 		messageIncomingQWorkers     = ko.MustDuration("message.incoming_queue_workers")
@@ -211,32 +241,54 @@ func main() {
 		businessHours               = initBusinessHours(db, i18n)
 		webhook                     = initWebhook(db, i18n)
 		user                        = initUser(i18n, db)
-		wsHub                       = initWS(user)
+		wsHub                       = initWS(user, rdb)
 		notifier                    = initNotifier()
 		userNotification            = initUserNotification(db, i18n)
 		notifDispatcher             = initNotifDispatcher(userNotification, notifier, wsHub)
 		automation                  = initAutomationEngine(db, i18n)
 		sla                         = initSLA(db, team, settings, businessHours, template, user, i18n, notifDispatcher)
-		conversation                = initConversations(i18n, sla, status, priority, wsHub, db, inbox, user, team, media, settings, csat, automation, template, webhook, notifDispatcher)
+		spamFilter                  = initSpam()
+		blockRule                   = initBlockRule(db, i18n)
+		deadLetter                  = initDeadLetter(db, i18n, notifDispatcher)
+		conversation                = initConversations(i18n, sla, status, priority, wsHub, db, inbox, user, team, media, settings, csat, automation, template, webhook, notifDispatcher, spamFilter, blockRule, deadLetter)
 		autoassigner                = initAutoAssigner(team, user, conversation)
+		retention                   = initRetention(db, i18n, media)
+		exporter                    = initExport(db, i18n, media)
+		elector                     = initLeader(rdb)
 	)
 	automation.SetConversationStore(conversation)
 
-	startInboxes(ctx, inbox, conversation, user)
+	initInboxes(inbox, conversation, user)
+	go wsHub.Run(ctx)
 	go automation.Run(ctx, automationWorkers)
-	go autoassigner.Run(ctx, autoAssignInterval)
 	go conversation.Run(ctx, messageIncomingQWorkers, messageOutgoingQWorkers, messageOutgoingScanInterval)
-	go conversation.RunUnsnoozer(ctx, unsnoozeInterval)
 	go webhook.Run(ctx)
 	go notifier.Run(ctx)
-	go sla.Run(ctx, slaEvaluationInterval)
-	go sla.SendNotifications(ctx)
-	go media.DeleteUnlinkedMedia(ctx)
-	go user.MonitorAgentAvailability(ctx)
-	go conversation.RunDraftCleaner(ctx, draftRetentionDuration)
-	go userNotification.RunNotificationCleaner(ctx)
+
+	// Singleton jobs run on a single elected node across all instances.
+	go elector.Run(ctx, "inbox_receivers", func(ctx context.Context) {
+		if err := inbox.Start(ctx); err != nil {
+			lo.Error("error starting inboxes", "error", err)
+		}
+		<-ctx.Done()
+	})
+	go elector.Run(ctx, "automation_time_triggers", automation.RunTimeTriggers)
+	go elector.Run(ctx, "autoassigner", func(ctx context.Context) { autoassigner.Run(ctx, autoAssignInterval) })
+	go elector.Run(ctx, "unsnoozer", func(ctx context.Context) { conversation.RunUnsnoozer(ctx, unsnoozeInterval) })
+	go elector.Run(ctx, "sla_evaluator", func(ctx context.Context) {
+		sla.Run(ctx, slaEvaluationInterval)
+		<-ctx.Done()
+	})
+	go elector.Run(ctx, "sla_notifications", func(ctx context.Context) { sla.SendNotifications(ctx) })
+	go elector.Run(ctx, "unlinked_media_cleaner", media.DeleteUnlinkedMedia)
+	go elector.Run(ctx, "agent_availability", user.MonitorAgentAvailability)
+	go elector.Run(ctx, "draft_cleaner", func(ctx context.Context) { conversation.RunDraftCleaner(ctx, draftRetentionDuration) })
+	go elector.Run(ctx, "notification_cleaner", userNotification.RunNotificationCleaner)
+	go elector.Run(ctx, "retention", func(ctx context.Context) { retention.Run(ctx, retentionInterval) })
+	go elector.Run(ctx, "export_cleaner", exporter.Run)
 
 	var app = &App{
+		db:               db,
 		lo:               lo,
 		redis:            rdb,
 		fs:               fs,
@@ -259,6 +311,7 @@ func main() {
 		automation:       automation,
 		businessHours:    businessHours,
 		importer:         initImporter(i18n),
+		export:           exporter,
 		activityLog:      initActivityLog(db, i18n),
 		customAttribute:  initCustomAttribute(db, i18n),
 		authz:            initAuthz(i18n),
@@ -268,14 +321,33 @@ func main() {
 		search:           initSearch(db, i18n),
 		role:             initRole(db, i18n),
 		tag:              initTag(db, i18n),
+		organization:     initOrganization(db, i18n),
+		retention:        retention,
+		spam:             spamFilter,
+		blockRule:        blockRule,
+		deadLetter:       deadLetter,
 		macro:            initMacro(db, i18n),
 		ai:               initAI(db, i18n),
 		webhook:          webhook,
+		leader:           elector,
 	}
 	app.consts.Store(constants)
+	go listenAdminRequests(ctx, rdb, app)
 
 	g := fastglue.NewGlue()
 	g.SetContext(app)
+
+	// Metrics and traces of HTTP requests are recorded by their matched route.
+	g.Router.SaveMatchedRoutePath = true
+	metricsEnabled := ko.Bool("metrics.enabled")
+	if metricsEnabled {
+		token := ko.String("metrics.token")
+		if token == "" {
+			colorlog.Red("WARNING: /metrics is not authenticated, set metrics.token or restrict access to it at the reverse proxy.")
+		}
+		g.GET("/metrics", metricsAuth(token, handleMetrics))
+		initMetrics(db, conversation, wsHub)
+	}
 	initHandlers(g, wsHub)
 
 	s := &fasthttp.Server{
@@ -286,6 +358,13 @@ func main() {
 		MaxKeepaliveDuration: ko.MustDuration("app.server.keepalive_timeout"),
 		ReadBufferSize:       ko.Int("app.server.read_buffer_size"),
 	}
+	s.Handler = g.Handler()
+	if metricsEnabled {
+		s.Handler = metricsMiddleware(s.Handler)
+	}
+	if ko.Bool("tracing.enabled") {
+		s.Handler = tracingMiddleware(s.Handler)
+	}
 
 	go func() {
 		colorlog.Green("Server started at %s", ko.String("app.server.address"))
@@ -322,6 +401,10 @@ func main() {
 	sla.Close()
 	colorlog.Red("Shutting down importer...")
 	app.importer.Close()
+	colorlog.Red("Shutting down export...")
+	app.export.Close()
+	colorlog.Red("Shutting down tracing...")
+	shutdownTracing(lo)
 	colorlog.Red("Shutting down database...")
 	db.Close()
 	colorlog.Red("Shutting down redis...")
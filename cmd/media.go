@@ -89,6 +89,13 @@ func handleMediaUpload(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.T("media.fileTypeNotAllowed"), nil, envelope.InputError)
 	}
 
+	// Scan the file for malware before it is stored.
+	scan, quarantine := app.media.ScanForMalware(file)
+	if quarantine {
+		app.lo.Warn("rejecting uploaded file flagged by malware scanner", "name", srcFileName, "status", scan.Status, "signature", scan.Signature)
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.T("media.malwareDetected"), nil, envelope.InputError)
+	}
+
 	// Delete files on any error.
 	var uuid = uuid.New()
 	thumbName := image.ThumbPrefix + uuid.String()
@@ -99,8 +106,11 @@ func handleMediaUpload(r *fastglue.Request) error {
 		}
 	}()
 
-	// Generate and upload thumbnail and store image dimensions in the media meta.
-	var meta = []byte("{}")
+	// Generate and upload thumbnail and store image dimensions and the malware scan verdict in the media meta.
+	var meta = map[string]interface{}{}
+	if scan != nil {
+		meta["malware_scan"] = scan
+	}
 	if slices.Contains(image.Exts, srcExt) {
 		file.Seek(0, 0)
 		thumbFile, err := image.CreateThumb(image.DefThumbSize, file)
@@ -121,11 +131,10 @@ func handleMediaUpload(r *fastglue.Request) error {
 			app.lo.Error("error getting image dimensions", "error", err)
 			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, app.i18n.Ts("globals.messages.errorUploading", "name", "{globals.terms.media}"), nil, envelope.GeneralError)
 		}
-		meta, _ = json.Marshal(map[string]interface{}{
-			"width":  width,
-			"height": height,
-		})
+		meta["width"] = width
+		meta["height"] = height
 	}
+	metaJSON, _ := json.Marshal(meta)
 
 	// Reset ptr.
 	file.Seek(0, 0)
@@ -139,7 +148,7 @@ func handleMediaUpload(r *fastglue.Request) error {
 	}
 
 	// Insert in DB.
-	media, err := app.media.Insert(disposition, srcFileName, srcContentType, "" /**content_id**/, null.NewString(linkedModel, linkedModel != ""), uuid.String(), null.Int{} /**model_id**/, int(srcFileSize), meta)
+	media, err := app.media.Insert(disposition, srcFileName, srcContentType, "" /**content_id**/, null.NewString(linkedModel, linkedModel != ""), uuid.String(), null.Int{} /**model_id**/, int(srcFileSize), metaJSON)
 	if err != nil {
 		cleanUp = true
 		app.lo.Error("error inserting metadata into database", "error", err)
@@ -182,6 +191,11 @@ func handleServeMedia(r *fastglue.Request) error {
 		return sendErrorEnvelope(r, err)
 	}
 
+	// Export files are only served to their owner through the export download.
+	if media.Model.String == mmodels.ModelExports {
+		allowed = false
+	}
+
 	// For messages, check access to the conversation this message is part of.
 	if media.Model.String == "messages" {
 		conversation, err := app.conversation.GetConversationByMessageID(media.ModelID.Int)
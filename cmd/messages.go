@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"strings"
+	"time"
 
 	amodels "github.com/abhinavxd/libredesk/internal/auth/models"
 	authzModels "github.com/abhinavxd/libredesk/internal/authz/models"
 	cmodels "github.com/abhinavxd/libredesk/internal/conversation/models"
 	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/inbox/channel/email"
 	medModels "github.com/abhinavxd/libredesk/internal/media/models"
 	umodels "github.com/abhinavxd/libredesk/internal/user/models"
 	"github.com/valyala/fasthttp"
+	"github.com/volatiletech/null/v9"
 	"github.com/zerodha/fastglue"
 )
 
@@ -22,6 +27,7 @@ type messageReq struct {
 	BCC         []string               `json:"bcc"`
 	SenderType  string                 `json:"sender_type"`
 	Mentions    []cmodels.MentionInput `json:"mentions"`
+	SendAt      null.Time              `json:"send_at"`
 }
 
 // handleGetMessages returns messages for a conversation.
@@ -53,12 +59,12 @@ func handleGetMessages(r *fastglue.Request) error {
 	}
 
 	// Check permission
-	_, err = enforceConversationAccess(app, uuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, uuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
 
-	messages, pageSize, err := app.conversation.GetConversationMessages(uuid, page, pageSize, private, msgTypes)
+	messages, pageSize, err := app.conversation.GetConversationMessages(requestContext(r), uuid, page, pageSize, private, msgTypes)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -97,12 +103,12 @@ func handleGetMessage(r *fastglue.Request) error {
 	}
 
 	// Check permission
-	_, err = enforceConversationAccess(app, cuuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, cuuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
 
-	message, err := app.conversation.GetMessage(uuid)
+	message, err := app.conversation.GetMessageContext(requestContext(r), uuid)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -133,7 +139,7 @@ func handleRetryMessage(r *fastglue.Request) error {
 	}
 
 	// Check permission
-	_, err = enforceConversationAccess(app, cuuid, user)
+	_, err = enforceConversationAccess(requestContext(r), app, cuuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -144,6 +150,119 @@ func handleRetryMessage(r *fastglue.Request) error {
 	return r.SendEnvelope(true)
 }
 
+// handleCancelScheduledMessage cancels a scheduled or recently queued reply and returns it to the user's draft.
+func handleCancelScheduledMessage(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		uuid  = r.RequestCtx.UserValue("uuid").(string)
+		cuuid = r.RequestCtx.UserValue("cuuid").(string)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+	)
+
+	user, err := app.user.GetAgent(auser.ID, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	// Check permission
+	_, err = enforceConversationAccess(requestContext(r), app, cuuid, user)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	// Make sure the message belongs to the conversation.
+	message, err := app.conversation.GetMessageContext(requestContext(r), uuid)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if message.ConversationUUID != cuuid {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, app.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.message}"), nil, envelope.NotFoundError)
+	}
+
+	// Allow cancelling only own replies, the content is moved to the draft of the agent cancelling it.
+	if message.SenderID != user.ID {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, app.i18n.Ts("globals.messages.canOnlyDeleteOwn", "name", "{globals.terms.message}"), nil, envelope.PermissionError)
+	}
+
+	draft, err := app.conversation.CancelScheduledMessage(uuid, user.ID)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(draft)
+}
+
+// handleGetMessageSource downloads the original raw email of a message as an .eml file. The source includes
+// attachments quarantined by the malware scanner unchanged, so it is gated by the admin only reprocess permission.
+func handleGetMessageSource(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	message, err := getConversationMessage(r)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	raw, err := app.conversation.GetMessageSource(message.ID)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	r.RequestCtx.SetStatusCode(fasthttp.StatusOK)
+	r.RequestCtx.SetContentType("message/rfc822")
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "message-"+message.UUID+".eml"))
+	r.RequestCtx.SetBody(raw)
+	return nil
+}
+
+// handleReprocessMessage parses the original raw email of an incoming message again and replaces the content
+// and attachments of the message with the result.
+func handleReprocessMessage(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	message, err := getConversationMessage(r)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	raw, err := app.conversation.GetMessageSource(message.ID)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	parsed, err := email.ParseArchivedMessage(bytes.NewReader(raw), 0, "")
+	if err != nil {
+		return sendErrorEnvelope(r, envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.message}"), err.Error()))
+	}
+	updated, err := app.conversation.ReprocessMessage(message, parsed.Message)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	for j := range updated.Attachments {
+		att := updated.Attachments[j]
+		updated.Attachments[j].URL = app.media.GetURL(att.UUID, att.ContentType, att.Name)
+	}
+	return r.SendEnvelope(updated)
+}
+
+// getConversationMessage returns the message of the request after checking the user can access its conversation.
+func getConversationMessage(r *fastglue.Request) (cmodels.Message, error) {
+	var (
+		app   = r.Context.(*App)
+		uuid  = r.RequestCtx.UserValue("uuid").(string)
+		cuuid = r.RequestCtx.UserValue("cuuid").(string)
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+	)
+	user, err := app.user.GetAgent(auser.ID, "")
+	if err != nil {
+		return cmodels.Message{}, err
+	}
+	if _, err := enforceConversationAccess(requestContext(r), app, cuuid, user); err != nil {
+		return cmodels.Message{}, err
+	}
+	message, err := app.conversation.GetMessageContext(requestContext(r), uuid)
+	if err != nil {
+		return message, err
+	}
+	if message.ConversationUUID != cuuid {
+		return message, envelope.NewError(envelope.NotFoundError, app.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.message}"), nil)
+	}
+	return message, nil
+}
+
 // handleSendMessage sends a message in a conversation.
 func handleSendMessage(r *fastglue.Request) error {
 	var (
@@ -159,7 +278,7 @@ func handleSendMessage(r *fastglue.Request) error {
 	}
 
 	// Check access to conversation.
-	conv, err := enforceConversationAccess(app, cuuid, user)
+	conv, err := enforceConversationAccess(requestContext(r), app, cuuid, user)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
@@ -201,7 +320,8 @@ func handleSendMessage(r *fastglue.Request) error {
 			app.lo.Error("error fetching media", "error", err)
 			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, app.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.media}"), nil, envelope.GeneralError)
 		}
-		if m.ModelID.Int > 0 {
+		// Attachments of drafts, e.g. of cancelled replies, are sent again with the reply.
+		if m.ModelID.Int > 0 && m.Model.String != medModels.ModelDrafts {
 			// Attachment is already associated with another model. Skip it.
 			app.lo.Warn("attachment already associated with another model, skipping", "media_id", m.ID, "model", m.Model.String, "model_id", m.ModelID.Int)
 			continue
@@ -227,8 +347,18 @@ func handleSendMessage(r *fastglue.Request) error {
 		return r.SendEnvelope(message)
 	}
 
+	// Scheduled replies must be in the future, replies without a schedule are held back for the undo-send window.
+	sendAt := req.SendAt
+	if sendAt.Valid {
+		if !sendAt.Time.After(time.Now()) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`send_at`"), nil, envelope.InputError)
+		}
+	} else {
+		sendAt = app.conversation.UndoSendAt()
+	}
+
 	// Queue reply.
-	message, err := app.conversation.QueueReply(media, conv.InboxID, user.ID, cuuid, req.Message, req.To, req.CC, req.BCC, map[string]any{} /**meta**/)
+	message, err := app.conversation.QueueReply(media, conv.InboxID, user.ID, cuuid, req.Message, req.To, req.CC, req.BCC, map[string]any{} /**meta**/, sendAt)
 	if err != nil {
 		return sendErrorEnvelope(r, err)
 	}
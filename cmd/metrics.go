@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/abhinavxd/libredesk/internal/conversation"
+	"github.com/abhinavxd/libredesk/internal/ws"
+	"github.com/fasthttp/router"
+	"github.com/jmoiron/sqlx"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// initMetrics registers the metrics that are read on every scrape: queue depths, websocket connections and
+// database pool stats. Counters and durations are recorded where they happen.
+func initMetrics(db *sqlx.DB, co *conversation.Manager, hub *ws.Hub) {
+	metrics.RegisterMetricsWriter(func(w io.Writer) {
+		if stats, err := co.IncomingQueueStats(); err == nil {
+			metrics.WriteGaugeUint64(w, "libredesk_incoming_queue_depth", uint64(stats.Depth))
+			metrics.WriteGaugeUint64(w, "libredesk_incoming_queue_retrying", uint64(stats.Retrying))
+			metrics.WriteGaugeFloat64(w, "libredesk_incoming_queue_oldest_age_seconds", stats.OldestAgeSeconds)
+		}
+		metrics.WriteGaugeUint64(w, "libredesk_outgoing_queue_depth", uint64(co.OutgoingQueueLength()))
+		metrics.WriteGaugeUint64(w, "libredesk_websocket_connections", uint64(hub.ConnectionCount()))
+
+		s := db.Stats()
+		metrics.WriteGaugeUint64(w, "libredesk_db_open_connections", uint64(s.OpenConnections))
+		metrics.WriteGaugeUint64(w, "libredesk_db_in_use_connections", uint64(s.InUse))
+		metrics.WriteGaugeUint64(w, "libredesk_db_idle_connections", uint64(s.Idle))
+		metrics.WriteCounterUint64(w, "libredesk_db_wait_count_total", uint64(s.WaitCount))
+		metrics.WriteCounterFloat64(w, "libredesk_db_wait_duration_seconds_total", s.WaitDuration.Seconds())
+	})
+}
+
+// handleMetrics exposes the metrics in the Prometheus text format.
+func handleMetrics(r *fastglue.Request) error {
+	r.RequestCtx.SetContentType("text/plain; version=0.0.4")
+	metrics.WritePrometheus(r.RequestCtx, true)
+	return nil
+}
+
+// metricsAuth requires the bearer token in the Authorization header of scrapes, if a token is set.
+func metricsAuth(token string, next fastglue.FastRequestHandler) fastglue.FastRequestHandler {
+	return func(r *fastglue.Request) error {
+		if token != "" {
+			got, ok := strings.CutPrefix(string(r.RequestCtx.Request.Header.Peek("Authorization")), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				r.RequestCtx.Response.Header.Set("WWW-Authenticate", "Bearer")
+				r.RequestCtx.SetStatusCode(fasthttp.StatusUnauthorized)
+				return nil
+			}
+		}
+		return next(r)
+	}
+}
+
+// metricsMiddleware records the count and duration of HTTP requests by route. Routes are the registered
+// paths, e.g. `/api/v1/conversations/{uuid}`, to keep the number of series bounded.
+func metricsMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+
+		route, _ := ctx.UserValue(router.MatchedRoutePathParam).(string)
+		if route == "" {
+			route = "unmatched"
+		}
+		method := metricsMethod(ctx.Method())
+		metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_http_requests_total{method=%q,route=%q,status="%d"}`, method, route, ctx.Response.StatusCode())).Inc()
+		metrics.GetOrCreateHistogram(fmt.Sprintf(`libredesk_http_request_duration_seconds{method=%q,route=%q}`, method, route)).UpdateDuration(start)
+	}
+}
+
+// metricsMethod returns the HTTP method as a metric label, methods other than the standard ones are reported as
+// `other` as the method is picked by the client.
+func metricsMethod(method []byte) string {
+	switch m := string(method); m {
+	case fasthttp.MethodGet, fasthttp.MethodHead, fasthttp.MethodPost, fasthttp.MethodPut, fasthttp.MethodPatch,
+		fasthttp.MethodDelete, fasthttp.MethodOptions:
+		return m
+	}
+	return "other"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+func TestMetricsMethod(t *testing.T) {
+	for method, want := range map[string]string{
+		"GET":      "GET",
+		"DELETE":   "DELETE",
+		"OPTIONS":  "OPTIONS",
+		"get":      "other",
+		"PROPFIND": "other",
+		"":         "other",
+	} {
+		if got := metricsMethod([]byte(method)); got != want {
+			t.Errorf("metricsMethod(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+func TestMetricsAuth(t *testing.T) {
+	next := func(r *fastglue.Request) error {
+		r.RequestCtx.SetStatusCode(fasthttp.StatusOK)
+		return nil
+	}
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{name: "no token set", want: fasthttp.StatusOK},
+		{name: "valid token", token: "secret", header: "Bearer secret", want: fasthttp.StatusOK},
+		{name: "missing token", token: "secret", want: fasthttp.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer wrong", want: fasthttp.StatusUnauthorized},
+		{name: "not a bearer token", token: "secret", header: "secret", want: fasthttp.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &fastglue.Request{RequestCtx: &fasthttp.RequestCtx{}}
+			if tt.header != "" {
+				r.RequestCtx.Request.Header.Set("Authorization", tt.header)
+			}
+			if err := metricsAuth(tt.token, next)(r); err != nil {
+				t.Fatalf("metricsAuth() error = %v", err)
+			}
+			if got := r.RequestCtx.Response.StatusCode(); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
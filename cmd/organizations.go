@@ -0,0 +1,292 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	amodels "github.com/abhinavxd/libredesk/internal/auth/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	omodels "github.com/abhinavxd/libredesk/internal/organization/models"
+	"github.com/valyala/fasthttp"
+	"github.com/volatiletech/null/v9"
+	"github.com/zerodha/fastglue"
+)
+
+type setContactOrganizationReq struct {
+	OrganizationID null.Int `json:"organization_id"`
+}
+
+// handleGetOrganizations returns a list of organizations from the database.
+func handleGetOrganizations(r *fastglue.Request) error {
+	var (
+		app     = r.Context.(*App)
+		order   = string(r.RequestCtx.QueryArgs().Peek("order"))
+		orderBy = string(r.RequestCtx.QueryArgs().Peek("order_by"))
+		filters = string(r.RequestCtx.QueryArgs().Peek("filters"))
+		total   = 0
+	)
+	page, pageSize := getPagination(r)
+	organizations, err := app.organization.GetAll(page, pageSize, order, orderBy, filters)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if len(organizations) > 0 {
+		total = organizations[0].Total
+	}
+	return r.SendEnvelope(envelope.PageResults{
+		Results:    organizations,
+		Total:      total,
+		PerPage:    pageSize,
+		TotalPages: (total + pageSize - 1) / pageSize,
+		Page:       page,
+	})
+}
+
+// handleGetOrganization returns an organization from the database.
+func handleGetOrganization(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	org, err := app.organization.Get(id)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(org)
+}
+
+// handleCreateOrganization creates a new organization.
+func handleCreateOrganization(r *fastglue.Request) error {
+	var (
+		app = r.Context.(*App)
+		org = omodels.Organization{}
+	)
+	if err := r.Decode(&org, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil, envelope.InputError)
+	}
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.empty", "name", "`name`"), nil, envelope.InputError)
+	}
+	created, err := app.organization.Create(org)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(created)
+}
+
+// handleUpdateOrganization updates an organization.
+func handleUpdateOrganization(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		org   = omodels.Organization{}
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := r.Decode(&org, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil, envelope.InputError)
+	}
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.empty", "name", "`name`"), nil, envelope.InputError)
+	}
+	if _, err := app.organization.Get(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	updated, err := app.organization.Update(id, org)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(updated)
+}
+
+// handleDeleteOrganization deletes an organization.
+func handleDeleteOrganization(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+
+	app.lo.Info("deleting organization", "organization_id", id, "actor_id", auser.ID)
+
+	if err := app.organization.Delete(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(true)
+}
+
+// handleGetOrganizationContacts returns the contacts belonging to an organization.
+func handleGetOrganizationContacts(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		total = 0
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	page, pageSize := getPagination(r)
+	contacts, err := app.organization.GetContacts(id, page, pageSize)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if len(contacts) > 0 {
+		total = contacts[0].Total
+	}
+	return r.SendEnvelope(envelope.PageResults{
+		Results:    contacts,
+		Total:      total,
+		PerPage:    pageSize,
+		TotalPages: (total + pageSize - 1) / pageSize,
+		Page:       page,
+	})
+}
+
+// handleGetOrganizationConversations returns the conversations of contacts belonging to an organization.
+func handleGetOrganizationConversations(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		total = 0
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	page, pageSize := getPagination(r)
+	conversations, err := app.organization.GetConversations(id, page, pageSize)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if len(conversations) > 0 {
+		total = conversations[0].Total
+	}
+	return r.SendEnvelope(envelope.PageResults{
+		Results:    conversations,
+		Total:      total,
+		PerPage:    pageSize,
+		TotalPages: (total + pageSize - 1) / pageSize,
+		Page:       page,
+	})
+}
+
+// handleGetOrganizationNotes returns all notes for an organization.
+func handleGetOrganizationNotes(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	notes, err := app.organization.GetNotes(id)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(notes)
+}
+
+// handleCreateOrganizationNote creates a note for an organization.
+func handleCreateOrganizationNote(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		auser = r.RequestCtx.UserValue("user").(amodels.User)
+		req   = createContactNoteReq{}
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := r.Decode(&req, "json"); err != nil {
+		return sendErrorEnvelope(r, envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil))
+	}
+	if len(req.Note) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.empty", "name", "note"), nil, envelope.InputError)
+	}
+	if _, err := app.organization.Get(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	n, err := app.organization.CreateNote(id, auser.ID, req.Note)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(n)
+}
+
+// handleDeleteOrganizationNote deletes a note for an organization.
+func handleDeleteOrganizationNote(r *fastglue.Request) error {
+	var (
+		app       = r.Context.(*App)
+		id, _     = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		noteID, _ = strconv.Atoi(r.RequestCtx.UserValue("note_id").(string))
+		auser     = r.RequestCtx.UserValue("user").(amodels.User)
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if noteID <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`note_id`"), nil, envelope.InputError)
+	}
+
+	agent, err := app.user.GetAgent(auser.ID, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+
+	// Allow deletion of only own notes and not those created by others, but also allow `Admin` to delete any note.
+	if !agent.HasAdminRole() {
+		note, err := app.organization.GetNote(noteID)
+		if err != nil {
+			return sendErrorEnvelope(r, err)
+		}
+		if note.UserID != auser.ID {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, app.i18n.Ts("globals.messages.canOnlyDeleteOwn", "name", "{globals.terms.note}"), nil, envelope.InputError)
+		}
+	}
+
+	app.lo.Info("deleting organization note", "note_id", noteID, "organization_id", id, "actor_id", auser.ID)
+
+	if err := app.organization.DeleteNote(noteID, id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(true)
+}
+
+// handleSetContactOrganization links a contact to an organization, or unlinks it when `organization_id` is null.
+func handleSetContactOrganization(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		req   = setContactOrganizationReq{}
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := r.Decode(&req, "json"); err != nil {
+		return sendErrorEnvelope(r, envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), nil))
+	}
+	if req.OrganizationID.Valid {
+		if _, err := app.organization.Get(req.OrganizationID.Int); err != nil {
+			return sendErrorEnvelope(r, err)
+		}
+	}
+	if _, err := app.user.GetContact(id, ""); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if err := app.user.SetOrganization(id, req.OrganizationID); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	contact, err := app.user.GetContact(id, "")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(contact)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	rmodels "github.com/abhinavxd/libredesk/internal/retention/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// handleGetRetentionPolicies returns all retention policies.
+func handleGetRetentionPolicies(r *fastglue.Request) error {
+	var app = r.Context.(*App)
+	policies, err := app.retention.GetAll()
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(policies)
+}
+
+// handleGetRetentionPolicy returns a retention policy.
+func handleGetRetentionPolicy(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	policy, err := app.retention.Get(id)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(policy)
+}
+
+// handleCreateRetentionPolicy creates a retention policy.
+func handleCreateRetentionPolicy(r *fastglue.Request) error {
+	var (
+		app    = r.Context.(*App)
+		policy rmodels.Policy
+	)
+	if err := r.Decode(&policy, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), err.Error(), envelope.InputError)
+	}
+	if err := validateRetentionPolicy(app, policy); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	created, err := app.retention.Create(policy)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(created)
+}
+
+// handleUpdateRetentionPolicy updates a retention policy.
+func handleUpdateRetentionPolicy(r *fastglue.Request) error {
+	var (
+		app    = r.Context.(*App)
+		id, _  = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+		policy rmodels.Policy
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := r.Decode(&policy, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), err.Error(), envelope.InputError)
+	}
+	if err := validateRetentionPolicy(app, policy); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if _, err := app.retention.Get(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	updated, err := app.retention.Update(id, policy)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(updated)
+}
+
+// handleDeleteRetentionPolicy deletes a retention policy.
+func handleDeleteRetentionPolicy(r *fastglue.Request) error {
+	var (
+		app   = r.Context.(*App)
+		id, _ = strconv.Atoi(r.RequestCtx.UserValue("id").(string))
+	)
+	if id <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`id`"), nil, envelope.InputError)
+	}
+	if err := app.retention.Delete(id); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(true)
+}
+
+// handlePreviewRetentionPolicy returns the number of items a retention policy would purge if it ran now,
+// so a policy can be checked before it is enabled.
+func handlePreviewRetentionPolicy(r *fastglue.Request) error {
+	var (
+		app    = r.Context.(*App)
+		policy rmodels.Policy
+	)
+	if err := r.Decode(&policy, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.request}"), err.Error(), envelope.InputError)
+	}
+	if err := validateRetentionPolicy(app, policy); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	preview, err := app.retention.Preview(policy)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(preview)
+}
+
+// handleGetRetentionLogs returns the audit log of retention policy runs, optionally filtered by `policy_id`.
+func handleGetRetentionLogs(r *fastglue.Request) error {
+	var (
+		app         = r.Context.(*App)
+		policyID, _ = strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("policy_id")))
+		total       = 0
+	)
+	page, pageSize := getPagination(r)
+	logs, err := app.retention.GetLogs(policyID, page, pageSize)
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if len(logs) > 0 {
+		total = logs[0].Total
+	}
+	return r.SendEnvelope(envelope.PageResults{
+		Results:    logs,
+		Total:      total,
+		PerPage:    pageSize,
+		TotalPages: (total + pageSize - 1) / pageSize,
+		Page:       page,
+	})
+}
+
+// validateRetentionPolicy validates a retention policy.
+func validateRetentionPolicy(app *App, policy rmodels.Policy) error {
+	if policy.Name == "" {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.empty", "name", "`name`"), nil)
+	}
+	if !slices.Contains(rmodels.Actions, policy.Action) {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.invalid", "name", "`action`"), nil)
+	}
+	if policy.Days <= 0 {
+		return envelope.NewError(envelope.InputError, app.i18n.Ts("globals.messages.invalid", "name", "`days`"), nil)
+	}
+	if policy.InboxID.Valid {
+		if _, err := app.inbox.GetDBRecord(int(policy.InboxID.Int)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"encoding/json"
 	"net/mail"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/abhinavxd/libredesk/internal/envelope"
 	"github.com/abhinavxd/libredesk/internal/setting/models"
+	"github.com/abhinavxd/libredesk/internal/spam"
 	"github.com/abhinavxd/libredesk/internal/stringutil"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
@@ -156,3 +159,83 @@ func handleUpdateEmailNotificationSettings(r *fastglue.Request) error {
 
 	return r.SendEnvelope(true)
 }
+
+// handleGetSpamSettings fetches spam filter settings.
+func handleGetSpamSettings(r *fastglue.Request) error {
+	var (
+		app = r.Context.(*App)
+	)
+	out, err := app.setting.GetByPrefix("spam")
+	if err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	return r.SendEnvelope(out)
+}
+
+// handleUpdateSpamSettings updates spam filter settings and reloads the spam filter.
+func handleUpdateSpamSettings(r *fastglue.Request) error {
+	var (
+		app = r.Context.(*App)
+		req = models.Spam{}
+	)
+
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.T("globals.messages.badRequest"), nil, envelope.InputError)
+	}
+
+	req.Scanner = strings.TrimSpace(req.Scanner)
+	req.ScannerAddress = strings.TrimSpace(req.ScannerAddress)
+	req.ScannerTimeout = strings.TrimSpace(req.ScannerTimeout)
+	req.Allowlist = normalizeSenderList(req.Allowlist)
+	req.Blocklist = normalizeSenderList(req.Blocklist)
+	req.AuthservIDs = normalizeSenderList(req.AuthservIDs)
+
+	if req.Threshold <= 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`threshold`"), nil, envelope.InputError)
+	}
+	if !slices.Contains(spam.Scanners, req.Scanner) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`scanner`"), nil, envelope.InputError)
+	}
+	if req.ScannerTimeout != "" {
+		if _, err := time.ParseDuration(req.ScannerTimeout); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, app.i18n.Ts("globals.messages.invalid", "name", "`scanner_timeout`"), nil, envelope.InputError)
+		}
+	}
+
+	if err := app.setting.Update(req); err != nil {
+		return sendErrorEnvelope(r, err)
+	}
+	if err := reloadSpamFilter(app); err != nil {
+		return envelope.NewError(envelope.GeneralError, app.i18n.Ts("globals.messages.couldNotReload", "name", app.i18n.T("globals.terms.setting")), nil)
+	}
+
+	// Incoming messages are processed on all instances, make the others reload the spam filter too.
+	if _, err := publishAdminRequest(ctx, app.redis, adminRequest{Action: adminActionSpamReload, Node: app.leader.NodeID()}); err != nil {
+		app.lo.Error("error publishing spam filter reload to other instances", "error", err)
+	}
+	return r.SendEnvelope(true)
+}
+
+// reloadSpamFilter reloads the settings from the DB and the spam filter from them.
+func reloadSpamFilter(app *App) error {
+	if err := reloadSettings(app); err != nil {
+		return err
+	}
+	app.Lock()
+	opts := spamOpts()
+	app.Unlock()
+	app.spam.Reload(opts)
+	return nil
+}
+
+// normalizeSenderList lowercases and trims sender addresses, domains or hostnames, dropping empty and duplicate entries.
+func normalizeSenderList(entries []string) []string {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e != "" && !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
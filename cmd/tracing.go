@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"log"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/abhinavxd/libredesk/internal/tracing"
+	"github.com/fasthttp/router"
+	"github.com/jmoiron/sqlx"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"github.com/zerodha/logf"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceCtxKey is the request user value under which the context carrying the request span is stored.
+const traceCtxKey = "trace_ctx"
+
+// initTracing inits OpenTelemetry tracing if enabled and returns a function flushing pending spans on shutdown.
+func initTracing(ctx context.Context) func(lo *logf.Logger) {
+	if !ko.Bool("tracing.enabled") {
+		return func(*logf.Logger) {}
+	}
+	shutdown, err := tracing.Init(ctx, tracing.Opts{
+		Endpoint:    ko.MustString("tracing.endpoint"),
+		Insecure:    ko.Bool("tracing.insecure"),
+		Headers:     ko.StringMap("tracing.headers"),
+		SampleRatio: ko.Float64("tracing.sample_ratio"),
+		ServiceName: appName,
+		Version:     versionString,
+	})
+	if err != nil {
+		log.Fatalf("error initializing tracing: %v", err)
+	}
+	return func(lo *logf.Logger) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			lo.Error("error flushing traces", "error", err)
+		}
+	}
+}
+
+// connectTracedDB connects to the DB through a driver that records a span for every query run with a context
+// carrying a span. Queries run without one, e.g. from background jobs, are not traced to keep traces meaningful.
+func connectTracedDB(driverName, dsn string) (*sqlx.DB, error) {
+	sdb, err := otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(attribute.String("db.system.name", "postgresql")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	db := sqlx.NewDb(sdb, driverName)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// tracingMiddleware records a span for every HTTP request, continuing the trace of the caller if the request
+// carries a `traceparent` header. Handlers get the context carrying the span with requestContext.
+func tracingMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		method := string(ctx.Method())
+		parent := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier{&ctx.Request.Header})
+		spanCtx, span := tracing.Tracer().Start(parent, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", method),
+				attribute.String("url.path", string(ctx.Path())),
+			),
+		)
+		defer span.End()
+		ctx.SetUserValue(traceCtxKey, spanCtx)
+
+		next(ctx)
+
+		status := ctx.Response.StatusCode()
+		if route, ok := ctx.UserValue(router.MatchedRoutePathParam).(string); ok {
+			span.SetName(method + " " + route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= fasthttp.StatusInternalServerError {
+			span.SetStatus(codes.Error, fasthttp.StatusMessage(status))
+		}
+	}
+}
+
+// requestContext returns the context carrying the span of the request, to be passed to traced calls.
+func requestContext(r *fastglue.Request) context.Context {
+	if ctx, ok := r.RequestCtx.UserValue(traceCtxKey).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// headerCarrier adapts fasthttp request headers to an OpenTelemetry propagation carrier.
+type headerCarrier struct {
+	h *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.h.Peek(key))
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.h.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+	c.h.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// stubDriver is a database/sql driver answering every query with a single row holding 1.
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) { return stubConn{}, nil }
+
+type stubConn struct{}
+
+func (stubConn) Prepare(string) (driver.Stmt, error) { return stubStmt{}, nil }
+func (stubConn) Close() error                        { return nil }
+func (stubConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type stubStmt struct{}
+
+func (stubStmt) Close() error                               { return nil }
+func (stubStmt) NumInput() int                              { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(0), nil }
+func (stubStmt) Query([]driver.Value) (driver.Rows, error)  { return &stubRows{}, nil }
+
+type stubRows struct{ done bool }
+
+func (*stubRows) Columns() []string { return []string{"n"} }
+func (*stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func init() {
+	sql.Register("stub", stubDriver{})
+}
+
+func TestHandlerQueryIsChildOfRequestSpan(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	db, err := connectTracedDB("stub", "")
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	defer db.Close()
+	stmt, err := db.Preparex("SELECT 1")
+	if err != nil {
+		t.Fatalf("preparing: %v", err)
+	}
+	defer stmt.Close()
+
+	// Queries without a request span are not traced.
+	var n int
+	if err := stmt.Get(&n); err != nil {
+		t.Fatalf("querying: %v", err)
+	}
+	if got := len(rec.Ended()); got != 0 {
+		t.Fatalf("expected no spans for an untraced query, got %d", got)
+	}
+
+	handler := tracingMiddleware(func(ctx *fasthttp.RequestCtx) {
+		r := &fastglue.Request{RequestCtx: ctx}
+		if err := stmt.GetContext(requestContext(r), &n); err != nil {
+			t.Errorf("querying: %v", err)
+		}
+	})
+	var ctx fasthttp.RequestCtx
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.SetRequestURI("/api/v1/conversations")
+	handler(&ctx)
+
+	var reqSpan, querySpan sdktrace.ReadOnlySpan
+	for _, s := range rec.Ended() {
+		switch s.SpanKind() {
+		case trace.SpanKindServer:
+			reqSpan = s
+		case trace.SpanKindClient:
+			querySpan = s
+		}
+	}
+	if reqSpan == nil || querySpan == nil {
+		t.Fatalf("expected a request and a query span, got %d spans", len(rec.Ended()))
+	}
+	if querySpan.Parent().SpanID() != reqSpan.SpanContext().SpanID() {
+		t.Errorf("query span parent = %s, want request span %s", querySpan.Parent().SpanID(), reqSpan.SpanContext().SpanID())
+	}
+	if querySpan.SpanContext().TraceID() != reqSpan.SpanContext().TraceID() {
+		t.Error("query span is not part of the request trace")
+	}
+}
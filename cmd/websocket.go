@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	amodels "github.com/abhinavxd/libredesk/internal/auth/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
 	"github.com/abhinavxd/libredesk/internal/ws"
 	wsmodels "github.com/abhinavxd/libredesk/internal/ws/models"
 	"github.com/fasthttp/websocket"
@@ -26,6 +27,17 @@ var upgrader = websocket.FastHTTPUpgrader{
 	Error: ErrHandler,
 }
 
+// handleGetOnlineAgents returns the IDs of the agents connected over websocket to any replica.
+func handleGetOnlineAgents(r *fastglue.Request, hub *ws.Hub) error {
+	var app = r.Context.(*App)
+	ids, err := hub.OnlineUsers(r.RequestCtx)
+	if err != nil {
+		app.lo.Error("error fetching online agents", "error", err)
+		return sendErrorEnvelope(r, envelope.NewError(envelope.GeneralError, app.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.agent}"), nil))
+	}
+	return r.SendEnvelope(ids)
+}
+
 // handleWS handles the websocket connection.
 func handleWS(r *fastglue.Request, hub *ws.Hub) error {
 	var (
@@ -61,6 +61,9 @@ const searchContacts = (params) => http.get('/api/v1/contacts/search', { params
 const getEmailNotificationSettings = () => http.get('/api/v1/settings/notifications/email')
 const updateEmailNotificationSettings = (data) =>
   http.put('/api/v1/settings/notifications/email', data)
+const getSpamSettings = () => http.get('/api/v1/settings/spam')
+const updateSpamSettings = (data) => http.put('/api/v1/settings/spam', data)
+const releaseConversation = (uuid) => http.post(`/api/v1/conversations/${uuid}/release`)
 const getPriorities = () => http.get('/api/v1/priorities')
 const getStatuses = () => http.get('/api/v1/statuses')
 const createStatus = (data) => http.post('/api/v1/statuses', data)
@@ -314,6 +317,8 @@ const getConversationMessage = (cuuid, uuid) =>
   http.get(`/api/v1/conversations/${cuuid}/messages/${uuid}`)
 const retryMessage = (cuuid, uuid) =>
   http.put(`/api/v1/conversations/${cuuid}/messages/${uuid}/retry`)
+const cancelScheduledMessage = (cuuid, uuid) =>
+  http.put(`/api/v1/conversations/${cuuid}/messages/${uuid}/cancel`)
 const getConversationMessages = (uuid, params) =>
   http.get(`/api/v1/conversations/${uuid}/messages`, { params })
 const sendMessage = (uuid, data) =>
@@ -566,6 +571,7 @@ export default {
   createConversation,
   sendMessage,
   retryMessage,
+  cancelScheduledMessage,
   createUser,
   createInbox,
   updateInbox,
@@ -599,6 +605,9 @@ export default {
   getUsersCompact,
   getEmailNotificationSettings,
   updateEmailNotificationSettings,
+  getSpamSettings,
+  updateSpamSettings,
+  releaseConversation,
   saveDraft,
   getAllDrafts,
   deleteDraft,
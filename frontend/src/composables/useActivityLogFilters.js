@@ -40,6 +40,12 @@ export function useActivityLogFilters () {
             }, {
                 label: t('activityLog.type.agentRolePermissionsChanged'),
                 value: 'agent_role_permissions_changed'
+            }, {
+                label: t('activityLog.type.contactDataExported'),
+                value: 'contact_data_exported'
+            }, {
+                label: t('activityLog.type.contactDataErased'),
+                value: 'contact_data_erased'
             }]
         },
     }))
@@ -212,6 +212,18 @@ export function useDraftManager (key, uploadedFiles = null) {
   }
 
 
+  /**
+   * Restore a draft saved on the backend, e.g. of a cancelled reply, and load it if it's the current draft
+   */
+  const restoreDraft = async (draftKey, draft) => {
+    if (!draftKey) return
+    removeLocalDraft(draftKey)
+    conversationStore.setDraft(draftKey, draft)
+    if (key.value === draftKey) {
+      await loadDraft(draftKey)
+    }
+  }
+
   // Watch for key changes - sync to backend before switching
   watch(
     key,
@@ -278,6 +290,7 @@ export function useDraftManager (key, uploadedFiles = null) {
     textContent,
     isLoading,
     clearDraft,
+    restoreDraft,
     loadedAttachments,
     loadedMacroActions
   }
@@ -12,6 +12,7 @@ export const CONVERSATION_DEFAULT_STATUSES = {
   SNOOZED: 'Snoozed',
   RESOLVED: 'Resolved',
   CLOSED: 'Closed',
+  QUARANTINED: 'Quarantined',
 }
 
 export const CONVERSATION_DEFAULT_STATUSES_LIST = Object.values(CONVERSATION_DEFAULT_STATUSES);
@@ -56,6 +56,11 @@ export const adminNavItems = [
         href: '/admin/conversations/statuses',
         permission: 'status:manage',
         isTitleKeyPlural: true
+      },
+      {
+        titleKey: 'globals.terms.spam',
+        href: '/admin/conversations/spam',
+        permission: 'general_settings:manage'
       }
     ]
   },
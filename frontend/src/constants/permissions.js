@@ -11,9 +11,11 @@ export const permissions = {
   CONVERSATIONS_UPDATE_PRIORITY: 'conversations:update_priority',
   CONVERSATIONS_UPDATE_STATUS: 'conversations:update_status',
   CONVERSATIONS_UPDATE_TAGS: 'conversations:update_tags',
+  CONVERSATIONS_MERGE: 'conversations:merge',
   MESSAGES_READ: 'messages:read',
   MESSAGES_WRITE: 'messages:write',
   MESSAGES_WRITE_AS_CONTACT: 'messages:write_as_contact',
+  MESSAGES_REPROCESS: 'messages:reprocess',
   VIEW_MANAGE: 'view:manage',
   SHARED_VIEWS_MANAGE: 'shared_views:manage',
   GENERAL_SETTINGS_MANAGE: 'general_settings:manage',
@@ -31,12 +33,17 @@ export const permissions = {
   REPORTS_MANAGE: 'reports:manage',
   BUSINESS_HOURS_MANAGE: 'business_hours:manage',
   SLA_MANAGE: 'sla:manage',
+  RETENTION_MANAGE: 'retention:manage',
+  BLOCK_RULES_MANAGE: 'block_rules:manage',
+  DEAD_LETTERS_MANAGE: 'dead_letters:manage',
   AI_MANAGE: 'ai:manage',
   CUSTOM_ATTRIBUTES_MANAGE: 'custom_attributes:manage',
   CONTACTS_READ_ALL: 'contacts:read_all',
   CONTACTS_READ: 'contacts:read',
   CONTACTS_WRITE: 'contacts:write',
   CONTACTS_BLOCK: 'contacts:block',
+  CONTACTS_EXPORT: 'contacts:export',
+  CONTACTS_ERASE: 'contacts:erase',
   CONTACT_NOTES_READ: 'contact_notes:read',
   CONTACT_NOTES_WRITE: 'contact_notes:write',
   CONTACT_NOTES_DELETE: 'contact_notes:delete',
@@ -1,6 +1,7 @@
 export const WS_EVENT = {
     NEW_MESSAGE: 'new_message',
     MESSAGE_PROP_UPDATE: 'message_prop_update',
+    MESSAGE_DELETED: 'message_deleted',
     CONVERSATION_PROP_UPDATE: 'conversation_prop_update',
     NEW_NOTIFICATION: 'new_notification',
 }
\ No newline at end of file
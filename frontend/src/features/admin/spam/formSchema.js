@@ -0,0 +1,24 @@
+import * as z from 'zod';
+import { isGoDuration } from '@/utils/strings';
+
+export const createFormSchema = (t) => z.object({
+    enabled: z.boolean().default(false),
+    threshold: z
+        .number({
+            invalid_type_error: t('globals.messages.mustBeNumber'),
+            required_error: t('globals.messages.required')
+        })
+        .positive({ message: t('globals.messages.invalidValue', { name: t('admin.spam.threshold') }) })
+        .default(5),
+    allowlist: z.string().optional(),
+    blocklist: z.string().optional(),
+    authserv_ids: z.string().optional(),
+    scanner: z.enum(['none', 'spamassassin', 'rspamd']).default('none'),
+    scanner_address: z.string().optional(),
+    scanner_timeout: z
+        .string()
+        .refine(isGoDuration, {
+            message: t('globals.messages.goDuration')
+        })
+        .default('10s'),
+});
@@ -467,6 +467,11 @@ const routes = [
                 component: () => import('@/views/admin/status/StatusView.vue'),
                 meta: { title: 'Statuses' }
               },
+              {
+                path: 'spam',
+                component: () => import('@/features/admin/spam/SpamSetting.vue'),
+                meta: { title: 'Spam Settings' }
+              },
               {
                 path: 'macros',
                 component: () => import('@/views/admin/macros/Macros.vue'),
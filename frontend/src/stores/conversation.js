@@ -640,6 +640,18 @@ export const useConversationStore = defineStore('conversation', () => {
     }
   }
 
+  /**
+   * Remove a deleted message from the cache, e.g. a cancelled scheduled reply.
+   * 
+   * @param {Object} message - { conversation_uuid, uuid }
+   */
+  function removeMessage (message) {
+    if (messages.data.hasMessage(message.conversation_uuid, message.uuid)) {
+      messages.data.removeMessage(message.conversation_uuid, message.uuid)
+      incrementMessageVersion()
+    }
+  }
+
   /**
    * Update a conversation property, supports nested paths via dot notation
    * @param {Object} update - { uuid, prop, value }
@@ -778,6 +790,7 @@ export const useConversationStore = defineStore('conversation', () => {
     fetchNextMessages,
     fetchNextConversations,
     updateMessageProp,
+    removeMessage,
     updateAssigneeLastSeen,
     markAsUnread,
     updateConversationMessage,
@@ -63,6 +63,7 @@ export class WebSocketClient {
           this.convStore.updateConversationMessage(data.data)
         },
         [WS_EVENT.MESSAGE_PROP_UPDATE]: () => this.convStore.updateMessageProp(data.data),
+        [WS_EVENT.MESSAGE_DELETED]: () => this.convStore.removeMessage(data.data),
         [WS_EVENT.CONVERSATION_PROP_UPDATE]: () => this.convStore.updateConversationProp(data.data),
         [WS_EVENT.NEW_NOTIFICATION]: () => this.notificationStore.addNotification(data.data)
       }
@@ -220,6 +220,31 @@ func (al *Manager) RolePermissionsChanged(actorID int, actorEmail, ip string, ro
 	)
 }
 
+// ContactDataExported records an export of a contact's personal data.
+func (al *Manager) ContactDataExported(actorID int, actorEmail, ip string, contactID int, contactEmail string) error {
+	return al.create(
+		models.ContactDataExported,
+		fmt.Sprintf("%s (#%d) exported personal data of contact %s (#%d)", actorEmail, actorID, contactEmail, contactID),
+		actorID,
+		umodels.UserModel,
+		contactID,
+		ip,
+	)
+}
+
+// ContactDataErased records an erasure of a contact's personal data.
+// The contact's email is intentionally not recorded as it is part of the erased data.
+func (al *Manager) ContactDataErased(actorID int, actorEmail, ip string, contactID int) error {
+	return al.create(
+		models.ContactDataErased,
+		fmt.Sprintf("%s (#%d) erased personal data of contact #%d", actorEmail, actorID, contactID),
+		actorID,
+		umodels.UserModel,
+		contactID,
+		ip,
+	)
+}
+
 // create creates a new activity log in DB.
 func (m *Manager) create(activityType, activityDescription string, actorID int, targetModelType string, targetModelID int, ip string) error {
 	if _, err := m.q.InsertActivity.Exec(activityType, activityDescription, actorID, targetModelType, targetModelID, ip); err != nil {
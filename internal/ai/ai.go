@@ -2,18 +2,22 @@
 package ai
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/abhinavxd/libredesk/internal/ai/models"
 	"github.com/abhinavxd/libredesk/internal/crypto"
 	"github.com/abhinavxd/libredesk/internal/dbutil"
 	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/tracing"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/go-i18n"
 	"github.com/zerodha/logf"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -25,18 +29,18 @@ var (
 )
 
 type Manager struct {
-	q             queries
-	lo            *logf.Logger
-	i18n          *i18n.I18n
-	encryptionKey string
+	q       queries
+	lo      *logf.Logger
+	i18n    *i18n.I18n
+	keyring *crypto.Keyring
 }
 
 // Opts contains options for initializing the Manager.
 type Opts struct {
-	DB            *sqlx.DB
-	I18n          *i18n.I18n
-	Lo            *logf.Logger
-	EncryptionKey string
+	DB      *sqlx.DB
+	I18n    *i18n.I18n
+	Lo      *logf.Logger
+	Keyring *crypto.Keyring
 }
 
 // queries contains prepared SQL queries.
@@ -45,6 +49,8 @@ type queries struct {
 	GetPrompt          *sqlx.Stmt `query:"get-prompt"`
 	GetPrompts         *sqlx.Stmt `query:"get-prompts"`
 	SetOpenAIKey       *sqlx.Stmt `query:"set-openai-key"`
+	GetProviders       *sqlx.Stmt `query:"get-providers"`
+	SetProviderAPIKey  *sqlx.Stmt `query:"set-provider-api-key"`
 }
 
 // New creates and returns a new instance of the Manager.
@@ -54,16 +60,19 @@ func New(opts Opts) (*Manager, error) {
 		return nil, err
 	}
 	return &Manager{
-		q:             q,
-		lo:            opts.Lo,
-		i18n:          opts.I18n,
-		encryptionKey: opts.EncryptionKey,
+		q:       q,
+		lo:      opts.Lo,
+		i18n:    opts.I18n,
+		keyring: opts.Keyring,
 	}, nil
 }
 
 // Completion sends a prompt to the default provider and returns the response.
-func (m *Manager) Completion(k string, prompt string) (string, error) {
-	systemPrompt, err := m.getPrompt(k)
+func (m *Manager) Completion(ctx context.Context, k string, prompt string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "ai.completion", attribute.String("ai.prompt_key", k))
+	defer func() { tracing.End(span, err) }()
+
+	systemPrompt, err := m.getPrompt(ctx, k)
 	if err != nil {
 		return "", err
 	}
@@ -79,7 +88,7 @@ func (m *Manager) Completion(k string, prompt string) (string, error) {
 		UserPrompt:   prompt,
 	}
 
-	response, err := client.SendPrompt(payload)
+	response, err := client.SendPrompt(ctx, payload)
 	if err != nil {
 		if errors.Is(err, ErrInvalidAPIKey) {
 			m.lo.Error("error invalid API key", "error", err)
@@ -120,7 +129,7 @@ func (m *Manager) UpdateProvider(provider, apiKey string) error {
 // setOpenAIAPIKey sets the OpenAI API key in the database.
 func (m *Manager) setOpenAIAPIKey(apiKey string) error {
 	// Encrypt API key before storing.
-	encryptedKey, err := crypto.Encrypt(apiKey, m.encryptionKey)
+	encryptedKey, err := m.keyring.Encrypt(apiKey)
 	if err != nil {
 		m.lo.Error("error encrypting API key", "error", err)
 		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "OpenAI API Key"), nil)
@@ -133,10 +142,43 @@ func (m *Manager) setOpenAIAPIKey(apiKey string) error {
 	return nil
 }
 
+// ReEncrypt re-encrypts the API keys of all providers that are not encrypted with the primary key and returns the
+// number of providers updated.
+func (m *Manager) ReEncrypt() (int, error) {
+	var providers []models.Provider
+	if err := m.q.GetProviders.Select(&providers); err != nil {
+		m.lo.Error("error fetching providers", "error", err)
+		return 0, fmt.Errorf("fetching providers: %w", err)
+	}
+
+	var n int
+	for _, p := range providers {
+		var config struct {
+			APIKey string `json:"api_key"`
+		}
+		if err := json.Unmarshal([]byte(p.Config), &config); err != nil {
+			return n, fmt.Errorf("parsing config of provider %s: %w", p.Name, err)
+		}
+		apiKey, changed, err := m.keyring.ReEncrypt(config.APIKey)
+		if err != nil {
+			return n, fmt.Errorf("re-encrypting API key of provider %s: %w", p.Name, err)
+		}
+		if !changed {
+			continue
+		}
+		if _, err := m.q.SetProviderAPIKey.Exec(p.ID, apiKey); err != nil {
+			m.lo.Error("error updating provider API key", "provider", p.Name, "error", err)
+			return n, fmt.Errorf("updating API key of provider %s: %w", p.Name, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
 // getPrompt returns a prompt from the database.
-func (m *Manager) getPrompt(k string) (string, error) {
+func (m *Manager) getPrompt(ctx context.Context, k string) (string, error) {
 	var p models.Prompt
-	if err := m.q.GetPrompt.Get(&p, k); err != nil {
+	if err := m.q.GetPrompt.GetContext(ctx, &p, k); err != nil {
 		if err == sql.ErrNoRows {
 			m.lo.Error("error prompt not found", "key", k)
 			return "", envelope.NewError(envelope.InputError, m.i18n.Ts("globals.messages.notFound", "name", m.i18n.Ts("globals.terms.template")), nil)
@@ -166,7 +208,7 @@ func (m *Manager) getDefaultProviderClient() (ProviderClient, error) {
 			return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorParsing", "name", m.i18n.Ts("globals.terms.provider")), nil)
 		}
 		// Decrypt API key.
-		decryptedKey, err := crypto.Decrypt(config.APIKey, m.encryptionKey)
+		decryptedKey, err := m.keyring.Decrypt(config.APIKey)
 		if err != nil {
 			m.lo.Error("error decrypting API key", "error", err)
 			return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.Ts("globals.terms.provider")), nil)
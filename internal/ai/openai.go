@@ -2,6 +2,7 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -27,7 +28,7 @@ func NewOpenAIClient(apiKey string, lo *logf.Logger) *OpenAIClient {
 }
 
 // SendPrompt sends a prompt to the OpenAI API and returns the response text.
-func (o *OpenAIClient) SendPrompt(payload PromptPayload) (string, error) {
+func (o *OpenAIClient) SendPrompt(ctx context.Context, payload PromptPayload) (string, error) {
 	if o.apikey == "" {
 		return "", ErrApiKeyNotSet
 	}
@@ -49,7 +50,7 @@ func (o *OpenAIClient) SendPrompt(payload PromptPayload) (string, error) {
 		return "", fmt.Errorf("marshalling request body: %w", err)
 	}
 
-	req, err := http.NewRequest(fasthttp.MethodPost, apiURL, bytes.NewBuffer(bodyBytes))
+	req, err := http.NewRequestWithContext(ctx, fasthttp.MethodPost, apiURL, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		o.lo.Error("error creating request", "error", err)
 		return "", fmt.Errorf("error creating request: %w", err)
@@ -1,8 +1,10 @@
 package ai
 
+import "context"
+
 // ProviderClient is the interface all providers should implement.
 type ProviderClient interface {
-	SendPrompt(payload PromptPayload) (string, error)
+	SendPrompt(ctx context.Context, payload PromptPayload) (string, error)
 }
 
 // ProviderType is an enum-like type for different providers.
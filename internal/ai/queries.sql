@@ -15,3 +15,9 @@ SET config = jsonb_set(
     to_jsonb($1::text)
 ) 
 WHERE provider = 'openai';
+
+-- name: get-providers
+SELECT id, name, provider, config, is_default FROM ai_providers;
+
+-- name: set-provider-api-key
+UPDATE ai_providers SET config = jsonb_set(COALESCE(config, '{}'::jsonb), '{api_key}', to_jsonb($2::text)) WHERE id = $1;
@@ -14,9 +14,11 @@ const (
 	PermConversationsUpdateStatus       = "conversations:update_status"
 	PermConversationsUpdateTags         = "conversations:update_tags"
 	PermConversationWrite               = "conversations:write"
+	PermConversationsMerge              = "conversations:merge"
 	PermMessagesRead                    = "messages:read"
 	PermMessagesWrite                   = "messages:write"
 	PermMessagesWriteAsContact          = "messages:write_as_contact"
+	PermMessagesReprocess               = "messages:reprocess"
 
 	// View
 	PermViewManage        = "view:manage"
@@ -61,6 +63,13 @@ const (
 	// SLA
 	PermSLAManage = "sla:manage"
 
+	// Data retention
+	PermRetentionManage = "retention:manage"
+
+	// Sender block rules
+	PermBlockRulesManage  = "block_rules:manage"
+	PermDeadLettersManage = "dead_letters:manage"
+
 	// General Settings
 	PermGeneralSettingsManage = "general_settings:manage"
 
@@ -78,6 +87,8 @@ const (
 	PermContactsRead    = "contacts:read"
 	PermContactsWrite   = "contacts:write"
 	PermContactsBlock   = "contacts:block"
+	PermContactsExport  = "contacts:export"
+	PermContactsErase   = "contacts:erase"
 
 	// Contact Notes
 	PermContactNotesRead   = "contact_notes:read"
@@ -104,9 +115,11 @@ var validPermissions = map[string]struct{}{
 	PermConversationsUpdateStatus:       {},
 	PermConversationsUpdateTags:         {},
 	PermConversationWrite:               {},
+	PermConversationsMerge:              {},
 	PermMessagesRead:                    {},
 	PermMessagesWrite:                   {},
 	PermMessagesWriteAsContact:          {},
+	PermMessagesReprocess:               {},
 	PermViewManage:                      {},
 	PermSharedViewsManage:               {},
 	PermStatusManage:                    {},
@@ -121,6 +134,9 @@ var validPermissions = map[string]struct{}{
 	PermReportsManage:                   {},
 	PermBusinessHoursManage:             {},
 	PermSLAManage:                       {},
+	PermRetentionManage:                 {},
+	PermBlockRulesManage:                {},
+	PermDeadLettersManage:               {},
 	PermGeneralSettingsManage:           {},
 	PermNotificationSettingsManage:      {},
 	PermOIDCManage:                      {},
@@ -130,6 +146,8 @@ var validPermissions = map[string]struct{}{
 	PermContactsRead:                    {},
 	PermContactsWrite:                   {},
 	PermContactsBlock:                   {},
+	PermContactsExport:                  {},
+	PermContactsErase:                   {},
 	PermContactNotesRead:                {},
 	PermContactNotesWrite:               {},
 	PermContactNotesDelete:              {},
@@ -15,11 +15,13 @@ import (
 	cmodels "github.com/abhinavxd/libredesk/internal/conversation/models"
 	"github.com/abhinavxd/libredesk/internal/dbutil"
 	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/tracing"
 	umodels "github.com/abhinavxd/libredesk/internal/user/models"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/go-i18n"
 	"github.com/lib/pq"
 	"github.com/zerodha/logf"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
@@ -43,6 +45,8 @@ type ConversationTask struct {
 	taskType     TaskType
 	eventType    string
 	conversation cmodels.Conversation
+	// traceContext carries the trace of the enqueuer to the evaluation.
+	traceContext map[string]string
 }
 
 type Engine struct {
@@ -113,14 +117,20 @@ func (e *Engine) ReloadRules() {
 	e.rules = e.queryRules()
 }
 
-// Run starts the Engine with a worker pool to evaluate rules based on events.
+// Run starts the Engine with a worker pool to evaluate rules based on events. It blocks until the context is
+// cancelled.
 func (e *Engine) Run(ctx context.Context, workerCount int) {
 	// Spawn worker pool.
 	for i := 0; i < workerCount; i++ {
 		e.wg.Add(1)
 		go e.worker(ctx)
 	}
+	<-ctx.Done()
+}
 
+// RunTimeTriggers queues the evaluation of time based rules every hour until the context is cancelled. It must run
+// on a single node, the rules would otherwise be applied once per node.
+func (e *Engine) RunTimeTriggers(ctx context.Context) {
 	// Hourly ticker for timed triggers.
 	ticker := time.NewTicker(1 * time.Hour)
 	defer func() {
@@ -149,6 +159,11 @@ func (e *Engine) worker(ctx context.Context) {
 			if !ok {
 				return
 			}
+			_, span := tracing.Start(tracing.Extract(context.Background(), task.traceContext), "automation.evaluate",
+				attribute.String("automation.task_type", string(task.taskType)),
+				attribute.String("automation.event", task.eventType),
+				attribute.String("conversation.uuid", task.conversation.UUID),
+			)
 			switch task.taskType {
 			case NewConversation:
 				e.handleNewConversation(task.conversation)
@@ -157,6 +172,7 @@ func (e *Engine) worker(ctx context.Context) {
 			case TimeTrigger:
 				e.handleTimeTrigger()
 			}
+			span.End()
 		}
 	}
 }
@@ -276,6 +292,11 @@ func (e *Engine) UpdateRuleExecutionMode(ruleType, mode string) error {
 
 // EvaluateNewConversationRules enqueues a new conversation for rule evaluation.
 func (e *Engine) EvaluateNewConversationRules(conversation cmodels.Conversation) {
+	e.EvaluateNewConversationRulesContext(context.Background(), conversation)
+}
+
+// EvaluateNewConversationRulesContext is EvaluateNewConversationRules continuing the trace of ctx in the evaluation.
+func (e *Engine) EvaluateNewConversationRulesContext(ctx context.Context, conversation cmodels.Conversation) {
 	e.closedMu.RLock()
 	defer e.closedMu.RUnlock()
 	if e.closed {
@@ -285,6 +306,7 @@ func (e *Engine) EvaluateNewConversationRules(conversation cmodels.Conversation)
 	case e.taskQueue <- ConversationTask{
 		taskType:     NewConversation,
 		conversation: conversation,
+		traceContext: tracing.Inject(ctx),
 	}:
 	default:
 		// Queue is full.
@@ -294,6 +316,11 @@ func (e *Engine) EvaluateNewConversationRules(conversation cmodels.Conversation)
 
 // EvaluateConversationUpdateRules enqueues a conversation for rule evaluation, this function exists along with EvaluateConversationUpdateRulesByID to reduce DB queries for fetching conversations.
 func (e *Engine) EvaluateConversationUpdateRules(conversation cmodels.Conversation, eventType string) {
+	e.EvaluateConversationUpdateRulesContext(context.Background(), conversation, eventType)
+}
+
+// EvaluateConversationUpdateRulesContext is EvaluateConversationUpdateRules continuing the trace of ctx in the evaluation.
+func (e *Engine) EvaluateConversationUpdateRulesContext(ctx context.Context, conversation cmodels.Conversation, eventType string) {
 	if eventType == "" {
 		e.lo.Error("error evaluating conversation update rules: eventType is empty")
 		return
@@ -308,6 +335,7 @@ func (e *Engine) EvaluateConversationUpdateRules(conversation cmodels.Conversati
 		taskType:     UpdateConversation,
 		eventType:    eventType,
 		conversation: conversation,
+		traceContext: tracing.Inject(ctx),
 	}:
 	default:
 		// Queue is full.
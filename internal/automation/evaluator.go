@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/abhinavxd/libredesk/internal/automation/models"
 	cmodels "github.com/abhinavxd/libredesk/internal/conversation/models"
 	umodels "github.com/abhinavxd/libredesk/internal/user/models"
@@ -38,9 +39,11 @@ func (e *Engine) evalConversationRules(rules []models.Rule, conversation cmodels
 
 		if evaluateFinalResult(groupEvalResults, rule.GroupOperator) {
 			e.lo.Debug("all rules within groups evaluated successfully, executing actions", "conversation_uuid", conversation.UUID)
+			metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_automation_rule_executions_total{type=%q}`, rule.Type)).Inc()
 			for _, action := range rule.Actions {
 				if err := e.conversationStore.ApplyAction(action, conversation, umodels.User{}); err != nil {
 					e.lo.Error("error applying action on conversation", "action", action, "conversation_uuid", conversation.UUID, "error", err)
+					metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_automation_action_errors_total{action=%q}`, action.Type)).Inc()
 				}
 			}
 			if rule.ExecutionMode == models.ExecutionModeFirstMatch {
@@ -124,6 +127,10 @@ func (e *Engine) evaluateRule(rule models.RuleDetail, conversation cmodels.Conve
 		switch rule.Field {
 		case models.ContactEmail:
 			valueToCompare = conversation.Contact.Email.String
+		case models.ContactOrganization:
+			if conversation.Contact.OrganizationID.Valid {
+				valueToCompare = strconv.Itoa(conversation.Contact.OrganizationID.Int)
+			}
 		case models.ConversationSubject:
 			valueToCompare = conversation.Subject.String
 		case models.ConversationContent:
@@ -160,9 +167,16 @@ func (e *Engine) evaluateRule(rule models.RuleDetail, conversation cmodels.Conve
 			e.lo.Error("error unrecognized conversation field", "field", rule.Field, "field_type", rule.FieldType, "conversation_uuid", conversation.UUID)
 			return false
 		}
-	} else if rule.FieldType == models.FieldTypeContactCustomAttribute {
+	} else if rule.FieldType == models.FieldTypeContactCustomAttribute || rule.FieldType == models.FieldTypeOrganizationCustomAttribute {
 		// If the field type is custom attribute, need to extract the value from the custom attributes
 		var attributes json.RawMessage = conversation.Contact.CustomAttributes
+		if rule.FieldType == models.FieldTypeOrganizationCustomAttribute {
+			// Contacts without an organization have no organization custom attributes.
+			if !conversation.Contact.OrganizationID.Valid {
+				return false
+			}
+			attributes = conversation.Contact.OrganizationCustomAttributes
+		}
 
 		// Unmarshal the custom attributes
 		if err := json.Unmarshal(attributes, &customAttributes); err != nil {
@@ -431,6 +431,46 @@ func TestCustomAttributes_StringComparison(t *testing.T) {
 	assert.Equal(t, models.ActionSendCSAT, mockStore.appliedActions[0].Type)
 }
 
+// Test: Organization fields - organization ID and organization custom attributes
+func TestOrganizationFields(t *testing.T) {
+	mockStore := new(mockConversationStore)
+	mockStore.On("ApplyAction", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	engine := createTestEngine(mockStore)
+
+	customJSON, _ := json.Marshal(map[string]interface{}{"plan": "enterprise"})
+
+	rules := []models.Rule{
+		{
+			Groups: []models.RuleGroup{
+				{
+					LogicalOp: models.OperatorAnd,
+					Rules: []models.RuleDetail{
+						{Field: models.ContactOrganization, Operator: models.RuleOperatorEquals, Value: "7", FieldType: models.FieldTypeConversationField},
+						{Field: "plan", Operator: models.RuleOperatorEquals, Value: "enterprise", FieldType: models.FieldTypeOrganizationCustomAttribute},
+					},
+				},
+			},
+			Actions: []models.RuleAction{
+				{Type: models.ActionSetPriority, Value: []string{"1"}},
+			},
+			GroupOperator: models.OperatorOR,
+			ExecutionMode: models.ExecutionModeAll,
+		},
+	}
+
+	engine.evalConversationRules(rules, createTestConversation(func(c *cmodels.Conversation) {
+		c.Contact.OrganizationID = null.IntFrom(7)
+		c.Contact.OrganizationCustomAttributes = customJSON
+	}))
+	assert.Equal(t, 1, mockStore.callCount, "Organization fields should be compared correctly")
+
+	// Contacts without an organization never match organization fields.
+	engine.evalConversationRules(rules, createTestConversation(func(c *cmodels.Conversation) {
+		c.Contact.OrganizationCustomAttributes = customJSON
+	}))
+	assert.Equal(t, 1, mockStore.callCount, "Contacts without an organization should not match")
+}
+
 // Test: Custom attributes - missing field
 func TestCustomAttributes_MissingField(t *testing.T) {
 	mockStore := new(mockConversationStore)
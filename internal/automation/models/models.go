@@ -49,6 +49,7 @@ const (
 	ConversationHoursSinceResolved   = "hours_since_resolved"
 	ConversationInbox                = "inbox"
 	ContactEmail                     = "contact_email"
+	ContactOrganization              = "contact_organization"
 
 	EventConversationUserAssigned    = "conversation.user.assigned"
 	EventConversationTeamAssigned    = "conversation.team.assigned"
@@ -61,6 +62,7 @@ const (
 	ExecutionModeFirstMatch = "first_match"
 
 	FieldTypeContactCustomAttribute      = "contact_custom_attribute"
+	FieldTypeOrganizationCustomAttribute = "organization_custom_attribute"
 	FieldTypeConversationField           = "conversation"
 )
 
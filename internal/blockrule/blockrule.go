@@ -0,0 +1,244 @@
+// Package blockrule handles sender block rules that drop or auto-close incoming messages from senders
+// matching a domain, wildcard address or regular expression.
+package blockrule
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/abhinavxd/libredesk/internal/blockrule/models"
+	"github.com/abhinavxd/libredesk/internal/dbutil"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/go-i18n"
+	"github.com/zerodha/logf"
+)
+
+var (
+	//go:embed queries.sql
+	efs embed.FS
+)
+
+// Manager manages sender block rules.
+type Manager struct {
+	q    queries
+	lo   *logf.Logger
+	i18n *i18n.I18n
+
+	// rules are the enabled rules, compiled and cached for matching incoming messages.
+	mu    sync.RWMutex
+	rules []compiledRule
+}
+
+// Opts contains options for initializing the Manager.
+type Opts struct {
+	DB   *sqlx.DB
+	Lo   *logf.Logger
+	I18n *i18n.I18n
+}
+
+// queries contains prepared SQL queries.
+type queries struct {
+	GetRules             *sqlx.Stmt `query:"get-rules"`
+	GetEnabledRules      *sqlx.Stmt `query:"get-enabled-rules"`
+	GetRule              *sqlx.Stmt `query:"get-rule"`
+	InsertRule           *sqlx.Stmt `query:"insert-rule"`
+	UpdateRule           *sqlx.Stmt `query:"update-rule"`
+	DeleteRule           *sqlx.Stmt `query:"delete-rule"`
+	InsertBlockedMessage *sqlx.Stmt `query:"insert-blocked-message"`
+	MessageDropped       *sqlx.Stmt `query:"message-dropped"`
+}
+
+// compiledRule is a rule with its pattern compiled for matching.
+type compiledRule struct {
+	models.Rule
+	re *regexp.Regexp
+}
+
+// New creates and returns a new instance of the Manager.
+func New(opts Opts) (*Manager, error) {
+	var q queries
+	if err := dbutil.ScanSQLFile("queries.sql", &q, opts.DB, efs); err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		q:    q,
+		lo:   opts.Lo,
+		i18n: opts.I18n,
+	}
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetAll retrieves all block rules.
+func (m *Manager) GetAll() ([]models.Rule, error) {
+	var rules = make([]models.Rule, 0)
+	if err := m.q.GetRules.Select(&rules); err != nil {
+		m.lo.Error("error fetching block rules", "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.blockRule")), nil)
+	}
+	return rules, nil
+}
+
+// Get retrieves a block rule by ID.
+func (m *Manager) Get(id int) (models.Rule, error) {
+	var rule models.Rule
+	if err := m.q.GetRule.Get(&rule, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return rule, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.blockRule}"), nil)
+		}
+		m.lo.Error("error fetching block rule", "error", err)
+		return rule, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.blockRule}"), nil)
+	}
+	return rule, nil
+}
+
+// Create creates a new block rule.
+func (m *Manager) Create(rule models.Rule) (models.Rule, error) {
+	var id int
+	if err := m.q.InsertRule.Get(&id, rule.Name, rule.Enabled, rule.Type, rule.Pattern, rule.Action); err != nil {
+		m.lo.Error("error inserting block rule", "error", err)
+		return rule, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.blockRule}"), nil)
+	}
+	m.reloadOrLog()
+	return m.Get(id)
+}
+
+// Update updates a block rule.
+func (m *Manager) Update(id int, rule models.Rule) (models.Rule, error) {
+	if _, err := m.q.UpdateRule.Exec(id, rule.Name, rule.Enabled, rule.Type, rule.Pattern, rule.Action); err != nil {
+		m.lo.Error("error updating block rule", "error", err)
+		return rule, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.blockRule}"), nil)
+	}
+	m.reloadOrLog()
+	return m.Get(id)
+}
+
+// Delete deletes a block rule.
+func (m *Manager) Delete(id int) error {
+	if _, err := m.q.DeleteRule.Exec(id); err != nil {
+		m.lo.Error("error deleting block rule", "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.blockRule}"), nil)
+	}
+	m.reloadOrLog()
+	return nil
+}
+
+// Match returns the first enabled rule matching the sender address.
+func (m *Manager) Match(sender string) (models.Rule, bool) {
+	sender = strings.ToLower(strings.TrimSpace(sender))
+	if sender == "" {
+		return models.Rule{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, r := range m.rules {
+		if r.match(sender) {
+			return r.Rule, true
+		}
+	}
+	return models.Rule{}, false
+}
+
+// RecordBlocked records a message blocked by a rule and increments the blocked count of the rule.
+func (m *Manager) RecordBlocked(rule models.Rule, inboxID int, sourceID, sender string) error {
+	if _, err := m.q.InsertBlockedMessage.Exec(rule.ID, rule.Action, inboxID, sourceID, sender); err != nil {
+		m.lo.Error("error recording blocked message", "rule_id", rule.ID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// MessageDropped returns true if a message with the source ID was dropped by a block rule.
+func (m *Manager) MessageDropped(sourceID string) (bool, error) {
+	var dropped bool
+	if err := m.q.MessageDropped.Get(&dropped, sourceID); err != nil {
+		m.lo.Error("error checking if message was dropped", "source_id", sourceID, "error", err)
+		return false, err
+	}
+	return dropped, nil
+}
+
+// Validate checks the type, action and pattern of a rule.
+func Validate(rule models.Rule) error {
+	_, err := compile(rule)
+	return err
+}
+
+// Reload loads the enabled rules from the DB and compiles them. Rules that fail to compile are skipped.
+func (m *Manager) Reload() error {
+	var rules []models.Rule
+	if err := m.q.GetEnabledRules.Select(&rules); err != nil {
+		return fmt.Errorf("fetching block rules: %w", err)
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		c, err := compile(r)
+		if err != nil {
+			m.lo.Error("error compiling block rule, skipping", "rule_id", r.ID, "error", err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	m.mu.Lock()
+	m.rules = compiled
+	m.mu.Unlock()
+	return nil
+}
+
+// reloadOrLog reloads the rules after a change, logging errors.
+func (m *Manager) reloadOrLog() {
+	if err := m.Reload(); err != nil {
+		m.lo.Error("error reloading block rules", "error", err)
+	}
+}
+
+// compile compiles the pattern of a rule.
+func compile(rule models.Rule) (compiledRule, error) {
+	c := compiledRule{Rule: rule}
+	pattern := strings.ToLower(strings.TrimSpace(rule.Pattern))
+	if pattern == "" {
+		return c, errors.New("empty pattern")
+	}
+	switch rule.Type {
+	case models.TypeDomain:
+		c.Pattern = strings.TrimPrefix(pattern, "@")
+		if strings.ContainsAny(c.Pattern, "@*? ") {
+			return c, fmt.Errorf("invalid domain: %q", rule.Pattern)
+		}
+	case models.TypeWildcard:
+		expr := regexp.QuoteMeta(pattern)
+		expr = strings.ReplaceAll(expr, `\*`, ".*")
+		expr = strings.ReplaceAll(expr, `\?`, ".")
+		c.re = regexp.MustCompile("^" + expr + "$")
+	case models.TypeRegex:
+		re, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			return c, fmt.Errorf("invalid regex: %w", err)
+		}
+		c.re = re
+	default:
+		return c, fmt.Errorf("invalid type: %q", rule.Type)
+	}
+	if rule.Action != models.ActionDrop && rule.Action != models.ActionClose {
+		return c, fmt.Errorf("invalid action: %q", rule.Action)
+	}
+	return c, nil
+}
+
+// match returns true if the lowercased sender address matches the rule.
+func (c compiledRule) match(sender string) bool {
+	if c.Type == models.TypeDomain {
+		_, domain, ok := strings.Cut(sender, "@")
+		return ok && (domain == c.Pattern || strings.HasSuffix(domain, "."+c.Pattern))
+	}
+	return c.re.MatchString(sender)
+}
@@ -0,0 +1,62 @@
+package blockrule
+
+import (
+	"testing"
+
+	"github.com/abhinavxd/libredesk/internal/blockrule/models"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		pattern string
+		sender  string
+		want    bool
+	}{
+		{name: "domain", typ: models.TypeDomain, pattern: "example.com", sender: "news@example.com", want: true},
+		{name: "domain with at", typ: models.TypeDomain, pattern: "@Example.com", sender: "news@example.com", want: true},
+		{name: "subdomain", typ: models.TypeDomain, pattern: "example.com", sender: "news@mail.example.com", want: true},
+		{name: "domain suffix only", typ: models.TypeDomain, pattern: "example.com", sender: "news@badexample.com", want: false},
+		{name: "wildcard", typ: models.TypeWildcard, pattern: "*-noreply@*.example.com", sender: "promo-noreply@mail.example.com", want: true},
+		{name: "wildcard single char", typ: models.TypeWildcard, pattern: "user?@example.com", sender: "user1@example.com", want: true},
+		{name: "wildcard no match", typ: models.TypeWildcard, pattern: "*@example.com", sender: "user@example.org", want: false},
+		{name: "wildcard dot is literal", typ: models.TypeWildcard, pattern: "a.b@example.com", sender: "axb@example.com", want: false},
+		{name: "regex", typ: models.TypeRegex, pattern: `^(news|promo)@.*\.io$`, sender: "promo@vendor.io", want: true},
+		{name: "regex case insensitive", typ: models.TypeRegex, pattern: `^NEWS@`, sender: "news@vendor.io", want: true},
+		{name: "regex no match", typ: models.TypeRegex, pattern: `^news@`, sender: "support@vendor.io", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := compile(models.Rule{Type: tt.typ, Pattern: tt.pattern, Action: models.ActionDrop})
+			if err != nil {
+				t.Fatalf("compile() error = %v", err)
+			}
+			if got := c.match(tt.sender); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.sender, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.Rule
+		err  bool
+	}{
+		{name: "valid", rule: models.Rule{Type: models.TypeDomain, Pattern: "example.com", Action: models.ActionClose}},
+		{name: "empty pattern", rule: models.Rule{Type: models.TypeDomain, Pattern: " ", Action: models.ActionDrop}, err: true},
+		{name: "address as domain", rule: models.Rule{Type: models.TypeDomain, Pattern: "a@example.com", Action: models.ActionDrop}, err: true},
+		{name: "invalid regex", rule: models.Rule{Type: models.TypeRegex, Pattern: "(", Action: models.ActionDrop}, err: true},
+		{name: "invalid type", rule: models.Rule{Type: "glob", Pattern: "*", Action: models.ActionDrop}, err: true},
+		{name: "invalid action", rule: models.Rule{Type: models.TypeDomain, Pattern: "example.com", Action: "bounce"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); (err != nil) != tt.err {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null/v9"
+)
+
+const (
+	// TypeDomain matches senders of a domain and its subdomains, e.g. `example.com`.
+	TypeDomain = "domain"
+	// TypeWildcard matches sender addresses with `*` and `?` wildcards, e.g. `*-noreply@*.example.com`.
+	TypeWildcard = "wildcard"
+	// TypeRegex matches sender addresses with a case-insensitive regular expression.
+	TypeRegex = "regex"
+
+	// ActionDrop drops messages from matching senders without creating a contact or conversation.
+	ActionDrop = "drop"
+	// ActionClose stores messages from matching senders in conversations that are closed right away,
+	// without running automations or reopening existing conversations.
+	ActionClose = "close"
+)
+
+// Types are the valid block rule types.
+var Types = []string{TypeDomain, TypeWildcard, TypeRegex}
+
+// Actions are the valid block rule actions.
+var Actions = []string{ActionDrop, ActionClose}
+
+// Rule is a sender block rule applied to incoming messages.
+type Rule struct {
+	ID            int       `db:"id" json:"id"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+	Name          string    `db:"name" json:"name"`
+	Enabled       bool      `db:"enabled" json:"enabled"`
+	Type          string    `db:"type" json:"type"`
+	Pattern       string    `db:"pattern" json:"pattern"`
+	Action        string    `db:"action" json:"action"`
+	BlockedCount  int       `db:"blocked_count" json:"blocked_count"`
+	LastBlockedAt null.Time `db:"last_blocked_at" json:"last_blocked_at"`
+}
@@ -0,0 +1,50 @@
+-- name: get-rules
+SELECT id, created_at, updated_at, "name", enabled, "type", pattern, "action", blocked_count, last_blocked_at
+FROM sender_block_rules
+ORDER BY created_at;
+
+-- name: get-enabled-rules
+SELECT id, created_at, updated_at, "name", enabled, "type", pattern, "action", blocked_count, last_blocked_at
+FROM sender_block_rules
+WHERE enabled = TRUE
+ORDER BY id;
+
+-- name: get-rule
+SELECT id, created_at, updated_at, "name", enabled, "type", pattern, "action", blocked_count, last_blocked_at
+FROM sender_block_rules
+WHERE id = $1;
+
+-- name: insert-rule
+INSERT INTO sender_block_rules ("name", enabled, "type", pattern, "action")
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;
+
+-- name: update-rule
+UPDATE sender_block_rules
+SET "name" = $2,
+    enabled = $3,
+    "type" = $4,
+    pattern = $5,
+    "action" = $6,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: delete-rule
+DELETE FROM sender_block_rules WHERE id = $1;
+
+-- name: insert-blocked-message
+-- Records a blocked message and increments the counter of the rule, a message is only counted once
+-- as inboxes may fetch dropped messages again.
+WITH blocked AS (
+    INSERT INTO blocked_messages (rule_id, "action", inbox_id, source_id, sender)
+    VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+    ON CONFLICT (source_id) DO NOTHING
+    RETURNING rule_id
+)
+UPDATE sender_block_rules
+SET blocked_count = blocked_count + 1,
+    last_blocked_at = NOW()
+WHERE id IN (SELECT rule_id FROM blocked);
+
+-- name: message-dropped
+SELECT EXISTS (SELECT 1 FROM blocked_messages WHERE source_id = $1 AND "action" = 'drop');
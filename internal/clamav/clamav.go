@@ -0,0 +1,165 @@
+// Package clamav is a client for scanning files with the clamd daemon over TCP or a unix socket.
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+
+	// chunkSize is the size of the chunks streamed to clamd.
+	chunkSize = 64 * 1024
+)
+
+// Opts contains the clamd client options.
+type Opts struct {
+	// Address is `tcp://host:port`, `unix:///path/to/clamd.sock` or `host:port`.
+	Address string
+	Timeout time.Duration
+}
+
+// Result is the verdict of a scan.
+type Result struct {
+	Infected bool
+	// Signature is the name of the detected malware.
+	Signature string
+}
+
+// Client scans files with clamd.
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// New returns a new clamd client.
+func New(opts Opts) (*Client, error) {
+	network, address, err := parseAddress(opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{network: network, address: address, timeout: timeout}, nil
+}
+
+// Scan streams the content to clamd with the INSTREAM command and returns the verdict.
+func (c *Client) Scan(ctx context.Context, content io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("connecting to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("writing to clamd: %w", err)
+	}
+	var (
+		buf  = make([]byte, chunkSize)
+		size = make([]byte, 4)
+	)
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return Result{}, fmt.Errorf("writing to clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return Result{}, fmt.Errorf("writing to clamd: %w", err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Result{}, fmt.Errorf("reading content: %w", err)
+		}
+	}
+	// A zero length chunk ends the stream.
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return Result{}, fmt.Errorf("writing to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return Result{}, fmt.Errorf("reading clamd reply: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// Ping checks if clamd is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return fmt.Errorf("connecting to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return fmt.Errorf("writing to clamd: %w", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("reading clamd reply: %w", err)
+	}
+	if strings.TrimRight(reply, "\x00") != "PONG" {
+		return fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+	return nil
+}
+
+// parseReply parses a clamd INSTREAM reply, e.g. "stream: OK" or "stream: Win.Test.EICAR_HDB-1 FOUND".
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	_, verdict, ok := strings.Cut(reply, ": ")
+	if !ok {
+		return Result{}, fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	}
+	return Result{}, fmt.Errorf("clamd error: %s", reply)
+}
+
+// parseAddress returns the network and address of a clamd address.
+func parseAddress(addr string) (string, string, error) {
+	addr = strings.TrimSpace(addr)
+	switch {
+	case addr == "":
+		return "", "", fmt.Errorf("empty clamd address")
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://"), nil
+	case strings.HasPrefix(addr, "tcp://"):
+		return "tcp", strings.TrimPrefix(addr, "tcp://"), nil
+	case strings.HasPrefix(addr, "/"):
+		return "unix", addr, nil
+	}
+	return "tcp", addr, nil
+}
@@ -0,0 +1,109 @@
+package clamav
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeClamd accepts one INSTREAM connection and replies FOUND if the streamed content contains "EICAR".
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				if cmd, err := rd.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					return
+				}
+				var content bytes.Buffer
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(rd, size); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+					if _, err := io.CopyN(&content, rd, int64(n)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(content.String(), "EICAR") {
+					conn.Write([]byte("stream: Win.Test.EICAR_HDB-1 FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+func TestScan(t *testing.T) {
+	c, err := New(Opts{Address: fakeClamd(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Larger than a chunk to check the content is streamed in multiple chunks.
+	clean := strings.Repeat("a", chunkSize+10)
+	res, err := c.Scan(context.Background(), strings.NewReader(clean))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if res.Infected {
+		t.Errorf("Scan() of clean content = %+v, want not infected", res)
+	}
+
+	res, err = c.Scan(context.Background(), strings.NewReader(clean+"EICAR"))
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !res.Infected || res.Signature != "Win.Test.EICAR_HDB-1" {
+		t.Errorf("Scan() of infected content = %+v, want infected with signature", res)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	if _, err := parseReply("INSTREAM size limit exceeded. ERROR\x00"); err == nil {
+		t.Error("parseReply() of an error reply returned no error")
+	}
+	if _, err := parseReply("stream: Can't allocate memory ERROR\x00"); err == nil {
+		t.Error("parseReply() of an error reply returned no error")
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+	}{
+		{"tcp://127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"unix:///run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl"},
+		{"/run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl"},
+	}
+	for _, tt := range tests {
+		network, address, err := parseAddress(tt.addr)
+		if err != nil || network != tt.network || address != tt.address {
+			t.Errorf("parseAddress(%q) = %q, %q, %v, want %q, %q", tt.addr, network, address, err, tt.network, tt.address)
+		}
+	}
+}
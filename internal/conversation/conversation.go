@@ -2,6 +2,7 @@
 package conversation
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"embed"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/abhinavxd/libredesk/internal/automation"
 	amodels "github.com/abhinavxd/libredesk/internal/automation/models"
+	brmodels "github.com/abhinavxd/libredesk/internal/blockrule/models"
 	"github.com/abhinavxd/libredesk/internal/conversation/models"
 	pmodels "github.com/abhinavxd/libredesk/internal/conversation/priority/models"
 	smodels "github.com/abhinavxd/libredesk/internal/conversation/status/models"
@@ -29,12 +31,14 @@ import (
 	notifier "github.com/abhinavxd/libredesk/internal/notification"
 	nmodels "github.com/abhinavxd/libredesk/internal/notification/models"
 	slaModels "github.com/abhinavxd/libredesk/internal/sla/models"
+	"github.com/abhinavxd/libredesk/internal/spam"
 	"github.com/abhinavxd/libredesk/internal/stringutil"
 	tmodels "github.com/abhinavxd/libredesk/internal/team/models"
 	"github.com/abhinavxd/libredesk/internal/template"
 	umodels "github.com/abhinavxd/libredesk/internal/user/models"
 	wmodels "github.com/abhinavxd/libredesk/internal/webhook/models"
 	"github.com/abhinavxd/libredesk/internal/ws"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/go-i18n"
 	"github.com/lib/pq"
@@ -48,7 +52,8 @@ var (
 	errConversationNotFound         = errors.New("conversation not found")
 	conversationsAllowedFields      = []string{"status_id", "priority_id", "assigned_team_id", "assigned_user_id", "inbox_id", "last_message_at", "last_interaction_at", "created_at", "waiting_since", "next_sla_deadline_at", "priority_id"}
 	conversationStatusAllowedFields = []string{"id", "name"}
-	usersAllowedFields              = []string{"email"}
+	usersAllowedFields              = []string{"email", "organization_id"}
+	organizationsAllowedFields      = []string{"id", "name"}
 	csatReplyMessage                = "Please rate your experience with us: <a href=\"%s\">Rate now</a>"
 )
 
@@ -58,30 +63,39 @@ const (
 
 // Manager handles the operations related to conversations
 type Manager struct {
-	q                          queries
-	inboxStore                 inboxStore
-	userStore                  userStore
-	teamStore                  teamStore
-	mediaStore                 mediaStore
-	statusStore                statusStore
-	priorityStore              priorityStore
-	slaStore                   slaStore
-	settingsStore              settingsStore
-	csatStore                  csatStore
-	webhookStore               webhookStore
-	dispatcher                 *notifier.Dispatcher
-	lo                         *logf.Logger
-	db                         *sqlx.DB
-	i18n                       *i18n.I18n
-	automation                 *automation.Engine
-	wsHub                      *ws.Hub
-	template                   *template.Manager
-	incomingMessageQueue       chan models.IncomingMessage
-	outgoingMessageQueue       chan models.Message
-	outgoingProcessingMessages sync.Map
-	closed                     bool
-	closedMu                   sync.RWMutex
-	wg                         sync.WaitGroup
+	q               queries
+	inboxStore      inboxStore
+	userStore       userStore
+	teamStore       teamStore
+	mediaStore      mediaStore
+	statusStore     statusStore
+	priorityStore   priorityStore
+	slaStore        slaStore
+	settingsStore   settingsStore
+	csatStore       csatStore
+	webhookStore    webhookStore
+	dispatcher      *notifier.Dispatcher
+	spamFilter      *spam.Filter
+	blockRuleStore  blockRuleStore
+	deadLetterStore deadLetterStore
+	lo              *logf.Logger
+	db              *sqlx.DB
+	i18n            *i18n.I18n
+	automation      *automation.Engine
+	wsHub           *ws.Hub
+	template        *template.Manager
+	// incomingNotify wakes up incoming message workers when a message is enqueued.
+	incomingNotify            chan struct{}
+	incomingVisibilityTimeout time.Duration
+	incomingMaxAttempts       int
+	outgoingMessageQueue      chan models.Message
+	// nodeID identifies this instance in the claims of the outgoing messages it sends.
+	nodeID             string
+	outgoingClaimLease time.Duration
+	undoSendWindow     time.Duration
+	closed             bool
+	closedMu           sync.RWMutex
+	wg                 sync.WaitGroup
 }
 
 type slaStore interface {
@@ -90,6 +104,17 @@ type slaStore interface {
 	SetLatestSLAEventMetAt(appliedSLAID int, metric string) (time.Time, error)
 }
 
+type blockRuleStore interface {
+	Match(sender string) (brmodels.Rule, bool)
+	RecordBlocked(rule brmodels.Rule, inboxID int, sourceID, sender string) error
+	MessageDropped(sourceID string) (bool, error)
+}
+
+type deadLetterStore interface {
+	Add(in models.IncomingMessage, processErr error) error
+	Exists(sourceID string) (bool, error)
+}
+
 type statusStore interface {
 	Get(int) (smodels.Status, error)
 }
@@ -117,6 +142,9 @@ type mediaStore interface {
 	ContentIDExists(contentID string) (bool, string, error)
 	Upload(fileName, contentType string, content io.ReadSeeker) (string, string, error)
 	UploadAndInsert(fileName, contentType, contentID string, modelType null.String, modelID null.Int, content io.ReadSeeker, fileSize int, disposition null.String, meta []byte) (mmodels.Media, error)
+	DeleteWithThumbnail(media mmodels.Media) error
+	ScanForMalware(content io.Reader) (*mmodels.MalwareScan, bool)
+	Quarantine(contentType string, content io.ReadSeeker) (string, error)
 }
 
 type inboxStore interface {
@@ -135,6 +163,7 @@ type csatStore interface {
 
 type webhookStore interface {
 	TriggerEvent(event wmodels.WebhookEvent, data any)
+	TriggerEventContext(ctx context.Context, event wmodels.WebhookEvent, data any)
 }
 
 // Opts holds the options for creating a new Manager.
@@ -142,7 +171,17 @@ type Opts struct {
 	DB                       *sqlx.DB
 	Lo                       *logf.Logger
 	OutgoingMessageQueueSize int
-	IncomingMessageQueueSize int
+	// IncomingVisibilityTimeout is how long an incoming message being processed is hidden from other workers,
+	// after which it is processed again, e.g. if the worker died.
+	IncomingVisibilityTimeout time.Duration
+	// IncomingMaxAttempts is the number of times an incoming message is processed before it is moved to the
+	// dead-letter queue.
+	IncomingMaxAttempts int
+	// UndoSendWindow delays agent replies so they can be cancelled before being sent.
+	UndoSendWindow time.Duration
+	// OutgoingClaimLease is how long a pending outgoing message claimed for sending is held by an instance that
+	// stopped extending it, e.g. crashed, before other instances can claim it.
+	OutgoingClaimLease time.Duration
 }
 
 // New initializes a new conversation Manager.
@@ -162,6 +201,9 @@ func New(
 	template *template.Manager,
 	webhook webhookStore,
 	dispatcher *notifier.Dispatcher,
+	spamFilter *spam.Filter,
+	blockRuleStore blockRuleStore,
+	deadLetterStore deadLetterStore,
 	opts Opts) (*Manager, error) {
 
 	var q queries
@@ -170,27 +212,34 @@ func New(
 	}
 
 	c := &Manager{
-		q:                          q,
-		wsHub:                      wsHub,
-		i18n:                       i18n,
-		dispatcher:                 dispatcher,
-		inboxStore:                 inboxStore,
-		userStore:                  userStore,
-		teamStore:                  teamStore,
-		mediaStore:                 mediaStore,
-		settingsStore:              settingsStore,
-		csatStore:                  csatStore,
-		webhookStore:               webhook,
-		slaStore:                   slaStore,
-		statusStore:                statusStore,
-		priorityStore:              priorityStore,
-		automation:                 automation,
-		template:                   template,
-		db:                         opts.DB,
-		lo:                         opts.Lo,
-		incomingMessageQueue:       make(chan models.IncomingMessage, opts.IncomingMessageQueueSize),
-		outgoingMessageQueue:       make(chan models.Message, opts.OutgoingMessageQueueSize),
-		outgoingProcessingMessages: sync.Map{},
+		q:                         q,
+		wsHub:                     wsHub,
+		i18n:                      i18n,
+		dispatcher:                dispatcher,
+		spamFilter:                spamFilter,
+		blockRuleStore:            blockRuleStore,
+		deadLetterStore:           deadLetterStore,
+		inboxStore:                inboxStore,
+		userStore:                 userStore,
+		teamStore:                 teamStore,
+		mediaStore:                mediaStore,
+		settingsStore:             settingsStore,
+		csatStore:                 csatStore,
+		webhookStore:              webhook,
+		slaStore:                  slaStore,
+		statusStore:               statusStore,
+		priorityStore:             priorityStore,
+		automation:                automation,
+		template:                  template,
+		db:                        opts.DB,
+		lo:                        opts.Lo,
+		incomingNotify:            make(chan struct{}, 1),
+		incomingVisibilityTimeout: cmp.Or(opts.IncomingVisibilityTimeout, 5*time.Minute),
+		incomingMaxAttempts:       cmp.Or(opts.IncomingMaxAttempts, 3),
+		outgoingMessageQueue:      make(chan models.Message, opts.OutgoingMessageQueueSize),
+		nodeID:                    uuid.NewString(),
+		outgoingClaimLease:        cmp.Or(opts.OutgoingClaimLease, time.Minute),
+		undoSendWindow:            opts.UndoSendWindow,
 	}
 
 	return c, nil
@@ -204,6 +253,7 @@ type queries struct {
 	GetUnassignedConversations         *sqlx.Stmt `query:"get-unassigned-conversations"`
 	GetConversations                   string     `query:"get-conversations"`
 	GetContactPreviousConversations    *sqlx.Stmt `query:"get-contact-previous-conversations"`
+	GetContactConversationUUIDs        *sqlx.Stmt `query:"get-contact-conversation-uuids"`
 	GetConversationParticipants        *sqlx.Stmt `query:"get-conversation-participants"`
 	GetUserActiveConversationsCount    *sqlx.Stmt `query:"get-user-active-conversations-count"`
 	UpdateConversationFirstReplyAt     *sqlx.Stmt `query:"update-conversation-first-reply-at"`
@@ -227,6 +277,11 @@ type queries struct {
 	ReOpenConversation                 *sqlx.Stmt `query:"re-open-conversation"`
 	UnsnoozeAll                        *sqlx.Stmt `query:"unsnooze-all"`
 	DeleteConversation                 *sqlx.Stmt `query:"delete-conversation"`
+	LockConversationsForMerge          *sqlx.Stmt `query:"lock-conversations-for-merge"`
+	MergeConversation                  *sqlx.Stmt `query:"merge-conversation"`
+	SplitConversationMessages          *sqlx.Stmt `query:"split-conversation-messages"`
+	RefreshConversationLastMessage     *sqlx.Stmt `query:"refresh-conversation-last-message"`
+	FinishImportedConversation         *sqlx.Stmt `query:"finish-imported-conversation"`
 	RemoveConversationAssignee         *sqlx.Stmt `query:"remove-conversation-assignee"`
 	GetLatestMessage                   *sqlx.Stmt `query:"get-latest-message"`
 
@@ -239,11 +294,28 @@ type queries struct {
 	// Message queries.
 	GetMessage                         *sqlx.Stmt `query:"get-message"`
 	GetMessages                        string     `query:"get-messages"`
-	GetOutgoingPendingMessages         *sqlx.Stmt `query:"get-outgoing-pending-messages"`
+	GetConversationExportMessages      *sqlx.Stmt `query:"get-conversation-export-messages"`
+	EnqueueIncomingMessage             *sqlx.Stmt `query:"enqueue-incoming-message"`
+	ClaimIncomingMessage               *sqlx.Stmt `query:"claim-incoming-message"`
+	ExtendIncomingMessage              *sqlx.Stmt `query:"extend-incoming-message"`
+	DeleteIncomingMessage              *sqlx.Stmt `query:"delete-incoming-message"`
+	DeferIncomingMessage               *sqlx.Stmt `query:"defer-incoming-message"`
+	IncomingMessageQueued              *sqlx.Stmt `query:"incoming-message-queued"`
+	GetIncomingQueueStats              *sqlx.Stmt `query:"get-incoming-queue-stats"`
+	ClaimOutgoingPendingMessages       *sqlx.Stmt `query:"claim-outgoing-pending-messages"`
+	ExtendOutgoingMessageClaims        *sqlx.Stmt `query:"extend-outgoing-message-claims"`
+	ReleaseOutgoingMessageClaims       *sqlx.Stmt `query:"release-outgoing-message-claims"`
 	GetMessageSourceIDs                *sqlx.Stmt `query:"get-message-source-ids"`
 	GetConversationUUIDFromMessageUUID *sqlx.Stmt `query:"get-conversation-uuid-from-message-uuid"`
 	InsertMessage                      *sqlx.Stmt `query:"insert-message"`
+	InsertImportedMessage              *sqlx.Stmt `query:"insert-imported-message"`
 	UpdateMessageStatus                *sqlx.Stmt `query:"update-message-status"`
+	UpdateMessageContent               *sqlx.Stmt `query:"update-message-content"`
+	InsertMessageSource                *sqlx.Stmt `query:"insert-message-source"`
+	GetMessageSource                   *sqlx.Stmt `query:"get-message-source"`
+	DeleteScheduledMessage             *sqlx.Stmt `query:"delete-scheduled-message"`
+	GetMessageAttachments              *sqlx.Stmt `query:"get-message-attachments"`
+	MoveMessageAttachmentsToDraft      *sqlx.Stmt `query:"move-message-attachments-to-draft"`
 	MessageExistsBySourceID            *sqlx.Stmt `query:"message-exists-by-source-id"`
 	GetConversationByMessageID         *sqlx.Stmt `query:"get-conversation-by-message-id"`
 
@@ -253,12 +325,17 @@ type queries struct {
 
 // CreateConversation creates a new conversation and returns its ID and UUID.
 func (c *Manager) CreateConversation(contactID, contactChannelID, inboxID int, lastMessage string, lastMessageAt time.Time, subject string, appendRefNumToSubject bool) (int, string, error) {
+	return c.createConversation(contactID, contactChannelID, inboxID, models.StatusOpen, lastMessage, lastMessageAt, subject, appendRefNumToSubject)
+}
+
+// createConversation creates a new conversation with the given status and returns its ID and UUID.
+func (c *Manager) createConversation(contactID, contactChannelID, inboxID int, status, lastMessage string, lastMessageAt time.Time, subject string, appendRefNumToSubject bool) (int, string, error) {
 	var (
 		id     int
 		uuid   string
 		prefix string
 	)
-	if err := c.q.InsertConversation.QueryRow(contactID, contactChannelID, models.StatusOpen, inboxID, lastMessage, lastMessageAt, subject, prefix, appendRefNumToSubject).Scan(&id, &uuid); err != nil {
+	if err := c.q.InsertConversation.QueryRow(contactID, contactChannelID, status, inboxID, lastMessage, lastMessageAt, subject, prefix, appendRefNumToSubject).Scan(&id, &uuid); err != nil {
 		c.lo.Error("error inserting new conversation into the DB", "error", err)
 		return id, uuid, err
 	}
@@ -267,13 +344,18 @@ func (c *Manager) CreateConversation(contactID, contactChannelID, inboxID int, l
 
 // GetConversation retrieves a conversation by its ID or UUID.
 func (c *Manager) GetConversation(id int, uuid, refNum string) (models.Conversation, error) {
+	return c.GetConversationContext(context.Background(), id, uuid, refNum)
+}
+
+// GetConversationContext is GetConversation tracing the query in the trace of ctx, e.g. of an HTTP request.
+func (c *Manager) GetConversationContext(ctx context.Context, id int, uuid, refNum string) (models.Conversation, error) {
 	var conversation models.Conversation
 	var uuidParam any
 	if uuid != "" {
 		uuidParam = uuid
 	}
 
-	if err := c.q.GetConversation.Get(&conversation, id, uuidParam, refNum); err != nil {
+	if err := c.q.GetConversation.GetContext(ctx, &conversation, id, uuidParam, refNum); err != nil {
 		if err == sql.ErrNoRows {
 			return conversation, envelope.NewError(envelope.NotFoundError,
 				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.conversation}"), nil)
@@ -293,6 +375,16 @@ func (c *Manager) GetConversation(id int, uuid, refNum string) (models.Conversat
 	return conversation, nil
 }
 
+// GetContactConversationUUIDs returns the UUIDs of all conversations of a contact, oldest first.
+func (c *Manager) GetContactConversationUUIDs(contactID int) ([]string, error) {
+	var uuids = make([]string, 0)
+	if err := c.q.GetContactConversationUUIDs.Select(&uuids, contactID); err != nil {
+		c.lo.Error("error fetching contact conversations", "contact_id", contactID, "error", err)
+		return uuids, envelope.NewError(envelope.GeneralError, c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.conversation}"), nil)
+	}
+	return uuids, nil
+}
+
 // GetContactPreviousConversations retrieves previous conversations for a contact with a configurable limit.
 func (c *Manager) GetContactPreviousConversations(contactID int, limit int) ([]models.PreviousConversation, error) {
 	var conversations = make([]models.PreviousConversation, 0)
@@ -367,28 +459,28 @@ func (c *Manager) GetConversationUUID(id int) (string, error) {
 }
 
 // GetAllConversationsList retrieves all conversations with optional filtering, ordering, and pagination.
-func (c *Manager) GetAllConversationsList(viewingUserID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
-	return c.GetConversations(viewingUserID, 0, []int{}, []string{models.AllConversations}, order, orderBy, filters, page, pageSize)
+func (c *Manager) GetAllConversationsList(ctx context.Context, viewingUserID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
+	return c.GetConversations(ctx, viewingUserID, 0, []int{}, []string{models.AllConversations}, order, orderBy, filters, page, pageSize)
 }
 
 // GetAssignedConversationsList retrieves conversations assigned to a specific user with optional filtering, ordering, and pagination.
-func (c *Manager) GetAssignedConversationsList(viewingUserID, userID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
-	return c.GetConversations(viewingUserID, userID, []int{}, []string{models.AssignedConversations}, order, orderBy, filters, page, pageSize)
+func (c *Manager) GetAssignedConversationsList(ctx context.Context, viewingUserID, userID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
+	return c.GetConversations(ctx, viewingUserID, userID, []int{}, []string{models.AssignedConversations}, order, orderBy, filters, page, pageSize)
 }
 
 // GetUnassignedConversationsList retrieves conversations assigned to a team the user is part of with optional filtering, ordering, and pagination.
-func (c *Manager) GetUnassignedConversationsList(viewingUserID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
-	return c.GetConversations(viewingUserID, 0, []int{}, []string{models.UnassignedConversations}, order, orderBy, filters, page, pageSize)
+func (c *Manager) GetUnassignedConversationsList(ctx context.Context, viewingUserID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
+	return c.GetConversations(ctx, viewingUserID, 0, []int{}, []string{models.UnassignedConversations}, order, orderBy, filters, page, pageSize)
 }
 
 // GetTeamUnassignedConversationsList retrieves conversations assigned to a team with optional filtering, ordering, and pagination.
-func (c *Manager) GetTeamUnassignedConversationsList(viewingUserID, teamID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
-	return c.GetConversations(viewingUserID, 0, []int{teamID}, []string{models.TeamUnassignedConversations}, order, orderBy, filters, page, pageSize)
+func (c *Manager) GetTeamUnassignedConversationsList(ctx context.Context, viewingUserID, teamID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
+	return c.GetConversations(ctx, viewingUserID, 0, []int{teamID}, []string{models.TeamUnassignedConversations}, order, orderBy, filters, page, pageSize)
 }
 
 // GetMentionedConversationsList retrieves conversations where the user is mentioned (directly or via team).
-func (c *Manager) GetMentionedConversationsList(viewingUserID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
-	return c.GetConversations(viewingUserID, 0, []int{}, []string{models.MentionedConversations}, order, orderBy, filters, page, pageSize)
+func (c *Manager) GetMentionedConversationsList(ctx context.Context, viewingUserID int, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
+	return c.GetConversations(ctx, viewingUserID, 0, []int{}, []string{models.MentionedConversations}, order, orderBy, filters, page, pageSize)
 }
 
 // InsertMentions inserts mentions for a message.
@@ -412,13 +504,13 @@ func (c *Manager) InsertMentions(conversationID, messageID, mentionedByUserID in
 	return nil
 }
 
-func (c *Manager) GetViewConversationsList(viewingUserID, userID int, teamIDs []int, listType []string, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
-	return c.GetConversations(viewingUserID, userID, teamIDs, listType, order, orderBy, filters, page, pageSize)
+func (c *Manager) GetViewConversationsList(ctx context.Context, viewingUserID, userID int, teamIDs []int, listType []string, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
+	return c.GetConversations(ctx, viewingUserID, userID, teamIDs, listType, order, orderBy, filters, page, pageSize)
 }
 
 // GetConversations retrieves conversations list based on user ID, type, and optional filtering, ordering, and pagination.
 // viewingUserID is used to calculate per-agent unread counts.
-func (c *Manager) GetConversations(viewingUserID, userID int, teamIDs []int, listTypes []string, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
+func (c *Manager) GetConversations(ctx context.Context, viewingUserID, userID int, teamIDs []int, listTypes []string, order, orderBy, filters string, page, pageSize int) ([]models.ConversationListItem, error) {
 	var conversations = make([]models.ConversationListItem, 0)
 
 	// Make the query.
@@ -428,7 +520,7 @@ func (c *Manager) GetConversations(viewingUserID, userID int, teamIDs []int, lis
 		return conversations, envelope.NewError(envelope.GeneralError, c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.conversation}"), nil)
 	}
 
-	tx, err := c.db.BeginTxx(context.Background(), &sql.TxOptions{
+	tx, err := c.db.BeginTxx(ctx, &sql.TxOptions{
 		ReadOnly: true,
 	})
 	defer tx.Rollback()
@@ -1129,6 +1221,7 @@ func (m *Manager) ApplyAction(action amodels.RuleAction, conv models.Conversatio
 			cc,
 			bcc,
 			map[string]any{}, /**meta**/
+			null.Time{},      /**send_at**/
 		)
 		if err != nil {
 			return fmt.Errorf("sending reply: %w", err)
@@ -1204,7 +1297,7 @@ func (m *Manager) SendCSATReply(actorUserID int, conversation models.Conversatio
 	}
 
 	// Queue CSAT reply.
-	_, err = m.QueueReply(nil /**media**/, conversation.InboxID, actorUserID, conversation.UUID, message, to, cc, bcc, meta)
+	_, err = m.QueueReply(nil /**media**/, conversation.InboxID, actorUserID, conversation.UUID, message, to, cc, bcc, meta, null.Time{} /**send_at**/)
 	if err != nil {
 		m.lo.Error("error sending CSAT reply", "conversation_uuid", conversation.UUID, "error", err)
 		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.csat}"), nil)
@@ -1421,5 +1514,6 @@ func (c *Manager) makeConversationsListQuery(viewingUserID, userID int, teamIDs
 		"conversations":         conversationsAllowedFields,
 		"conversation_statuses": conversationStatusAllowedFields,
 		"users":                 usersAllowedFields,
+		"organizations":         organizationsAllowedFields,
 	})
 }
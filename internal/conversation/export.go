@@ -0,0 +1,184 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/attachment"
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/template"
+	"github.com/jhillyerd/enmime"
+)
+
+const (
+	ExportFormatJSON = "json"
+	ExportFormatEML  = "eml"
+	ExportFormatHTML = "html"
+)
+
+// ExportFormats are the supported conversation export formats.
+var ExportFormats = []string{ExportFormatJSON, ExportFormatEML, ExportFormatHTML}
+
+// ExportFileName returns the file name of a conversation export in the given format.
+func ExportFileName(referenceNumber, format string) string {
+	return fmt.Sprintf("conversation-%s.%s", referenceNumber, format)
+}
+
+// GetConversationExport returns a conversation with all its messages, private notes and activities.
+func (m *Manager) GetConversationExport(uuid string) (models.ConversationExport, error) {
+	var export = models.ConversationExport{ExportedAt: time.Now()}
+
+	conversation, err := m.GetConversation(0, uuid, "")
+	if err != nil {
+		return export, err
+	}
+	export.Conversation = conversation
+
+	export.Messages = make([]models.ExportMessage, 0)
+	if err := m.q.GetConversationExportMessages.Select(&export.Messages, conversation.ID); err != nil {
+		m.lo.Error("error fetching conversation export messages", "conversation_uuid", uuid, "error", err)
+		return export, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.message}"), nil)
+	}
+	return export, nil
+}
+
+// WriteConversationExport writes a conversation export to w in the given format:
+//   - json: the conversation, messages, notes, activities and attachment details.
+//   - eml: an RFC 5322 multipart/digest message with every public message of the thread, including attachments.
+//   - html: a print-friendly transcript rendered with the conversation transcript template.
+func (m *Manager) WriteConversationExport(w io.Writer, export models.ConversationExport, format string) error {
+	switch format {
+	case ExportFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(export)
+	case ExportFormatEML:
+		return m.writeConversationEML(w, export)
+	case ExportFormatHTML:
+		out, err := m.template.RenderWebTemplate(template.TmplConversationTranscript, &export)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, out)
+		return err
+	}
+	return fmt.Errorf("invalid export format: %s", format)
+}
+
+// writeConversationEML writes the public messages of a conversation as a multipart/digest email thread.
+func (m *Manager) writeConversationEML(w io.Writer, export models.ConversationExport) error {
+	var (
+		conv        = export.Conversation
+		inboxName   = conv.InboxName
+		inboxAddr   = conv.InboxMail
+		contactName = conv.Contact.FullName()
+		contactAddr = conv.Contact.Email.String
+		subject     = conv.Subject.String
+	)
+	if addr, err := mail.ParseAddress(conv.InboxMail); err == nil {
+		inboxAddr = addr.Address
+		if addr.Name != "" {
+			inboxName = addr.Name
+		}
+	}
+	if contactAddr == "" {
+		contactAddr = "unknown@invalid"
+	}
+	_, domain, _ := strings.Cut(inboxAddr, "@")
+	if domain == "" {
+		domain = "libredesk"
+	}
+
+	mw := multipart.NewWriter(w)
+	header := fmt.Sprintf("MIME-Version: 1.0\r\n"+
+		"Date: %s\r\n"+
+		"From: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"Message-ID: <export-%s@%s>\r\n"+
+		"Content-Type: multipart/digest; boundary=%q\r\n\r\n",
+		export.ExportedAt.Format(time.RFC1123Z),
+		(&mail.Address{Name: inboxName, Address: inboxAddr}).String(),
+		(&mail.Address{Name: contactName, Address: contactAddr}).String(),
+		mime.QEncoding.Encode("utf-8", fmt.Sprintf("Conversation #%s: %s", conv.ReferenceNumber, subject)),
+		conv.UUID, domain,
+		mw.Boundary())
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	var references []string
+	for _, msg := range export.Messages {
+		if msg.Private || (msg.Type != models.MessageIncoming && msg.Type != models.MessageOutgoing) {
+			continue
+		}
+
+		messageID := msg.SourceID.String
+		if messageID == "" {
+			messageID = msg.UUID + "@" + domain
+		}
+
+		b := enmime.Builder().
+			Date(msg.CreatedAt).
+			Subject(subject).
+			Header("Message-ID", "<"+messageID+">")
+		if msg.Type == models.MessageIncoming {
+			from := msg.Author.Email.String
+			if from == "" {
+				from = contactAddr
+			}
+			b = b.From(msg.Author.FullName(), from).To(inboxName, inboxAddr)
+		} else {
+			name := msg.Author.FullName()
+			if name == "" {
+				name = inboxName
+			}
+			b = b.From(name, inboxAddr).To(contactName, contactAddr)
+		}
+		if len(references) > 0 {
+			b = b.Header("In-Reply-To", "<"+references[len(references)-1]+">").
+				Header("References", "<"+strings.Join(references, "> <")+">")
+		}
+		if msg.ContentType == models.ContentTypeHTML {
+			b = b.HTML([]byte(msg.Content)).Text([]byte(msg.TextContent))
+		} else {
+			b = b.Text([]byte(msg.Content))
+		}
+
+		for _, a := range msg.Attachments {
+			blob, err := m.mediaStore.GetBlob(a.UUID)
+			if err != nil {
+				m.lo.Error("error fetching attachment for export", "media_uuid", a.UUID, "error", err)
+				continue
+			}
+			if a.Disposition == attachment.DispositionInline && a.ContentID != "" {
+				b = b.AddInline(blob, a.ContentType, a.Name, a.ContentID)
+			} else {
+				b = b.AddAttachment(blob, a.ContentType, a.Name)
+			}
+		}
+
+		part, err := b.Build()
+		if err != nil {
+			m.lo.Error("error building exported message", "message_uuid", msg.UUID, "error", err)
+			continue
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"message/rfc822"}})
+		if err != nil {
+			return err
+		}
+		if err := part.Encode(pw); err != nil {
+			return err
+		}
+		references = append(references, messageID)
+	}
+	return mw.Close()
+}
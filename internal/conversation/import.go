@@ -0,0 +1,118 @@
+package conversation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/dbutil"
+	mmodels "github.com/abhinavxd/libredesk/internal/media/models"
+	"github.com/abhinavxd/libredesk/internal/stringutil"
+)
+
+// ErrMessageExists is returned when an incoming or imported message already exists.
+var ErrMessageExists = errors.New("message already exists")
+
+// ImportMessage inserts a historical message, e.g. from an email archive, with its original timestamp.
+// The message is threaded into an existing conversation using its In-Reply-To and References headers or a new
+// conversation is created for it. Unlike incoming messages, imported messages do not trigger automations, SLAs,
+// webhooks or broadcasts. New conversations are created closed so historical threads never show up as open work.
+// It returns the conversation ID and whether the conversation was created for this message, call
+// FinishImportedConversation for every conversation once the import is done.
+func (m *Manager) ImportMessage(in models.IncomingMessage, createdAt time.Time) (int, bool, error) {
+	if err := m.userStore.CreateContact(&in.Contact); err != nil {
+		return 0, false, fmt.Errorf("upserting contact: %w", err)
+	}
+
+	// Skip messages that were already received or imported.
+	if _, err := m.messageExistsBySourceID([]string{in.Message.SourceID.String}); err == nil {
+		return 0, false, ErrMessageExists
+	} else if err != errConversationNotFound {
+		return 0, false, err
+	}
+
+	// Outgoing messages in the archive were sent from the inbox, attribute them to the system user.
+	in.Message.SenderID = in.Contact.ID
+	if in.Message.Type == models.MessageOutgoing {
+		systemUser, err := m.userStore.GetSystemUser()
+		if err != nil {
+			return 0, false, fmt.Errorf("fetching system user: %w", err)
+		}
+		in.Message.SenderID = systemUser.ID
+	}
+
+	var (
+		msg               = &in.Message
+		isNewConversation bool
+	)
+	conversationID, err := m.messageExistsBySourceID(append([]string{msg.InReplyTo}, msg.References...))
+	if err != nil && err != errConversationNotFound {
+		return 0, false, err
+	}
+	if conversationID > 0 {
+		if msg.ConversationUUID, err = m.GetConversationUUID(conversationID); err != nil {
+			return 0, false, err
+		}
+		msg.ConversationID = conversationID
+	} else {
+		isNewConversation = true
+		msg.ConversationID, msg.ConversationUUID, err = m.createConversation(in.Contact.ID, in.Contact.ContactChannelID, in.InboxID,
+			models.StatusClosed, stringutil.HTML2Text(msg.Content), createdAt, msg.Subject, false /**append reference number to subject**/)
+		if err != nil {
+			return 0, false, fmt.Errorf("creating conversation: %w", err)
+		}
+	}
+
+	if err := m.uploadMessageAttachments(msg); err != nil {
+		if isNewConversation {
+			m.DeleteConversation(msg.ConversationUUID)
+		}
+		return 0, false, fmt.Errorf("uploading message attachments: %w", err)
+	}
+
+	if len(msg.Meta) == 0 {
+		msg.Meta = json.RawMessage(`{}`)
+	}
+	if msg.ContentType == "" {
+		msg.ContentType = models.ContentTypeText
+	}
+	msg.TextContent = stringutil.HTML2Text(msg.Content)
+	if err := m.q.InsertImportedMessage.Get(msg, msg.Type, msg.Status, msg.ConversationID, msg.Content, msg.TextContent,
+		msg.SenderID, msg.SenderType, msg.ContentType, msg.SourceID, msg.Meta, createdAt); err != nil {
+		if isNewConversation {
+			m.DeleteConversation(msg.ConversationUUID)
+		}
+		// Stored concurrently, e.g. received while being imported.
+		if dbutil.IsUniqueViolationError(err) {
+			return 0, false, ErrMessageExists
+		}
+		m.lo.Error("error inserting imported message", "source_id", msg.SourceID.String, "error", err)
+		return 0, false, fmt.Errorf("inserting message: %w", err)
+	}
+
+	for _, media := range msg.Media {
+		m.mediaStore.Attach(media.ID, mmodels.ModelMessages, msg.ID)
+	}
+	m.addConversationParticipant(msg.SenderID, msg.ConversationUUID)
+
+	return msg.ConversationID, isNewConversation, nil
+}
+
+// FinishImportedConversation refreshes the last message of a conversation imported messages were added to.
+// Conversations created by the import are also backdated to their messages.
+func (m *Manager) FinishImportedConversation(id int, created bool) error {
+	if _, err := m.q.RefreshConversationLastMessage.Exec(id); err != nil {
+		m.lo.Error("error refreshing conversation last message", "conversation_id", id, "error", err)
+		return err
+	}
+	if !created {
+		return nil
+	}
+	if _, err := m.q.FinishImportedConversation.Exec(id); err != nil {
+		m.lo.Error("error finishing imported conversation", "conversation_id", id, "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package conversation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// incomingPollInterval is how often idle incoming message workers check the queue for messages enqueued by
+	// other instances and messages due for a retry.
+	incomingPollInterval = 2 * time.Second
+
+	// incomingRetryDelay is the delay before a failed incoming message is processed again, multiplied by the
+	// number of attempts.
+	incomingRetryDelay = time.Minute
+)
+
+// queuedIncomingMessage is an incoming message claimed from the queue.
+type queuedIncomingMessage struct {
+	ID       int    `db:"id"`
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+}
+
+// EnqueueIncoming stores an incoming message in the incoming queue to be processed by the incoming message workers
+// of any instance. Messages already in the queue are skipped. The trace of ctx is continued by the processing.
+func (m *Manager) EnqueueIncoming(ctx context.Context, message models.IncomingMessage) (err error) {
+	m.closedMu.RLock()
+	defer m.closedMu.RUnlock()
+	if m.closed {
+		return errors.New("incoming message queue is closed")
+	}
+
+	ctx, span := tracing.Start(ctx, "conversation.enqueue_incoming", attribute.String("message.source_id", message.Message.SourceID.String))
+	defer func() { tracing.End(span, err) }()
+	message.TraceContext = tracing.Inject(ctx)
+
+	payload, err := models.EncodeIncomingMessage(message)
+	if err != nil {
+		m.lo.Error("error encoding incoming message", "source_id", message.Message.SourceID.String, "error", err)
+		return err
+	}
+	if _, err := m.q.EnqueueIncomingMessage.ExecContext(ctx, message.InboxID, message.Message.SourceID.String, payload); err != nil {
+		m.lo.Error("error enqueuing incoming message", "source_id", message.Message.SourceID.String, "error", err)
+		return err
+	}
+
+	select {
+	case m.incomingNotify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// IncomingMessageWorker processes messages from the incoming message queue until the context is cancelled.
+func (m *Manager) IncomingMessageWorker(ctx context.Context) {
+	ticker := time.NewTicker(incomingPollInterval)
+	defer ticker.Stop()
+	for {
+		// Keep processing while there are messages in the queue.
+		if m.processNextIncomingMessage() {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.incomingNotify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// IncomingQueueStats returns the depth and age of the incoming message queue.
+func (m *Manager) IncomingQueueStats() (models.IncomingQueueStats, error) {
+	var stats models.IncomingQueueStats
+	if err := m.q.GetIncomingQueueStats.Get(&stats); err != nil {
+		m.lo.Error("error fetching incoming queue stats", "error", err)
+		return stats, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.message}"), nil)
+	}
+	return stats, nil
+}
+
+// processNextIncomingMessage claims the next visible message from the queue and processes it. The message is
+// deleted from the queue once processed, otherwise it is retried later or moved to the dead-letter queue after
+// the maximum number of attempts. It returns false if there was no message to process.
+func (m *Manager) processNextIncomingMessage() bool {
+	var qm queuedIncomingMessage
+	if err := m.q.ClaimIncomingMessage.Get(&qm, m.incomingVisibilityTimeout.Milliseconds()); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			m.lo.Error("error claiming incoming message", "error", err)
+		}
+		return false
+	}
+
+	in, err := models.DecodeIncomingMessage(qm.Payload)
+	if err != nil {
+		// Nothing to retry or dead-letter without the message, drop it.
+		m.lo.Error("error decoding queued incoming message, dropping it", "id", qm.ID, "error", err)
+		m.deleteIncomingMessage(qm.ID)
+		return true
+	}
+
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), in.TraceContext), "conversation.process_incoming",
+		attribute.String("message.source_id", in.Message.SourceID.String), attribute.Int("queue.attempt", qm.Attempts))
+	start := time.Now()
+	stop := m.extendIncomingVisibility(qm.ID)
+	procErr := m.processIncomingMessage(ctx, in)
+	stop()
+	metrics.GetOrCreateHistogram(`libredesk_incoming_message_processing_duration_seconds`).UpdateDuration(start)
+	tracing.End(span, procErr)
+	if procErr == nil {
+		metrics.GetOrCreateCounter(`libredesk_incoming_messages_processed_total{status="success"}`).Inc()
+		m.deleteIncomingMessage(qm.ID)
+		return true
+	}
+
+	m.lo.Error("error processing incoming msg", "source_id", in.Message.SourceID.String, "attempt", qm.Attempts, "error", procErr)
+	if qm.Attempts < m.incomingMaxAttempts {
+		metrics.GetOrCreateCounter(`libredesk_incoming_messages_processed_total{status="retry"}`).Inc()
+		delay := time.Duration(qm.Attempts) * incomingRetryDelay
+		if _, err := m.q.DeferIncomingMessage.Exec(qm.ID, procErr.Error(), delay.Milliseconds()); err != nil {
+			m.lo.Error("error deferring incoming message", "id", qm.ID, "error", err)
+		}
+		return true
+	}
+
+	// Keep the message in the queue if it could not be dead-lettered, it is retried after the visibility timeout.
+	if err := m.deadLetterStore.Add(in, procErr); err != nil {
+		return true
+	}
+	metrics.GetOrCreateCounter(`libredesk_incoming_messages_processed_total{status="dead_letter"}`).Inc()
+	m.deleteIncomingMessage(qm.ID)
+	return true
+}
+
+// extendIncomingVisibility keeps extending the visibility timeout of a message being processed until the returned
+// function is called, so slow processing, e.g. malware and spam scans, does not let another worker claim it.
+func (m *Manager) extendIncomingVisibility(id int) func() {
+	var (
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		heartbeat := time.NewTicker(m.incomingVisibilityTimeout / 3)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-heartbeat.C:
+				if _, err := m.q.ExtendIncomingMessage.Exec(id, m.incomingVisibilityTimeout.Milliseconds()); err != nil {
+					m.lo.Error("error extending incoming message visibility", "id", id, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// deleteIncomingMessage deletes a message from the incoming queue.
+func (m *Manager) deleteIncomingMessage(id int) {
+	if _, err := m.q.DeleteIncomingMessage.Exec(id); err != nil {
+		m.lo.Error("error deleting incoming message from queue", "id", id, "error", err)
+	}
+}
+
+// incomingMessageQueued returns true if a message with the source ID is waiting in the incoming queue.
+func (m *Manager) incomingMessageQueued(sourceID string) (bool, error) {
+	var queued bool
+	if err := m.q.IncomingMessageQueued.Get(&queued, sourceID); err != nil {
+		m.lo.Error("error checking if message is queued", "source_id", sourceID, "error", err)
+		return false, err
+	}
+	return queued, nil
+}
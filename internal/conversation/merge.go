@@ -0,0 +1,134 @@
+package conversation
+
+import (
+	"context"
+
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	umodels "github.com/abhinavxd/libredesk/internal/user/models"
+	"github.com/lib/pq"
+	"github.com/volatiletech/null/v9"
+)
+
+const (
+	// maxMergeHops limits how many merge pointers are followed when resolving a merged conversation.
+	maxMergeHops = 10
+)
+
+// MergeConversations merges the secondary conversations into the primary conversation. Messages (with their media),
+// mentions, participants, tags and drafts are moved to the primary conversation and the secondary conversations are
+// closed with a pointer to the primary one. As message source IDs move along with the messages, email replies to any
+// of the merged threads land in the primary conversation.
+func (m *Manager) MergeConversations(primaryUUID string, secondaryUUIDs []string, actor umodels.User) (models.Conversation, error) {
+	primary, err := m.GetConversation(0, primaryUUID, "")
+	if err != nil {
+		return primary, err
+	}
+	if primary.MergedIntoID.Valid {
+		return primary, envelope.NewError(envelope.InputError, m.i18n.T("conversation.alreadyMerged"), nil)
+	}
+
+	var (
+		secondaries = make([]models.Conversation, 0, len(secondaryUUIDs))
+		ids         = []int{primary.ID}
+		seen        = make(map[string]struct{}, len(secondaryUUIDs))
+	)
+	for _, uuid := range secondaryUUIDs {
+		if _, ok := seen[uuid]; ok {
+			continue
+		}
+		seen[uuid] = struct{}{}
+
+		if uuid == primary.UUID {
+			return primary, envelope.NewError(envelope.InputError, m.i18n.T("conversation.cannotMergeIntoItself"), nil)
+		}
+		secondary, err := m.GetConversation(0, uuid, "")
+		if err != nil {
+			return primary, err
+		}
+		if secondary.MergedIntoID.Valid {
+			return primary, envelope.NewError(envelope.InputError, m.i18n.T("conversation.alreadyMerged"), nil)
+		}
+		secondaries = append(secondaries, secondary)
+		ids = append(ids, secondary.ID)
+	}
+	if len(secondaries) == 0 {
+		return primary, envelope.NewError(envelope.InputError, m.i18n.Ts("globals.messages.empty", "name", "`conversation_uuids`"), nil)
+	}
+
+	tx, err := m.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		m.lo.Error("error beginning merge conversations transaction", "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorMerging"), nil)
+	}
+	defer tx.Rollback()
+
+	// Lock the conversations and check again that none of them got merged meanwhile, e.g. by a concurrent
+	// merge in the opposite direction.
+	var locked []struct {
+		ID           int      `db:"id"`
+		MergedIntoID null.Int `db:"merged_into_id"`
+	}
+	if err := tx.Stmtx(m.q.LockConversationsForMerge).Select(&locked, pq.Array(ids)); err != nil {
+		m.lo.Error("error locking conversations for merge", "primary_uuid", primary.UUID, "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorMerging"), nil)
+	}
+	for _, c := range locked {
+		if c.MergedIntoID.Valid {
+			return primary, envelope.NewError(envelope.InputError, m.i18n.T("conversation.alreadyMerged"), nil)
+		}
+	}
+
+	for _, secondary := range secondaries {
+		res, err := tx.Stmtx(m.q.MergeConversation).Exec(secondary.ID, primary.ID)
+		if err != nil {
+			m.lo.Error("error merging conversation", "primary_uuid", primary.UUID, "secondary_uuid", secondary.UUID, "error", err)
+			return primary, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorMerging"), nil)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return primary, envelope.NewError(envelope.InputError, m.i18n.T("conversation.alreadyMerged"), nil)
+		}
+	}
+	if _, err := tx.Stmtx(m.q.RefreshConversationLastMessage).Exec(primary.ID); err != nil {
+		m.lo.Error("error refreshing conversation last message", "conversation_id", primary.ID, "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorMerging"), nil)
+	}
+	if err := tx.Commit(); err != nil {
+		m.lo.Error("error committing merge conversations transaction", "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorMerging"), nil)
+	}
+
+	// Record activities on both conversations and close the secondaries like any other status change, so
+	// webhooks, automations and SLAs see them closed.
+	for _, secondary := range secondaries {
+		m.lo.Info("merged conversation", "primary_uuid", primary.UUID, "secondary_uuid", secondary.UUID, "actor_id", actor.ID)
+		if err := m.InsertConversationActivity(models.ActivityConversationMerged, primary.UUID, secondary.ReferenceNumber, actor); err != nil {
+			m.lo.Error("error inserting merge activity", "conversation_uuid", primary.UUID, "error", err)
+		}
+		if err := m.InsertConversationActivity(models.ActivityMergedInto, secondary.UUID, primary.ReferenceNumber, actor); err != nil {
+			m.lo.Error("error inserting merge activity", "conversation_uuid", secondary.UUID, "error", err)
+		}
+		m.BroadcastConversationUpdate(secondary.UUID, "merged_into_uuid", primary.UUID)
+		if err := m.UpdateConversationStatus(secondary.UUID, 0, models.StatusClosed, "", actor); err != nil {
+			m.lo.Error("error closing merged conversation", "conversation_uuid", secondary.UUID, "error", err)
+		}
+	}
+
+	return m.GetConversation(primary.ID, "", "")
+}
+
+// resolveMergedConversation follows the merge pointers of a conversation and returns the conversation
+// it was (eventually) merged into, or the conversation itself if it was never merged.
+func (m *Manager) resolveMergedConversation(conversation models.Conversation) (models.Conversation, error) {
+	for range maxMergeHops {
+		if !conversation.MergedIntoID.Valid {
+			return conversation, nil
+		}
+		primary, err := m.GetConversation(conversation.MergedIntoID.Int, "", "")
+		if err != nil {
+			return conversation, err
+		}
+		conversation = primary
+	}
+	return conversation, nil
+}
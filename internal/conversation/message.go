@@ -12,30 +12,39 @@ import (
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/abhinavxd/libredesk/internal/attachment"
 	amodels "github.com/abhinavxd/libredesk/internal/automation/models"
+	brmodels "github.com/abhinavxd/libredesk/internal/blockrule/models"
 	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/dbutil"
 	"github.com/abhinavxd/libredesk/internal/envelope"
 	"github.com/abhinavxd/libredesk/internal/image"
 	"github.com/abhinavxd/libredesk/internal/inbox"
 	mmodels "github.com/abhinavxd/libredesk/internal/media/models"
 	"github.com/abhinavxd/libredesk/internal/sla"
 	"github.com/abhinavxd/libredesk/internal/stringutil"
+	"github.com/abhinavxd/libredesk/internal/tracing"
 	umodels "github.com/abhinavxd/libredesk/internal/user/models"
 	wmodels "github.com/abhinavxd/libredesk/internal/webhook/models"
 	"github.com/lib/pq"
 	"github.com/volatiletech/null/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
 	maxMessagesPerPage = 100
 )
 
-// Run starts a pool of worker goroutines to handle message dispatching via inbox's channel and processes incoming messages. It scans for
-// pending outgoing messages at the specified read interval and pushes them to the outgoing queue to be sent.
+// Run starts a pool of worker goroutines to handle message dispatching via inbox's channel and processes incoming messages. It claims
+// pending outgoing messages at the specified read interval and pushes them to the outgoing queue to be sent. Claims are held with a
+// lease that is extended while the messages are in flight, so each message is sent by a single instance and the claims of crashed
+// instances are picked up by others once their lease expires.
 func (m *Manager) Run(ctx context.Context, incomingQWorkers, outgoingQWorkers, scanInterval time.Duration) {
 	dbScanner := time.NewTicker(scanInterval)
 	defer dbScanner.Stop()
+	heartbeat := time.NewTicker(m.outgoingClaimLease / 3)
+	defer heartbeat.Stop()
 
 	for range outgoingQWorkers {
 		m.wg.Add(1)
@@ -57,30 +66,41 @@ func (m *Manager) Run(ctx context.Context, incomingQWorkers, outgoingQWorkers, s
 		select {
 		case <-ctx.Done():
 			return
+		case <-heartbeat.C:
+			if _, err := m.q.ExtendOutgoingMessageClaims.Exec(m.nodeID, m.outgoingClaimLease.Milliseconds()); err != nil {
+				m.lo.Error("error extending outgoing message claims", "error", err)
+			}
 		case <-dbScanner.C:
-			var (
-				pendingMessages = []models.Message{}
-				messageIDs      = m.getOutgoingProcessingMessageIDs()
-			)
-
-			// Get pending outgoing messages and skip the currently processing message ids.
-			if err := m.q.GetOutgoingPendingMessages.Select(&pendingMessages, pq.Array(messageIDs)); err != nil {
-				m.lo.Error("error fetching pending messages from db", "error", err)
+			// Claim only as many messages as the queue can take so claimed messages never wait on a full queue.
+			limit := cap(m.outgoingMessageQueue) - len(m.outgoingMessageQueue)
+			if limit <= 0 {
 				continue
 			}
 
-			// Prepare and push the message to the outgoing queue.
-			for _, message := range pendingMessages {
-				// Put the message ID in the processing map.
-				m.outgoingProcessingMessages.Store(message.ID, message.ID)
+			var pendingMessages = []models.Message{}
+			if err := m.q.ClaimOutgoingPendingMessages.Select(&pendingMessages, m.nodeID, m.outgoingClaimLease.Milliseconds(), limit); err != nil {
+				m.lo.Error("error claiming pending messages from db", "error", err)
+				continue
+			}
 
-				// Push the message to the outgoing message queue.
+			// Push the claimed messages to the outgoing message queue.
+			for _, message := range pendingMessages {
 				m.outgoingMessageQueue <- message
 			}
 		}
 	}
 }
 
+// OutgoingQueueLength returns the number of claimed outgoing messages waiting to be sent by this instance.
+func (m *Manager) OutgoingQueueLength() int {
+	return len(m.outgoingMessageQueue)
+}
+
+// OutgoingQueueCapacity returns the number of outgoing messages this instance can hold waiting to be sent.
+func (m *Manager) OutgoingQueueCapacity() int {
+	return cap(m.outgoingMessageQueue)
+}
+
 // Close signals the Manager to stop processing messages, closes channels,
 // and waits for all worker goroutines to finish processing.
 func (m *Manager) Close() {
@@ -88,24 +108,11 @@ func (m *Manager) Close() {
 	defer m.closedMu.Unlock()
 	m.closed = true
 	close(m.outgoingMessageQueue)
-	close(m.incomingMessageQueue)
 	m.wg.Wait()
-}
 
-// IncomingMessageWorker processes incoming messages from the incoming message queue.
-func (m *Manager) IncomingMessageWorker(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case msg, ok := <-m.incomingMessageQueue:
-			if !ok {
-				return
-			}
-			if err := m.processIncomingMessage(msg); err != nil {
-				m.lo.Error("error processing incoming msg", "error", err)
-			}
-		}
+	// Release the claims of the outgoing messages that were not sent so other instances pick them up right away.
+	if _, err := m.q.ReleaseOutgoingMessageClaims.Exec(m.nodeID); err != nil {
+		m.lo.Error("error releasing outgoing message claims", "error", err)
 	}
 }
 
@@ -126,13 +133,18 @@ func (m *Manager) MessageSenderWorker(ctx context.Context) {
 
 // sendOutgoingMessage sends an outgoing message.
 func (m *Manager) sendOutgoingMessage(message models.Message) {
-	defer m.outgoingProcessingMessages.Delete(message.ID)
+	start := time.Now()
+	ctx, span := tracing.Start(context.Background(), "conversation.send_outgoing",
+		attribute.String("message.uuid", message.UUID), attribute.Int("inbox.id", message.InboxID))
+	defer span.End()
 
 	// Helper function to handle errors
 	handleError := func(err error, errorMsg string) bool {
 		if err != nil {
+			tracing.SetError(span, err)
 			m.lo.Error(errorMsg, "error", err, "message_id", message.ID)
 			m.UpdateMessageStatus(message.UUID, models.MessageStatusFailed)
+			metrics.GetOrCreateCounter(`libredesk_outgoing_messages_sent_total{status="failed"}`).Inc()
 			return true
 		}
 		return false
@@ -177,13 +189,17 @@ func (m *Manager) sendOutgoingMessage(message models.Message) {
 	}
 
 	// Send message
+	_, sendSpan := tracing.Start(ctx, "inbox.send", attribute.String("inbox.channel", inbox.Channel()))
 	err = inbox.Send(message)
+	tracing.End(sendSpan, err)
 	if handleError(err, "error sending message") {
 		return
 	}
 
 	// Update status.
 	m.UpdateMessageStatus(message.UUID, models.MessageStatusSent)
+	metrics.GetOrCreateCounter(`libredesk_outgoing_messages_sent_total{status="sent"}`).Inc()
+	metrics.GetOrCreateHistogram(`libredesk_outgoing_message_send_duration_seconds`).UpdateDuration(start)
 
 	// Skip system user replies since we only update timestamps and SLA for human replies.
 	systemUser, err := m.userStore.GetSystemUser()
@@ -286,7 +302,7 @@ func (m *Manager) RenderMessageInTemplate(channel string, message *models.Messag
 }
 
 // GetConversationMessages retrieves messages for a specific conversation.
-func (m *Manager) GetConversationMessages(conversationUUID string, page, pageSize int, private *bool, msgTypes []string) ([]models.Message, int, error) {
+func (m *Manager) GetConversationMessages(ctx context.Context, conversationUUID string, page, pageSize int, private *bool, msgTypes []string) ([]models.Message, int, error) {
 	var (
 		messages = make([]models.Message, 0)
 		qArgs    []any
@@ -305,7 +321,7 @@ func (m *Manager) GetConversationMessages(conversationUUID string, page, pageSiz
 		return messages, pageSize, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.message}"), nil)
 	}
 
-	tx, err := m.db.BeginTxx(context.Background(), &sql.TxOptions{
+	tx, err := m.db.BeginTxx(ctx, &sql.TxOptions{
 		ReadOnly: true,
 	})
 	defer tx.Rollback()
@@ -324,8 +340,13 @@ func (m *Manager) GetConversationMessages(conversationUUID string, page, pageSiz
 
 // GetMessage retrieves a message by UUID.
 func (m *Manager) GetMessage(uuid string) (models.Message, error) {
+	return m.GetMessageContext(context.Background(), uuid)
+}
+
+// GetMessageContext is GetMessage tracing the query in the trace of ctx, e.g. of an HTTP request.
+func (m *Manager) GetMessageContext(ctx context.Context, uuid string) (models.Message, error) {
 	var message models.Message
-	if err := m.q.GetMessage.Get(&message, uuid); err != nil {
+	if err := m.q.GetMessage.GetContext(ctx, &message, uuid); err != nil {
 		m.lo.Error("error fetching message", "uuid", uuid, "error", err)
 		return message, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.message}"), nil)
 	}
@@ -409,8 +430,9 @@ func (m *Manager) CreateContactMessage(media []mmodels.Media, contactID int, con
 	return message, nil
 }
 
-// QueueReply queues a reply message in a conversation.
-func (m *Manager) QueueReply(media []mmodels.Media, inboxID, senderID int, conversationUUID, content string, to, cc, bcc []string, meta map[string]interface{}) (models.Message, error) {
+// QueueReply queues a reply message in a conversation. If sendAt is set, the message
+// is held back by the outgoing scanner until that time.
+func (m *Manager) QueueReply(media []mmodels.Media, inboxID, senderID int, conversationUUID, content string, to, cc, bcc []string, meta map[string]interface{}, sendAt null.Time) (models.Message, error) {
 	var (
 		message = models.Message{}
 	)
@@ -461,6 +483,7 @@ func (m *Manager) QueueReply(media []mmodels.Media, inboxID, senderID int, conve
 		Media:            media,
 		Meta:             metaJSON,
 		SourceID:         null.StringFrom(sourceID),
+		SendAt:           sendAt,
 	}
 	if err := m.InsertMessage(&message); err != nil {
 		return models.Message{}, err
@@ -468,8 +491,118 @@ func (m *Manager) QueueReply(media []mmodels.Media, inboxID, senderID int, conve
 	return message, nil
 }
 
+// UndoSendAt returns the time until which an agent reply queued now can still be cancelled,
+// or a null time if the undo-send window is disabled.
+func (m *Manager) UndoSendAt() null.Time {
+	if m.undoSendWindow <= 0 {
+		return null.Time{}
+	}
+	return null.TimeFrom(time.Now().Add(m.undoSendWindow))
+}
+
+// CancelScheduledMessage cancels a pending outgoing message that is not yet due for sending
+// and returns its content and attachments to the user's draft for the conversation.
+func (m *Manager) CancelScheduledMessage(uuid string, userID int) (models.ConversationDraft, error) {
+	// Fetch the full message for the webhook payload, it's gone once cancelled.
+	message, err := m.GetMessage(uuid)
+	if err != nil {
+		return models.ConversationDraft{}, err
+	}
+
+	tx, err := m.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		m.lo.Error("error beginning cancel scheduled message transaction", "error", err)
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.message}"), nil)
+	}
+	defer tx.Rollback()
+
+	var deleted models.Message
+	if err := tx.Stmtx(m.q.DeleteScheduledMessage).Get(&deleted, uuid); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ConversationDraft{}, envelope.NewError(envelope.InputError, m.i18n.T("conversation.messageCannotBeCancelled"), nil)
+		}
+		m.lo.Error("error deleting scheduled message", "uuid", uuid, "error", err)
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.message}"), nil)
+	}
+
+	var medias []mmodels.Media
+	if err := tx.Stmtx(m.q.GetMessageAttachments).Select(&medias, deleted.ID); err != nil {
+		m.lo.Error("error fetching cancelled message media", "message_id", deleted.ID, "error", err)
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.media}"), nil)
+	}
+	var attachments = make([]map[string]any, 0, len(medias))
+	for _, media := range medias {
+		attachments = append(attachments, map[string]any{
+			"id":           media.ID,
+			"size":         media.Size,
+			"uuid":         media.UUID,
+			"filename":     media.Filename,
+			"content_type": media.ContentType,
+		})
+	}
+
+	// Carry over the recipients and attachments to the draft.
+	var meta = map[string]any{}
+	if err := json.Unmarshal(deleted.Meta, &meta); err != nil {
+		m.lo.Error("error unmarshalling cancelled message meta", "message_id", deleted.ID, "error", err)
+	}
+	draftMeta := map[string]any{}
+	for _, key := range []string{"to", "cc", "bcc"} {
+		if v, ok := meta[key]; ok {
+			draftMeta[key] = v
+		}
+	}
+	if len(attachments) > 0 {
+		draftMeta["attachments"] = attachments
+	}
+	draftMetaJSON, err := json.Marshal(draftMeta)
+	if err != nil {
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorMarshalling", "name", "{globals.terms.meta}"), nil)
+	}
+
+	var draft models.ConversationDraft
+	if err := tx.Stmtx(m.q.UpsertConversationDraft).Get(&draft, deleted.ConversationID, userID, deleted.Content, draftMetaJSON); err != nil {
+		m.lo.Error("error upserting conversation draft", "conversation_id", deleted.ConversationID, "user_id", userID, "error", err)
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "draft"), nil)
+	}
+
+	// Link the attachments to the draft so they are not purged as unlinked media.
+	if _, err := tx.Stmtx(m.q.MoveMessageAttachmentsToDraft).Exec(deleted.ID, draft.ID); err != nil {
+		m.lo.Error("error moving cancelled message media to draft", "message_id", deleted.ID, "draft_id", draft.ID, "error", err)
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "draft"), nil)
+	}
+
+	if _, err := tx.Stmtx(m.q.RefreshConversationLastMessage).Exec(deleted.ConversationID); err != nil {
+		m.lo.Error("error refreshing conversation last message", "conversation_id", deleted.ConversationID, "error", err)
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.message}"), nil)
+	}
+
+	if err := tx.Commit(); err != nil {
+		m.lo.Error("error committing cancel scheduled message transaction", "error", err)
+		return models.ConversationDraft{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.message}"), nil)
+	}
+
+	// Let conversation subscribers drop the cancelled message and update the last message.
+	draft.ConversationUUID = message.ConversationUUID
+	m.BroadcastMessageDeleted(message.ConversationUUID, uuid)
+	if conversation, err := m.GetConversation(deleted.ConversationID, "", ""); err == nil {
+		m.BroadcastConversationUpdate(conversation.UUID, "last_message", conversation.LastMessage)
+		m.BroadcastConversationUpdate(conversation.UUID, "last_message_at", conversation.LastMessageAt)
+	}
+
+	m.webhookStore.TriggerEvent(wmodels.EventMessageDeleted, message)
+
+	return draft, nil
+}
+
 // InsertMessage inserts a message and attaches the media to the message.
 func (m *Manager) InsertMessage(message *models.Message) error {
+	return m.InsertMessageContext(context.Background(), message)
+}
+
+// InsertMessageContext is InsertMessage tracing the insert in the trace of ctx, which is continued by the
+// webhook deliveries.
+func (m *Manager) InsertMessageContext(ctx context.Context, message *models.Message) error {
 	if message.Private {
 		message.Status = models.MessageStatusSent
 	}
@@ -486,10 +619,13 @@ func (m *Manager) InsertMessage(message *models.Message) error {
 	message.TextContent = stringutil.HTML2Text(message.Content)
 
 	// Insert and scan the message into the struct.
-	if err := m.q.InsertMessage.Get(message,
+	if err := m.q.InsertMessage.GetContext(ctx, message,
 		message.Type, message.Status, message.ConversationID, message.ConversationUUID,
 		message.Content, message.TextContent, message.SenderID, message.SenderType,
-		message.Private, message.ContentType, message.SourceID, message.Meta); err != nil {
+		message.Private, message.ContentType, message.SourceID, message.Meta, message.SendAt); err != nil {
+		if dbutil.IsUniqueViolationError(err) {
+			return ErrMessageExists
+		}
 		m.lo.Error("error inserting message in db", "error", err)
 		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorInserting", "name", "{globals.terms.message}"), nil)
 	}
@@ -523,7 +659,7 @@ func (m *Manager) InsertMessage(message *models.Message) error {
 	}
 
 	// Trigger webhook for new message created.
-	m.webhookStore.TriggerEvent(wmodels.EventMessageCreated, message)
+	m.webhookStore.TriggerEventContext(ctx, wmodels.EventMessageCreated, message)
 
 	return nil
 }
@@ -633,6 +769,14 @@ func (m *Manager) getMessageActivityContent(activityType, newValue, actorName st
 		content = fmt.Sprintf("%s removed tag %s", actorName, newValue)
 	case models.ActivitySLASet:
 		content = fmt.Sprintf("%s set %s SLA policy", actorName, newValue)
+	case models.ActivityConversationMerged:
+		content = fmt.Sprintf("%s merged conversation #%s into this conversation", actorName, newValue)
+	case models.ActivityMergedInto:
+		content = fmt.Sprintf("%s merged this conversation into #%s", actorName, newValue)
+	case models.ActivityConversationSplit:
+		content = fmt.Sprintf("%s split messages into conversation #%s", actorName, newValue)
+	case models.ActivitySplitFrom:
+		content = fmt.Sprintf("%s split this conversation from #%s", actorName, newValue)
 	default:
 		return "", fmt.Errorf("invalid activity type %s", activityType)
 	}
@@ -643,7 +787,18 @@ func (m *Manager) getMessageActivityContent(activityType, newValue, actorName st
 // associated contact. It finds or creates the contact, checks for existing
 // conversations, and creates a new conversation if necessary. It also
 // inserts the message, uploads any attachments, and queues the conversation evaluation of automation rules.
-func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
+// The trace of ctx is continued by the automation rule evaluation and webhook deliveries.
+func (m *Manager) processIncomingMessage(ctx context.Context, in models.IncomingMessage) error {
+	// Drop messages from senders matching a block rule, or let them through to be closed right away.
+	rule, blocked := m.blockRuleStore.Match(in.Contact.Email.String)
+	if blocked {
+		m.blockRuleStore.RecordBlocked(rule, in.InboxID, in.Message.SourceID.String, in.Contact.Email.String)
+		if rule.Action == brmodels.ActionDrop {
+			m.lo.Info("dropping message from blocked sender", "rule_id", rule.ID, "sender", in.Contact.Email.String, "message_source_id", in.Message.SourceID.String)
+			return nil
+		}
+	}
+
 	// Find or create contact and set sender ID in message.
 	if err := m.userStore.CreateContact(&in.Contact); err != nil {
 		m.lo.Error("error upserting contact", "error", err)
@@ -651,7 +806,8 @@ func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
 	}
 	in.Message.SenderID = in.Contact.ID
 
-	// Message exists by source ID?
+	// Message exists by source ID? This skips the work for known messages, a message being stored concurrently is
+	// caught by the unique source ID of incoming messages on insert.
 	conversationID, err := m.messageExistsBySourceID([]string{in.Message.SourceID.String})
 	if err != nil && err != errConversationNotFound {
 		return err
@@ -674,6 +830,10 @@ func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
 
 		// Verify sender email matches conversation contact
 		if strings.EqualFold(conversation.Contact.Email.String, in.Contact.Email.String) {
+			// Replies to a merged conversation land in the conversation it was merged into.
+			if conversation, err = m.resolveMergedConversation(conversation); err != nil {
+				return fmt.Errorf("resolving merged conversation: %w", err)
+			}
 			in.Message.ConversationID = conversation.ID
 			in.Message.ConversationUUID = conversation.UUID
 			m.lo.Debug("matched conversation by plus-addressed Reply-To",
@@ -699,7 +859,10 @@ func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
 				}
 			}
 			if conversation.Contact.Email.String != "" && strings.EqualFold(conversation.Contact.Email.String, in.Contact.Email.String) {
-				// Conversation found and contact email matches, use this conversation.
+				// Conversation found and contact email matches, use this conversation or the one it was merged into.
+				if conversation, err = m.resolveMergedConversation(conversation); err != nil {
+					return fmt.Errorf("resolving merged conversation: %w", err)
+				}
 				in.Message.ConversationID = conversation.ID
 				in.Message.ConversationUUID = conversation.UUID
 				m.lo.Debug("matched conversation by reference number in subject", "reference_number", refNum, "contact_email", in.Contact.Email.String)
@@ -711,14 +874,20 @@ func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
 
 	// If conversation not matched via reference number, find conversation using references and in-reply-to headers else create a new one.
 	if in.Message.ConversationID == 0 {
-		isNewConversation, err = m.findOrCreateConversation(&in.Message, in.InboxID, in.Contact.ContactChannelID, in.Contact.ID)
+		_, span := tracing.Start(ctx, "conversation.find_or_create")
+		isNewConversation, err = m.findOrCreateConversation(&in, blocked)
+		span.SetAttributes(attribute.Bool("conversation.new", isNewConversation))
+		tracing.End(span, err)
 		if err != nil {
 			return err
 		}
 	}
 
 	// Upload message attachments, on failure delete the conversation if it was just created for this message.
-	if upErr := m.uploadMessageAttachments(&in.Message); upErr != nil {
+	_, span := tracing.Start(ctx, "conversation.upload_attachments", attribute.Int("attachments", len(in.Message.Attachments)))
+	upErr := m.uploadMessageAttachments(&in.Message)
+	tracing.End(span, upErr)
+	if upErr != nil {
 		m.lo.Error("error uploading message attachments", "message_source_id", in.Message.SourceID, "error", upErr)
 		if isNewConversation && in.Message.ConversationUUID != "" {
 			m.lo.Info("deleting conversation as message attachment upload failed", "conversation_uuid", in.Message.ConversationUUID, "message_source_id", in.Message.SourceID)
@@ -730,16 +899,38 @@ func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
 	}
 
 	// Insert message.
-	if err = m.InsertMessage(&in.Message); err != nil {
+	insertCtx, span := tracing.Start(ctx, "conversation.insert_message", attribute.String("conversation.uuid", in.Message.ConversationUUID))
+	err = m.InsertMessageContext(insertCtx, &in.Message)
+	if errors.Is(err, ErrMessageExists) {
+		tracing.End(span, nil)
+		m.lo.Info("skipping message already stored", "message_source_id", in.Message.SourceID.String)
+		if isNewConversation {
+			if err := m.DeleteConversation(in.Message.ConversationUUID); err != nil {
+				return fmt.Errorf("error deleting conversation created for an already stored message: %w", err)
+			}
+		}
+		return nil
+	}
+	tracing.End(span, err)
+	if err != nil {
 		return err
 	}
 
-	// Evaluate automation rules & send webhook events.
+	// Keep the original email to download it and to reprocess the message if parsing mangled it.
+	m.InsertMessageSource(in.Message.ID, in.Raw)
+
+	// Conversations of blocked senders are closed, skip automations, webhooks and reopening.
+	if blocked {
+		m.lo.Info("closed message from blocked sender", "rule_id", rule.ID, "sender", in.Contact.Email.String, "conversation_uuid", in.Message.ConversationUUID)
+		return nil
+	}
+
+	// Evaluate automation rules & send webhook events, quarantined conversations get them when released.
 	if isNewConversation {
 		conversation, err := m.GetConversation(in.Message.ConversationID, "", "")
-		if err == nil {
-			m.webhookStore.TriggerEvent(wmodels.EventConversationCreated, conversation)
-			m.automation.EvaluateNewConversationRules(conversation)
+		if err == nil && conversation.Status.String != models.StatusQuarantined {
+			m.webhookStore.TriggerEventContext(ctx, wmodels.EventConversationCreated, conversation)
+			m.automation.EvaluateNewConversationRulesContext(ctx, conversation)
 		}
 		return nil
 	}
@@ -763,9 +954,11 @@ func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
 	conversation, err := m.GetConversation(in.Message.ConversationID, "", "")
 	if err != nil {
 		m.lo.Error("error fetching conversation", "conversation_id", in.Message.ConversationID, "error", err)
+	} else if conversation.Status.String == models.StatusQuarantined {
+		m.lo.Debug("skipping automations and SLA for message in quarantined conversation", "conversation_id", conversation.ID)
 	} else {
 		// Trigger automations on incoming message event.
-		m.automation.EvaluateConversationUpdateRules(conversation, amodels.EventConversationMessageIncoming)
+		m.automation.EvaluateConversationUpdateRulesContext(ctx, conversation, amodels.EventConversationMessageIncoming)
 
 		if conversation.SLAPolicyID.Int == 0 {
 			m.lo.Info("no SLA policy applied to conversation, skipping next response SLA event creation")
@@ -783,12 +976,24 @@ func (m *Manager) processIncomingMessage(in models.IncomingMessage) error {
 	return nil
 }
 
-// MessageExists checks if a message with the given messageID exists.
+// ProcessIncomingMessage processes an incoming message synchronously, used to retry dead-lettered messages.
+func (m *Manager) ProcessIncomingMessage(in models.IncomingMessage) error {
+	return m.processIncomingMessage(context.Background(), in)
+}
+
+// MessageExists checks if a message with the given messageID exists, is waiting in the incoming queue, was
+// dropped by a block rule or is in the dead-letter queue.
 func (m *Manager) MessageExists(messageID string) (bool, error) {
 	_, err := m.messageExistsBySourceID([]string{messageID})
 	if err != nil {
 		if errors.Is(err, errConversationNotFound) {
-			return false, nil
+			if queued, err := m.incomingMessageQueued(messageID); err != nil || queued {
+				return queued, err
+			}
+			if dropped, err := m.blockRuleStore.MessageDropped(messageID); err != nil || dropped {
+				return dropped, err
+			}
+			return m.deadLetterStore.Exists(messageID)
 		}
 		m.lo.Error("error fetching message from db", "error", err)
 		return false, err
@@ -796,23 +1001,6 @@ func (m *Manager) MessageExists(messageID string) (bool, error) {
 	return true, nil
 }
 
-// EnqueueIncoming enqueues an incoming message for inserting in db.
-func (m *Manager) EnqueueIncoming(message models.IncomingMessage) error {
-	m.closedMu.Lock()
-	defer m.closedMu.Unlock()
-	if m.closed {
-		return errors.New("incoming message queue is closed")
-	}
-
-	select {
-	case m.incomingMessageQueue <- message:
-		return nil
-	default:
-		m.lo.Warn("WARNING: incoming message queue is full")
-		return errors.New("incoming message queue is full")
-	}
-}
-
 // GetConversationByMessageID returns conversation by message id.
 func (m *Manager) GetConversationByMessageID(id int) (models.Conversation, error) {
 	var conversation = models.Conversation{}
@@ -877,6 +1065,16 @@ func (m *Manager) uploadMessageAttachments(message *models.Message) error {
 		// Sanitize filename.
 		attachment.Name = stringutil.SanitizeFilename(attachment.Name)
 
+		// Scan the attachment for malware, flagged attachments are quarantined and replaced with a placeholder.
+		meta := []byte("{}")
+		if scan, quarantine := m.mediaStore.ScanForMalware(bytes.NewReader(attachment.Content)); scan != nil {
+			if quarantine {
+				m.quarantineAttachment(&attachment, scan)
+				contentID = ""
+			}
+			meta, _ = json.Marshal(map[string]any{"malware_scan": scan})
+		}
+
 		m.lo.Debug("uploading message attachment", "name", attachment.Name, "content_id", contentID, "size", attachment.Size, "content_type", attachment.ContentType,
 			"content_id", contentID, "disposition", attachment.Disposition)
 
@@ -892,7 +1090,7 @@ func (m *Manager) uploadMessageAttachments(message *models.Message) error {
 			attachReader,
 			attachment.Size,
 			null.StringFrom(attachment.Disposition),
-			[]byte("{}"), /** meta **/
+			meta,
 		)
 		if err != nil {
 			m.lo.Error("failed to upload attachment", "name", attachment.Name, "error", err)
@@ -911,13 +1109,41 @@ func (m *Manager) uploadMessageAttachments(message *models.Message) error {
 	return nil
 }
 
+// quarantineAttachment moves an attachment flagged by the malware scanner to quarantine and replaces it
+// with a text placeholder explaining why it was removed.
+func (m *Manager) quarantineAttachment(a *attachment.Attachment, scan *mmodels.MalwareScan) {
+	m.lo.Warn("quarantining message attachment flagged by malware scanner", "name", a.Name, "status", scan.Status, "signature", scan.Signature)
+
+	name, err := m.mediaStore.Quarantine(a.ContentType, bytes.NewReader(a.Content))
+	if err != nil {
+		m.lo.Error("error quarantining attachment, discarding it", "name", a.Name, "error", err)
+	}
+	scan.OriginalName = a.Name
+	scan.QuarantineFile = name
+
+	var placeholder string
+	if scan.Status == mmodels.MalwareScanInfected {
+		placeholder = m.i18n.Ts("media.attachmentQuarantinedInfected", "name", a.Name, "signature", scan.Signature)
+	} else {
+		placeholder = m.i18n.Ts("media.attachmentQuarantinedUnscanned", "name", a.Name)
+	}
+	a.Name = a.Name + ".quarantined.txt"
+	a.ContentType = "text/plain"
+	a.ContentID = ""
+	a.Disposition = attachment.DispositionAttachment
+	a.Content = []byte(placeholder)
+	a.Size = len(a.Content)
+}
+
 // findOrCreateConversation finds or creates a conversation for the given message.
-func (m *Manager) findOrCreateConversation(in *models.Message, inboxID, contactChannelID, contactID int) (bool, error) {
+// New conversations are created closed if closed is set, or quarantined if the message is spam.
+func (m *Manager) findOrCreateConversation(incoming *models.IncomingMessage, closed bool) (bool, error) {
 	var (
 		new              bool
 		err              error
 		conversationID   int
 		conversationUUID string
+		in               = &incoming.Message
 	)
 
 	// Search for existing conversation using the in-reply-to and references.
@@ -934,7 +1160,13 @@ func (m *Manager) findOrCreateConversation(in *models.Message, inboxID, contactC
 		new = true
 		lastMessage := stringutil.HTML2Text(in.Content)
 		lastMessageAt := time.Now()
-		conversationID, conversationUUID, err = m.CreateConversation(contactID, contactChannelID, inboxID, lastMessage, lastMessageAt, in.Subject, false /**append reference number to subject**/)
+		status := models.StatusOpen
+		if closed {
+			status = models.StatusClosed
+		} else if m.isSpam(incoming) {
+			status = models.StatusQuarantined
+		}
+		conversationID, conversationUUID, err = m.createConversation(incoming.Contact.ID, incoming.Contact.ContactChannelID, incoming.InboxID, status, lastMessage, lastMessageAt, in.Subject, false /**append reference number to subject**/)
 		if err != nil || conversationID == 0 {
 			return new, err
 		}
@@ -1002,18 +1234,6 @@ func (m *Manager) attachAttachmentsToMessage(message *models.Message) error {
 	return nil
 }
 
-// getOutgoingProcessingMessageIDs returns the IDs of outgoing messages currently being processed.
-func (m *Manager) getOutgoingProcessingMessageIDs() []int {
-	var out = make([]int, 0)
-	m.outgoingProcessingMessages.Range(func(key, _ any) bool {
-		if k, ok := key.(int); ok {
-			out = append(out, k)
-		}
-		return true
-	})
-	return out
-}
-
 // uploadThumbnailForMedia prepares and uploads a thumbnail for an image attachment.
 func (m *Manager) uploadThumbnailForMedia(media mmodels.Media, content []byte) error {
 	// Create a reader from the content
@@ -1,8 +1,11 @@
 package models
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"net/textproto"
+	"strings"
 	"time"
 
 	"github.com/abhinavxd/libredesk/internal/attachment"
@@ -18,6 +21,8 @@ var (
 	StatusResolved = "Resolved"
 	StatusClosed   = "Closed"
 	StatusSnoozed  = "Snoozed"
+	// StatusQuarantined is set on new conversations flagged as spam until an agent releases them.
+	StatusQuarantined = "Quarantined"
 
 	AssigneeTypeTeam = "team"
 	AssigneeTypeUser = "user"
@@ -52,6 +57,10 @@ var (
 	ActivityTagAdded           = "tag_added"
 	ActivityTagRemoved         = "tag_removed"
 	ActivitySLASet             = "sla_set"
+	ActivityConversationMerged = "conversation_merged"
+	ActivityMergedInto         = "merged_into"
+	ActivityConversationSplit  = "conversation_split"
+	ActivitySplitFrom          = "split_from"
 
 	ContentTypeText = "text"
 	ContentTypeHTML = "html"
@@ -107,6 +116,7 @@ type Conversation struct {
 	UpdatedAt             time.Time              `db:"updated_at" json:"updated_at"`
 	UUID                  string                 `db:"uuid" json:"uuid"`
 	ContactID             int                    `db:"contact_id" json:"contact_id"`
+	ContactChannelID      int                    `db:"contact_channel_id" json:"contact_channel_id"`
 	InboxID               int                    `db:"inbox_id" json:"inbox_id"`
 	ClosedAt              null.Time              `db:"closed_at" json:"closed_at"`
 	ResolvedAt            null.Time              `db:"resolved_at" json:"resolved_at"`
@@ -141,6 +151,8 @@ type Conversation struct {
 	ResolutionDueAt       null.Time              `db:"resolution_deadline_at" json:"resolution_deadline_at"`
 	NextResponseDueAt     null.Time              `db:"next_response_deadline_at" json:"next_response_deadline_at"`
 	NextResponseMetAt     null.Time              `db:"next_response_met_at" json:"next_response_met_at"`
+	MergedIntoID          null.Int               `db:"merged_into_id" json:"merged_into_id"`
+	MergedIntoUUID        null.String            `db:"merged_into_uuid" json:"merged_into_uuid"`
 	PreviousConversations []PreviousConversation `db:"-" json:"previous_conversations"`
 }
 
@@ -160,6 +172,10 @@ type ConversationContact struct {
 	Enabled                bool            `db:"enabled" json:"enabled"`
 	LastActiveAt           null.Time       `db:"last_active_at" json:"last_active_at"`
 	LastLoginAt            null.Time       `db:"last_login_at" json:"last_login_at"`
+	OrganizationID         null.Int        `db:"organization_id" json:"organization_id"`
+	OrganizationName       null.String     `db:"organization_name" json:"organization_name"`
+	// Custom attributes of the organization the contact belongs to.
+	OrganizationCustomAttributes json.RawMessage `db:"organization_custom_attributes" json:"organization_custom_attributes"`
 }
 
 func (c *ConversationContact) FullName() string {
@@ -200,6 +216,45 @@ type MessageAuthor struct {
 	AvatarURL null.String `db:"avatar_url" json:"avatar_url"`
 }
 
+// ExportMessage is a message of a conversation export.
+type ExportMessage struct {
+	ID          int                    `db:"id" json:"-"`
+	CreatedAt   time.Time              `db:"created_at" json:"created_at"`
+	UUID        string                 `db:"uuid" json:"uuid"`
+	Type        string                 `db:"type" json:"type"`
+	Status      string                 `db:"status" json:"status"`
+	Private     bool                   `db:"private" json:"private"`
+	ContentType string                 `db:"content_type" json:"content_type"`
+	Content     string                 `db:"content" json:"content"`
+	TextContent string                 `db:"text_content" json:"text_content"`
+	SourceID    null.String            `db:"source_id" json:"source_id"`
+	SenderType  string                 `db:"sender_type" json:"sender_type"`
+	Author      ExportMessageAuthor    `db:"author" json:"author"`
+	Meta        json.RawMessage        `db:"meta" json:"meta"`
+	Attachments attachment.Attachments `db:"attachments" json:"attachments"`
+}
+
+// ExportMessageAuthor is the author of an exported message.
+type ExportMessageAuthor struct {
+	ID        int         `db:"id" json:"id"`
+	FirstName string      `db:"first_name" json:"first_name"`
+	LastName  string      `db:"last_name" json:"last_name"`
+	Email     null.String `db:"email" json:"email"`
+	Type      string      `db:"type" json:"type"`
+}
+
+// FullName returns the full name of the author.
+func (a ExportMessageAuthor) FullName() string {
+	return strings.TrimSpace(a.FirstName + " " + a.LastName)
+}
+
+// ConversationExport is a full export of a conversation with all its messages, notes and activities.
+type ConversationExport struct {
+	ExportedAt   time.Time       `json:"exported_at"`
+	Conversation Conversation    `json:"conversation"`
+	Messages     []ExportMessage `json:"messages"`
+}
+
 type ConversationCounts struct {
 	TotalAssigned         int `db:"total_assigned" json:"total_assigned"`
 	UnresolvedCount       int `db:"unresolved_count" json:"unresolved_count"`
@@ -233,6 +288,7 @@ type Message struct {
 	Author           MessageAuthor          `db:"author" json:"author"`
 	InboxID          int                    `db:"inbox_id" json:"-"`
 	Meta             json.RawMessage        `db:"meta" json:"meta"`
+	SendAt           null.Time              `db:"send_at" json:"send_at"`
 	Attachments      attachment.Attachments `db:"attachments" json:"attachments"`
 	From             string                 `db:"from"  json:"-"`
 	Subject          string                 `db:"subject" json:"-"`
@@ -276,6 +332,36 @@ type IncomingMessage struct {
 	Message                     Message
 	Contact                     umodels.User
 	InboxID                     int
+	// Headers and Raw are the headers and raw source of email messages, used to check the message for spam.
+	Headers textproto.MIMEHeader
+	Raw     []byte
+	// TraceContext carries the trace of the fetch through the incoming queue to the processing of the message.
+	TraceContext map[string]string
+}
+
+// EncodeIncomingMessage encodes an incoming message with gob, which unlike JSON keeps all fields of the message.
+func EncodeIncomingMessage(in IncomingMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeIncomingMessage decodes an incoming message encoded with EncodeIncomingMessage.
+func DecodeIncomingMessage(payload []byte) (IncomingMessage, error) {
+	var in IncomingMessage
+	err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&in)
+	return in, err
+}
+
+// IncomingQueueStats holds the state of the incoming message queue.
+type IncomingQueueStats struct {
+	// Depth is the number of messages waiting to be processed, including the ones being retried.
+	Depth    int `db:"depth" json:"depth"`
+	Retrying int `db:"retrying" json:"retrying"`
+	// OldestAgeSeconds is how long the oldest message has been waiting.
+	OldestAgeSeconds float64 `db:"oldest_age_seconds" json:"oldest_age_seconds"`
 }
 
 type Status struct {
@@ -93,6 +93,7 @@ SELECT
     FROM conversations
     JOIN users ON contact_id = users.id
     JOIN inboxes ON inbox_id = inboxes.id  
+    LEFT JOIN organizations ON users.organization_id = organizations.id
     LEFT JOIN conversation_statuses ON status_id = conversation_statuses.id
     LEFT JOIN conversation_priorities ON priority_id = conversation_priorities.id
     LEFT JOIN LATERAL (
@@ -135,6 +136,7 @@ SELECT
    c.assigned_team_id,
    c.subject,
    c.contact_id,
+   c.contact_channel_id,
    c.sla_policy_id,
    c.meta,
    sla.name as sla_policy_name,
@@ -145,6 +147,8 @@ SELECT
    c.last_interaction_at,
    c.last_interaction_sender,
    c.custom_attributes,
+   c.merged_into_id,
+   mc.uuid as merged_into_uuid,
    (SELECT COALESCE(
        (SELECT json_agg(t.name)
        FROM tags t
@@ -167,6 +171,9 @@ SELECT
    ct.enabled as "contact.enabled",
    ct.last_active_at as "contact.last_active_at",
    ct.last_login_at as "contact.last_login_at",
+   ct.organization_id as "contact.organization_id",
+   org.name as "contact.organization_name",
+   COALESCE(org.custom_attributes, '{}'::jsonb) as "contact.organization_custom_attributes",
    as_latest.first_response_deadline_at,
    as_latest.resolution_deadline_at,
    as_latest.id as applied_sla_id,
@@ -179,6 +186,8 @@ LEFT JOIN sla_policies sla ON c.sla_policy_id = sla.id
 LEFT JOIN teams at ON at.id = c.assigned_team_id
 LEFT JOIN conversation_statuses s ON c.status_id = s.id
 LEFT JOIN conversation_priorities p ON c.priority_id = p.id
+LEFT JOIN conversations mc ON c.merged_into_id = mc.id
+LEFT JOIN organizations org ON ct.organization_id = org.id
 LEFT JOIN LATERAL (
     SELECT id, first_response_deadline_at, resolution_deadline_at
     FROM applied_slas
@@ -226,6 +235,9 @@ WHERE c.contact_id = $1
 ORDER BY c.created_at DESC
 LIMIT $2;
 
+-- name: get-contact-conversation-uuids
+SELECT uuid FROM conversations WHERE contact_id = $1 ORDER BY created_at;
+
 -- name: get-conversation-uuid
 SELECT uuid from conversations where id = $1;
 
@@ -304,7 +316,8 @@ SELECT
     inb.name as inbox_name
 FROM conversations c
     JOIN inboxes inb ON c.inbox_id = inb.id 
-WHERE assigned_user_id IS NULL AND assigned_team_id IS NOT NULL;
+WHERE assigned_user_id IS NULL AND assigned_team_id IS NOT NULL
+    AND c.status_id NOT IN (SELECT id FROM conversation_statuses WHERE name = 'Quarantined');
 
 -- name: update-conversation-first-reply-at
 UPDATE conversations
@@ -391,6 +404,7 @@ WHERE uuid = $1;
 
 -- name: re-open-conversation
 -- Open conversation if it is not already open and unset the assigned user if they are away and reassigning.
+-- Quarantined conversations are only opened when released.
 UPDATE conversations
 SET 
   status_id = (SELECT id FROM conversation_statuses WHERE name = 'Open'),
@@ -407,7 +421,7 @@ SET
 WHERE 
   uuid = $1
   AND status_id IN (
-    SELECT id FROM conversation_statuses WHERE name NOT IN ('Open')
+    SELECT id FROM conversation_statuses WHERE name NOT IN ('Open', 'Quarantined')
   )
 
 -- name: get-conversation-by-message-id
@@ -423,6 +437,109 @@ WHERE m.id = $1;
 -- name: delete-conversation
 DELETE FROM conversations WHERE uuid = $1;
 
+-- name: lock-conversations-for-merge
+-- Locks the conversations being merged, in ID order to avoid deadlocks, so concurrent merges of the same conversations wait
+-- and see each other's merge pointers.
+SELECT id, merged_into_id
+FROM conversations
+WHERE id = ANY($1::BIGINT[])
+ORDER BY id
+FOR UPDATE;
+
+-- name: merge-conversation
+-- Moves messages, mentions, participants, tags and drafts of conversation $1 into conversation $2 and points $1 to $2.
+-- Media is linked to messages and drafts, so it moves along with them. Drafts of users with a draft in $2 are kept in $1.
+-- The conversation is not merged if it's already merged into another conversation.
+WITH moved_messages AS (
+    UPDATE conversation_messages SET conversation_id = $2, updated_at = NOW() WHERE conversation_id = $1
+),
+moved_mentions AS (
+    UPDATE conversation_mentions SET conversation_id = $2 WHERE conversation_id = $1
+),
+copied_participants AS (
+    INSERT INTO conversation_participants (user_id, conversation_id)
+    SELECT user_id, $2 FROM conversation_participants WHERE conversation_id = $1
+    ON CONFLICT (conversation_id, user_id) DO NOTHING
+),
+copied_tags AS (
+    INSERT INTO conversation_tags (conversation_id, tag_id)
+    SELECT $2, tag_id FROM conversation_tags WHERE conversation_id = $1
+    ON CONFLICT (conversation_id, tag_id) DO NOTHING
+),
+moved_drafts AS (
+    UPDATE conversation_drafts SET conversation_id = $2, updated_at = NOW()
+    WHERE conversation_id = $1 AND user_id NOT IN (SELECT user_id FROM conversation_drafts WHERE conversation_id = $2)
+)
+UPDATE conversations
+SET merged_into_id = $2,
+    snoozed_until = NULL,
+    waiting_since = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND merged_into_id IS NULL;
+
+-- name: split-conversation-messages
+-- Moves message $3 (and all messages created after it if $4 is true) of conversation $1 into conversation $2,
+-- along with their mentions, and adds the message senders as participants of conversation $2. Returns the moved message UUIDs.
+WITH moved_messages AS (
+    UPDATE conversation_messages
+    SET conversation_id = $2, updated_at = NOW()
+    WHERE conversation_id = $1
+    AND (
+        id = $3
+        OR ($4 = TRUE AND created_at >= (SELECT created_at FROM conversation_messages WHERE id = $3))
+    )
+    RETURNING id, uuid, sender_id
+),
+moved_mentions AS (
+    UPDATE conversation_mentions SET conversation_id = $2 WHERE message_id IN (SELECT id FROM moved_messages)
+),
+added_participants AS (
+    INSERT INTO conversation_participants (user_id, conversation_id)
+    SELECT DISTINCT sender_id, $2 FROM moved_messages
+    ON CONFLICT (conversation_id, user_id) DO NOTHING
+)
+SELECT uuid FROM moved_messages;
+
+-- name: refresh-conversation-last-message
+-- Recomputes the last message details of a conversation from its latest message.
+UPDATE conversations c SET
+    last_message = lm.text_content,
+    last_message_sender = lm.sender_type,
+    last_message_at = lm.created_at,
+    updated_at = NOW()
+FROM (
+    SELECT text_content, sender_type, created_at
+    FROM conversation_messages
+    WHERE conversation_id = $1
+    ORDER BY created_at DESC
+    LIMIT 1
+) lm
+WHERE c.id = $1;
+
+-- name: finish-imported-conversation
+-- Backdates a conversation created by an archive import to its messages.
+UPDATE conversations c SET
+    created_at = m.first_at,
+    first_reply_at = m.first_reply_at,
+    last_reply_at = m.last_reply_at,
+    resolved_at = m.last_at,
+    closed_at = m.last_at,
+    waiting_since = NULL,
+    last_interaction = c.last_message,
+    last_interaction_sender = c.last_message_sender,
+    last_interaction_at = c.last_message_at,
+    updated_at = NOW()
+FROM (
+    SELECT
+        MIN(created_at) AS first_at,
+        MAX(created_at) AS last_at,
+        MIN(created_at) FILTER (WHERE type = 'outgoing') AS first_reply_at,
+        MAX(created_at) FILTER (WHERE type = 'outgoing') AS last_reply_at
+    FROM conversation_messages
+    WHERE conversation_id = $1
+) m
+WHERE c.id = $1 AND m.first_at IS NOT NULL;
+
 -- MESSAGE queries.
 -- name: get-message-source-ids
 SELECT 
@@ -434,7 +551,23 @@ and source_id > ''
 ORDER BY id DESC
 LIMIT $2;
 
--- name: get-outgoing-pending-messages
+-- name: claim-outgoing-pending-messages
+-- Claims due pending outgoing messages for an instance ($1) for the lease duration ($2) and returns them. Messages
+-- claimed by other instances are skipped until their lease expires, rows being claimed concurrently are skipped too.
+WITH claimed AS (
+    UPDATE conversation_messages
+    SET claimed_by = $1, claim_expires_at = NOW() + $2 * INTERVAL '1 millisecond'
+    WHERE id IN (
+        SELECT id FROM conversation_messages
+        WHERE status = 'pending' AND type = 'outgoing' AND private = false
+        AND (send_at IS NULL OR send_at <= NOW())
+        AND (claim_expires_at IS NULL OR claim_expires_at < NOW())
+        ORDER BY id
+        LIMIT $3
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING *
+)
 SELECT
     m.id,
     m.created_at,
@@ -458,10 +591,21 @@ SELECT
     ARRAY(SELECT jsonb_array_elements_text(m.meta->'to')) AS to,
     c.inbox_id,
     c.subject
-FROM conversation_messages m
+FROM claimed m
 INNER JOIN conversations c ON c.id = m.conversation_id
-WHERE m.status = 'pending' AND m.type = 'outgoing' AND m.private = false
-AND NOT(m.id = ANY($1::INT[]))
+ORDER BY m.id;
+
+-- name: extend-outgoing-message-claims
+-- Extends the lease of the pending outgoing messages claimed by an instance.
+UPDATE conversation_messages
+SET claim_expires_at = NOW() + $2 * INTERVAL '1 millisecond'
+WHERE claimed_by = $1 AND status = 'pending';
+
+-- name: release-outgoing-message-claims
+-- Releases the pending outgoing messages claimed by an instance so others can send them right away.
+UPDATE conversation_messages
+SET claimed_by = NULL, claim_expires_at = NULL
+WHERE claimed_by = $1 AND status = 'pending';
 
 -- name: get-message
 SELECT
@@ -479,6 +623,7 @@ SELECT
     m.sender_type,
     m.sender_id,
     m.meta,
+    m.send_at,
     c.uuid as conversation_uuid,
     u.id AS "author.id",
     u.first_name AS "author.first_name",
@@ -524,6 +669,7 @@ SELECT
    m.sender_id,
    m.sender_type,
    m.meta,
+   m.send_at,
    $1::uuid AS conversation_uuid,
    u.id AS "author.id",
    u.first_name AS "author.first_name",
@@ -551,6 +697,44 @@ AND ($2::boolean IS NULL OR m.private = $2)
 AND ($3::text[] IS NULL OR m.type::text = ANY($3))
 ORDER BY m.created_at DESC %s
 
+-- name: get-conversation-export-messages
+-- Returns all messages, notes and activities of a conversation in chronological order for exports.
+SELECT
+   m.id,
+   m.created_at,
+   m.uuid,
+   m.type,
+   m.status,
+   m.private,
+   m.content_type,
+   m.content,
+   m.text_content,
+   m.source_id,
+   m.sender_type,
+   m.meta,
+   u.id AS "author.id",
+   u.first_name AS "author.first_name",
+   u.last_name AS "author.last_name",
+   u.email AS "author.email",
+   u.type AS "author.type",
+   COALESCE(
+     (SELECT json_agg(
+       json_build_object(
+         'name', filename,
+         'content_type', content_type,
+         'uuid', uuid,
+         'size', size,
+         'content_id', content_id,
+         'disposition', disposition
+       ) ORDER BY filename
+     ) FROM media
+     WHERE model_type = 'messages' AND model_id = m.id),
+   '[]'::json) AS attachments
+FROM conversation_messages m
+JOIN users u ON m.sender_id = u.id
+WHERE m.conversation_id = $1
+ORDER BY m.created_at, m.id;
+
 -- name: insert-message
 WITH conversation_id AS (
    SELECT id 
@@ -564,23 +748,71 @@ inserted_msg AS (
    INSERT INTO conversation_messages (
        "type", status, conversation_id, "content", 
        text_content, sender_id, sender_type, private,
-       content_type, source_id, meta
+       content_type, source_id, meta, send_at
    )
    VALUES (
        $1, $2, (SELECT id FROM conversation_id),
-       $5, $6, $7, $8, $9, $10, $11, $12
+       $5, $6, $7, $8, $9, $10, $11, $12, $13
    )
    RETURNING *
 )
 SELECT * FROM inserted_msg;
 
+-- name: insert-imported-message
+-- Inserts a message imported from an email archive with its original timestamp.
+INSERT INTO conversation_messages (
+    "type", status, conversation_id, "content",
+    text_content, sender_id, sender_type, private,
+    content_type, source_id, meta, created_at, updated_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, false, $8, $9, $10, $11, $11)
+RETURNING *;
+
+-- name: insert-message-source
+INSERT INTO message_sources (message_id, raw)
+VALUES ($1, $2)
+ON CONFLICT (message_id) DO UPDATE SET raw = EXCLUDED.raw, created_at = NOW();
+
+-- name: get-message-source
+SELECT raw FROM message_sources WHERE message_id = $1;
+
+-- name: update-message-content
+UPDATE conversation_messages
+SET content = $2, text_content = $3, content_type = $4, updated_at = NOW()
+WHERE id = $1;
+
 -- name: message-exists-by-source-id
+-- Prefer the most recent message as messages of a thread can be split across conversations.
 SELECT conversation_id
 FROM conversation_messages
-WHERE source_id = ANY($1::text []);
+WHERE source_id = ANY($1::text [])
+ORDER BY created_at DESC
+LIMIT 1;
 
 -- name: update-message-status
-update conversation_messages set status = $1, updated_at = NOW() where uuid = $2;
+-- Any claim on the message is released as it is no longer pending or is pending again, e.g. on retry.
+update conversation_messages set status = $1, claimed_by = NULL, claim_expires_at = NULL, updated_at = NOW() where uuid = $2;
+
+-- name: delete-scheduled-message
+-- Deletes a pending outgoing message only if it has not become due for sending yet.
+DELETE FROM conversation_messages
+WHERE uuid = $1
+AND status = 'pending'
+AND type = 'outgoing'
+AND send_at > NOW()
+RETURNING id, conversation_id, content, meta;
+
+-- name: get-message-attachments
+SELECT id, created_at, updated_at, "uuid", store, filename, content_type, content_id, model_id, model_type, disposition, "size", meta
+FROM media
+WHERE model_type = 'messages' AND model_id = $1;
+
+-- name: move-message-attachments-to-draft
+UPDATE media
+SET model_type = 'drafts',
+    model_id = $2,
+    updated_at = NOW()
+WHERE model_type = 'messages' AND model_id = $1;
 
 -- name: get-latest-message
 SELECT
@@ -617,6 +849,15 @@ WHERE cd.user_id = $1
 ORDER BY cd.updated_at DESC;
 
 -- name: delete-conversation-draft
+-- Deletes a draft and unlinks its attachments, which are then purged with other unlinked media.
+WITH unlinked_media AS (
+  UPDATE media SET model_type = 'messages', model_id = NULL
+  WHERE model_type = 'drafts' AND model_id IN (
+    SELECT cd.id FROM conversation_drafts cd
+    JOIN conversations c ON c.id = cd.conversation_id
+    WHERE (($1 > 0 AND c.id = $1) OR ($2::uuid IS NOT NULL AND c.uuid = $2::uuid)) AND cd.user_id = $3
+  )
+)
 DELETE FROM conversation_drafts
 WHERE conversation_id IN (
   SELECT id FROM conversations
@@ -624,6 +865,11 @@ WHERE conversation_id IN (
 ) AND user_id = $3;
 
 -- name: delete-stale-drafts
+-- Deletes stale drafts and unlinks their attachments, which are then purged with other unlinked media.
+WITH unlinked_media AS (
+  UPDATE media SET model_type = 'messages', model_id = NULL
+  WHERE model_type = 'drafts' AND model_id IN (SELECT id FROM conversation_drafts WHERE created_at < $1)
+)
 DELETE FROM conversation_drafts
 WHERE created_at < $1;
 
@@ -645,4 +891,48 @@ DO UPDATE SET
     last_seen_at = (SELECT created_at - INTERVAL '1 second' FROM conversation_messages
                     WHERE conversation_id = (SELECT id FROM conversations WHERE uuid = $2)
                     ORDER BY created_at DESC LIMIT 1),
-    updated_at = NOW();
\ No newline at end of file
+    updated_at = NOW();
+
+-- name: enqueue-incoming-message
+-- Messages already in the queue are skipped, e.g. when fetched again from the inbox before being processed.
+INSERT INTO incoming_message_queue (inbox_id, source_id, payload)
+VALUES ($1, NULLIF($2, ''), $3)
+ON CONFLICT (source_id) DO NOTHING;
+
+-- name: claim-incoming-message
+-- Claims the oldest visible message for the visibility timeout ($1 in milliseconds) and counts the attempt.
+UPDATE incoming_message_queue
+SET visible_at = NOW() + $1 * INTERVAL '1 millisecond', attempts = attempts + 1
+WHERE id = (
+    SELECT id FROM incoming_message_queue
+    WHERE visible_at <= NOW()
+    ORDER BY id
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, payload, attempts;
+
+-- name: delete-incoming-message
+DELETE FROM incoming_message_queue WHERE id = $1;
+
+-- name: extend-incoming-message
+-- Keeps a message being processed invisible to other workers for another visibility timeout ($2 in milliseconds).
+UPDATE incoming_message_queue
+SET visible_at = NOW() + $2 * INTERVAL '1 millisecond'
+WHERE id = $1;
+
+-- name: defer-incoming-message
+-- Makes a message that failed processing visible again after the retry delay ($3 in milliseconds).
+UPDATE incoming_message_queue
+SET last_error = $2, visible_at = NOW() + $3 * INTERVAL '1 millisecond'
+WHERE id = $1;
+
+-- name: incoming-message-queued
+SELECT EXISTS(SELECT 1 FROM incoming_message_queue WHERE source_id = $1);
+
+-- name: get-incoming-queue-stats
+SELECT
+    COUNT(*) AS depth,
+    COUNT(*) FILTER (WHERE last_error IS NOT NULL) AS retrying,
+    COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at)), 0)::FLOAT AS oldest_age_seconds
+FROM incoming_message_queue;
@@ -0,0 +1,87 @@
+package conversation
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	mmodels "github.com/abhinavxd/libredesk/internal/media/models"
+	"github.com/abhinavxd/libredesk/internal/stringutil"
+)
+
+// InsertMessageSource stores the original raw email of a message.
+func (m *Manager) InsertMessageSource(messageID int, raw []byte) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	if _, err := m.q.InsertMessageSource.Exec(messageID, raw); err != nil {
+		m.lo.Error("error inserting message source", "message_id", messageID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetMessageSource returns the original raw email of a message.
+func (m *Manager) GetMessageSource(messageID int) ([]byte, error) {
+	var raw []byte
+	if err := m.q.GetMessageSource.Get(&raw, messageID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, envelope.NewError(envelope.NotFoundError, m.i18n.T("conversation.messageSourceNotFound"), nil)
+		}
+		m.lo.Error("error fetching message source", "message_id", messageID, "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.message}"), nil)
+	}
+	return raw, nil
+}
+
+// ReprocessMessage replaces the content and attachments of an incoming message with the ones parsed again from its
+// original raw email. Attachments go through the same pipeline as new messages (malware scanning, thumbnails) and
+// the previous attachments are deleted, except inline images that are still referenced by the new content.
+func (m *Manager) ReprocessMessage(message, parsed models.Message) (models.Message, error) {
+	if message.Type != models.MessageIncoming {
+		return message, envelope.NewError(envelope.InputError, m.i18n.T("conversation.cannotReprocessMessage"), nil)
+	}
+
+	oldMedia, err := m.mediaStore.GetByModel(message.ID, mmodels.ModelMessages)
+	if err != nil {
+		m.lo.Error("error fetching message media", "message_id", message.ID, "error", err)
+		return message, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.media}"), nil)
+	}
+
+	parsed.ConversationUUID = message.ConversationUUID
+	if err := m.uploadMessageAttachments(&parsed); err != nil {
+		m.lo.Error("error uploading reprocessed message attachments", "message_id", message.ID, "error", err)
+		return message, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.message}"), nil)
+	}
+
+	if parsed.ContentType == "" {
+		parsed.ContentType = models.ContentTypeText
+	}
+	textContent := stringutil.HTML2Text(parsed.Content)
+	if _, err := m.q.UpdateMessageContent.Exec(message.ID, parsed.Content, textContent, parsed.ContentType); err != nil {
+		m.lo.Error("error updating message content", "message_id", message.ID, "error", err)
+		return message, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.message}"), nil)
+	}
+	for _, media := range parsed.Media {
+		m.mediaStore.Attach(media.ID, mmodels.ModelMessages, message.ID)
+	}
+
+	for _, media := range oldMedia {
+		if strings.Contains(parsed.Content, "/uploads/"+media.UUID) {
+			continue
+		}
+		if err := m.mediaStore.DeleteWithThumbnail(media); err != nil {
+			m.lo.Error("error deleting media of reprocessed message", "message_id", message.ID, "media_uuid", media.UUID, "error", err)
+		}
+	}
+	m.lo.Info("message reprocessed from source", "message_id", message.ID, "attachments", len(parsed.Media))
+
+	updated, err := m.GetMessage(message.UUID)
+	if err != nil {
+		return message, err
+	}
+	m.BroadcastMessageUpdate(updated.ConversationUUID, updated.UUID, "content", updated.Content)
+	return updated, nil
+}
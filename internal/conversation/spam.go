@@ -0,0 +1,70 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/spam"
+	umodels "github.com/abhinavxd/libredesk/internal/user/models"
+	wmodels "github.com/abhinavxd/libredesk/internal/webhook/models"
+)
+
+// isSpam checks an incoming message with the spam filter. The verdict of spam messages is stored in the
+// message meta so agents can see why the conversation was quarantined.
+func (m *Manager) isSpam(in *models.IncomingMessage) bool {
+	if m.spamFilter == nil {
+		return false
+	}
+	verdict := m.spamFilter.Check(context.Background(), spam.Message{
+		From:    in.Contact.Email.String,
+		Headers: in.Headers,
+		Raw:     in.Raw,
+	})
+	if !verdict.Spam {
+		return false
+	}
+
+	m.lo.Info("quarantining conversation for spam message", "message_source_id", in.Message.SourceID.String,
+		"from", in.Contact.Email.String, "score", verdict.Score, "reasons", verdict.Reasons)
+
+	meta := map[string]any{}
+	if len(in.Message.Meta) > 0 {
+		if err := json.Unmarshal(in.Message.Meta, &meta); err != nil {
+			m.lo.Error("error unmarshalling message meta", "error", err)
+			return true
+		}
+	}
+	meta["spam"] = map[string]any{
+		"score":   verdict.Score,
+		"reasons": verdict.Reasons,
+	}
+	if b, err := json.Marshal(meta); err == nil {
+		in.Message.Meta = b
+	}
+	return true
+}
+
+// ReleaseConversation opens a quarantined conversation and sends the webhook events and runs the
+// new conversation automation rules that were skipped when it was quarantined.
+func (m *Manager) ReleaseConversation(uuid string, actor umodels.User) error {
+	conversation, err := m.GetConversation(0, uuid, "")
+	if err != nil {
+		return err
+	}
+	if conversation.Status.String != models.StatusQuarantined {
+		return envelope.NewError(envelope.InputError, m.i18n.T("conversation.notQuarantined"), nil)
+	}
+	if err := m.UpdateConversationStatus(uuid, 0, models.StatusOpen, "", actor); err != nil {
+		return err
+	}
+
+	conversation, err = m.GetConversation(conversation.ID, "", "")
+	if err != nil {
+		return err
+	}
+	m.webhookStore.TriggerEvent(wmodels.EventConversationCreated, conversation)
+	m.automation.EvaluateNewConversationRules(conversation)
+	return nil
+}
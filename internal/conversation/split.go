@@ -0,0 +1,90 @@
+package conversation
+
+import (
+	"context"
+
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	umodels "github.com/abhinavxd/libredesk/internal/user/models"
+	wmodels "github.com/abhinavxd/libredesk/internal/webhook/models"
+)
+
+// SplitConversation moves a message, and optionally all messages created after it, out of a conversation into a new
+// conversation for the same contact and inbox. Media is linked to messages, so it moves along with them, and as the
+// message source IDs move too, email replies to the split messages land in the new conversation.
+func (m *Manager) SplitConversation(conversationUUID, messageUUID, subject string, includeLater bool, actor umodels.User) (models.Conversation, error) {
+	conversation, err := m.GetConversation(0, conversationUUID, "")
+	if err != nil {
+		return conversation, err
+	}
+
+	message, err := m.GetMessage(messageUUID)
+	if err != nil {
+		return conversation, err
+	}
+	if message.ConversationID != conversation.ID {
+		return conversation, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.message}"), nil)
+	}
+	if message.Type == models.MessageActivity {
+		return conversation, envelope.NewError(envelope.InputError, m.i18n.T("conversation.cannotSplitActivity"), nil)
+	}
+
+	if subject == "" {
+		subject = conversation.Subject.String
+	}
+
+	newID, newUUID, err := m.CreateConversation(conversation.ContactID, conversation.ContactChannelID, conversation.InboxID, message.TextContent, message.CreatedAt, subject, false /**append reference number to subject**/)
+	if err != nil {
+		return conversation, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorSplitting"), nil)
+	}
+
+	tx, err := m.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		m.lo.Error("error beginning split conversation transaction", "error", err)
+		m.DeleteConversation(newUUID)
+		return conversation, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorSplitting"), nil)
+	}
+	defer tx.Rollback()
+
+	var movedUUIDs []string
+	if err := tx.Stmtx(m.q.SplitConversationMessages).Select(&movedUUIDs, conversation.ID, newID, message.ID, includeLater); err != nil {
+		m.lo.Error("error moving split messages", "conversation_uuid", conversation.UUID, "message_uuid", messageUUID, "error", err)
+		m.DeleteConversation(newUUID)
+		return conversation, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorSplitting"), nil)
+	}
+	for _, id := range []int{conversation.ID, newID} {
+		if _, err := tx.Stmtx(m.q.RefreshConversationLastMessage).Exec(id); err != nil {
+			m.lo.Error("error refreshing conversation last message", "conversation_id", id, "error", err)
+			m.DeleteConversation(newUUID)
+			return conversation, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorSplitting"), nil)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		m.lo.Error("error committing split conversation transaction", "error", err)
+		m.DeleteConversation(newUUID)
+		return conversation, envelope.NewError(envelope.GeneralError, m.i18n.T("conversation.errorSplitting"), nil)
+	}
+
+	newConversation, err := m.GetConversation(newID, "", "")
+	if err != nil {
+		return newConversation, err
+	}
+	m.lo.Info("split conversation", "conversation_uuid", conversation.UUID, "new_conversation_uuid", newConversation.UUID, "message_uuid", messageUUID, "actor_id", actor.ID)
+
+	// Cross-reference both conversations.
+	if err := m.InsertConversationActivity(models.ActivityConversationSplit, conversation.UUID, newConversation.ReferenceNumber, actor); err != nil {
+		m.lo.Error("error inserting split activity", "conversation_uuid", conversation.UUID, "error", err)
+	}
+	if err := m.InsertConversationActivity(models.ActivitySplitFrom, newConversation.UUID, conversation.ReferenceNumber, actor); err != nil {
+		m.lo.Error("error inserting split activity", "conversation_uuid", newConversation.UUID, "error", err)
+	}
+
+	// Let subscribers drop the moved messages from the original conversation.
+	for _, uuid := range movedUUIDs {
+		m.BroadcastMessageDeleted(conversation.UUID, uuid)
+	}
+
+	m.webhookStore.TriggerEvent(wmodels.EventConversationCreated, newConversation)
+
+	return newConversation, nil
+}
@@ -7,6 +7,7 @@ var DefaultStatuses = []string{
 	"Snoozed",
 	"Resolved",
 	"Closed",
+	"Quarantined",
 }
 
 type Status struct {
@@ -38,6 +38,17 @@ func (m *Manager) BroadcastMessageUpdate(conversationUUID, messageUUID, prop str
 	m.broadcastToUsers([]int{}, message)
 }
 
+// BroadcastMessageDeleted broadcasts a message deletion to all users.
+func (m *Manager) BroadcastMessageDeleted(conversationUUID, messageUUID string) {
+	m.broadcastToUsers([]int{}, wsmodels.Message{
+		Type: wsmodels.MessageTypeMessageDeleted,
+		Data: map[string]interface{}{
+			"conversation_uuid": conversationUUID,
+			"uuid":              messageUUID,
+		},
+	})
+}
+
 // BroadcastConversationUpdate broadcasts a conversation update to all users.
 func (m *Manager) BroadcastConversationUpdate(conversationUUID, prop string, value any) {
 	message := wsmodels.Message{
@@ -5,7 +5,9 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"io"
 	"strings"
@@ -14,72 +16,181 @@ import (
 const (
 	// EncryptedPrefix is prepended to encrypted values to identify them.
 	EncryptedPrefix = "enc:"
+
+	// keyIDLen is the length of the key ID, the hex encoded prefix of the SHA-256 of a key, that follows
+	// EncryptedPrefix in ciphertext, eg: enc:1a2b3c4d:<base64>. Values encrypted before keys were versioned have no
+	// key ID.
+	keyIDLen = 8
 )
 
 var (
 	ErrInvalidKey        = errors.New("encryption key must be 32 bytes")
 	ErrInvalidCiphertext = errors.New("invalid ciphertext format")
 	ErrDecryptionFailed  = errors.New("decryption failed")
+	ErrUnknownKey        = errors.New("value is encrypted with an unknown key")
 )
 
-// Encrypt encrypts plaintext using AES-256-GCM with the provided key.
-// Returns base64 encoded ciphertext with "enc:" prefix.
-func Encrypt(plaintext, key string) (string, error) {
-	if plaintext == "" {
-		return "", nil
+// Keyring encrypts values with a primary key and decrypts values encrypted with any of its keys, which allows
+// rotating the encryption key: the previous keys stay in the keyring until all values are re-encrypted.
+type Keyring struct {
+	primaryID string
+	keys      map[string]string
+	// ids is the key IDs in order of preference, the primary key first.
+	ids []string
+}
+
+// NewKeyring returns a keyring that encrypts with the primary key and also decrypts with the previous keys.
+func NewKeyring(primary string, previous ...string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]string)}
+	for _, key := range append([]string{primary}, previous...) {
+		if len(key) != 32 {
+			return nil, ErrInvalidKey
+		}
+		id := KeyID(key)
+		if _, ok := k.keys[id]; ok {
+			continue
+		}
+		k.keys[id] = key
+		k.ids = append(k.ids, id)
 	}
+	k.primaryID = k.ids[0]
+	return k, nil
+}
+
+// KeyID returns the ID of a key that is stored in the ciphertext of values encrypted with it.
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:keyIDLen]
+}
 
-	// Check if already encrypted
-	if strings.HasPrefix(plaintext, EncryptedPrefix) {
+// Encrypt encrypts plaintext with the primary key. Returns the base64 encoded ciphertext with the "enc:" prefix
+// and the key ID. Values that are already encrypted are returned as-is.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || IsEncrypted(plaintext) {
 		return plaintext, nil
 	}
+	encoded, err := seal(plaintext, k.keys[k.primaryID])
+	if err != nil {
+		return "", err
+	}
+	return EncryptedPrefix + k.primaryID + ":" + encoded, nil
+}
+
+// Decrypt decrypts ciphertext with the key it was encrypted with. Values without a key ID are tried with all keys.
+// Values that are not encrypted are returned as-is.
+func (k *Keyring) Decrypt(ciphertext string) (string, error) {
+	if !IsEncrypted(ciphertext) {
+		return ciphertext, nil
+	}
 
-	if len(key) != 32 {
-		return "", ErrInvalidKey
+	id, encoded, ok := splitCiphertext(ciphertext)
+	if ok {
+		key, found := k.keys[id]
+		if !found {
+			return "", ErrUnknownKey
+		}
+		return open(encoded, key)
 	}
 
-	block, err := aes.NewCipher([]byte(key))
+	for _, id := range k.ids {
+		if plaintext, err := open(encoded, k.keys[id]); err == nil {
+			return plaintext, nil
+		}
+	}
+	return "", ErrDecryptionFailed
+}
+
+// ReEncrypt returns the value encrypted with the primary key, used to rotate the encryption key. Plaintext is
+// encrypted and values already encrypted with the primary key are returned as-is. It reports whether the value
+// changed.
+func (k *Keyring) ReEncrypt(value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if IsEncrypted(value) {
+		if id, _, ok := splitCiphertext(value); ok && id == k.primaryID {
+			return value, false, nil
+		}
+		plaintext, err := k.Decrypt(value)
+		if err != nil {
+			return "", false, err
+		}
+		value = plaintext
+	}
+	encrypted, err := k.Encrypt(value)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
+	return encrypted, true, nil
+}
 
-	gcm, err := cipher.NewGCM(block)
+// Encrypt encrypts plaintext using AES-256-GCM with the provided key.
+// Returns base64 encoded ciphertext with "enc:" prefix and the key ID.
+func Encrypt(plaintext, key string) (string, error) {
+	if plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	k, err := NewKeyring(key)
 	if err != nil {
 		return "", err
 	}
+	return k.Encrypt(plaintext)
+}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+// Decrypt decrypts ciphertext using AES-256-GCM with the provided key.
+// Expects base64 encoded ciphertext with "enc:" prefix.
+func Decrypt(ciphertext, key string) (string, error) {
+	if !IsEncrypted(ciphertext) {
+		// Not encrypted, return as-is
+		return ciphertext, nil
+	}
+	k, err := NewKeyring(key)
+	if err != nil {
 		return "", err
 	}
+	return k.Decrypt(ciphertext)
+}
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	encoded := base64.StdEncoding.EncodeToString(ciphertext)
+// IsEncrypted checks if a value is encrypted by looking for the prefix.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
 
-	return EncryptedPrefix + encoded, nil
+// splitCiphertext splits an encrypted value into its key ID and base64 encoded ciphertext. ok is false for values
+// without a key ID. The base64 alphabet has no ":", so the separator is unambiguous.
+func splitCiphertext(value string) (id, encoded string, ok bool) {
+	value = strings.TrimPrefix(value, EncryptedPrefix)
+	id, encoded, ok = strings.Cut(value, ":")
+	if !ok {
+		return "", value, false
+	}
+	return id, encoded, true
 }
 
-// Decrypt decrypts ciphertext using AES-256-GCM with the provided key.
-// Expects base64 encoded ciphertext with "enc:" prefix.
-func Decrypt(ciphertext, key string) (string, error) {
-	if ciphertext == "" {
-		return "", nil
+// seal encrypts plaintext using AES-256-GCM and returns the base64 encoded nonce and ciphertext.
+func seal(plaintext, key string) (string, error) {
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return "", err
 	}
 
-	// Check if it's encrypted
-	if !strings.HasPrefix(ciphertext, EncryptedPrefix) {
-		// Not encrypted, return as-is
-		return ciphertext, nil
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
 	}
 
-	if len(key) != 32 {
-		return "", ErrInvalidKey
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
 	}
 
-	// Remove prefix
-	ciphertext = strings.TrimPrefix(ciphertext, EncryptedPrefix)
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
 
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
+// open decrypts the base64 encoded nonce and ciphertext returned by seal.
+func open(encoded, key string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
 		return "", ErrInvalidCiphertext
 	}
@@ -107,8 +218,3 @@ func Decrypt(ciphertext, key string) (string, error) {
 
 	return string(plaintext), nil
 }
-
-// IsEncrypted checks if a value is encrypted by looking for the prefix.
-func IsEncrypted(value string) bool {
-	return strings.HasPrefix(value, EncryptedPrefix)
-}
@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	oldKey = "0123456789abcdef0123456789abcdef"
+	newKey = "fedcba9876543210fedcba9876543210"
+)
+
+func TestKeyringEncrypt(t *testing.T) {
+	k, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := k.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := EncryptedPrefix + KeyID(newKey) + ":"; !strings.HasPrefix(enc, want) {
+		t.Errorf("got %s, want prefix %s", enc, want)
+	}
+
+	dec, err := k.Decrypt(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec != "secret" {
+		t.Errorf("got %s, want secret", dec)
+	}
+
+	// Encrypted values and empty values are not encrypted again.
+	for _, v := range []string{enc, ""} {
+		got, err := k.Encrypt(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("got %s, want %s", got, v)
+		}
+	}
+}
+
+func TestKeyringDecrypt(t *testing.T) {
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withID, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := seal("secret", oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := EncryptedPrefix + encoded
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		value   string
+		want    string
+		err     error
+	}{
+		{name: "plaintext", keyring: current, value: "secret", want: "secret"},
+		{name: "previous key", keyring: rotated, value: withID, want: "secret"},
+		{name: "legacy value with previous key", keyring: rotated, value: legacy, want: "secret"},
+		{name: "unknown key", keyring: current, value: withID, err: ErrUnknownKey},
+		{name: "legacy value with unknown key", keyring: current, value: legacy, err: ErrDecryptionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyring.Decrypt(tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringReEncrypt(t *testing.T) {
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reenc, changed, err := k.ReEncrypt(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || !strings.HasPrefix(reenc, EncryptedPrefix+KeyID(newKey)+":") {
+		t.Fatalf("got %s (changed %v), want value encrypted with the new key", reenc, changed)
+	}
+
+	// Values encrypted with the primary key are left as-is.
+	again, changed, err := k.ReEncrypt(reenc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || again != reenc {
+		t.Errorf("got %s (changed %v), want %s unchanged", again, changed, reenc)
+	}
+
+	// The new key alone decrypts the re-encrypted value.
+	current, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := current.Decrypt(reenc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec != "secret" {
+		t.Errorf("got %s, want secret", dec)
+	}
+}
+
+func TestNewKeyringInvalidKey(t *testing.T) {
+	if _, err := NewKeyring(newKey, "short"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidKey)
+	}
+}
@@ -41,9 +41,10 @@ type Opts struct {
 
 // queries contains prepared SQL queries.
 type queries struct {
-	Insert *sqlx.Stmt `query:"insert"`
-	Get    *sqlx.Stmt `query:"get"`
-	Update *sqlx.Stmt `query:"update"`
+	Insert       *sqlx.Stmt `query:"insert"`
+	Get          *sqlx.Stmt `query:"get"`
+	Update       *sqlx.Stmt `query:"update"`
+	GetByContact *sqlx.Stmt `query:"get-by-contact"`
 }
 
 // New creates and returns a new instance of the Manager.
@@ -86,6 +87,16 @@ func (m *Manager) Get(uuid string) (models.CSATResponse, error) {
 	return csat, nil
 }
 
+// GetByContact returns the CSATs of all conversations of a contact.
+func (m *Manager) GetByContact(contactID int) ([]models.CSATResponse, error) {
+	var responses = make([]models.CSATResponse, 0)
+	if err := m.q.GetByContact.Select(&responses, contactID); err != nil {
+		m.lo.Error("error getting contact CSATs", "contact_id", contactID, "error", err)
+		return responses, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.csatSurvey}"), nil)
+	}
+	return responses, nil
+}
+
 // UpdateResponse updates the CSAT response for the given csat.
 func (m *Manager) UpdateResponse(uuid string, score int, feedback string) error {
 	csat, err := m.Get(uuid)
@@ -23,3 +23,17 @@ SET rating = $2,
     feedback = $3,
     response_timestamp = NOW()
 WHERE uuid = $1;
+
+-- name: get-by-contact
+SELECT csat_responses.id,
+    csat_responses.uuid,
+    csat_responses.created_at,
+    csat_responses.updated_at,
+    csat_responses.conversation_id,
+    csat_responses.rating,
+    csat_responses.feedback,
+    csat_responses.response_timestamp
+FROM csat_responses
+    JOIN conversations ON conversations.id = csat_responses.conversation_id
+WHERE conversations.contact_id = $1
+ORDER BY csat_responses.created_at;
@@ -6,6 +6,16 @@ import (
 	"github.com/lib/pq"
 )
 
+const (
+	// Custom attribute data types.
+	DataTypeText     = "text"
+	DataTypeNumber   = "number"
+	DataTypeCheckbox = "checkbox"
+	DataTypeDate     = "date"
+	DataTypeLink     = "link"
+	DataTypeList     = "list"
+)
+
 type CustomAttribute struct {
 	ID          int            `db:"id" json:"id"`
 	CreatedAt   time.Time      `db:"created_at" json:"created_at"`
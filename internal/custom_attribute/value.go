@@ -0,0 +1,68 @@
+package customAttribute
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/custom_attribute/models"
+)
+
+// ParseValue parses a raw string value, e.g. from a CSV cell, into the JSON value stored for the
+// custom attribute, validating it against the attribute's data type, list values and regex.
+func ParseValue(attr models.CustomAttribute, raw string) (any, error) {
+	raw = strings.TrimSpace(raw)
+	switch attr.DataType {
+	case models.DataTypeNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a number", attr.Key, raw)
+		}
+		return n, nil
+	case models.DataTypeCheckbox:
+		switch strings.ToLower(raw) {
+		case "true", "yes", "1":
+			return true, nil
+		case "false", "no", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%s: %q is not a boolean", attr.Key, raw)
+	case models.DataTypeDate:
+		for _, layout := range []string{time.DateOnly, time.RFC3339} {
+			if _, err := time.Parse(layout, raw); err == nil {
+				return raw, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: %q is not a date (YYYY-MM-DD)", attr.Key, raw)
+	case models.DataTypeLink:
+		u, err := url.ParseRequestURI(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("%s: %q is not a URL", attr.Key, raw)
+		}
+		return raw, nil
+	case models.DataTypeList:
+		if !slices.Contains(attr.Values, raw) {
+			return nil, fmt.Errorf("%s: %q is not one of %s", attr.Key, raw, strings.Join(attr.Values, ", "))
+		}
+		return raw, nil
+	}
+
+	// Text, regex applies only to text attributes.
+	if attr.Regex != "" {
+		re, err := regexp.Compile(attr.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid regex %q: %v", attr.Key, attr.Regex, err)
+		}
+		if !re.MatchString(raw) {
+			if attr.RegexHint != "" {
+				return nil, fmt.Errorf("%s: %q is invalid: %s", attr.Key, raw, attr.RegexHint)
+			}
+			return nil, fmt.Errorf("%s: %q does not match %s", attr.Key, raw, attr.Regex)
+		}
+	}
+	return raw, nil
+}
@@ -0,0 +1,96 @@
+package customAttribute
+
+import (
+	"testing"
+
+	"github.com/abhinavxd/libredesk/internal/custom_attribute/models"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		attr     models.CustomAttribute
+		raw      string
+		expected any
+		wantErr  bool
+	}{
+		{
+			name:     "text",
+			attr:     models.CustomAttribute{Key: "plan", DataType: models.DataTypeText},
+			raw:      " gold ",
+			expected: "gold",
+		},
+		{
+			name:     "text matching regex",
+			attr:     models.CustomAttribute{Key: "client_id", DataType: models.DataTypeText, Regex: `^[A-Z]{3}\d{3}$`},
+			raw:      "ABC123",
+			expected: "ABC123",
+		},
+		{
+			name:    "text not matching regex",
+			attr:    models.CustomAttribute{Key: "client_id", DataType: models.DataTypeText, Regex: `^[A-Z]{3}\d{3}$`},
+			raw:     "abc",
+			wantErr: true,
+		},
+		{
+			name:     "number",
+			attr:     models.CustomAttribute{Key: "seats", DataType: models.DataTypeNumber},
+			raw:      "42",
+			expected: float64(42),
+		},
+		{
+			name:    "invalid number",
+			attr:    models.CustomAttribute{Key: "seats", DataType: models.DataTypeNumber},
+			raw:     "many",
+			wantErr: true,
+		},
+		{
+			name:     "checkbox",
+			attr:     models.CustomAttribute{Key: "vip", DataType: models.DataTypeCheckbox},
+			raw:      "Yes",
+			expected: true,
+		},
+		{
+			name:     "date",
+			attr:     models.CustomAttribute{Key: "renewal", DataType: models.DataTypeDate},
+			raw:      "2025-01-31",
+			expected: "2025-01-31",
+		},
+		{
+			name:    "invalid date",
+			attr:    models.CustomAttribute{Key: "renewal", DataType: models.DataTypeDate},
+			raw:     "31/01/2025",
+			wantErr: true,
+		},
+		{
+			name:    "invalid link",
+			attr:    models.CustomAttribute{Key: "site", DataType: models.DataTypeLink},
+			raw:     "example",
+			wantErr: true,
+		},
+		{
+			name:     "list value",
+			attr:     models.CustomAttribute{Key: "tier", DataType: models.DataTypeList, Values: []string{"free", "pro"}},
+			raw:      "pro",
+			expected: "pro",
+		},
+		{
+			name:    "value not in list",
+			attr:    models.CustomAttribute{Key: "tier", DataType: models.DataTypeList, Values: []string{"free", "pro"}},
+			raw:     "enterprise",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseValue(tt.attr, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.expected {
+				t.Errorf("ParseValue() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Package deadletter keeps incoming messages that failed processing, along with their payload and error,
+// so they can be retried or discarded by admins instead of being lost.
+package deadletter
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	cmodels "github.com/abhinavxd/libredesk/internal/conversation/models"
+	"github.com/abhinavxd/libredesk/internal/dbutil"
+	"github.com/abhinavxd/libredesk/internal/deadletter/models"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	notifier "github.com/abhinavxd/libredesk/internal/notification"
+	nmodels "github.com/abhinavxd/libredesk/internal/notification/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/go-i18n"
+	"github.com/volatiletech/null/v9"
+	"github.com/zerodha/logf"
+)
+
+var (
+	//go:embed queries.sql
+	efs embed.FS
+)
+
+// Manager manages dead-lettered incoming messages.
+type Manager struct {
+	q              queries
+	lo             *logf.Logger
+	i18n           *i18n.I18n
+	dispatcher     *notifier.Dispatcher
+	alertThreshold int
+}
+
+// Opts contains options for initializing the Manager.
+type Opts struct {
+	DB   *sqlx.DB
+	Lo   *logf.Logger
+	I18n *i18n.I18n
+	// AlertThreshold is the number of pending messages at which admins are alerted, and again at every
+	// multiple of it. 0 disables alerts.
+	AlertThreshold int
+}
+
+// queries contains prepared SQL queries.
+type queries struct {
+	GetMessages        *sqlx.Stmt `query:"get-messages"`
+	GetMessage         *sqlx.Stmt `query:"get-message"`
+	UpsertMessage      *sqlx.Stmt `query:"upsert-message"`
+	UpdateAttempt      *sqlx.Stmt `query:"update-attempt"`
+	DeleteMessage      *sqlx.Stmt `query:"delete-message"`
+	DiscardMessage     *sqlx.Stmt `query:"discard-message"`
+	SourceIDExists     *sqlx.Stmt `query:"source-id-exists"`
+	CountPending       *sqlx.Stmt `query:"count-pending"`
+	GetAlertRecipients *sqlx.Stmt `query:"get-alert-recipients"`
+}
+
+// New creates and returns a new instance of the Manager.
+func New(dispatcher *notifier.Dispatcher, opts Opts) (*Manager, error) {
+	var q queries
+	if err := dbutil.ScanSQLFile("queries.sql", &q, opts.DB, efs); err != nil {
+		return nil, err
+	}
+	return &Manager{
+		q:              q,
+		lo:             opts.Lo,
+		i18n:           opts.I18n,
+		dispatcher:     dispatcher,
+		alertThreshold: opts.AlertThreshold,
+	}, nil
+}
+
+// GetAll retrieves all pending dead-lettered messages.
+func (m *Manager) GetAll() ([]models.Message, error) {
+	var msgs = make([]models.Message, 0)
+	if err := m.q.GetMessages.Select(&msgs); err != nil {
+		m.lo.Error("error fetching dead-lettered messages", "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.message")), nil)
+	}
+	return msgs, nil
+}
+
+// Get retrieves a pending dead-lettered message by ID.
+func (m *Manager) Get(id int) (models.Message, error) {
+	var msg models.Message
+	if err := m.q.GetMessage.Get(&msg, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return msg, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.message}"), nil)
+		}
+		m.lo.Error("error fetching dead-lettered message", "id", id, "error", err)
+		return msg, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.message}"), nil)
+	}
+	return msg, nil
+}
+
+// Add stores an incoming message that failed processing along with the error. Admins are alerted when the
+// number of pending messages reaches the alert threshold.
+func (m *Manager) Add(in cmodels.IncomingMessage, processErr error) error {
+	payload, err := cmodels.EncodeIncomingMessage(in)
+	if err != nil {
+		m.lo.Error("error encoding dead-lettered message", "source_id", in.Message.SourceID.String, "error", err)
+		return err
+	}
+
+	var inserted bool
+	if err := m.q.UpsertMessage.Get(&inserted, in.InboxID, in.Message.SourceID.String, in.Contact.Email.String,
+		in.Message.Subject, processErr.Error(), payload); err != nil {
+		m.lo.Error("error inserting dead-lettered message", "source_id", in.Message.SourceID.String, "error", err)
+		return err
+	}
+	m.lo.Warn("incoming message moved to dead-letter queue", "source_id", in.Message.SourceID.String, "error", processErr)
+
+	if inserted {
+		m.alert(processErr)
+	}
+	return nil
+}
+
+// Exists returns true if a message with the source ID is in the dead-letter queue, pending or discarded.
+func (m *Manager) Exists(sourceID string) (bool, error) {
+	var exists bool
+	if err := m.q.SourceIDExists.Get(&exists, sourceID); err != nil {
+		m.lo.Error("error checking if message is dead-lettered", "source_id", sourceID, "error", err)
+		return false, err
+	}
+	return exists, nil
+}
+
+// Retry processes a dead-lettered message again with the process function. The message is removed from the
+// queue on success, otherwise the error and attempt count are updated.
+func (m *Manager) Retry(id int, process func(cmodels.IncomingMessage) error) error {
+	msg, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	in, err := cmodels.DecodeIncomingMessage(msg.Payload)
+	if err != nil {
+		m.lo.Error("error decoding dead-lettered message", "id", id, "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorParsing", "name", "{globals.terms.message}"), nil)
+	}
+
+	if procErr := process(in); procErr != nil {
+		if _, err := m.q.UpdateAttempt.Exec(id, procErr.Error()); err != nil {
+			m.lo.Error("error updating dead-lettered message attempt", "id", id, "error", err)
+		}
+		return envelope.NewError(envelope.GeneralError, m.i18n.T("deadLetter.retryFailed"), procErr.Error())
+	}
+
+	if _, err := m.q.DeleteMessage.Exec(id); err != nil {
+		m.lo.Error("error deleting dead-lettered message", "id", id, "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.message}"), nil)
+	}
+	return nil
+}
+
+// Discard discards a dead-lettered message. Its source ID is kept so the message is not fetched again.
+func (m *Manager) Discard(id int) error {
+	if _, err := m.q.DiscardMessage.Exec(id); err != nil {
+		m.lo.Error("error discarding dead-lettered message", "id", id, "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.message}"), nil)
+	}
+	return nil
+}
+
+// alert notifies admins when the number of pending messages reaches a multiple of the alert threshold.
+func (m *Manager) alert(lastErr error) {
+	if m.alertThreshold <= 0 || m.dispatcher == nil {
+		return
+	}
+	var count int
+	if err := m.q.CountPending.Get(&count); err != nil {
+		m.lo.Error("error counting dead-lettered messages", "error", err)
+		return
+	}
+	if count < m.alertThreshold || count%m.alertThreshold != 0 {
+		return
+	}
+
+	var recipients []int
+	if err := m.q.GetAlertRecipients.Select(&recipients); err != nil {
+		m.lo.Error("error fetching dead-letter alert recipients", "error", err)
+		return
+	}
+	m.lo.Warn("dead-letter queue threshold reached, alerting admins", "count", count, "recipients", len(recipients))
+	m.dispatcher.Send(notifier.Notification{
+		Type:         nmodels.NotificationTypeDeadLetter,
+		RecipientIDs: recipients,
+		Title:        m.i18n.Ts("deadLetter.alertTitle", "count", fmt.Sprintf("%d", count)),
+		Body:         null.StringFrom(lastErr.Error()),
+	})
+}
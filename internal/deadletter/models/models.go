@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null/v9"
+)
+
+const (
+	// StatusPending messages are waiting to be retried or discarded.
+	StatusPending = "pending"
+	// StatusDiscarded messages were discarded by an admin, they are kept without their payload so the
+	// inbox does not fetch the message again.
+	StatusDiscarded = "discarded"
+)
+
+// Message is an incoming message that failed processing.
+type Message struct {
+	ID            int         `db:"id" json:"id"`
+	CreatedAt     time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time   `db:"updated_at" json:"updated_at"`
+	Status        string      `db:"status" json:"status"`
+	InboxID       null.Int    `db:"inbox_id" json:"inbox_id"`
+	SourceID      null.String `db:"source_id" json:"source_id"`
+	Sender        string      `db:"sender" json:"sender"`
+	Subject       string      `db:"subject" json:"subject"`
+	Error         string      `db:"error" json:"error"`
+	Attempts      int         `db:"attempts" json:"attempts"`
+	LastAttemptAt time.Time   `db:"last_attempt_at" json:"last_attempt_at"`
+	Payload       []byte      `db:"payload" json:"-"`
+}
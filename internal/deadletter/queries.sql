@@ -0,0 +1,58 @@
+-- name: get-messages
+SELECT id, created_at, updated_at, status, inbox_id, source_id, sender, subject, error, attempts, last_attempt_at
+FROM dead_letter_messages
+WHERE status = 'pending'
+ORDER BY created_at DESC;
+
+-- name: get-message
+SELECT id, created_at, updated_at, status, inbox_id, source_id, sender, subject, error, attempts, last_attempt_at, payload
+FROM dead_letter_messages
+WHERE id = $1 AND status = 'pending';
+
+-- name: upsert-message
+-- Messages failing again, e.g. when fetched again by the inbox, update the existing entry. Returns true for new entries.
+INSERT INTO dead_letter_messages (inbox_id, source_id, sender, subject, error, payload)
+VALUES (NULLIF($1, 0), NULLIF($2, ''), $3, $4, $5, $6)
+ON CONFLICT (source_id) DO UPDATE
+SET error = EXCLUDED.error,
+    payload = EXCLUDED.payload,
+    status = 'pending',
+    attempts = dead_letter_messages.attempts + 1,
+    last_attempt_at = NOW(),
+    updated_at = NOW()
+RETURNING (xmax = 0) AS inserted;
+
+-- name: update-attempt
+UPDATE dead_letter_messages
+SET error = $2,
+    attempts = attempts + 1,
+    last_attempt_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: delete-message
+DELETE FROM dead_letter_messages WHERE id = $1;
+
+-- name: discard-message
+UPDATE dead_letter_messages
+SET status = 'discarded',
+    payload = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND status = 'pending';
+
+-- name: source-id-exists
+SELECT EXISTS(SELECT 1 FROM dead_letter_messages WHERE source_id = $1);
+
+-- name: count-pending
+SELECT COUNT(*) FROM dead_letter_messages WHERE status = 'pending';
+
+-- name: get-alert-recipients
+-- Enabled agents with a role that can manage dead-lettered messages.
+SELECT DISTINCT u.id
+FROM users u
+JOIN user_roles ur ON ur.user_id = u.id
+JOIN roles r ON r.id = ur.role_id
+WHERE u.type = 'agent'
+  AND u.enabled = TRUE
+  AND u.deleted_at IS NULL
+  AND 'dead_letters:manage' = ANY(r.permissions);
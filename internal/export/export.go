@@ -0,0 +1,400 @@
+// Package export manages background export jobs that write a downloadable file. Jobs are kept in the DB and their
+// files in the media store, so the status and file of a job can be fetched from any instance.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/dbutil"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	mmodels "github.com/abhinavxd/libredesk/internal/media/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/go-i18n"
+	"github.com/lib/pq"
+	"github.com/volatiletech/null/v9"
+	"github.com/zerodha/logf"
+)
+
+var (
+	//go:embed queries.sql
+	efs embed.FS
+)
+
+const (
+	// Completed export jobs and their files are kept for this long before they are removed.
+	defaultRetention = 24 * time.Hour
+
+	// heartbeatInterval is how often a running job saves its progress. A running job not saved for staleAfter was
+	// interrupted, e.g. by a crash of its instance, and is failed.
+	heartbeatInterval = 10 * time.Second
+	staleAfter        = 2 * time.Minute
+
+	statusCompleted = "completed"
+	statusFailed    = "failed"
+)
+
+// Job represents the status of an export job.
+type Job struct {
+	ID        int            `db:"id" json:"-"`
+	UUID      string         `db:"uuid" json:"uuid"`
+	Kind      string         `db:"kind" json:"kind"`
+	UserID    int            `db:"user_id" json:"user_id"`
+	Running   bool           `db:"running" json:"running"`
+	Failed    bool           `db:"failed" json:"failed"`
+	Logs      pq.StringArray `db:"logs" json:"logs"`
+	Total     int            `db:"total" json:"total"`
+	Success   int            `db:"success" json:"success"`
+	Errors    int            `db:"errors" json:"errors"`
+	FileName  string         `db:"file_name" json:"file_name"`
+	MediaID   null.Int       `db:"media_id" json:"-"`
+	StartedAt time.Time      `db:"started_at" json:"started_at"`
+	EndedAt   null.Time      `db:"ended_at" json:"ended_at"`
+}
+
+// Tracker lets an export function report the progress of its job.
+type Tracker struct {
+	m   *Manager
+	mu  sync.Mutex
+	job Job
+}
+
+// mediaStore stores the files of export jobs.
+type mediaStore interface {
+	UploadAndInsert(fileName, contentType, contentID string, modelType null.String, modelID null.Int, content io.ReadSeeker, fileSize int, disposition null.String, meta []byte) (mmodels.Media, error)
+	Get(id int, uuid string) (mmodels.Media, error)
+	Delete(name string) error
+}
+
+// Manager manages background export jobs.
+type Manager struct {
+	q         queries
+	media     mediaStore
+	lo        *logf.Logger
+	i18n      *i18n.I18n
+	retention time.Duration
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// Opts contains options for initializing the Manager.
+type Opts struct {
+	DB   *sqlx.DB
+	Lo   *logf.Logger
+	I18n *i18n.I18n
+}
+
+// queries contains prepared SQL queries.
+type queries struct {
+	InsertJob      *sqlx.Stmt `query:"insert-job"`
+	GetJob         *sqlx.Stmt `query:"get-job"`
+	UpdateProgress *sqlx.Stmt `query:"update-progress"`
+	FinishJob      *sqlx.Stmt `query:"finish-job"`
+	FailStaleJobs  *sqlx.Stmt `query:"fail-stale-jobs"`
+	GetExpiredJobs *sqlx.Stmt `query:"get-expired-jobs"`
+	DeleteJob      *sqlx.Stmt `query:"delete-job"`
+}
+
+// New creates and returns a new instance of the Manager.
+func New(media mediaStore, opts Opts) (*Manager, error) {
+	var q queries
+	if err := dbutil.ScanSQLFile("queries.sql", &q, opts.DB, efs); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		q:         q,
+		media:     media,
+		lo:        opts.Lo,
+		i18n:      opts.I18n,
+		retention: defaultRetention,
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+// Submit starts a new export job of the given kind for a user. fn writes the export file to w.
+// A user can only run one export job of a kind at a time.
+func (m *Manager) Submit(kind string, userID int, fileName string, fn func(t *Tracker, w io.Writer) error) (Job, error) {
+	// Fail jobs interrupted by a crash so they do not block new ones.
+	m.failStaleJobs()
+
+	var job Job
+	if err := m.q.InsertJob.Get(&job, kind, userID, fileName); err != nil {
+		if dbutil.IsUniqueViolationError(err) {
+			return Job{}, envelope.NewError(envelope.ConflictError, m.i18n.T("export.exportAlreadyInProgress"), nil)
+		}
+		m.lo.Error("error inserting export job", "kind", kind, "user_id", userID, "error", err)
+		return Job{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.export}"), nil)
+	}
+
+	m.lo.Info("starting export job", "uuid", job.UUID, "kind", kind, "user_id", userID)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		t := &Tracker{m: m, job: job}
+		stop := t.heartbeat()
+		media, err := m.run(t, fn)
+		stop()
+		m.finish(t, media, err)
+	}()
+
+	return job, nil
+}
+
+// Get returns the status of an export job of a user.
+func (m *Manager) Get(jobUUID string, userID int) (Job, error) {
+	var job Job
+	if _, err := uuid.Parse(jobUUID); err != nil {
+		return job, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.export}"), nil)
+	}
+	if err := m.q.GetJob.Get(&job, jobUUID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return job, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.export}"), nil)
+		}
+		m.lo.Error("error fetching export job", "uuid", jobUUID, "error", err)
+		return job, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.export}"), nil)
+	}
+	return job, nil
+}
+
+// GetFile returns the media of the file of a completed export job of a user.
+func (m *Manager) GetFile(jobUUID string, userID int) (mmodels.Media, Job, error) {
+	job, err := m.Get(jobUUID, userID)
+	if err != nil {
+		return mmodels.Media{}, job, err
+	}
+	if job.Running || job.Failed {
+		return mmodels.Media{}, job, envelope.NewError(envelope.InputError, m.i18n.T("export.exportNotReady"), nil)
+	}
+	if !job.MediaID.Valid {
+		return mmodels.Media{}, job, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.export}"), nil)
+	}
+	media, err := m.media.Get(job.MediaID.Int, "")
+	if err != nil {
+		return mmodels.Media{}, job, err
+	}
+	return media, job, nil
+}
+
+// Run periodically fails interrupted jobs and removes jobs that ended more than the retention ago along with their
+// files. It blocks until the context is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.failStaleJobs()
+			m.deleteExpiredJobs()
+		}
+	}
+}
+
+// Close gracefully shuts down the manager, running jobs are cancelled and marked as failed.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// SetTotal sets the total number of items being exported.
+func (t *Tracker) SetTotal(total int) {
+	t.mu.Lock()
+	t.job.Total = total
+	t.mu.Unlock()
+}
+
+// Success records a successfully exported item.
+func (t *Tracker) Success() {
+	t.mu.Lock()
+	t.job.Success++
+	t.mu.Unlock()
+}
+
+// Error records an item that could not be exported.
+func (t *Tracker) Error(msg string) {
+	t.mu.Lock()
+	t.job.Errors++
+	t.job.Logs = append(t.job.Logs, msg)
+	t.mu.Unlock()
+}
+
+// Log appends a log message to the job.
+func (t *Tracker) Log(msg string) {
+	t.mu.Lock()
+	t.job.Logs = append(t.job.Logs, msg)
+	t.mu.Unlock()
+}
+
+// Context returns a context that is cancelled when the manager shuts down.
+func (t *Tracker) Context() context.Context {
+	return t.m.ctx
+}
+
+// heartbeat periodically saves the progress of the job until the returned function is called.
+func (t *Tracker) heartbeat() func() {
+	var (
+		done = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				job := t.snapshot()
+				if _, err := t.m.q.UpdateProgress.Exec(job.ID, job.Total, job.Success, job.Errors, job.Logs); err != nil {
+					t.m.lo.Error("error saving export job progress", "uuid", job.UUID, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// snapshot returns a copy of the job that is safe to use without holding the lock.
+func (t *Tracker) snapshot() Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := t.job
+	out.Logs = append(pq.StringArray{}, t.job.Logs...)
+	return out
+}
+
+// run writes the export file to a temporary file with fn, recovering from panics, and uploads it to the media store.
+func (m *Manager) run(t *Tracker, fn func(t *Tracker, w io.Writer) error) (media mmodels.Media, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.lo.Error("export job panicked", "uuid", t.job.UUID, "panic", r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	f, err := os.CreateTemp("", "libredesk-export-*")
+	if err != nil {
+		return media, fmt.Errorf("creating export file: %w", err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	if err := fn(t, f); err != nil {
+		return media, err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return media, fmt.Errorf("reading export file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return media, fmt.Errorf("reading export file: %w", err)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(t.job.FileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	media, err = m.media.UploadAndInsert(t.job.FileName, contentType, "", null.StringFrom(mmodels.ModelExports), null.IntFrom(t.job.ID), f, int(size), null.StringFrom("attachment"), []byte("{}"))
+	if err != nil {
+		return media, fmt.Errorf("storing export file: %w", err)
+	}
+	return media, nil
+}
+
+// finish saves the outcome of a job.
+func (m *Manager) finish(t *Tracker, media mmodels.Media, err error) {
+	var (
+		status  = statusCompleted
+		mediaID null.Int
+	)
+	if err != nil {
+		status = statusFailed
+		t.Log(fmt.Sprintf("Error: %v", err))
+	} else {
+		mediaID = null.IntFrom(media.ID)
+	}
+
+	job := t.snapshot()
+	res, dbErr := m.q.FinishJob.Exec(job.ID, status, job.Total, job.Success, job.Errors, job.Logs, mediaID)
+	if dbErr != nil {
+		m.lo.Error("error saving export job", "uuid", job.UUID, "error", dbErr)
+		if mediaID.Valid {
+			m.media.Delete(media.UUID)
+		}
+		return
+	}
+	// The job was failed as stale in the meantime, e.g. its heartbeat could not reach the DB, the outcome is dropped.
+	if n, _ := res.RowsAffected(); n == 0 {
+		m.lo.Warn("export job no longer running, dropping its outcome", "uuid", job.UUID, "kind", job.Kind)
+		if mediaID.Valid {
+			m.media.Delete(media.UUID)
+		}
+		return
+	}
+
+	if err != nil {
+		m.lo.Error("export job failed", "uuid", job.UUID, "kind", job.Kind, "error", err)
+		return
+	}
+	m.lo.Info("export job completed", "uuid", job.UUID, "kind", job.Kind, "total", job.Total, "success", job.Success, "errors", job.Errors)
+}
+
+// failStaleJobs fails running jobs that stopped saving their progress.
+func (m *Manager) failStaleJobs() {
+	res, err := m.q.FailStaleJobs.Exec(time.Now().Add(-staleAfter), "Error: export interrupted")
+	if err != nil {
+		m.lo.Error("error failing interrupted export jobs", "error", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		m.lo.Warn("failed interrupted export jobs", "count", n)
+	}
+}
+
+// deleteExpiredJobs removes jobs that ended more than the retention ago and their files.
+func (m *Manager) deleteExpiredJobs() {
+	var jobs []struct {
+		ID        int         `db:"id"`
+		MediaUUID null.String `db:"media_uuid"`
+	}
+	if err := m.q.GetExpiredJobs.Select(&jobs, time.Now().Add(-m.retention)); err != nil {
+		m.lo.Error("error fetching expired export jobs", "error", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.MediaUUID.Valid {
+			if err := m.media.Delete(job.MediaUUID.String); err != nil {
+				continue
+			}
+		}
+		if _, err := m.q.DeleteJob.Exec(job.ID); err != nil {
+			m.lo.Error("error deleting export job", "id", job.ID, "error", err)
+			continue
+		}
+		m.lo.Debug("cleaned up old export job", "id", job.ID)
+	}
+}
@@ -0,0 +1,37 @@
+-- name: insert-job
+INSERT INTO export_jobs (kind, user_id, file_name)
+VALUES ($1, $2, $3)
+RETURNING id, "uuid", kind, user_id, status = 'running' AS running, status = 'failed' AS failed, logs, total, success,
+    errors, file_name, media_id, created_at AS started_at, ended_at;
+
+-- name: get-job
+SELECT id, "uuid", kind, user_id, status = 'running' AS running, status = 'failed' AS failed, logs, total, success,
+    errors, file_name, media_id, created_at AS started_at, ended_at
+FROM export_jobs
+WHERE "uuid" = $1 AND user_id = $2;
+
+-- name: update-progress
+-- Also serves as the heartbeat of a running job.
+UPDATE export_jobs
+SET total = $2, success = $3, errors = $4, logs = $5, updated_at = NOW()
+WHERE id = $1 AND status = 'running';
+
+-- name: finish-job
+UPDATE export_jobs
+SET status = $2, total = $3, success = $4, errors = $5, logs = $6, media_id = $7, ended_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'running';
+
+-- name: fail-stale-jobs
+-- Fails running jobs whose instance stopped refreshing them, e.g. after a crash.
+UPDATE export_jobs
+SET status = 'failed', logs = array_append(logs, $2), ended_at = NOW(), updated_at = NOW()
+WHERE status = 'running' AND updated_at < $1;
+
+-- name: get-expired-jobs
+SELECT export_jobs.id, media."uuid" AS media_uuid
+FROM export_jobs
+LEFT JOIN media ON media.id = export_jobs.media_id
+WHERE export_jobs.ended_at < $1;
+
+-- name: delete-job
+DELETE FROM export_jobs WHERE id = $1;
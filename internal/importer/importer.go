@@ -3,6 +3,7 @@ package importer
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,13 +14,18 @@ import (
 
 // Job represents the status of an import job.
 type Job struct {
-	Running   bool      `json:"running"`
-	Logs      []string  `json:"logs"`
-	Total     int       `json:"total"`
-	Success   int       `json:"success"`
-	Errors    int       `json:"errors"`
+	Running bool     `json:"running"`
+	Logs    []string `json:"logs"`
+	Total   int      `json:"total"`
+	Success int      `json:"success"`
+	Errors  int      `json:"errors"`
+	// Skipped is the number of items not imported as they already exist.
+	Skipped   int       `json:"skipped"`
 	StartedAt time.Time `json:"started_at"`
 	EndedAt   time.Time `json:"ended_at"`
+
+	// Report holds rows of a downloadable report, e.g. rejected CSV rows along with the reason.
+	Report [][]string `json:"-"`
 }
 
 // Importer manages background import jobs.
@@ -90,7 +96,7 @@ func (i *Importer) Submit(namespace string, fn func() error) error {
 			i.mu.Unlock()
 
 			i.lo.Info("import job completed", "namespace", namespace,
-				"total", status.Total, "success", status.Success, "errors", status.Errors)
+				"total", status.Total, "success", status.Success, "errors", status.Errors, "skipped", status.Skipped)
 		}()
 
 		if err := fn(); err != nil {
@@ -128,6 +134,29 @@ func (i *Importer) AddLog(namespace, message string) {
 	}
 }
 
+// AddReportRow appends a row to the job's report.
+func (i *Importer) AddReportRow(namespace string, row []string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if status, exists := i.jobs[namespace]; exists {
+		status.Report = append(status.Report, row)
+	}
+}
+
+// GetReport returns a copy of the report rows of an import job.
+func (i *Importer) GetReport(namespace string) ([][]string, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	status, exists := i.jobs[namespace]
+	if !exists {
+		return nil, envelope.NewError(envelope.NotFoundError,
+			i.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.import}"), nil)
+	}
+	return slices.Clone(status.Report), nil
+}
+
 // UpdateCounts updates the success/error counts and total.
 func (i *Importer) UpdateCounts(namespace string, total, success, errors int) {
 	i.mu.Lock()
@@ -146,6 +175,16 @@ func (i *Importer) UpdateCounts(namespace string, total, success, errors int) {
 	}
 }
 
+// AddSkipped adds to the count of items skipped as they already exist.
+func (i *Importer) AddSkipped(namespace string, skipped int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if status, exists := i.jobs[namespace]; exists {
+		status.Skipped += skipped
+	}
+}
+
 // Close gracefully shuts down the importer.
 func (i *Importer) Close() {
 	i.cancel()
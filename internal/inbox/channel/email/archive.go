@@ -0,0 +1,208 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+	umodels "github.com/abhinavxd/libredesk/internal/user/models"
+	"github.com/emersion/go-imap/v2"
+	"github.com/jhillyerd/enmime"
+	"github.com/volatiletech/null/v9"
+)
+
+const (
+	// maxArchiveMessageSize is the maximum size of a single message read from an mbox archive.
+	maxArchiveMessageSize = 64 * 1024 * 1024
+)
+
+var (
+	ErrNoMessageID = errors.New("message has no Message-ID")
+	ErrNoSender    = errors.New("message has no sender")
+	ErrNoRecipient = errors.New("message has no recipient")
+)
+
+// ArchivedMessage is a message parsed from an email archive along with its original date.
+type ArchivedMessage struct {
+	models.IncomingMessage
+	Date time.Time
+}
+
+// ParseArchivedMessage parses a raw RFC 5322 message from an email archive (mbox or .eml) of an inbox.
+// Messages sent from the inbox address are returned as outgoing messages with the first recipient as the contact,
+// all other messages are returned as incoming messages from the sender.
+func ParseArchivedMessage(raw io.Reader, inboxID int, inboxAddress string) (ArchivedMessage, error) {
+	var msg ArchivedMessage
+
+	envelope, err := enmime.ReadEnvelope(raw)
+	if err != nil {
+		return msg, fmt.Errorf("parsing email envelope: %w", err)
+	}
+
+	messageID := extractMessageIDFromHeaders(envelope)
+	if messageID == "" {
+		return msg, ErrNoMessageID
+	}
+
+	from := addressList(envelope, "From")
+	if len(from) == 0 {
+		return msg, ErrNoSender
+	}
+	to, cc, bcc := addressList(envelope, "To"), addressList(envelope, "Cc"), addressList(envelope, "Bcc")
+
+	// Messages without a valid date are imported at the time of import.
+	date, err := envelope.Date()
+	if err != nil {
+		date = time.Now()
+	}
+
+	var (
+		subject    = envelope.GetHeader("Subject")
+		contact    = from[0]
+		msgType    = models.MessageIncoming
+		senderType = models.SenderTypeContact
+		status     = models.MessageStatusReceived
+	)
+	if inboxAddress != "" && strings.EqualFold(from[0].Address, inboxAddress) {
+		if len(to) == 0 {
+			return msg, ErrNoRecipient
+		}
+		contact = to[0]
+		msgType = models.MessageOutgoing
+		senderType = models.SenderTypeAgent
+		status = models.MessageStatusSent
+	}
+
+	meta, err := json.Marshal(map[string]interface{}{
+		"from":    addresses(from),
+		"cc":      addresses(cc),
+		"bcc":     addresses(bcc),
+		"to":      addresses(to),
+		"subject": subject,
+	})
+	if err != nil {
+		return msg, fmt.Errorf("marshalling meta: %w", err)
+	}
+
+	contactEmail := strings.ToLower(contact.Address)
+	mailbox, host, _ := strings.Cut(contact.Address, "@")
+	firstName, lastName := getContactName(imap.Address{Name: contact.Name, Mailbox: mailbox, Host: host})
+	msg = ArchivedMessage{
+		IncomingMessage: models.IncomingMessage{
+			Message: models.Message{
+				Channel:    ChannelEmail,
+				SenderType: senderType,
+				Type:       msgType,
+				InboxID:    inboxID,
+				Status:     status,
+				Subject:    subject,
+				SourceID:   null.StringFrom(messageID),
+				Meta:       meta,
+			},
+			Contact: umodels.User{
+				InboxID:         inboxID,
+				FirstName:       firstName,
+				LastName:        lastName,
+				SourceChannel:   null.NewString(ChannelEmail, true),
+				SourceChannelID: null.NewString(contactEmail, true),
+				Email:           null.NewString(contactEmail, true),
+				Type:            umodels.UserTypeContact,
+			},
+			InboxID: inboxID,
+		},
+		Date: date,
+	}
+	setMessageParts(envelope, &msg.IncomingMessage)
+
+	return msg, nil
+}
+
+// ReadMbox reads messages from an mbox archive and calls fn with the raw bytes of each message.
+// Lines starting with "From " separate messages and ">From " quoted body lines are unquoted (mboxrd).
+func ReadMbox(r io.Reader, fn func(raw []byte) error) error {
+	var (
+		sc      = bufio.NewScanner(r)
+		buf     bytes.Buffer
+		started bool
+	)
+	sc.Buffer(make([]byte, 0, 64*1024), maxArchiveMessageSize)
+
+	flush := func() error {
+		if !started || buf.Len() == 0 {
+			return nil
+		}
+		raw := bytes.Clone(buf.Bytes())
+		buf.Reset()
+		return fn(raw)
+	}
+
+	for sc.Scan() {
+		line := sc.Bytes()
+		if bytes.HasPrefix(line, []byte("From ")) {
+			if err := flush(); err != nil {
+				return err
+			}
+			started = true
+			continue
+		}
+		if !started {
+			continue
+		}
+
+		// Unquote ">From ", ">>From " etc.
+		if trimmed := bytes.TrimLeft(line, ">"); len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From ")) {
+			line = line[1:]
+		}
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\r\n")
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading mbox: %w", err)
+	}
+	return flush()
+}
+
+// addressList returns the addresses in the given header, ignoring unparseable headers.
+func addressList(envelope *enmime.Envelope, header string) []*mail.Address {
+	list, err := envelope.AddressList(header)
+	if err != nil {
+		return nil
+	}
+	out := make([]*mail.Address, 0, len(list))
+	for _, a := range list {
+		if a.Address != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// addresses returns the lowercased email addresses of the given list.
+func addresses(list []*mail.Address) []string {
+	out := make([]string, 0, len(list))
+	for _, a := range list {
+		out = append(out, strings.ToLower(a.Address))
+	}
+	return out
+}
+
+// ReadArchivedMessageDate reads the date of a raw message from an email archive from its headers only, without
+// parsing the body. Messages without a valid date get the time of import, as in ParseArchivedMessage.
+func ReadArchivedMessageDate(raw io.Reader) time.Time {
+	msg, err := mail.ReadMessage(bufio.NewReader(raw))
+	if err != nil {
+		return time.Now()
+	}
+	date, err := msg.Header.Date()
+	if err != nil {
+		return time.Now()
+	}
+	return date
+}
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/conversation/models"
+)
+
+const testMbox = `From customer@example.com Mon Jan  6 10:00:00 2025
+From: Jane Doe <customer@example.com>
+To: support@company.com
+Subject: Printer broken
+Date: Mon, 06 Jan 2025 10:00:00 +0000
+Message-ID: <first@example.com>
+
+Hello,
+>From the office printer, nothing prints.
+
+From support@company.com Mon Jan  6 11:00:00 2025
+From: Support <support@company.com>
+To: Jane Doe <customer@example.com>
+Subject: Re: Printer broken
+Date: Mon, 06 Jan 2025 11:00:00 +0000
+Message-ID: <second@company.com>
+In-Reply-To: <first@example.com>
+References: <first@example.com>
+
+Have you tried turning it off and on again?
+`
+
+func TestReadMbox(t *testing.T) {
+	var msgs []ArchivedMessage
+	err := ReadMbox(strings.NewReader(testMbox), func(raw []byte) error {
+		msg, err := ParseArchivedMessage(strings.NewReader(string(raw)), 1, "support@company.com")
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadMbox() error = %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("ReadMbox() read %d messages, want 2", len(msgs))
+	}
+
+	first, second := msgs[0], msgs[1]
+	if first.Message.Type != models.MessageIncoming || first.Contact.Email.String != "customer@example.com" {
+		t.Errorf("first message = %s from %s, want incoming from customer@example.com", first.Message.Type, first.Contact.Email.String)
+	}
+	if !strings.Contains(first.Message.Content, "From the office printer") || strings.Contains(first.Message.Content, ">From") {
+		t.Errorf("first message content not unquoted: %q", first.Message.Content)
+	}
+	if first.Contact.FirstName != "Jane" || first.Contact.LastName != "Doe" {
+		t.Errorf("first message contact = %q %q, want Jane Doe", first.Contact.FirstName, first.Contact.LastName)
+	}
+	if first.Date.Hour() != 10 {
+		t.Errorf("first message date = %v, want original date", first.Date)
+	}
+
+	if second.Message.Type != models.MessageOutgoing || second.Contact.Email.String != "customer@example.com" {
+		t.Errorf("second message = %s to %s, want outgoing to customer@example.com", second.Message.Type, second.Contact.Email.String)
+	}
+	if second.Message.SourceID.String != "second@company.com" || second.Message.InReplyTo != "first@example.com" {
+		t.Errorf("second message threading = %q in reply to %q", second.Message.SourceID.String, second.Message.InReplyTo)
+	}
+}
+
+func TestReadArchivedMessageDate(t *testing.T) {
+	_, raw, _ := strings.Cut(testMbox, "\n")
+	date := ReadArchivedMessageDate(strings.NewReader(raw))
+	if date.Day() != 6 || date.Hour() != 10 {
+		t.Errorf("ReadArchivedMessageDate() = %v, want the Date header", date)
+	}
+	if date := ReadArchivedMessageDate(strings.NewReader("Subject: No date\r\n\r\nBody")); time.Since(date) > time.Minute {
+		t.Errorf("ReadArchivedMessageDate() = %v without a Date header, want the time of import", date)
+	}
+}
@@ -38,6 +38,17 @@ type Email struct {
 	userStore            inbox.UserStore
 	wg                   sync.WaitGroup
 	tokenRefreshCallback TokenRefreshCallback
+	// polls holds the state of the IMAP connections polled on this node by IMAP address.
+	polls   map[string]*pollState
+	pollsMu sync.Mutex
+	// fetchCh is closed to make the IMAP loops fetch right away, and replaced for the next request.
+	fetchCh chan struct{}
+	fetchMu sync.Mutex
+	// smtpHealthRes holds the results of the last SMTP server checks, refreshed once older than smtpHealthTTL.
+	smtpHealthRes []models.ConnectionHealth
+	smtpCheckedAt time.Time
+	smtpChecking  bool
+	smtpHealthMu  sync.Mutex
 }
 
 // TokenRefreshCallback is called when OAuth tokens are refreshed.
@@ -80,10 +91,27 @@ func New(store inbox.MessageStore, userStore inbox.UserStore, opts Opts) (*Email
 		authType:             opts.Config.AuthType,
 		enablePlusAddressing: opts.Config.EnablePlusAddressing,
 		tokenRefreshCallback: opts.TokenRefreshCallback,
+		polls:                make(map[string]*pollState),
+		fetchCh:              make(chan struct{}),
 	}
 	return e, nil
 }
 
+// Fetch makes the IMAP loops fetch new messages right away instead of at their next read interval.
+func (e *Email) Fetch() {
+	e.fetchMu.Lock()
+	defer e.fetchMu.Unlock()
+	close(e.fetchCh)
+	e.fetchCh = make(chan struct{})
+}
+
+// fetchRequested returns a channel closed on the next call to Fetch.
+func (e *Email) fetchRequested() <-chan struct{} {
+	e.fetchMu.Lock()
+	defer e.fetchMu.Unlock()
+	return e.fetchCh
+}
+
 // Identifier returns the unique identifier of the inbox which is the database ID.
 func (e *Email) Identifier() int {
 	return e.id
@@ -0,0 +1,159 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/inbox/models"
+)
+
+const (
+	// missedPollsThreshold is the number of read intervals without a successful poll after which an IMAP
+	// connection is reported down.
+	missedPollsThreshold = 3
+
+	smtpDialTimeout = 5 * time.Second
+	// smtpHealthTTL is how long the results of the SMTP server checks are reused. Expired results are refreshed
+	// in the background, so health checks don't wait on the SMTP servers.
+	smtpHealthTTL = time.Minute
+)
+
+// pollState is the state of an IMAP connection polled on this node.
+type pollState struct {
+	startedAt     time.Time
+	interval      time.Duration
+	lastSuccessAt time.Time
+	lastErr       error
+}
+
+// Health returns the health of the IMAP connections, from their last polls, and of the SMTP servers, from the
+// last checks connecting to them.
+func (e *Email) Health(ctx context.Context) []models.ConnectionHealth {
+	health := make([]models.ConnectionHealth, 0, len(e.imapCfg)+len(e.smtpCfg))
+
+	e.pollsMu.Lock()
+	for _, cfg := range e.imapCfg {
+		h := models.ConnectionHealth{Type: "imap", Host: imapAddress(cfg), Status: models.HealthInactive}
+		if st, ok := e.polls[imapAddress(cfg)]; ok {
+			h.Status = models.HealthOK
+			since := st.startedAt
+			if !st.lastSuccessAt.IsZero() {
+				t := st.lastSuccessAt
+				h.LastSuccessAt = &t
+				since = t
+			}
+			if st.lastErr != nil {
+				h.Error = st.lastErr.Error()
+			}
+			if time.Since(since) > missedPollsThreshold*st.interval+time.Minute {
+				h.Status = models.HealthDown
+				if h.Error == "" {
+					h.Error = fmt.Sprintf("no successful poll since %s", since.Format(time.RFC3339))
+				}
+			}
+		}
+		health = append(health, h)
+	}
+	e.pollsMu.Unlock()
+
+	return append(health, e.smtpHealth(ctx)...)
+}
+
+// smtpHealth returns the cached health of the SMTP servers. The servers are checked right away the first time,
+// and in the background once the results expire.
+func (e *Email) smtpHealth(ctx context.Context) []models.ConnectionHealth {
+	e.smtpHealthMu.Lock()
+	health := e.smtpHealthRes
+	refresh := health != nil && !e.smtpChecking && time.Since(e.smtpCheckedAt) > smtpHealthTTL
+	if refresh {
+		e.smtpChecking = true
+	}
+	e.smtpHealthMu.Unlock()
+
+	switch {
+	case health == nil:
+		health = e.checkSMTP(ctx)
+		e.storeSMTPHealth(health)
+	case refresh:
+		go func() {
+			e.storeSMTPHealth(e.checkSMTP(context.Background()))
+		}()
+	}
+	return health
+}
+
+// storeSMTPHealth caches the results of the SMTP server checks.
+func (e *Email) storeSMTPHealth(health []models.ConnectionHealth) {
+	e.smtpHealthMu.Lock()
+	e.smtpHealthRes = health
+	e.smtpCheckedAt = time.Now()
+	e.smtpChecking = false
+	e.smtpHealthMu.Unlock()
+}
+
+// checkSMTP checks the SMTP servers in parallel by connecting to them.
+func (e *Email) checkSMTP(ctx context.Context) []models.ConnectionHealth {
+	var (
+		health = make([]models.ConnectionHealth, len(e.smtpCfg))
+		wg     sync.WaitGroup
+	)
+	for i, cfg := range e.smtpCfg {
+		wg.Add(1)
+		go func(i int, cfg models.SMTPConfig) {
+			defer wg.Done()
+			addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+			h := models.ConnectionHealth{Type: "smtp", Host: addr, Status: models.HealthOK}
+
+			var d net.Dialer
+			dialCtx, cancel := context.WithTimeout(ctx, smtpDialTimeout)
+			conn, err := d.DialContext(dialCtx, "tcp", addr)
+			cancel()
+			if err != nil {
+				h.Status = models.HealthDown
+				h.Error = err.Error()
+			} else {
+				conn.Close()
+			}
+			health[i] = h
+		}(i, cfg)
+	}
+	wg.Wait()
+	return health
+}
+
+// startPolling marks an IMAP connection as polled on this node.
+func (e *Email) startPolling(cfg models.IMAPConfig, interval time.Duration) {
+	e.pollsMu.Lock()
+	e.polls[imapAddress(cfg)] = &pollState{startedAt: time.Now(), interval: interval}
+	e.pollsMu.Unlock()
+}
+
+// stopPolling marks an IMAP connection as no longer polled on this node.
+func (e *Email) stopPolling(cfg models.IMAPConfig) {
+	e.pollsMu.Lock()
+	delete(e.polls, imapAddress(cfg))
+	e.pollsMu.Unlock()
+}
+
+// recordPoll records the result of a poll of an IMAP connection.
+func (e *Email) recordPoll(cfg models.IMAPConfig, err error) {
+	e.pollsMu.Lock()
+	defer e.pollsMu.Unlock()
+	st, ok := e.polls[imapAddress(cfg)]
+	if !ok {
+		return
+	}
+	st.lastErr = err
+	if err == nil {
+		st.lastSuccessAt = time.Now()
+	}
+}
+
+// imapAddress returns the address and mailbox of an IMAP connection.
+func imapAddress(cfg models.IMAPConfig) string {
+	return net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)) + "/" + cfg.Mailbox
+}
@@ -1,23 +1,28 @@
 package email
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/abhinavxd/libredesk/internal/attachment"
 	"github.com/abhinavxd/libredesk/internal/conversation/models"
 	"github.com/abhinavxd/libredesk/internal/envelope"
 	imodels "github.com/abhinavxd/libredesk/internal/inbox/models"
 	"github.com/abhinavxd/libredesk/internal/stringutil"
+	"github.com/abhinavxd/libredesk/internal/tracing"
 	umodels "github.com/abhinavxd/libredesk/internal/user/models"
 	"github.com/emersion/go-imap/v2"
 	"github.com/emersion/go-imap/v2/imapclient"
 	"github.com/jhillyerd/enmime"
 	"github.com/volatiletech/null/v9"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -42,22 +47,36 @@ func (e *Email) ReadIncomingMessages(ctx context.Context, cfg imodels.IMAPConfig
 	readTicker := time.NewTicker(readInterval)
 	defer readTicker.Stop()
 
+	e.startPolling(cfg, readInterval)
+	defer e.stopPolling(cfg)
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		case <-readTicker.C:
-			// If the ticker interval is too short, it may trigger while the previous `processMailbox` call is still running,
-			// leading to overlapping executions or delays in handling context cancellation, check if the context is already done.
-			if ctx.Err() != nil {
-				return nil
-			}
+		case <-e.fetchRequested():
+			e.lo.Info("fetching emails on request", "mailbox", cfg.Mailbox, "inbox_id", e.Identifier())
+			readTicker.Reset(readInterval)
+		}
 
-			if err := e.processMailbox(ctx, scanInboxSince, cfg); err != nil && err != context.Canceled {
-				e.lo.Error("error searching emails", "error", err)
-			}
-			e.lo.Info("email search complete", "mailbox", cfg.Mailbox, "inbox_id", e.Identifier())
+		// If the ticker interval is too short, it may trigger while the previous `processMailbox` call is still running,
+		// leading to overlapping executions or delays in handling context cancellation, check if the context is already done.
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		start := time.Now()
+		fetchCtx, span := tracing.Start(ctx, "imap.fetch", attribute.Int("inbox.id", e.Identifier()), attribute.String("imap.mailbox", cfg.Mailbox))
+		err := e.processMailbox(fetchCtx, scanInboxSince, cfg)
+		tracing.End(span, err)
+		e.recordPoll(cfg, err)
+		if err != nil && err != context.Canceled {
+			e.lo.Error("error searching emails", "error", err)
+			metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_imap_fetch_errors_total{inbox_id="%d"}`, e.Identifier())).Inc()
 		}
+		metrics.GetOrCreateHistogram(fmt.Sprintf(`libredesk_imap_fetch_duration_seconds{inbox_id="%d"}`, e.Identifier())).UpdateDuration(start)
+		e.lo.Info("email search complete", "mailbox", cfg.Mailbox, "inbox_id", e.Identifier())
 	}
 }
 
@@ -357,6 +376,9 @@ func (e *Email) processEnvelope(ctx context.Context, client *imapclient.Client,
 
 	e.lo.Debug("processing new incoming message", "message_id", messageID, "subject", env.Subject, "from", fromAddress, "inbox_id", inboxID)
 
+	ctx, span := tracing.Start(ctx, "imap.fetch_message", attribute.Int("inbox.id", inboxID), attribute.String("message.source_id", messageID))
+	defer span.End()
+
 	// Make contact.
 	firstName, lastName := getContactName(env.From[0])
 	var contact = umodels.User{
@@ -450,14 +472,24 @@ func (e *Email) processEnvelope(ctx context.Context, client *imapclient.Client,
 
 		if fullItem, ok := fullFetchItem.(imapclient.FetchItemDataBodySection); ok {
 			e.lo.Debug("fetching full message body", "message_id", messageID)
-			return e.processFullMessage(fullItem, incomingMsg)
+			if err := e.processFullMessage(ctx, fullItem, incomingMsg); err != nil {
+				tracing.SetError(span, err)
+				return err
+			}
+			return nil
 		}
 	}
 }
 
 // processFullMessage processes the full message and enqueues it for inserting into the database.
-func (e *Email) processFullMessage(item imapclient.FetchItemDataBodySection, incomingMsg models.IncomingMessage) error {
-	envelope, err := enmime.ReadEnvelope(item.Literal)
+func (e *Email) processFullMessage(ctx context.Context, item imapclient.FetchItemDataBodySection, incomingMsg models.IncomingMessage) error {
+	raw, err := io.ReadAll(item.Literal)
+	if err != nil {
+		e.lo.Error("error reading email body", "error", err, "message_id", incomingMsg.Message.SourceID.String)
+		return fmt.Errorf("reading email body: %w", err)
+	}
+
+	envelope, err := enmime.ReadEnvelope(bytes.NewReader(raw))
 	if err != nil {
 		e.lo.Error("error parsing email envelope", "error", err, "message_id", incomingMsg.Message.SourceID.String)
 		for _, err := range envelope.Errors {
@@ -471,6 +503,32 @@ func (e *Email) processFullMessage(item imapclient.FetchItemDataBodySection, inc
 		e.lo.Error("error parsing email envelope", "error", err.Error(), "message_id", incomingMsg.Message.SourceID.String)
 	}
 
+	setMessageParts(envelope, &incomingMsg)
+	if envelope.Root != nil {
+		incomingMsg.Headers = envelope.Root.Header
+	}
+	incomingMsg.Raw = raw
+
+	e.lo.Debug("envelope HTML content", "message_id", incomingMsg.Message.SourceID.String, "content", incomingMsg.Message.Content)
+	e.lo.Debug("envelope text content", "message_id", incomingMsg.Message.SourceID.String, "content", envelope.Text)
+	if incomingMsg.ConversationUUIDFromReplyTo != "" {
+		e.lo.Debug("extracted conversation UUID from plus-addressed recipient",
+			"conversation_uuid", incomingMsg.ConversationUUIDFromReplyTo,
+			"message_id", incomingMsg.Message.SourceID.String)
+	}
+
+	e.lo.Debug("enqueuing incoming email message", "message_id", incomingMsg.Message.SourceID.String,
+		"attachments", len(envelope.Attachments), "inline_attachments", len(envelope.Inlines))
+
+	if err := e.messageStore.EnqueueIncoming(ctx, incomingMsg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// setMessageParts sets the content, threading headers, plus-addressed conversation UUID and
+// attachments of the message from the parsed email envelope.
+func setMessageParts(envelope *enmime.Envelope, in *models.IncomingMessage) {
 	// Extract all HTML content by traversing the tree
 	var allHTML strings.Builder
 	if envelope.Root != nil {
@@ -486,20 +544,16 @@ func (e *Email) processFullMessage(item imapclient.FetchItemDataBodySection, inc
 
 	// Set message content - prioritize combined HTML
 	if allHTML.Len() > 0 {
-		incomingMsg.Message.Content = allHTML.String()
-		incomingMsg.Message.ContentType = models.ContentTypeHTML
-		e.lo.Debug("extracted HTML content from parts", "message_id", incomingMsg.Message.SourceID.String, "content", incomingMsg.Message.Content)
+		in.Message.Content = allHTML.String()
+		in.Message.ContentType = models.ContentTypeHTML
 	} else if len(envelope.HTML) > 0 {
-		incomingMsg.Message.Content = envelope.HTML
-		incomingMsg.Message.ContentType = models.ContentTypeHTML
+		in.Message.Content = envelope.HTML
+		in.Message.ContentType = models.ContentTypeHTML
 	} else if len(envelope.Text) > 0 {
-		incomingMsg.Message.Content = envelope.Text
-		incomingMsg.Message.ContentType = models.ContentTypeText
+		in.Message.Content = envelope.Text
+		in.Message.ContentType = models.ContentTypeText
 	}
 
-	e.lo.Debug("envelope HTML content", "message_id", incomingMsg.Message.SourceID.String, "content", incomingMsg.Message.Content)
-	e.lo.Debug("envelope text content", "message_id", incomingMsg.Message.SourceID.String, "content", envelope.Text)
-
 	// Clean headers
 	inReplyTo := strings.ReplaceAll(strings.ReplaceAll(envelope.GetHeader("In-Reply-To"), "<", ""), ">", "")
 	references := strings.Fields(envelope.GetHeader("References"))
@@ -507,20 +561,15 @@ func (e *Email) processFullMessage(item imapclient.FetchItemDataBodySection, inc
 		references[i] = strings.Trim(strings.TrimSpace(ref), " <>")
 	}
 
-	incomingMsg.Message.InReplyTo = inReplyTo
-	incomingMsg.Message.References = references
+	in.Message.InReplyTo = inReplyTo
+	in.Message.References = references
 
 	// Extract conversation UUID from plus-addressed recipient (e.g., inbox+conv-{uuid}@domain)
-	incomingMsg.ConversationUUIDFromReplyTo = extractConversationUUIDFromRecipient(envelope)
-	if incomingMsg.ConversationUUIDFromReplyTo != "" {
-		e.lo.Debug("extracted conversation UUID from plus-addressed recipient",
-			"conversation_uuid", incomingMsg.ConversationUUIDFromReplyTo,
-			"message_id", incomingMsg.Message.SourceID.String)
-	}
+	in.ConversationUUIDFromReplyTo = extractConversationUUIDFromRecipient(envelope)
 
 	// Process attachments
 	for _, att := range envelope.Attachments {
-		incomingMsg.Message.Attachments = append(incomingMsg.Message.Attachments, attachment.Attachment{
+		in.Message.Attachments = append(in.Message.Attachments, attachment.Attachment{
 			Name:        att.FileName,
 			Content:     att.Content,
 			ContentType: att.ContentType,
@@ -537,7 +586,7 @@ func (e *Email) processFullMessage(item imapclient.FetchItemDataBodySection, inc
 			disposition = attachment.DispositionAttachment
 		}
 
-		incomingMsg.Message.Attachments = append(incomingMsg.Message.Attachments, attachment.Attachment{
+		in.Message.Attachments = append(in.Message.Attachments, attachment.Attachment{
 			Name:        inline.FileName,
 			Content:     inline.Content,
 			ContentType: inline.ContentType,
@@ -546,14 +595,6 @@ func (e *Email) processFullMessage(item imapclient.FetchItemDataBodySection, inc
 			Disposition: disposition,
 		})
 	}
-
-	e.lo.Debug("enqueuing incoming email message", "message_id", incomingMsg.Message.SourceID.String,
-		"attachments", len(envelope.Attachments), "inline_attachments", len(envelope.Inlines))
-
-	if err := e.messageStore.EnqueueIncoming(incomingMsg); err != nil {
-		return err
-	}
-	return nil
 }
 
 // getContactName extracts the contact's first and last name from the IMAP address.
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/abhinavxd/libredesk/internal/conversation/models"
 	imodels "github.com/abhinavxd/libredesk/internal/inbox/models"
 	"github.com/abhinavxd/libredesk/internal/stringutil"
@@ -235,7 +236,25 @@ func (e *Email) Send(m models.Message) error {
 			email.Text = []byte(m.AltContent)
 		}
 	}
-	return server.Send(email)
+
+	// Keep a copy of the raw email as rendered before sending, the date is set here so the copy has the same headers
+	// as the sent email. smtppool renders the email again when sending, so only the MIME boundaries differ.
+	email.Headers.Set("Date", time.Now().Format(time.RFC1123Z))
+	raw, err := email.Bytes()
+	if err != nil {
+		return fmt.Errorf("building email: %w", err)
+	}
+	start := time.Now()
+	if err := server.Send(email); err != nil {
+		metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_smtp_sends_total{inbox_id="%d",status="error"}`, e.Identifier())).Inc()
+		return err
+	}
+	metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_smtp_sends_total{inbox_id="%d",status="success"}`, e.Identifier())).Inc()
+	metrics.GetOrCreateHistogram(fmt.Sprintf(`libredesk_smtp_send_duration_seconds{inbox_id="%d"}`, e.Identifier())).UpdateDuration(start)
+	if err := e.messageStore.InsertMessageSource(m.ID, raw); err != nil {
+		e.lo.Error("error storing raw email of sent message", "message_id", m.ID, "error", err)
+	}
+	return nil
 }
 
 // buildPlusAddress creates a plus-addressed email for conversation matching.
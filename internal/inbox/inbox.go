@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/abhinavxd/libredesk/internal/conversation/models"
@@ -52,6 +53,16 @@ type MessageHandler interface {
 	Send(models.Message) error
 }
 
+// HealthChecker is implemented by inboxes that can report the health of their connections.
+type HealthChecker interface {
+	Health(ctx context.Context) []imodels.ConnectionHealth
+}
+
+// Fetcher is implemented by inboxes that poll for messages and can be asked to poll right away.
+type Fetcher interface {
+	Fetch()
+}
+
 // Inbox combines the operations of an inbox including its lifecycle, identification, and message handling.
 type Inbox interface {
 	Closer
@@ -64,7 +75,8 @@ type Inbox interface {
 // MessageStore defines methods for storing and processing messages.
 type MessageStore interface {
 	MessageExists(string) (bool, error)
-	EnqueueIncoming(models.IncomingMessage) error
+	EnqueueIncoming(context.Context, models.IncomingMessage) error
+	InsertMessageSource(messageID int, raw []byte) error
 }
 
 // UserStore defines methods for fetching user information.
@@ -79,16 +91,19 @@ type Opts struct {
 }
 
 type Manager struct {
-	mu            sync.RWMutex
-	queries       queries
-	inboxes       map[int]Inbox
-	lo            *logf.Logger
-	i18n          *i18n.I18n
-	receivers     map[int]context.CancelFunc
-	msgStore      MessageStore
-	usrStore      UserStore
-	wg            sync.WaitGroup
-	encryptionKey string
+	mu        sync.RWMutex
+	db        *sqlx.DB
+	queries   queries
+	inboxes   map[int]Inbox
+	lo        *logf.Logger
+	i18n      *i18n.I18n
+	receivers map[int]context.CancelFunc
+	// receiveCtx is the context receivers were started with, receivers are restarted with it on reload.
+	receiveCtx context.Context
+	msgStore   MessageStore
+	usrStore   UserStore
+	wg         sync.WaitGroup
+	keyring    *crypto.Keyring
 }
 
 // Prepared queries.
@@ -101,22 +116,24 @@ type queries struct {
 	SoftDelete   *sqlx.Stmt `query:"soft-delete"`
 	InsertInbox  *sqlx.Stmt `query:"insert-inbox"`
 	UpdateConfig *sqlx.Stmt `query:"update-config"`
+	LockConfig   *sqlx.Stmt `query:"lock-config"`
 }
 
 // New returns a new inbox manager.
-func New(lo *logf.Logger, db *sqlx.DB, i18n *i18n.I18n, encryptionKey string) (*Manager, error) {
+func New(lo *logf.Logger, db *sqlx.DB, i18n *i18n.I18n, keyring *crypto.Keyring) (*Manager, error) {
 	var q queries
 	if err := dbutil.ScanSQLFile("queries.sql", &q, db, efs); err != nil {
 		return nil, err
 	}
 
 	m := &Manager{
-		lo:            lo,
-		inboxes:       make(map[int]Inbox),
-		receivers:     make(map[int]context.CancelFunc),
-		queries:       q,
-		i18n:          i18n,
-		encryptionKey: encryptionKey,
+		db:        db,
+		lo:        lo,
+		inboxes:   make(map[int]Inbox),
+		receivers: make(map[int]context.CancelFunc),
+		queries:   q,
+		i18n:      i18n,
+		keyring:   keyring,
 	}
 	return m, nil
 }
@@ -243,8 +260,9 @@ func (m *Manager) InitInboxes(initFn initFn) error {
 	return nil
 }
 
-// Reload hot reloads the inboxes with the given init function.
-func (m *Manager) Reload(ctx context.Context, initFn initFn) error {
+// Reload hot reloads the inboxes with the given init function. Receivers are restarted only if they are running,
+// i.e. on the node elected to receive messages.
+func (m *Manager) Reload(initFn initFn) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -280,8 +298,11 @@ func (m *Manager) Reload(ctx context.Context, initFn initFn) error {
 	}
 
 	// Start new receivers.
+	if m.receiveCtx == nil || m.receiveCtx.Err() != nil {
+		return nil
+	}
 	for _, inb := range m.inboxes {
-		receiverCtx, cancel := context.WithCancel(ctx)
+		receiverCtx, cancel := context.WithCancel(m.receiveCtx)
 		m.receivers[inb.Identifier()] = cancel
 
 		go func(inbox Inbox) {
@@ -433,10 +454,89 @@ func (m *Manager) UpdateConfig(id int, config json.RawMessage) error {
 	return nil
 }
 
-// Start starts the receiver for each inbox.
+// ReEncrypt re-encrypts the sensitive fields of all inbox configs that are not encrypted with the primary key and
+// returns the number of inboxes updated.
+func (m *Manager) ReEncrypt() (int, error) {
+	var inboxes []imodels.Inbox
+	if err := m.queries.GetAll.Select(&inboxes); err != nil {
+		m.lo.Error("error fetching inboxes", "error", err)
+		return 0, fmt.Errorf("fetching inboxes: %w", err)
+	}
+
+	var n int
+	for _, inbox := range inboxes {
+		changed, err := m.reEncryptConfig(inbox.ID)
+		if err != nil {
+			return n, fmt.Errorf("inbox %d: %w", inbox.ID, err)
+		}
+		if changed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// reEncryptConfig re-encrypts the sensitive fields of an inbox config with the primary key. The config row is locked
+// while it is re-encrypted so tokens refreshed by a running instance in the meantime are not overwritten.
+func (m *Manager) reEncryptConfig(id int) (bool, error) {
+	tx, err := m.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		m.lo.Error("error beginning re-encrypt inbox config transaction", "id", id, "error", err)
+		return false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var config json.RawMessage
+	if err := tx.Stmtx(m.queries.LockConfig).Get(&config, id); err != nil {
+		// Deleted in the meantime.
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		m.lo.Error("error fetching inbox config", "id", id, "error", err)
+		return false, fmt.Errorf("fetching inbox config: %w", err)
+	}
+	config, changed, err := transformConfigSecrets(config, func(field, value string) (string, error) {
+		encrypted, _, err := m.keyring.ReEncrypt(value)
+		if err != nil {
+			return "", fmt.Errorf("re-encrypting %s: %w", field, err)
+		}
+		return encrypted, nil
+	})
+	if err != nil || !changed {
+		return false, err
+	}
+	if _, err := tx.Stmtx(m.queries.UpdateConfig).Exec(id, config); err != nil {
+		m.lo.Error("error updating inbox config", "id", id, "error", err)
+		return false, fmt.Errorf("updating inbox config: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		m.lo.Error("error committing re-encrypt inbox config transaction", "id", id, "error", err)
+		return false, fmt.Errorf("committing transaction: %w", err)
+	}
+	return true, nil
+}
+
+// Fetch makes the receiver of an inbox fetch new messages right away instead of at its next interval. It returns
+// false if the inbox has no receiver running on this node or does not support fetching on demand.
+func (m *Manager) Fetch(id int) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.receivers[id]; !ok {
+		return false
+	}
+	f, ok := m.inboxes[id].(Fetcher)
+	if !ok {
+		return false
+	}
+	f.Fetch()
+	return true
+}
+
+// Start starts the receiver for each inbox. Receivers stop when the context is cancelled.
 func (m *Manager) Start(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.receiveCtx = ctx
 	for _, inb := range m.inboxes {
 		receiverCtx, cancel := context.WithCancel(ctx)
 		m.receivers[inb.Identifier()] = cancel
@@ -452,6 +552,24 @@ func (m *Manager) Start(ctx context.Context) error {
 	return nil
 }
 
+// Health returns the health of the connections of the active inboxes by inbox ID.
+func (m *Manager) Health(ctx context.Context) map[int][]imodels.ConnectionHealth {
+	m.mu.RLock()
+	inboxes := make([]Inbox, 0, len(m.inboxes))
+	for _, inb := range m.inboxes {
+		inboxes = append(inboxes, inb)
+	}
+	m.mu.RUnlock()
+
+	health := make(map[int][]imodels.ConnectionHealth, len(inboxes))
+	for _, inb := range inboxes {
+		if hc, ok := inb.(HealthChecker); ok {
+			health[inb.Identifier()] = hc.Health(ctx)
+		}
+	}
+	return health
+}
+
 // Close closes all inboxes.
 func (m *Manager) Close() {
 	m.mu.Lock()
@@ -494,126 +612,81 @@ func (m *Manager) getActive() ([]imodels.Inbox, error) {
 
 // encryptInboxConfig encrypts sensitive fields in the inbox config JSON.
 func (m *Manager) encryptInboxConfig(config json.RawMessage) (json.RawMessage, error) {
-	if len(config) == 0 {
-		return config, nil
-	}
-
-	var cfg map[string]any
-	if err := json.Unmarshal(config, &cfg); err != nil {
-		return nil, fmt.Errorf("unmarshalling config: %w", err)
-	}
-
-	// Encrypt SMTP passwords
-	if smtpSlice, ok := cfg["smtp"].([]any); ok {
-		for i, smtpItem := range smtpSlice {
-			if smtpMap, ok := smtpItem.(map[string]any); ok {
-				if password, ok := smtpMap["password"].(string); ok && password != "" {
-					encrypted, err := crypto.Encrypt(password, m.encryptionKey)
-					if err != nil {
-						return nil, fmt.Errorf("encrypting SMTP password at index %d: %w", i, err)
-					}
-					smtpMap["password"] = encrypted
-				}
-			}
-		}
-	}
-
-	// Encrypt IMAP passwords
-	if imapSlice, ok := cfg["imap"].([]any); ok {
-		for i, imapItem := range imapSlice {
-			if imapMap, ok := imapItem.(map[string]any); ok {
-				if password, ok := imapMap["password"].(string); ok && password != "" {
-					encrypted, err := crypto.Encrypt(password, m.encryptionKey)
-					if err != nil {
-						return nil, fmt.Errorf("encrypting IMAP password at index %d: %w", i, err)
-					}
-					imapMap["password"] = encrypted
-				}
-			}
-		}
-	}
-
-	// Encrypt OAuth fields if present
-	if oauthMap, ok := cfg["oauth"].(map[string]any); ok {
-		fields := []string{"client_secret", "access_token", "refresh_token"}
-		for _, fieldName := range fields {
-			if fieldValue, ok := oauthMap[fieldName].(string); ok && fieldValue != "" {
-				encrypted, err := crypto.Encrypt(fieldValue, m.encryptionKey)
-				if err != nil {
-					return nil, fmt.Errorf("encrypting OAuth %s: %w", fieldName, err)
-				}
-				oauthMap[fieldName] = encrypted
-			}
+	encrypted, _, err := transformConfigSecrets(config, func(field, value string) (string, error) {
+		encrypted, err := m.keyring.Encrypt(value)
+		if err != nil {
+			return "", fmt.Errorf("encrypting %s: %w", field, err)
 		}
-	}
-
-	encrypted, err := json.Marshal(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("marshalling encrypted config: %w", err)
-	}
-
-	return encrypted, nil
+		return encrypted, nil
+	})
+	return encrypted, err
 }
 
 // decryptInboxConfig decrypts sensitive fields in the inbox config JSON.
 func (m *Manager) decryptInboxConfig(config json.RawMessage) (json.RawMessage, error) {
+	decrypted, _, err := transformConfigSecrets(config, func(field, value string) (string, error) {
+		decrypted, err := m.keyring.Decrypt(value)
+		if err != nil {
+			return "", fmt.Errorf("decrypting %s: %w", field, err)
+		}
+		return decrypted, nil
+	})
+	return decrypted, err
+}
+
+// transformConfigSecrets replaces the non-empty sensitive fields in the inbox config JSON, the SMTP and IMAP
+// passwords and OAuth secrets and tokens, with the values returned by fn. It reports whether any field changed.
+func transformConfigSecrets(config json.RawMessage, fn func(field, value string) (string, error)) (json.RawMessage, bool, error) {
 	if len(config) == 0 {
-		return config, nil
+		return config, false, nil
 	}
 
 	var cfg map[string]any
 	if err := json.Unmarshal(config, &cfg); err != nil {
-		return nil, fmt.Errorf("unmarshalling config: %w", err)
-	}
-
-	// Decrypt SMTP passwords
-	if smtpSlice, ok := cfg["smtp"].([]any); ok {
-		for i, smtpItem := range smtpSlice {
-			if smtpMap, ok := smtpItem.(map[string]any); ok {
-				if password, ok := smtpMap["password"].(string); ok && password != "" {
-					decrypted, err := crypto.Decrypt(password, m.encryptionKey)
-					if err != nil {
-						return nil, fmt.Errorf("decrypting SMTP password at index %d: %w", i, err)
-					}
-					smtpMap["password"] = decrypted
-				}
-			}
+		return nil, false, fmt.Errorf("unmarshalling config: %w", err)
+	}
+
+	var changed bool
+	transform := func(obj map[string]any, key, field string) error {
+		value, ok := obj[key].(string)
+		if !ok || value == "" {
+			return nil
 		}
+		out, err := fn(field, value)
+		if err != nil {
+			return err
+		}
+		if out != value {
+			obj[key] = out
+			changed = true
+		}
+		return nil
 	}
 
-	// Decrypt IMAP passwords
-	if imapSlice, ok := cfg["imap"].([]any); ok {
-		for i, imapItem := range imapSlice {
-			if imapMap, ok := imapItem.(map[string]any); ok {
-				if password, ok := imapMap["password"].(string); ok && password != "" {
-					decrypted, err := crypto.Decrypt(password, m.encryptionKey)
-					if err != nil {
-						return nil, fmt.Errorf("decrypting IMAP password at index %d: %w", i, err)
-					}
-					imapMap["password"] = decrypted
+	// SMTP and IMAP passwords.
+	for _, proto := range []string{"smtp", "imap"} {
+		items, _ := cfg[proto].([]any)
+		for i, item := range items {
+			if obj, ok := item.(map[string]any); ok {
+				if err := transform(obj, "password", fmt.Sprintf("%s password at index %d", strings.ToUpper(proto), i)); err != nil {
+					return nil, false, err
 				}
 			}
 		}
 	}
 
-	// Decrypt OAuth fields if present
-	if oauthMap, ok := cfg["oauth"].(map[string]any); ok {
-		fields := []string{"client_secret", "access_token", "refresh_token"}
-		for _, fieldName := range fields {
-			if fieldValue, ok := oauthMap[fieldName].(string); ok && fieldValue != "" {
-				decrypted, err := crypto.Decrypt(fieldValue, m.encryptionKey)
-				if err != nil {
-					return nil, fmt.Errorf("decrypting OAuth %s: %w", fieldName, err)
-				}
-				oauthMap[fieldName] = decrypted
+	// OAuth fields if present.
+	if obj, ok := cfg["oauth"].(map[string]any); ok {
+		for _, key := range []string{"client_secret", "access_token", "refresh_token"} {
+			if err := transform(obj, key, "OAuth "+key); err != nil {
+				return nil, false, err
 			}
 		}
 	}
 
-	decrypted, err := json.Marshal(cfg)
+	out, err := json.Marshal(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("marshalling decrypted config: %w", err)
+		return nil, false, fmt.Errorf("marshalling config: %w", err)
 	}
-
-	return decrypted, nil
+	return out, changed, nil
 }
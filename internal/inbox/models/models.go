@@ -135,3 +135,21 @@ func (m *Inbox) ClearPasswords() error {
 
 	return nil
 }
+
+// Health statuses of inbox connections.
+const (
+	HealthOK   = "ok"
+	HealthDown = "down"
+	// HealthInactive is reported for IMAP connections that are not polled on this node, e.g. when another
+	// node is elected to receive messages.
+	HealthInactive = "inactive"
+)
+
+// ConnectionHealth is the health of an IMAP or SMTP connection of an inbox.
+type ConnectionHealth struct {
+	Type          string     `json:"type"`
+	Host          string     `json:"host"`
+	Status        string     `json:"status"`
+	Error         string     `json:"error,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+}
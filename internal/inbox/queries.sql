@@ -31,4 +31,7 @@ RETURNING *;
 -- name: update-config
 UPDATE inboxes
 SET config = $2, updated_at = NOW()
-WHERE id = $1 AND deleted_at IS NULL;
\ No newline at end of file
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: lock-config
+SELECT config FROM inboxes WHERE id = $1 AND deleted_at IS NULL FOR UPDATE;
\ No newline at end of file
@@ -0,0 +1,205 @@
+// Package leader elects a single node of a multi-instance deployment to run each singleton background job,
+// using a Redis lock per job that the leader keeps renewing. Another node takes over a job when its leader
+// dies or loses its connection to Redis, after the lock expires.
+package leader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/zerodha/logf"
+)
+
+const (
+	keyPrefix = "libredesk:leader:"
+
+	defaultTTL = 15 * time.Second
+)
+
+var (
+	// renewScript extends the lock only if it is still held by this node.
+	renewScript = redis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		end
+		return 0
+	`)
+
+	// releaseScript deletes the lock only if it is still held by this node.
+	releaseScript = redis.NewScript(`
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		end
+		return 0
+	`)
+)
+
+// Elector runs jobs on the node holding their lock.
+type Elector struct {
+	rd     *redis.Client
+	lo     *logf.Logger
+	nodeID string
+	ttl    time.Duration
+
+	// jobs are the jobs this node is running for election.
+	jobs   map[string]struct{}
+	jobsMu sync.Mutex
+}
+
+// Opts contains options for initializing the Elector.
+type Opts struct {
+	Redis *redis.Client
+	Lo    *logf.Logger
+	// TTL is how long a lock is held without being renewed, which is how long a job is left without a leader
+	// when its leader dies. Locks are renewed at a third of it.
+	TTL time.Duration
+}
+
+// New returns a new Elector.
+func New(opts Opts) *Elector {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Elector{
+		rd:     opts.Redis,
+		lo:     opts.Lo,
+		nodeID: uuid.NewString(),
+		ttl:    ttl,
+		jobs:   make(map[string]struct{}),
+	}
+}
+
+// Run keeps trying to become the leader of the job and runs fn while it is. The context passed to fn is
+// cancelled when the leadership is lost, fn is expected to return then. Run blocks until ctx is cancelled, or
+// until fn returns on its own as the job is then considered done.
+func (e *Elector) Run(ctx context.Context, job string, fn func(ctx context.Context)) {
+	var (
+		key      = keyPrefix + job
+		interval = e.ttl / 3
+	)
+	e.jobsMu.Lock()
+	e.jobs[job] = struct{}{}
+	e.jobsMu.Unlock()
+	for {
+		acquired, err := e.rd.SetNX(ctx, key, e.nodeID, e.ttl).Result()
+		if err != nil && ctx.Err() == nil {
+			e.lo.Error("error acquiring leader lock", "job", job, "error", err)
+		}
+		if acquired {
+			e.lo.Info("elected leader, starting job", "job", job)
+			if done := e.lead(ctx, key, job, fn); done {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// NodeID returns the ID of this node.
+func (e *Elector) NodeID() string {
+	return e.nodeID
+}
+
+// Leaders returns whether each job run for election on this node has a leader on any node, i.e. whether its lock
+// is held and kept renewed.
+func (e *Elector) Leaders(ctx context.Context) (map[string]bool, error) {
+	e.jobsMu.Lock()
+	jobs := make([]string, 0, len(e.jobs))
+	for job := range e.jobs {
+		jobs = append(jobs, job)
+	}
+	e.jobsMu.Unlock()
+	if len(jobs) == 0 {
+		return map[string]bool{}, nil
+	}
+
+	var (
+		pipe = e.rd.Pipeline()
+		cmds = make(map[string]*redis.IntCmd, len(jobs))
+	)
+	for _, job := range jobs {
+		cmds[job] = pipe.Exists(ctx, keyPrefix+job)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	leaders := make(map[string]bool, len(jobs))
+	for job, cmd := range cmds {
+		leaders[job] = cmd.Val() == 1
+	}
+	return leaders, nil
+}
+
+// lead runs fn and renews the lock of the job until fn returns or the lock is lost. It returns true if fn
+// returned on its own or ctx is cancelled.
+func (e *Elector) lead(ctx context.Context, key, job string, fn func(ctx context.Context)) bool {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(jobCtx)
+	}()
+
+	var (
+		ticker      = time.NewTicker(e.ttl / 3)
+		lastRenewal = time.Now()
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			e.release(key, job)
+			return true
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				continue
+			}
+			held, err := e.renew(ctx, key)
+			if err != nil {
+				e.lo.Error("error renewing leader lock", "job", job, "error", err)
+				// The lock may have expired and been taken by another node without a renewal within the TTL.
+				if time.Since(lastRenewal) < e.ttl-e.ttl/3 {
+					continue
+				}
+			}
+			if held {
+				lastRenewal = time.Now()
+				continue
+			}
+			e.lo.Warn("lost leadership, stopping job", "job", job)
+			cancel()
+			<-done
+			return ctx.Err() != nil
+		}
+	}
+}
+
+// renew extends the lock if it is still held by this node.
+func (e *Elector) renew(ctx context.Context, key string) (bool, error) {
+	n, err := renewScript.Run(ctx, e.rd, []string{key}, e.nodeID, e.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// release deletes the lock if it is still held by this node so another node can take over right away.
+func (e *Elector) release(key, job string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := releaseScript.Run(ctx, e.rd, []string{key}, e.nodeID).Err(); err != nil {
+		e.lo.Error("error releasing leader lock", "job", job, "error", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/abhinavxd/libredesk/internal/clamav"
 	"github.com/abhinavxd/libredesk/internal/dbutil"
 	"github.com/abhinavxd/libredesk/internal/envelope"
 	"github.com/abhinavxd/libredesk/internal/image"
@@ -43,18 +45,24 @@ type Store interface {
 }
 
 type Manager struct {
-	store   Store
-	lo      *logf.Logger
-	i18n    *i18n.I18n
-	queries queries
+	store                 Store
+	scanner               *clamav.Client
+	quarantineOnScanError bool
+	lo                    *logf.Logger
+	i18n                  *i18n.I18n
+	queries               queries
 }
 
 // Opts provides options for configuring the Manager.
 type Opts struct {
 	Store Store
-	Lo    *logf.Logger
-	DB    *sqlx.DB
-	I18n  *i18n.I18n
+	// Scanner scans files for malware, scanning is disabled if nil.
+	Scanner *clamav.Client
+	// QuarantineOnScanError quarantines files that could not be scanned.
+	QuarantineOnScanError bool
+	Lo                    *logf.Logger
+	DB                    *sqlx.DB
+	I18n                  *i18n.I18n
 }
 
 // New initializes and returns a new Manager instance for handling media operations.
@@ -64,10 +72,12 @@ func New(opt Opts) (*Manager, error) {
 		return nil, err
 	}
 	return &Manager{
-		store:   opt.Store,
-		lo:      opt.Lo,
-		i18n:    opt.I18n,
-		queries: q,
+		store:                 opt.Store,
+		scanner:               opt.Scanner,
+		quarantineOnScanError: opt.QuarantineOnScanError,
+		lo:                    opt.Lo,
+		i18n:                  opt.I18n,
+		queries:               q,
 	}, nil
 }
 
@@ -81,6 +91,7 @@ type queries struct {
 	GetByModel              *sqlx.Stmt `query:"get-model-media"`
 	GetUnlinkedMessageMedia *sqlx.Stmt `query:"get-unlinked-message-media"`
 	ContentIDExists         *sqlx.Stmt `query:"content-id-exists"`
+	GetContactMedia         *sqlx.Stmt `query:"get-contact-media"`
 }
 
 // UploadAndInsert uploads file on storage and inserts an entry in db.
@@ -203,6 +214,73 @@ func (m *Manager) GetByModel(modelID int, model string) ([]models.Media, error)
 	return media, nil
 }
 
+// GetContactMedia returns the avatars of a contact and the attachments of all messages in the contact's conversations.
+func (m *Manager) GetContactMedia(contactID int) ([]models.Media, error) {
+	var media = make([]models.Media, 0)
+	if err := m.queries.GetContactMedia.Select(&media, contactID); err != nil {
+		m.lo.Error("error getting contact media", "contact_id", contactID, "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.media}"), nil)
+	}
+	return media, nil
+}
+
+// ScanForMalware scans content for malware. It returns a nil verdict if scanning is disabled, quarantine is true
+// if the content is infected, or could not be scanned and such files are quarantined.
+func (m *Manager) ScanForMalware(content io.Reader) (*models.MalwareScan, bool) {
+	if m.scanner == nil {
+		return nil, false
+	}
+	scan := &models.MalwareScan{ScannedAt: time.Now()}
+	res, err := m.scanner.Scan(context.Background(), content)
+	switch {
+	case err != nil:
+		m.lo.Error("error scanning file for malware", "error", err)
+		scan.Status = models.MalwareScanError
+		return scan, m.quarantineOnScanError
+	case res.Infected:
+		scan.Status = models.MalwareScanInfected
+		scan.Signature = res.Signature
+		return scan, true
+	}
+	scan.Status = models.MalwareScanClean
+	return scan, false
+}
+
+// Quarantine saves a file flagged by the malware scanner to the storage backend without a media record,
+// so it can't be downloaded by agents, and returns its store name.
+func (m *Manager) Quarantine(contentType string, content io.ReadSeeker) (string, error) {
+	name, err := m.store.Put(models.QuarantinePrefix+uuid.New().String(), contentType, content)
+	if err != nil {
+		m.lo.Error("error quarantining file", "error", err)
+		return "", err
+	}
+	return name, nil
+}
+
+// DeleteWithThumbnail deletes a media file and, for images, its thumbnail. The quarantined original
+// of a placeholder file is deleted too.
+func (m *Manager) DeleteWithThumbnail(media models.Media) error {
+	if err := m.Delete(media.UUID); err != nil {
+		return err
+	}
+	var meta struct {
+		MalwareScan models.MalwareScan `json:"malware_scan"`
+	}
+	if err := json.Unmarshal(media.Meta, &meta); err == nil && meta.MalwareScan.QuarantineFile != "" {
+		if err := m.store.Delete(meta.MalwareScan.QuarantineFile); err != nil {
+			m.lo.Error("error deleting quarantined file", "name", meta.MalwareScan.QuarantineFile, "error", err)
+		}
+	}
+	if strings.HasPrefix(media.ContentType, "image/") {
+		thumbUUID := image.ThumbPrefix + media.UUID
+		m.lo.Debug("deleting thumbnail", "thumb_uuid", thumbUUID)
+		if err := m.Delete(thumbUUID); err != nil {
+			m.lo.Error("error deleting thumbnail", "thumb_uuid", thumbUUID, "error", err)
+		}
+	}
+	return nil
+}
+
 // Delete deletes a media file from both the storage backend and the database.
 func (m *Manager) Delete(name string) error {
 	if err := m.store.Delete(name); err != nil {
@@ -251,18 +329,8 @@ func (m *Manager) deleteUnlinkedMessageMedia() error {
 	}
 	for _, mm := range media {
 		m.lo.Debug("deleting media not linked to any message", "media_id", mm.ID)
-		if err := m.Delete(mm.UUID); err != nil {
+		if err := m.DeleteWithThumbnail(mm); err != nil {
 			m.lo.Error("error deleting unlinked media", "error", err)
-			continue
-		}
-
-		// If it's an image, also delete the `thumb_uuid` image from store.
-		if strings.HasPrefix(mm.ContentType, "image/") {
-			thumbUUID := image.ThumbPrefix + mm.UUID
-			m.lo.Debug("deleting thumbnail for unlinked media", "thumb_uuid", thumbUUID)
-			if err := m.Delete(thumbUUID); err != nil {
-				m.lo.Error("error deleting thumbnail for unlinked media", "error", err)
-			}
 		}
 	}
 	return nil
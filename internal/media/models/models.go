@@ -11,10 +11,33 @@ const (
 	// TODO: pick these table names from their respective package/models/models.go
 	ModelMessages = "messages"
 	ModelUser     = "users"
+	// ModelDrafts is the model of attachments of conversation drafts, e.g. of cancelled scheduled replies.
+	ModelDrafts = "drafts"
+	// ModelExports is the model of the files of export jobs, they are only served through the export download.
+	ModelExports = "exports"
 
 	DispositionInline = "inline"
+
+	// QuarantinePrefix is the prefix of the store names of files quarantined by the malware scanner.
+	QuarantinePrefix = "quarantine_"
+
+	MalwareScanClean    = "clean"
+	MalwareScanInfected = "infected"
+	MalwareScanError    = "error"
 )
 
+// MalwareScan is the malware scan verdict of a file, stored in the media meta under `malware_scan`.
+type MalwareScan struct {
+	Status string `json:"status"`
+	// Signature is the name of the detected malware.
+	Signature string    `json:"signature,omitempty"`
+	ScannedAt time.Time `json:"scanned_at"`
+	// OriginalName and QuarantineFile are set on placeholders of quarantined files, QuarantineFile
+	// is the store name of the original file.
+	OriginalName   string `json:"original_name,omitempty"`
+	QuarantineFile string `json:"quarantine_file,omitempty"`
+}
+
 // Media represents an uploaded object in DB and storage backend.
 type Media struct {
 	ID          int             `db:"id" json:"id"`
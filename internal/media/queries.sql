@@ -51,4 +51,20 @@ WHERE model_type = 'messages'
   AND created_at < NOW() - INTERVAL '1 day';
 
 -- name: content-id-exists
-SELECT uuid FROM media WHERE content_id = $1;
\ No newline at end of file
+SELECT uuid FROM media WHERE content_id = $1;
+
+-- name: get-contact-media
+-- Returns the avatars of a contact and the contacts merged into it, and the attachments of the messages in the
+-- contact's conversations or sent by these contacts.
+WITH contacts AS (
+    SELECT id FROM users WHERE (id = $1 OR merged_into_id = $1) AND type = 'contact'
+)
+SELECT id, created_at, updated_at, "uuid", store, filename, content_type, content_id, model_id, model_type, disposition, "size", meta
+FROM media
+WHERE (model_type = 'users' AND model_id IN (SELECT id FROM contacts))
+   OR (model_type = 'messages' AND model_id IN (
+        SELECT id FROM conversation_messages
+        WHERE conversation_id IN (SELECT id FROM conversations WHERE contact_id = $1)
+           OR sender_id IN (SELECT id FROM contacts)
+   ))
+ORDER BY id;
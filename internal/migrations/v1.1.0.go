@@ -0,0 +1,396 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V1_1_0 updates the database schema to v1.1.0.
+func V1_1_0(db *sqlx.DB, fs stuffbin.FileSystem, ko *koanf.Koanf) error {
+	// Add send_at column to conversation_messages for scheduled and undo-send replies.
+	_, err := db.Exec(`
+		ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS send_at TIMESTAMPTZ NULL;
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add message.deleted webhook event for cancelled scheduled replies.
+	_, err = db.Exec(`ALTER TYPE webhook_event ADD VALUE IF NOT EXISTS 'message.deleted';`)
+	if err != nil {
+		return err
+	}
+
+	// Add merged_into_id column to conversations to point merged conversations to their primary conversation.
+	_, err = db.Exec(`
+		ALTER TABLE conversations
+		ADD COLUMN IF NOT EXISTS merged_into_id BIGINT REFERENCES conversations(id) ON DELETE SET NULL ON UPDATE CASCADE NULL;
+
+		CREATE INDEX IF NOT EXISTS index_conversations_on_merged_into_id ON conversations (merged_into_id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add `conversations:merge` permission to Admin role.
+	_, err = db.Exec(`
+		UPDATE roles
+		SET permissions = array_append(permissions, 'conversations:merge')
+		WHERE name = 'Admin' AND NOT ('conversations:merge' = ANY(permissions));
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add merged_into_id column to users to point merged contacts to their primary contact.
+	_, err = db.Exec(`
+		ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS merged_into_id BIGINT REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE NULL;
+
+		CREATE INDEX IF NOT EXISTS index_users_on_merged_into_id ON users (merged_into_id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create organizations and organization_notes tables and link contacts to organizations.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS organizations (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			"name" TEXT NOT NULL,
+			domains TEXT[] DEFAULT '{}'::TEXT[] NOT NULL,
+			custom_attributes JSONB DEFAULT '{}'::jsonb NOT NULL,
+			CONSTRAINT constraint_organizations_on_name CHECK (length("name") <= 140)
+		);
+		CREATE INDEX IF NOT EXISTS index_organizations_on_domains ON organizations USING GIN (domains);
+
+		CREATE TABLE IF NOT EXISTS organization_notes (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			note TEXT NOT NULL,
+			user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS index_organization_notes_on_organization_id_created_at ON organization_notes (organization_id, created_at);
+
+		ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS organization_id INT REFERENCES organizations(id) ON DELETE SET NULL ON UPDATE CASCADE NULL;
+
+		CREATE INDEX IF NOT EXISTS index_users_on_organization_id ON users (organization_id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add `contacts:export` permission to Admin role.
+	_, err = db.Exec(`
+		UPDATE roles
+		SET permissions = array_append(permissions, 'contacts:export')
+		WHERE name = 'Admin' AND NOT ('contacts:export' = ANY(permissions));
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add `contacts:erase` permission to Admin role.
+	_, err = db.Exec(`
+		UPDATE roles
+		SET permissions = array_append(permissions, 'contacts:erase')
+		WHERE name = 'Admin' AND NOT ('contacts:erase' = ANY(permissions));
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add contact data export and erasure to activity_log_type enum.
+	_, err = db.Exec(`ALTER TYPE activity_log_type ADD VALUE IF NOT EXISTS 'contact_data_exported';`)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`ALTER TYPE activity_log_type ADD VALUE IF NOT EXISTS 'contact_data_erased';`)
+	if err != nil {
+		return err
+	}
+
+	// Create retention policies and their logs.
+	_, err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'retention_action') THEN
+				CREATE TYPE retention_action AS ENUM ('delete_conversations', 'anonymize_conversations', 'purge_private_notes', 'purge_attachments');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS retention_policies (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			"name" TEXT NOT NULL,
+			enabled BOOLEAN DEFAULT FALSE NOT NULL,
+			"action" retention_action NOT NULL,
+			inbox_id INT REFERENCES inboxes(id) ON DELETE CASCADE ON UPDATE CASCADE NULL,
+			days INT NOT NULL,
+			last_run_at TIMESTAMPTZ NULL,
+			CONSTRAINT constraint_retention_policies_on_name CHECK (length("name") <= 140),
+			CONSTRAINT constraint_retention_policies_on_days CHECK (days > 0)
+		);
+
+		CREATE TABLE IF NOT EXISTS retention_logs (
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			policy_id INT REFERENCES retention_policies(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+			policy_name TEXT NOT NULL,
+			"action" retention_action NOT NULL,
+			inbox_id INT NULL,
+			days INT NOT NULL,
+			purged_count INT DEFAULT 0 NOT NULL,
+			conversation_refs TEXT[] DEFAULT '{}'::TEXT[] NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS index_retention_logs_on_created_at ON retention_logs (created_at);
+		CREATE INDEX IF NOT EXISTS index_retention_logs_on_policy_id ON retention_logs (policy_id);
+
+		ALTER TABLE conversations ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMPTZ NULL;
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add `retention:manage` permission to Admin role.
+	_, err = db.Exec(`
+		UPDATE roles
+		SET permissions = array_append(permissions, 'retention:manage')
+		WHERE name = 'Admin' AND NOT ('retention:manage' = ANY(permissions));
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add spam filter settings and the `Quarantined` status for conversations flagged as spam.
+	_, err = db.Exec(`
+		INSERT INTO settings (key, value)
+		VALUES
+			('spam.enabled', 'false'::jsonb),
+			('spam.threshold', '5'::jsonb),
+			('spam.allowlist', '[]'::jsonb),
+			('spam.blocklist', '[]'::jsonb),
+			('spam.authserv_ids', '[]'::jsonb),
+			('spam.scanner', '""'::jsonb),
+			('spam.scanner_address', '""'::jsonb),
+			('spam.scanner_timeout', '"10s"'::jsonb)
+		ON CONFLICT (key) DO NOTHING;
+
+		INSERT INTO conversation_statuses (name) VALUES ('Quarantined') ON CONFLICT (name) DO NOTHING;
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create sender block rules.
+	_, err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'block_rule_type') THEN
+				CREATE TYPE block_rule_type AS ENUM ('domain', 'wildcard', 'regex');
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'block_rule_action') THEN
+				CREATE TYPE block_rule_action AS ENUM ('drop', 'close');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS sender_block_rules (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			"name" TEXT NOT NULL,
+			enabled BOOLEAN DEFAULT TRUE NOT NULL,
+			"type" block_rule_type NOT NULL,
+			pattern TEXT NOT NULL,
+			"action" block_rule_action NOT NULL,
+			blocked_count INT DEFAULT 0 NOT NULL,
+			last_blocked_at TIMESTAMPTZ NULL,
+			CONSTRAINT constraint_sender_block_rules_on_name CHECK (length("name") <= 140),
+			CONSTRAINT constraint_sender_block_rules_on_pattern CHECK (length(pattern) <= 1000)
+		);
+
+		CREATE TABLE IF NOT EXISTS blocked_messages (
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			rule_id INT REFERENCES sender_block_rules(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+			"action" block_rule_action NOT NULL,
+			inbox_id INT NULL,
+			source_id TEXT NULL UNIQUE,
+			sender TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS index_blocked_messages_on_rule_id ON blocked_messages (rule_id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add `block_rules:manage` permission to Admin role.
+	_, err = db.Exec(`
+		UPDATE roles
+		SET permissions = array_append(permissions, 'block_rules:manage')
+		WHERE name = 'Admin' AND NOT ('block_rules:manage' = ANY(permissions));
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create message_sources table for the raw emails of messages.
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS message_sources (
+			message_id BIGINT PRIMARY KEY REFERENCES conversation_messages(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			raw BYTEA NOT NULL
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add `messages:reprocess` permission to Admin role.
+	_, err = db.Exec(`
+		UPDATE roles
+		SET permissions = array_append(permissions, 'messages:reprocess')
+		WHERE name = 'Admin' AND NOT ('messages:reprocess' = ANY(permissions));
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create dead_letter_messages table for incoming messages that failed processing.
+	_, err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'dead_letter_status') THEN
+				CREATE TYPE dead_letter_status AS ENUM ('pending', 'discarded');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS dead_letter_messages (
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			status dead_letter_status DEFAULT 'pending' NOT NULL,
+			inbox_id INT REFERENCES inboxes(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+			source_id TEXT NULL UNIQUE,
+			sender TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			error TEXT NOT NULL,
+			attempts INT DEFAULT 1 NOT NULL,
+			last_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+			payload BYTEA NULL
+		);
+		CREATE INDEX IF NOT EXISTS index_dead_letter_messages_on_status ON dead_letter_messages (status);
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`ALTER TYPE user_notification_type ADD VALUE IF NOT EXISTS 'dead_letter';`)
+	if err != nil {
+		return err
+	}
+
+	// Add retention action redacting dead-lettered and blocked messages.
+	_, err = db.Exec(`ALTER TYPE retention_action ADD VALUE IF NOT EXISTS 'redact_message_records';`)
+	if err != nil {
+		return err
+	}
+
+	// Add `dead_letters:manage` permission to Admin role.
+	_, err = db.Exec(`
+		UPDATE roles
+		SET permissions = array_append(permissions, 'dead_letters:manage')
+		WHERE name = 'Admin' AND NOT ('dead_letters:manage' = ANY(permissions));
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS claimed_by TEXT NULL;
+		ALTER TABLE conversation_messages ADD COLUMN IF NOT EXISTS claim_expires_at TIMESTAMPTZ NULL;
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS incoming_message_queue (
+			-- Incoming messages fetched from inboxes waiting to be processed, kept here so they survive restarts.
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			inbox_id INT REFERENCES inboxes(id) ON DELETE CASCADE ON UPDATE CASCADE NULL,
+			source_id TEXT NULL UNIQUE,
+			payload BYTEA NOT NULL,
+			attempts INT DEFAULT 0 NOT NULL,
+			last_error TEXT NULL,
+			-- A message is picked up by a worker once visible, a worker processing it pushes this forward by the visibility
+			-- timeout so it is picked up again if the worker dies.
+			visible_at TIMESTAMPTZ DEFAULT NOW() NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS index_incoming_message_queue_on_visible_at ON incoming_message_queue (visible_at);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Create export_jobs table so export jobs and their files are shared by all instances.
+	_, err = db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'export_job_status') THEN
+				CREATE TYPE export_job_status AS ENUM ('running', 'completed', 'failed');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS export_jobs (
+			-- Background export jobs, the exported file is kept in the media store.
+			id BIGSERIAL PRIMARY KEY,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			-- Refreshed while the job runs, a running job not updated for a while was interrupted by a crash.
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			"uuid" UUID DEFAULT gen_random_uuid() NOT NULL UNIQUE,
+			kind TEXT NOT NULL,
+			user_id INT REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+			status export_job_status DEFAULT 'running' NOT NULL,
+			logs TEXT[] DEFAULT '{}'::TEXT[] NOT NULL,
+			total INT DEFAULT 0 NOT NULL,
+			success INT DEFAULT 0 NOT NULL,
+			errors INT DEFAULT 0 NOT NULL,
+			file_name TEXT NOT NULL,
+			media_id INT REFERENCES media(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+			ended_at TIMESTAMPTZ NULL
+		);
+		-- A user can only run one export job of a kind at a time.
+		CREATE UNIQUE INDEX IF NOT EXISTS index_uniq_export_jobs_on_kind_user_id_running ON export_jobs (kind, user_id) WHERE status = 'running';
+		CREATE INDEX IF NOT EXISTS index_export_jobs_on_ended_at ON export_jobs (ended_at);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Store incoming messages once by their source ID. Duplicates inserted before keep the oldest message's
+	// source ID, the others are detached from it rather than deleted.
+	_, err = db.Exec(`
+		UPDATE conversation_messages m
+		SET source_id = NULL
+		WHERE m."type" = 'incoming' AND m.source_id IS NOT NULL AND EXISTS (
+			SELECT 1 FROM conversation_messages o
+			WHERE o."type" = 'incoming' AND o.source_id = m.source_id AND o.id < m.id
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS index_uniq_conversation_messages_on_source_id_incoming
+		ON conversation_messages (source_id) WHERE "type" = 'incoming';
+	`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
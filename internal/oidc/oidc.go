@@ -22,28 +22,29 @@ var (
 
 // Manager handles oidc-related operations.
 type Manager struct {
-	q             queries
-	lo            *logf.Logger
-	i18n          *i18n.I18n
-	setting       settingsStore
-	encryptionKey string
+	q       queries
+	lo      *logf.Logger
+	i18n    *i18n.I18n
+	setting settingsStore
+	keyring *crypto.Keyring
 }
 
 // Opts contains options for initializing the Manager.
 type Opts struct {
-	DB            *sqlx.DB
-	Lo            *logf.Logger
-	I18n          *i18n.I18n
-	EncryptionKey string
+	DB      *sqlx.DB
+	Lo      *logf.Logger
+	I18n    *i18n.I18n
+	Keyring *crypto.Keyring
 }
 
 // queries contains prepared SQL queries.
 type queries struct {
-	GetAllOIDC *sqlx.Stmt `query:"get-all-oidc"`
-	GetOIDC    *sqlx.Stmt `query:"get-oidc"`
-	InsertOIDC *sqlx.Stmt `query:"insert-oidc"`
-	UpdateOIDC *sqlx.Stmt `query:"update-oidc"`
-	DeleteOIDC *sqlx.Stmt `query:"delete-oidc"`
+	GetAllOIDC        *sqlx.Stmt `query:"get-all-oidc"`
+	GetOIDC           *sqlx.Stmt `query:"get-oidc"`
+	InsertOIDC        *sqlx.Stmt `query:"insert-oidc"`
+	UpdateOIDC        *sqlx.Stmt `query:"update-oidc"`
+	DeleteOIDC        *sqlx.Stmt `query:"delete-oidc"`
+	UpdateCredentials *sqlx.Stmt `query:"update-oidc-credentials"`
 }
 
 type settingsStore interface {
@@ -57,11 +58,11 @@ func New(opts Opts, setting settingsStore) (*Manager, error) {
 		return nil, err
 	}
 	return &Manager{
-		q:             q,
-		lo:            opts.Lo,
-		i18n:          opts.I18n,
-		setting:       setting,
-		encryptionKey: opts.EncryptionKey,
+		q:       q,
+		lo:      opts.Lo,
+		i18n:    opts.I18n,
+		setting: setting,
+		keyring: opts.Keyring,
 	}, nil
 }
 
@@ -185,13 +186,13 @@ func (o *Manager) Delete(id int) error {
 // encryptOIDC encrypts sensitive OIDC fields (ClientID and ClientSecret).
 // Returns the encrypted values and any error encountered.
 func (o *Manager) encryptOIDC(clientID, clientSecret string) (encClientID, encClientSecret string, err error) {
-	encClientID, err = crypto.Encrypt(clientID, o.encryptionKey)
+	encClientID, err = o.keyring.Encrypt(clientID)
 	if err != nil {
 		o.lo.Error("error encrypting client_id", "error", err)
 		return "", "", err
 	}
 
-	encClientSecret, err = crypto.Encrypt(clientSecret, o.encryptionKey)
+	encClientSecret, err = o.keyring.Encrypt(clientSecret)
 	if err != nil {
 		o.lo.Error("error encrypting client_secret", "error", err)
 		return "", "", err
@@ -204,13 +205,13 @@ func (o *Manager) encryptOIDC(clientID, clientSecret string) (encClientID, encCl
 // Returns an error if decryption of any field fails.
 func (o *Manager) decryptOIDC(oidc *models.OIDC) error {
 	var err error
-	oidc.ClientID, err = crypto.Decrypt(oidc.ClientID, o.encryptionKey)
+	oidc.ClientID, err = o.keyring.Decrypt(oidc.ClientID)
 	if err != nil {
 		o.lo.Error("error decrypting client_id", "error", err, "oidc_id", oidc.ID)
 		return err
 	}
 
-	oidc.ClientSecret, err = crypto.Decrypt(oidc.ClientSecret, o.encryptionKey)
+	oidc.ClientSecret, err = o.keyring.Decrypt(oidc.ClientSecret)
 	if err != nil {
 		o.lo.Error("error decrypting client_secret", "error", err, "oidc_id", oidc.ID)
 		return err
@@ -219,6 +220,37 @@ func (o *Manager) decryptOIDC(oidc *models.OIDC) error {
 	return nil
 }
 
+// ReEncrypt re-encrypts the client IDs and secrets of all OIDC providers that are not encrypted with the primary key
+// and returns the number of providers updated.
+func (o *Manager) ReEncrypt() (int, error) {
+	var oidcs []models.OIDC
+	if err := o.q.GetAllOIDC.Select(&oidcs); err != nil {
+		o.lo.Error("error fetching oidc", "error", err)
+		return 0, fmt.Errorf("fetching OIDC providers: %w", err)
+	}
+
+	var n int
+	for _, oidc := range oidcs {
+		clientID, idChanged, err := o.keyring.ReEncrypt(oidc.ClientID)
+		if err != nil {
+			return n, fmt.Errorf("re-encrypting client_id of OIDC provider %d: %w", oidc.ID, err)
+		}
+		clientSecret, secretChanged, err := o.keyring.ReEncrypt(oidc.ClientSecret)
+		if err != nil {
+			return n, fmt.Errorf("re-encrypting client_secret of OIDC provider %d: %w", oidc.ID, err)
+		}
+		if !idChanged && !secretChanged {
+			continue
+		}
+		if _, err := o.q.UpdateCredentials.Exec(oidc.ID, clientID, clientSecret); err != nil {
+			o.lo.Error("error updating oidc credentials", "oidc_id", oidc.ID, "error", err)
+			return n, fmt.Errorf("updating OIDC provider %d: %w", oidc.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
 // decryptOIDCSlice decrypts sensitive fields for all OIDC records in a slice.
 // Returns an error if decryption of any record fails.
 func (o *Manager) decryptOIDCSlice(oidcs []models.OIDC) error {
@@ -17,3 +17,6 @@ RETURNING *;
 
 -- name: delete-oidc
 DELETE FROM oidc WHERE id = $1;
+
+-- name: update-oidc-credentials
+UPDATE oidc SET client_id = $2, client_secret = $3 WHERE id = $1;
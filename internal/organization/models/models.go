@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/volatiletech/null/v9"
+)
+
+type Organization struct {
+	ID               int             `db:"id" json:"id"`
+	CreatedAt        time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time       `db:"updated_at" json:"updated_at"`
+	Name             string          `db:"name" json:"name"`
+	Domains          pq.StringArray  `db:"domains" json:"domains"`
+	CustomAttributes json.RawMessage `db:"custom_attributes" json:"custom_attributes"`
+	ContactCount     int             `db:"contact_count" json:"contact_count"`
+
+	Total int `db:"total" json:"-"`
+}
+
+// Contact is a contact belonging to an organization.
+type Contact struct {
+	ID        int         `db:"id" json:"id"`
+	CreatedAt time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time   `db:"updated_at" json:"updated_at"`
+	FirstName string      `db:"first_name" json:"first_name"`
+	LastName  string      `db:"last_name" json:"last_name"`
+	Email     null.String `db:"email" json:"email"`
+	Enabled   bool        `db:"enabled" json:"enabled"`
+	AvatarURL null.String `db:"avatar_url" json:"avatar_url"`
+
+	Total int `db:"total" json:"-"`
+}
+
+// Conversation is a conversation of a contact belonging to an organization.
+type Conversation struct {
+	ID               int         `db:"id" json:"id"`
+	CreatedAt        time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time   `db:"updated_at" json:"updated_at"`
+	UUID             string      `db:"uuid" json:"uuid"`
+	ReferenceNumber  string      `db:"reference_number" json:"reference_number"`
+	Subject          null.String `db:"subject" json:"subject"`
+	Status           null.String `db:"status" json:"status"`
+	Priority         null.String `db:"priority" json:"priority"`
+	LastMessage      null.String `db:"last_message" json:"last_message"`
+	LastMessageAt    null.Time   `db:"last_message_at" json:"last_message_at"`
+	ContactID        int         `db:"contact_id" json:"contact_id"`
+	ContactFirstName string      `db:"contact_first_name" json:"contact_first_name"`
+	ContactLastName  string      `db:"contact_last_name" json:"contact_last_name"`
+
+	Total int `db:"total" json:"-"`
+}
+
+type Note struct {
+	ID             int         `db:"id" json:"id"`
+	CreatedAt      time.Time   `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time   `db:"updated_at" json:"updated_at"`
+	OrganizationID int         `db:"organization_id" json:"organization_id"`
+	Note           string      `db:"note" json:"note"`
+	UserID         int         `db:"user_id" json:"user_id"`
+	FirstName      string      `db:"first_name" json:"first_name"`
+	LastName       string      `db:"last_name" json:"last_name"`
+	AvatarURL      null.String `db:"avatar_url" json:"avatar_url"`
+}
@@ -0,0 +1,45 @@
+package organization
+
+import (
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/organization/models"
+)
+
+// GetNotes returns all notes for an organization.
+func (m *Manager) GetNotes(id int) ([]models.Note, error) {
+	var notes = make([]models.Note, 0)
+	if err := m.q.GetNotes.Select(&notes, id); err != nil {
+		m.lo.Error("error fetching organization notes", "error", err)
+		return notes, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.note")), nil)
+	}
+	return notes, nil
+}
+
+// GetNote returns a note by its ID.
+func (m *Manager) GetNote(id int) (models.Note, error) {
+	var note models.Note
+	if err := m.q.GetNote.Get(&note, id); err != nil {
+		m.lo.Error("error fetching organization note", "error", err)
+		return note, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.note")), nil)
+	}
+	return note, nil
+}
+
+// CreateNote creates a new note for an organization.
+func (m *Manager) CreateNote(organizationID, authorID int, note string) (models.Note, error) {
+	var id int
+	if err := m.q.InsertNote.Get(&id, organizationID, authorID, note); err != nil {
+		m.lo.Error("error creating organization note", "error", err)
+		return models.Note{}, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorCreating", "name", m.i18n.P("globals.terms.note")), nil)
+	}
+	return m.GetNote(id)
+}
+
+// DeleteNote deletes a note for an organization.
+func (m *Manager) DeleteNote(noteID, organizationID int) error {
+	if _, err := m.q.DeleteNote.Exec(noteID, organizationID); err != nil {
+		m.lo.Error("error deleting organization note", "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", m.i18n.P("globals.terms.note")), nil)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+// Package organization handles the management of organizations that contacts belong to.
+package organization
+
+import (
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/abhinavxd/libredesk/internal/dbutil"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/organization/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/go-i18n"
+	"github.com/lib/pq"
+	"github.com/zerodha/logf"
+)
+
+var (
+	//go:embed queries.sql
+	efs embed.FS
+
+	maxListPageSize = 100
+)
+
+type Manager struct {
+	q    queries
+	lo   *logf.Logger
+	i18n *i18n.I18n
+	db   *sqlx.DB
+}
+
+// Opts contains options for initializing the Manager.
+type Opts struct {
+	DB   *sqlx.DB
+	Lo   *logf.Logger
+	I18n *i18n.I18n
+}
+
+// queries contains prepared SQL queries.
+type queries struct {
+	GetOrganizations             string     `query:"get-organizations"`
+	GetOrganization              *sqlx.Stmt `query:"get-organization"`
+	GetOrganizationByDomains     *sqlx.Stmt `query:"get-organization-by-domains"`
+	InsertOrganization           *sqlx.Stmt `query:"insert-organization"`
+	UpdateOrganization           *sqlx.Stmt `query:"update-organization"`
+	DeleteOrganization           *sqlx.Stmt `query:"delete-organization"`
+	GetOrganizationContacts      *sqlx.Stmt `query:"get-organization-contacts"`
+	GetOrganizationConversations *sqlx.Stmt `query:"get-organization-conversations"`
+	GetNotes                     *sqlx.Stmt `query:"get-notes"`
+	GetNote                      *sqlx.Stmt `query:"get-note"`
+	InsertNote                   *sqlx.Stmt `query:"insert-note"`
+	DeleteNote                   *sqlx.Stmt `query:"delete-note"`
+}
+
+// New creates and returns a new instance of the Manager.
+func New(opts Opts) (*Manager, error) {
+	var q queries
+	if err := dbutil.ScanSQLFile("queries.sql", &q, opts.DB, efs); err != nil {
+		return nil, err
+	}
+	return &Manager{
+		q:    q,
+		lo:   opts.Lo,
+		i18n: opts.I18n,
+		db:   opts.DB,
+	}, nil
+}
+
+// GetAll retrieves a page of organizations.
+func (m *Manager) GetAll(page, pageSize int, order, orderBy, filtersJSON string) ([]models.Organization, error) {
+	page, pageSize, err := m.validatePage(page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	query, qArgs, err := dbutil.BuildPaginatedQuery(m.q.GetOrganizations, nil, dbutil.PaginationOptions{
+		Order:    order,
+		OrderBy:  orderBy,
+		Page:     page,
+		PageSize: pageSize,
+	}, filtersJSON, dbutil.AllowedFields{
+		"organizations": {"name", "created_at", "updated_at"},
+	})
+	if err != nil {
+		m.lo.Error("error creating organization list query", "error", err)
+		return nil, envelope.NewError(envelope.InputError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.organization")), nil)
+	}
+
+	var organizations = make([]models.Organization, 0)
+	if err := m.db.Select(&organizations, query, qArgs...); err != nil {
+		m.lo.Error("error fetching organizations", "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.organization")), nil)
+	}
+	return organizations, nil
+}
+
+// Get retrieves an organization by ID.
+func (m *Manager) Get(id int) (models.Organization, error) {
+	var organization models.Organization
+	if err := m.q.GetOrganization.Get(&organization, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return organization, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.organization}"), nil)
+		}
+		m.lo.Error("error fetching organization", "error", err)
+		return organization, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.organization}"), nil)
+	}
+	return organization, nil
+}
+
+// Create creates a new organization. Existing contacts on the organization's domains that don't
+// belong to an organization yet are linked to it.
+func (m *Manager) Create(org models.Organization) (models.Organization, error) {
+	org.Domains = NormalizeDomains(org.Domains)
+	if err := m.checkDomains(0, org.Domains); err != nil {
+		return org, err
+	}
+	var id int
+	if err := m.q.InsertOrganization.Get(&id, org.Name, pq.Array(org.Domains), customAttributes(org.CustomAttributes)); err != nil {
+		m.lo.Error("error inserting organization", "error", err)
+		return org, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.organization}"), nil)
+	}
+	return m.Get(id)
+}
+
+// Update updates an organization. Existing contacts on the organization's domains that don't
+// belong to an organization yet are linked to it.
+func (m *Manager) Update(id int, org models.Organization) (models.Organization, error) {
+	org.Domains = NormalizeDomains(org.Domains)
+	if err := m.checkDomains(id, org.Domains); err != nil {
+		return org, err
+	}
+	if _, err := m.q.UpdateOrganization.Exec(id, org.Name, pq.Array(org.Domains), customAttributes(org.CustomAttributes)); err != nil {
+		m.lo.Error("error updating organization", "error", err)
+		return org, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.organization}"), nil)
+	}
+	return m.Get(id)
+}
+
+// Delete deletes an organization, its contacts are unlinked from it.
+func (m *Manager) Delete(id int) error {
+	if _, err := m.q.DeleteOrganization.Exec(id); err != nil {
+		m.lo.Error("error deleting organization", "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.organization}"), nil)
+	}
+	return nil
+}
+
+// GetContacts retrieves a page of contacts belonging to an organization.
+func (m *Manager) GetContacts(id, page, pageSize int) ([]models.Contact, error) {
+	page, pageSize, err := m.validatePage(page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	var contacts = make([]models.Contact, 0)
+	if err := m.q.GetOrganizationContacts.Select(&contacts, id, pageSize, (page-1)*pageSize); err != nil {
+		m.lo.Error("error fetching organization contacts", "organization_id", id, "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.contact")), nil)
+	}
+	return contacts, nil
+}
+
+// GetConversations retrieves a page of conversations of contacts belonging to an organization.
+func (m *Manager) GetConversations(id, page, pageSize int) ([]models.Conversation, error) {
+	page, pageSize, err := m.validatePage(page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	var conversations = make([]models.Conversation, 0)
+	if err := m.q.GetOrganizationConversations.Select(&conversations, id, pageSize, (page-1)*pageSize); err != nil {
+		m.lo.Error("error fetching organization conversations", "organization_id", id, "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.conversation")), nil)
+	}
+	return conversations, nil
+}
+
+// NormalizeDomains lowercases and trims domains, strips leading `@` and drops empty and duplicate domains.
+func NormalizeDomains(domains []string) []string {
+	var out = make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "@")
+		if d == "" || slices.Contains(out, d) {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// checkDomains returns an error if any of the domains is invalid or already claimed by another organization.
+func (m *Manager) checkDomains(id int, domains []string) error {
+	for _, d := range domains {
+		if strings.ContainsAny(d, "@ /") || !strings.Contains(d, ".") {
+			return envelope.NewError(envelope.InputError, m.i18n.Ts("globals.messages.invalid", "name", d), nil)
+		}
+	}
+	if len(domains) == 0 {
+		return nil
+	}
+	var existing models.Organization
+	if err := m.q.GetOrganizationByDomains.Get(&existing, id, pq.Array(domains)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		m.lo.Error("error fetching organization by domains", "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.organization}"), nil)
+	}
+	return envelope.NewError(envelope.ConflictError, m.i18n.Ts("organization.domainAlreadyClaimed", "name", existing.Name), nil)
+}
+
+// validatePage validates and defaults pagination parameters.
+func (m *Manager) validatePage(page, pageSize int) (int, int, error) {
+	if pageSize > maxListPageSize {
+		return 0, 0, envelope.NewError(envelope.InputError, m.i18n.Ts("globals.messages.pageTooLarge", "max", fmt.Sprintf("%d", maxListPageSize)), nil)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	return page, pageSize, nil
+}
+
+// customAttributes returns the custom attributes JSON, defaulting to an empty object.
+func customAttributes(attrs json.RawMessage) json.RawMessage {
+	if len(attrs) == 0 || string(attrs) == "null" {
+		return json.RawMessage("{}")
+	}
+	return attrs
+}
@@ -0,0 +1,115 @@
+-- name: get-organizations
+SELECT COUNT(*) OVER() as total, organizations.id, organizations.created_at, organizations.updated_at, organizations.name,
+    organizations.domains, organizations.custom_attributes,
+    (SELECT COUNT(*) FROM users u WHERE u.organization_id = organizations.id AND u.deleted_at IS NULL) AS contact_count
+FROM organizations
+WHERE 1=1
+
+-- name: get-organization
+SELECT id, created_at, updated_at, name, domains, custom_attributes,
+    (SELECT COUNT(*) FROM users u WHERE u.organization_id = organizations.id AND u.deleted_at IS NULL) AS contact_count
+FROM organizations
+WHERE id = $1;
+
+-- name: get-organization-by-domains
+-- Returns an organization other than $1 that already claims one of the domains in $2.
+SELECT id, created_at, updated_at, name, domains, custom_attributes
+FROM organizations
+WHERE id != $1 AND domains && $2::TEXT[]
+LIMIT 1;
+
+-- name: insert-organization
+WITH org AS (
+    INSERT INTO organizations (name, domains, custom_attributes)
+    VALUES ($1, $2, $3)
+    RETURNING id
+),
+linked AS (
+    -- Link existing contacts on the organization's domains that don't belong to an organization yet.
+    UPDATE users
+    SET organization_id = (SELECT id FROM org), updated_at = NOW()
+    WHERE type = 'contact' AND deleted_at IS NULL AND organization_id IS NULL
+    AND email IS NOT NULL AND split_part(email, '@', 2) = ANY($2::TEXT[])
+    RETURNING id
+)
+SELECT id FROM org;
+
+-- name: update-organization
+WITH org AS (
+    UPDATE organizations
+    SET name = $2, domains = $3, custom_attributes = $4, updated_at = NOW()
+    WHERE id = $1
+    RETURNING id
+),
+linked AS (
+    -- Link existing contacts on the organization's domains that don't belong to an organization yet.
+    UPDATE users
+    SET organization_id = (SELECT id FROM org), updated_at = NOW()
+    WHERE type = 'contact' AND deleted_at IS NULL AND organization_id IS NULL
+    AND EXISTS (SELECT 1 FROM org)
+    AND email IS NOT NULL AND split_part(email, '@', 2) = ANY($3::TEXT[])
+    RETURNING id
+)
+SELECT id FROM org;
+
+-- name: delete-organization
+DELETE FROM organizations WHERE id = $1;
+
+-- name: get-organization-contacts
+SELECT COUNT(*) OVER() as total, id, created_at, updated_at, first_name, COALESCE(last_name, '') AS last_name, email, enabled, avatar_url
+FROM users
+WHERE organization_id = $1 AND type = 'contact' AND deleted_at IS NULL
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: get-organization-conversations
+SELECT COUNT(*) OVER() as total, c.id, c.created_at, c.updated_at, c.uuid, c.reference_number, c.subject,
+    s.name AS status, p.name AS priority, c.last_message, c.last_message_at, c.contact_id,
+    u.first_name AS contact_first_name, COALESCE(u.last_name, '') AS contact_last_name
+FROM conversations c
+JOIN users u ON u.id = c.contact_id
+LEFT JOIN conversation_statuses s ON s.id = c.status_id
+LEFT JOIN conversation_priorities p ON p.id = c.priority_id
+WHERE u.organization_id = $1
+ORDER BY c.created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: get-notes
+SELECT
+    n.id,
+    n.created_at,
+    n.updated_at,
+    n.organization_id,
+    n.note,
+    n.user_id,
+    u.first_name,
+    u.last_name,
+    u.avatar_url
+FROM organization_notes n
+INNER JOIN users u ON u.id = n.user_id
+WHERE n.organization_id = $1
+ORDER BY n.created_at DESC;
+
+-- name: get-note
+SELECT
+    n.id,
+    n.created_at,
+    n.updated_at,
+    n.organization_id,
+    n.note,
+    n.user_id,
+    u.first_name,
+    u.last_name,
+    u.avatar_url
+FROM organization_notes n
+INNER JOIN users u ON u.id = n.user_id
+WHERE n.id = $1;
+
+-- name: insert-note
+INSERT INTO organization_notes (organization_id, user_id, note)
+VALUES ($1, $2, $3)
+RETURNING id;
+
+-- name: delete-note
+DELETE FROM organization_notes
+WHERE id = $1 AND organization_id = $2;
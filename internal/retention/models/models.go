@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/volatiletech/null/v9"
+)
+
+const (
+	// ActionDeleteConversations deletes closed conversations with their messages and attachments.
+	ActionDeleteConversations = "delete_conversations"
+	// ActionAnonymizeConversations redacts the messages of closed conversations and deletes their attachments,
+	// the conversations are kept for reports.
+	ActionAnonymizeConversations = "anonymize_conversations"
+	// ActionPurgePrivateNotes deletes private notes.
+	ActionPurgePrivateNotes = "purge_private_notes"
+	// ActionPurgeAttachments deletes message attachments.
+	ActionPurgeAttachments = "purge_attachments"
+	// ActionRedactMessageRecords redacts the senders, subjects and raw messages of dead-lettered and blocked
+	// messages, their message IDs are kept so they are not fetched again.
+	ActionRedactMessageRecords = "redact_message_records"
+)
+
+// Actions are the valid retention policy actions.
+var Actions = []string{ActionDeleteConversations, ActionAnonymizeConversations, ActionPurgePrivateNotes, ActionPurgeAttachments, ActionRedactMessageRecords}
+
+// Policy is a data retention policy. Conversation actions apply to conversations closed more than `days` ago,
+// other actions to items created more than `days` ago.
+type Policy struct {
+	ID        int       `db:"id" json:"id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	Name      string    `db:"name" json:"name"`
+	Enabled   bool      `db:"enabled" json:"enabled"`
+	Action    string    `db:"action" json:"action"`
+	// InboxID restricts the policy to an inbox, the policy applies to all inboxes when not set.
+	InboxID   null.Int  `db:"inbox_id" json:"inbox_id"`
+	Days      int       `db:"days" json:"days"`
+	LastRunAt null.Time `db:"last_run_at" json:"last_run_at"`
+}
+
+// Log is an audit log entry of a retention policy run.
+type Log struct {
+	ID               int            `db:"id" json:"id"`
+	CreatedAt        time.Time      `db:"created_at" json:"created_at"`
+	PolicyID         null.Int       `db:"policy_id" json:"policy_id"`
+	PolicyName       string         `db:"policy_name" json:"policy_name"`
+	Action           string         `db:"action" json:"action"`
+	InboxID          null.Int       `db:"inbox_id" json:"inbox_id"`
+	Days             int            `db:"days" json:"days"`
+	PurgedCount      int            `db:"purged_count" json:"purged_count"`
+	ConversationRefs pq.StringArray `db:"conversation_refs" json:"conversation_refs"`
+
+	Total int `db:"total" json:"-"`
+}
+
+// Preview is the number of items a retention policy would purge if it ran now.
+type Preview struct {
+	Count int `json:"count"`
+}
@@ -0,0 +1,189 @@
+-- name: get-policies
+SELECT id, created_at, updated_at, "name", enabled, "action", inbox_id, days, last_run_at
+FROM retention_policies
+ORDER BY created_at;
+
+-- name: get-enabled-policies
+SELECT id, created_at, updated_at, "name", enabled, "action", inbox_id, days, last_run_at
+FROM retention_policies
+WHERE enabled = TRUE
+ORDER BY id;
+
+-- name: get-policy
+SELECT id, created_at, updated_at, "name", enabled, "action", inbox_id, days, last_run_at
+FROM retention_policies
+WHERE id = $1;
+
+-- name: insert-policy
+INSERT INTO retention_policies ("name", enabled, "action", inbox_id, days)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id;
+
+-- name: update-policy
+UPDATE retention_policies
+SET "name" = $2,
+    enabled = $3,
+    "action" = $4,
+    inbox_id = $5,
+    days = $6,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: delete-policy
+DELETE FROM retention_policies WHERE id = $1;
+
+-- name: update-policy-last-run
+UPDATE retention_policies SET last_run_at = NOW() WHERE id = $1;
+
+-- name: get-logs
+SELECT COUNT(*) OVER() AS total,
+    id, created_at, policy_id, policy_name, "action", inbox_id, days, purged_count, conversation_refs
+FROM retention_logs
+WHERE ($1 = 0 OR policy_id = $1)
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: insert-log
+INSERT INTO retention_logs (policy_id, policy_name, "action", inbox_id, days, purged_count, conversation_refs)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: count-closed-conversations
+-- Counts conversations closed more than $2 days ago, in inbox $1 or all inboxes when $1 is 0,
+-- only counting conversations not anonymized yet when $3 is true.
+SELECT COUNT(*)
+FROM conversations c
+    JOIN conversation_statuses s ON s.id = c.status_id
+WHERE s."name" = 'Closed'
+    AND c.closed_at < NOW() - make_interval(days => $2)
+    AND ($1 = 0 OR c.inbox_id = $1)
+    AND (NOT $3 OR c.anonymized_at IS NULL);
+
+-- name: get-closed-conversations
+SELECT c.id, c.reference_number
+FROM conversations c
+    JOIN conversation_statuses s ON s.id = c.status_id
+WHERE s."name" = 'Closed'
+    AND c.closed_at < NOW() - make_interval(days => $2)
+    AND ($1 = 0 OR c.inbox_id = $1)
+    AND (NOT $3 OR c.anonymized_at IS NULL)
+ORDER BY c.id
+LIMIT $4;
+
+-- name: count-private-notes
+-- Counts private notes created more than $2 days ago, in inbox $1 or all inboxes when $1 is 0.
+SELECT COUNT(*)
+FROM conversation_messages m
+    JOIN conversations c ON c.id = m.conversation_id
+WHERE m.private = TRUE
+    AND m."type" = 'outgoing'
+    AND m.created_at < NOW() - make_interval(days => $2)
+    AND ($1 = 0 OR c.inbox_id = $1);
+
+-- name: get-private-notes
+SELECT m.id, c.reference_number
+FROM conversation_messages m
+    JOIN conversations c ON c.id = m.conversation_id
+WHERE m.private = TRUE
+    AND m."type" = 'outgoing'
+    AND m.created_at < NOW() - make_interval(days => $2)
+    AND ($1 = 0 OR c.inbox_id = $1)
+ORDER BY m.id
+LIMIT $3;
+
+-- name: count-attachments
+-- Counts attachments of messages created more than $2 days ago, in inbox $1 or all inboxes when $1 is 0.
+SELECT COUNT(*)
+FROM media
+    JOIN conversation_messages m ON m.id = media.model_id
+    JOIN conversations c ON c.id = m.conversation_id
+WHERE media.model_type = 'messages'
+    AND m.created_at < NOW() - make_interval(days => $2)
+    AND ($1 = 0 OR c.inbox_id = $1);
+
+-- name: get-attachments
+SELECT media.id, media.created_at, media.updated_at, media."uuid", media.store, media.filename, media.content_type,
+    media.content_id, media.model_id, media.model_type, media.disposition, media."size", media.meta, c.reference_number
+FROM media
+    JOIN conversation_messages m ON m.id = media.model_id
+    JOIN conversations c ON c.id = m.conversation_id
+WHERE media.model_type = 'messages'
+    AND m.created_at < NOW() - make_interval(days => $2)
+    AND ($1 = 0 OR c.inbox_id = $1)
+ORDER BY media.id
+LIMIT $3;
+
+-- name: get-conversations-media
+SELECT media.id, media.created_at, media.updated_at, media."uuid", media.store, media.filename, media.content_type,
+    media.content_id, media.model_id, media.model_type, media.disposition, media."size", media.meta
+FROM media
+    JOIN conversation_messages m ON m.id = media.model_id
+WHERE media.model_type = 'messages'
+    AND m.conversation_id = ANY($1::BIGINT[]);
+
+-- name: get-messages-media
+SELECT id, created_at, updated_at, "uuid", store, filename, content_type, content_id, model_id, model_type, disposition, "size", meta
+FROM media
+WHERE model_type = 'messages'
+    AND model_id = ANY($1::BIGINT[]);
+
+-- name: delete-conversations
+DELETE FROM conversations WHERE id = ANY($1::BIGINT[]);
+
+-- name: anonymize-conversations
+-- Redacts the messages of conversations and their previews, activities are kept.
+WITH redacted_messages AS (
+    UPDATE conversation_messages
+    SET content = $2,
+        text_content = $2,
+        meta = COALESCE(meta, '{}'::jsonb) - 'from' - 'to' - 'cc' - 'bcc' - 'subject',
+        updated_at = NOW()
+    WHERE conversation_id = ANY($1::BIGINT[]) AND "type" != 'activity'
+),
+deleted_sources AS (
+    DELETE FROM message_sources
+    WHERE message_id IN (SELECT id FROM conversation_messages WHERE conversation_id = ANY($1::BIGINT[]))
+),
+deleted_drafts AS (
+    DELETE FROM conversation_drafts WHERE conversation_id = ANY($1::BIGINT[])
+)
+UPDATE conversations
+SET subject = CASE WHEN subject IS NULL THEN NULL ELSE $2 END,
+    last_message = CASE WHEN last_message IS NULL THEN NULL ELSE $2 END,
+    last_interaction = CASE WHEN last_interaction IS NULL THEN NULL ELSE $2 END,
+    anonymized_at = NOW(),
+    updated_at = NOW()
+WHERE id = ANY($1::BIGINT[]);
+
+-- name: delete-messages
+DELETE FROM conversation_messages WHERE id = ANY($1::BIGINT[]);
+
+-- name: count-message-records
+-- Counts dead-lettered and blocked messages recorded more than $2 days ago and not redacted yet, in inbox $1 or all
+-- inboxes when $1 is 0.
+SELECT
+    (SELECT COUNT(*) FROM dead_letter_messages
+        WHERE sender != '' AND created_at < NOW() - make_interval(days => $2) AND ($1 = 0 OR inbox_id = $1))
+    + (SELECT COUNT(*) FROM blocked_messages
+        WHERE sender != '' AND created_at < NOW() - make_interval(days => $2) AND ($1 = 0 OR inbox_id = $1));
+
+-- name: redact-message-records
+-- Redacts the dead-lettered and blocked messages counted by count-message-records and returns their number. Pending
+-- dead-lettered messages are discarded as their payload is removed.
+WITH dead_letters AS (
+    UPDATE dead_letter_messages
+    SET sender = '',
+        subject = '',
+        error = '',
+        payload = NULL,
+        status = 'discarded',
+        updated_at = NOW()
+    WHERE sender != '' AND created_at < NOW() - make_interval(days => $2) AND ($1 = 0 OR inbox_id = $1)
+    RETURNING id
+),
+blocked AS (
+    UPDATE blocked_messages
+    SET sender = ''
+    WHERE sender != '' AND created_at < NOW() - make_interval(days => $2) AND ($1 = 0 OR inbox_id = $1)
+    RETURNING id
+)
+SELECT (SELECT COUNT(*) FROM dead_letters) + (SELECT COUNT(*) FROM blocked);
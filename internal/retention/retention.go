@@ -0,0 +1,403 @@
+// Package retention handles data retention policies that periodically delete or anonymize old support data.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/dbutil"
+	"github.com/abhinavxd/libredesk/internal/envelope"
+	mmodels "github.com/abhinavxd/libredesk/internal/media/models"
+	"github.com/abhinavxd/libredesk/internal/retention/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/go-i18n"
+	"github.com/lib/pq"
+	"github.com/zerodha/logf"
+)
+
+var (
+	//go:embed queries.sql
+	efs embed.FS
+)
+
+const (
+	// batchSize is the number of items purged at a time.
+	batchSize = 500
+
+	// redactedContent replaces the content of anonymized messages.
+	redactedContent = "[redacted]"
+)
+
+type mediaStore interface {
+	DeleteWithThumbnail(media mmodels.Media) error
+}
+
+// Manager manages data retention policies.
+type Manager struct {
+	q     queries
+	lo    *logf.Logger
+	i18n  *i18n.I18n
+	media mediaStore
+}
+
+// Opts contains options for initializing the Manager.
+type Opts struct {
+	DB   *sqlx.DB
+	Lo   *logf.Logger
+	I18n *i18n.I18n
+}
+
+// queries contains prepared SQL queries.
+type queries struct {
+	GetPolicies              *sqlx.Stmt `query:"get-policies"`
+	GetEnabledPolicies       *sqlx.Stmt `query:"get-enabled-policies"`
+	GetPolicy                *sqlx.Stmt `query:"get-policy"`
+	InsertPolicy             *sqlx.Stmt `query:"insert-policy"`
+	UpdatePolicy             *sqlx.Stmt `query:"update-policy"`
+	DeletePolicy             *sqlx.Stmt `query:"delete-policy"`
+	UpdatePolicyLastRun      *sqlx.Stmt `query:"update-policy-last-run"`
+	GetLogs                  *sqlx.Stmt `query:"get-logs"`
+	InsertLog                *sqlx.Stmt `query:"insert-log"`
+	CountClosedConversations *sqlx.Stmt `query:"count-closed-conversations"`
+	GetClosedConversations   *sqlx.Stmt `query:"get-closed-conversations"`
+	CountPrivateNotes        *sqlx.Stmt `query:"count-private-notes"`
+	GetPrivateNotes          *sqlx.Stmt `query:"get-private-notes"`
+	CountAttachments         *sqlx.Stmt `query:"count-attachments"`
+	GetAttachments           *sqlx.Stmt `query:"get-attachments"`
+	GetConversationsMedia    *sqlx.Stmt `query:"get-conversations-media"`
+	GetMessagesMedia         *sqlx.Stmt `query:"get-messages-media"`
+	DeleteConversations      *sqlx.Stmt `query:"delete-conversations"`
+	AnonymizeConversations   *sqlx.Stmt `query:"anonymize-conversations"`
+	DeleteMessages           *sqlx.Stmt `query:"delete-messages"`
+	CountMessageRecords      *sqlx.Stmt `query:"count-message-records"`
+	RedactMessageRecords     *sqlx.Stmt `query:"redact-message-records"`
+}
+
+// item is a conversation or message matched by a policy.
+type item struct {
+	ID              int64  `db:"id"`
+	ReferenceNumber string `db:"reference_number"`
+}
+
+// attachment is a message attachment matched by a policy.
+type attachment struct {
+	mmodels.Media
+	ReferenceNumber string `db:"reference_number"`
+}
+
+// New creates and returns a new instance of the Manager.
+func New(media mediaStore, opts Opts) (*Manager, error) {
+	var q queries
+	if err := dbutil.ScanSQLFile("queries.sql", &q, opts.DB, efs); err != nil {
+		return nil, err
+	}
+	return &Manager{
+		q:     q,
+		lo:    opts.Lo,
+		i18n:  opts.I18n,
+		media: media,
+	}, nil
+}
+
+// GetAll retrieves all retention policies.
+func (m *Manager) GetAll() ([]models.Policy, error) {
+	var policies = make([]models.Policy, 0)
+	if err := m.q.GetPolicies.Select(&policies); err != nil {
+		m.lo.Error("error fetching retention policies", "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.retentionPolicy")), nil)
+	}
+	return policies, nil
+}
+
+// Get retrieves a retention policy by ID.
+func (m *Manager) Get(id int) (models.Policy, error) {
+	var policy models.Policy
+	if err := m.q.GetPolicy.Get(&policy, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return policy, envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.retentionPolicy}"), nil)
+		}
+		m.lo.Error("error fetching retention policy", "error", err)
+		return policy, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.retentionPolicy}"), nil)
+	}
+	return policy, nil
+}
+
+// Create creates a new retention policy.
+func (m *Manager) Create(policy models.Policy) (models.Policy, error) {
+	var id int
+	if err := m.q.InsertPolicy.Get(&id, policy.Name, policy.Enabled, policy.Action, policy.InboxID, policy.Days); err != nil {
+		m.lo.Error("error inserting retention policy", "error", err)
+		return policy, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.retentionPolicy}"), nil)
+	}
+	return m.Get(id)
+}
+
+// Update updates a retention policy.
+func (m *Manager) Update(id int, policy models.Policy) (models.Policy, error) {
+	if _, err := m.q.UpdatePolicy.Exec(id, policy.Name, policy.Enabled, policy.Action, policy.InboxID, policy.Days); err != nil {
+		m.lo.Error("error updating retention policy", "error", err)
+		return policy, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.retentionPolicy}"), nil)
+	}
+	return m.Get(id)
+}
+
+// Delete deletes a retention policy, its logs are kept.
+func (m *Manager) Delete(id int) error {
+	if _, err := m.q.DeletePolicy.Exec(id); err != nil {
+		m.lo.Error("error deleting retention policy", "error", err)
+		return envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.retentionPolicy}"), nil)
+	}
+	return nil
+}
+
+// GetLogs retrieves a page of retention logs, of a policy if policyID is set.
+func (m *Manager) GetLogs(policyID, page, pageSize int) ([]models.Log, error) {
+	var logs = make([]models.Log, 0)
+	if err := m.q.GetLogs.Select(&logs, policyID, pageSize, (page-1)*pageSize); err != nil {
+		m.lo.Error("error fetching retention logs", "error", err)
+		return nil, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", m.i18n.P("globals.terms.log")), nil)
+	}
+	return logs, nil
+}
+
+// Preview returns the number of conversations, private notes or attachments a policy would purge if it ran now.
+func (m *Manager) Preview(policy models.Policy) (models.Preview, error) {
+	var (
+		preview models.Preview
+		inboxID = int(policy.InboxID.Int)
+		err     error
+	)
+	switch policy.Action {
+	case models.ActionDeleteConversations:
+		err = m.q.CountClosedConversations.Get(&preview.Count, inboxID, policy.Days, false)
+	case models.ActionAnonymizeConversations:
+		err = m.q.CountClosedConversations.Get(&preview.Count, inboxID, policy.Days, true)
+	case models.ActionPurgePrivateNotes:
+		err = m.q.CountPrivateNotes.Get(&preview.Count, inboxID, policy.Days)
+	case models.ActionPurgeAttachments:
+		err = m.q.CountAttachments.Get(&preview.Count, inboxID, policy.Days)
+	case models.ActionRedactMessageRecords:
+		err = m.q.CountMessageRecords.Get(&preview.Count, inboxID, policy.Days)
+	default:
+		return preview, envelope.NewError(envelope.InputError, m.i18n.Ts("globals.messages.invalid", "name", "`action`"), nil)
+	}
+	if err != nil {
+		m.lo.Error("error previewing retention policy", "action", policy.Action, "error", err)
+		return preview, envelope.NewError(envelope.GeneralError, m.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.retentionPolicy}"), nil)
+	}
+	return preview, nil
+}
+
+// Run applies all enabled retention policies at the given interval until the context is cancelled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.applyPolicies(ctx)
+		}
+	}
+}
+
+// applyPolicies applies all enabled retention policies.
+func (m *Manager) applyPolicies(ctx context.Context) {
+	var policies []models.Policy
+	if err := m.q.GetEnabledPolicies.Select(&policies); err != nil {
+		m.lo.Error("error fetching enabled retention policies", "error", err)
+		return
+	}
+	for _, policy := range policies {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := m.Apply(ctx, policy); err != nil {
+			m.lo.Error("error applying retention policy", "id", policy.ID, "name", policy.Name, "error", err)
+		}
+	}
+}
+
+// Apply applies a retention policy and records what was purged in the retention log.
+func (m *Manager) Apply(ctx context.Context, policy models.Policy) error {
+	var (
+		purged int
+		refs   []string
+		err    error
+	)
+	switch policy.Action {
+	case models.ActionDeleteConversations, models.ActionAnonymizeConversations:
+		purged, refs, err = m.purgeConversations(ctx, policy)
+	case models.ActionPurgePrivateNotes:
+		purged, refs, err = m.purgePrivateNotes(ctx, policy)
+	case models.ActionPurgeAttachments:
+		purged, refs, err = m.purgeAttachments(ctx, policy)
+	case models.ActionRedactMessageRecords:
+		purged, err = m.redactMessageRecords(policy)
+	default:
+		err = fmt.Errorf("invalid retention action: %s", policy.Action)
+	}
+
+	// Record whatever was purged, even if the run was interrupted.
+	if purged > 0 {
+		m.lo.Info("applied retention policy", "id", policy.ID, "name", policy.Name, "action", policy.Action, "purged", purged)
+		if _, lerr := m.q.InsertLog.Exec(policy.ID, policy.Name, policy.Action, policy.InboxID, policy.Days, purged, pq.Array(refs)); lerr != nil {
+			m.lo.Error("error inserting retention log", "id", policy.ID, "error", lerr)
+		}
+	}
+	if _, lerr := m.q.UpdatePolicyLastRun.Exec(policy.ID); lerr != nil {
+		m.lo.Error("error updating retention policy last run", "id", policy.ID, "error", lerr)
+	}
+	return err
+}
+
+// purgeConversations deletes or anonymizes the conversations closed before the policy's retention period
+// along with their attachments.
+func (m *Manager) purgeConversations(ctx context.Context, policy models.Policy) (int, []string, error) {
+	var (
+		anonymize = policy.Action == models.ActionAnonymizeConversations
+		purged    int
+		refs      []string
+	)
+	for ctx.Err() == nil {
+		var convs []item
+		if err := m.q.GetClosedConversations.Select(&convs, int(policy.InboxID.Int), policy.Days, anonymize, batchSize); err != nil {
+			return purged, refs, fmt.Errorf("fetching conversations: %w", err)
+		}
+		if len(convs) == 0 {
+			break
+		}
+
+		ids := make([]int64, 0, len(convs))
+		for _, c := range convs {
+			ids = append(ids, c.ID)
+		}
+		var media []mmodels.Media
+		if err := m.q.GetConversationsMedia.Select(&media, pq.Array(ids)); err != nil {
+			return purged, refs, fmt.Errorf("fetching conversation media: %w", err)
+		}
+		m.deleteMedia(media)
+
+		stmt := m.q.DeleteConversations
+		args := []any{pq.Array(ids)}
+		if anonymize {
+			stmt = m.q.AnonymizeConversations
+			args = append(args, redactedContent)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return purged, refs, fmt.Errorf("purging conversations: %w", err)
+		}
+
+		purged += len(convs)
+		for _, c := range convs {
+			refs = append(refs, c.ReferenceNumber)
+		}
+		if len(convs) < batchSize {
+			break
+		}
+	}
+	return purged, refs, ctx.Err()
+}
+
+// purgePrivateNotes deletes the private notes created before the policy's retention period along with their attachments.
+func (m *Manager) purgePrivateNotes(ctx context.Context, policy models.Policy) (int, []string, error) {
+	var (
+		purged int
+		refs   []string
+	)
+	for ctx.Err() == nil {
+		var notes []item
+		if err := m.q.GetPrivateNotes.Select(&notes, int(policy.InboxID.Int), policy.Days, batchSize); err != nil {
+			return purged, refs, fmt.Errorf("fetching private notes: %w", err)
+		}
+		if len(notes) == 0 {
+			break
+		}
+
+		ids := make([]int64, 0, len(notes))
+		for _, n := range notes {
+			ids = append(ids, n.ID)
+		}
+		var media []mmodels.Media
+		if err := m.q.GetMessagesMedia.Select(&media, pq.Array(ids)); err != nil {
+			return purged, refs, fmt.Errorf("fetching private note media: %w", err)
+		}
+		m.deleteMedia(media)
+
+		if _, err := m.q.DeleteMessages.Exec(pq.Array(ids)); err != nil {
+			return purged, refs, fmt.Errorf("deleting private notes: %w", err)
+		}
+
+		purged += len(notes)
+		for _, n := range notes {
+			if !slices.Contains(refs, n.ReferenceNumber) {
+				refs = append(refs, n.ReferenceNumber)
+			}
+		}
+		if len(notes) < batchSize {
+			break
+		}
+	}
+	return purged, refs, ctx.Err()
+}
+
+// purgeAttachments deletes the attachments of messages created before the policy's retention period.
+func (m *Manager) purgeAttachments(ctx context.Context, policy models.Policy) (int, []string, error) {
+	var (
+		purged int
+		refs   []string
+	)
+	for ctx.Err() == nil {
+		var attachments []attachment
+		if err := m.q.GetAttachments.Select(&attachments, int(policy.InboxID.Int), policy.Days, batchSize); err != nil {
+			return purged, refs, fmt.Errorf("fetching attachments: %w", err)
+		}
+		if len(attachments) == 0 {
+			break
+		}
+
+		var deleted int
+		for _, a := range attachments {
+			if err := m.media.DeleteWithThumbnail(a.Media); err != nil {
+				m.lo.Error("error deleting attachment", "uuid", a.UUID, "error", err)
+				continue
+			}
+			deleted++
+			if !slices.Contains(refs, a.ReferenceNumber) {
+				refs = append(refs, a.ReferenceNumber)
+			}
+		}
+		purged += deleted
+
+		// Stop if nothing could be deleted, the same attachments would be fetched again.
+		if deleted == 0 || len(attachments) < batchSize {
+			break
+		}
+	}
+	return purged, refs, ctx.Err()
+}
+
+// redactMessageRecords redacts the dead-lettered and blocked messages recorded before the policy's retention period.
+// They belong to no conversation so no references are returned.
+func (m *Manager) redactMessageRecords(policy models.Policy) (int, error) {
+	var n int
+	if err := m.q.RedactMessageRecords.Get(&n, int(policy.InboxID.Int), policy.Days); err != nil {
+		return 0, fmt.Errorf("redacting message records: %w", err)
+	}
+	return n, nil
+}
+
+// deleteMedia deletes media files and their thumbnails from the media store.
+func (m *Manager) deleteMedia(media []mmodels.Media) {
+	for _, mm := range media {
+		if err := m.media.DeleteWithThumbnail(mm); err != nil {
+			m.lo.Error("error deleting media", "uuid", mm.UUID, "error", err)
+		}
+	}
+}
@@ -2,7 +2,9 @@
 package search
 
 import (
+	"context"
 	"embed"
+	"fmt"
 
 	"github.com/abhinavxd/libredesk/internal/dbutil"
 	"github.com/abhinavxd/libredesk/internal/envelope"
@@ -15,11 +17,18 @@ import (
 var (
 	//go:embed queries.sql
 	efs embed.FS
+
+	// indexes are the trigram indexes searches use.
+	indexes = []string{
+		"index_trgm_conversation_messages_on_text_content",
+		"index_tgrm_users_on_email",
+	}
 )
 
 // Manager is the search manager
 type Manager struct {
 	q    queries
+	db   *sqlx.DB
 	lo   *logf.Logger
 	i18n *i18n.I18n
 }
@@ -45,7 +54,20 @@ func New(opts Opts) (*Manager, error) {
 	if err := dbutil.ScanSQLFile("queries.sql", &q, opts.DB, efs); err != nil {
 		return nil, err
 	}
-	return &Manager{q: q, lo: opts.Lo, i18n: opts.I18n}, nil
+	return &Manager{q: q, db: opts.DB, lo: opts.Lo, i18n: opts.I18n}, nil
+}
+
+// Reindex rebuilds the search indexes, e.g. after they got bloated or corrupted, without blocking writes, and
+// returns their names.
+func (s *Manager) Reindex(ctx context.Context) ([]string, error) {
+	for _, index := range indexes {
+		s.lo.Info("rebuilding search index", "index", index)
+		if _, err := s.db.ExecContext(ctx, "REINDEX INDEX CONCURRENTLY "+index); err != nil {
+			s.lo.Error("error rebuilding search index", "index", index, "error", err)
+			return nil, fmt.Errorf("rebuilding index %s: %w", index, err)
+		}
+	}
+	return indexes, nil
 }
 
 // Conversations searches conversations based on the query
@@ -29,7 +29,19 @@ type EmailNotification struct {
 	Enabled       bool   `json:"notification.email.enabled" db:"notification.email.enabled"`
 }
 
+type Spam struct {
+	Enabled        bool     `json:"spam.enabled"`
+	Threshold      float64  `json:"spam.threshold"`
+	Allowlist      []string `json:"spam.allowlist"`
+	Blocklist      []string `json:"spam.blocklist"`
+	AuthservIDs    []string `json:"spam.authserv_ids"`
+	Scanner        string   `json:"spam.scanner"`
+	ScannerAddress string   `json:"spam.scanner_address"`
+	ScannerTimeout string   `json:"spam.scanner_timeout"`
+}
+
 type Settings struct {
 	EmailNotification
 	General
+	Spam
 }
@@ -4,6 +4,7 @@ package setting
 import (
 	"embed"
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/abhinavxd/libredesk/internal/crypto"
@@ -24,15 +25,15 @@ var (
 type Manager struct {
 	q               queries
 	lo              *logf.Logger
-	encryptionKey   string
+	keyring         *crypto.Keyring
 	encryptedFields map[string]bool
 }
 
 // Opts contains options for initializing the Manager.
 type Opts struct {
-	DB            *sqlx.DB
-	Lo            *logf.Logger
-	EncryptionKey string
+	DB      *sqlx.DB
+	Lo      *logf.Logger
+	Keyring *crypto.Keyring
 }
 
 // queries contains prepared SQL queries.
@@ -59,7 +60,7 @@ func New(opts Opts) (*Manager, error) {
 	return &Manager{
 		q:               q,
 		lo:              opts.Lo,
-		encryptionKey:   opts.EncryptionKey,
+		keyring:         opts.Keyring,
 		encryptedFields: encryptedFields,
 	}, nil
 }
@@ -221,6 +222,48 @@ func (m *Manager) GetAppRootURL() (string, error) {
 	return strings.Trim(string(rootURL), "\""), nil
 }
 
+// ReEncrypt re-encrypts the sensitive settings that are not encrypted with the primary key and returns the number of
+// settings updated.
+func (m *Manager) ReEncrypt() (int, error) {
+	var b types.JSONText
+	if err := m.q.GetAll.Get(&b); err != nil {
+		m.lo.Error("error fetching settings", "error", err)
+		return 0, fmt.Errorf("fetching settings: %w", err)
+	}
+	var settings map[string]any
+	if err := json.Unmarshal(b, &settings); err != nil {
+		return 0, fmt.Errorf("parsing settings: %w", err)
+	}
+
+	updated := make(map[string]any)
+	for key := range m.encryptedFields {
+		value, ok := settings[key].(string)
+		if !ok {
+			continue
+		}
+		encrypted, changed, err := m.keyring.ReEncrypt(value)
+		if err != nil {
+			return 0, fmt.Errorf("re-encrypting setting %s: %w", key, err)
+		}
+		if changed {
+			updated[key] = encrypted
+		}
+	}
+	if len(updated) == 0 {
+		return 0, nil
+	}
+
+	out, err := json.Marshal(updated)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := m.q.Update.Exec(out); err != nil {
+		m.lo.Error("error updating settings", "error", err)
+		return 0, fmt.Errorf("updating settings: %w", err)
+	}
+	return len(updated), nil
+}
+
 // encryptSettings encrypts sensitive fields in the settings JSON.
 func (m *Manager) encryptSettings(data []byte) ([]byte, error) {
 	var settings map[string]interface{}
@@ -278,7 +321,7 @@ func (m *Manager) encryptIfNeeded(key, value string) (string, error) {
 		return value, nil
 	}
 
-	encrypted, err := crypto.Encrypt(value, m.encryptionKey)
+	encrypted, err := m.keyring.Encrypt(value)
 	if err != nil {
 		m.lo.Error("error encrypting setting", "key", key, "error", err)
 		return "", err
@@ -294,7 +337,7 @@ func (m *Manager) decryptIfNeeded(key, value string) (string, error) {
 		return value, nil
 	}
 
-	decrypted, err := crypto.Decrypt(value, m.encryptionKey)
+	decrypted, err := m.keyring.Decrypt(value)
 	if err != nil {
 		m.lo.Error("error decrypting setting", "key", key, "error", err)
 		return "", err
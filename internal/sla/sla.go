@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	businesshours "github.com/abhinavxd/libredesk/internal/business_hours"
 	bmodels "github.com/abhinavxd/libredesk/internal/business_hours/models"
 	cmodels "github.com/abhinavxd/libredesk/internal/conversation/models"
@@ -420,6 +421,7 @@ func (m *Manager) evaluatePendingSLAEvents(ctx context.Context) error {
 				m.lo.Error("error marking SLA event as breached", "error", err)
 				continue
 			}
+			metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_sla_breaches_total{metric=%q}`, MetricNextResponse)).Inc()
 		}
 
 		// Met at before the deadline - mark event met.
@@ -865,6 +867,7 @@ func (m *Manager) evaluatePendingSLAs(ctx context.Context) error {
 			if err := m.evaluateSLA(sla); err != nil {
 				m.lo.Error("error evaluating SLA", "error", err)
 			}
+			metrics.GetOrCreateCounter(`libredesk_sla_evaluations_total`).Inc()
 		}
 	}
 	m.lo.Info("evaluated pending SLAs", "count", len(pendingSLAs))
@@ -940,6 +943,7 @@ func (m *Manager) handleSLABreach(appliedSLAID, slaPolicyID int, metric string)
 	if _, err := m.q.UpdateAppliedSLABreachedAt.Exec(appliedSLAID, metric); err != nil {
 		return err
 	}
+	metrics.GetOrCreateCounter(fmt.Sprintf(`libredesk_sla_breaches_total{metric=%q}`, metric)).Inc()
 
 	// Schedule notification for the breach if there are any.
 	sla, err := m.Get(slaPolicyID)
@@ -0,0 +1,133 @@
+package spam
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// spamAssassin is a client for the spamd protocol.
+type spamAssassin struct {
+	address string
+	timeout time.Duration
+}
+
+func newSpamAssassin(address string, timeout time.Duration) *spamAssassin {
+	if address == "" {
+		address = "127.0.0.1:783"
+	}
+	return &spamAssassin{address: address, timeout: timeout}
+}
+
+// Scan sends a CHECK request to spamd and parses the "Spam: True ; 15.3 / 5.0" response header.
+func (s *spamAssassin) Scan(ctx context.Context, raw []byte) (float64, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return 0, false, fmt.Errorf("connecting to spamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := fmt.Fprintf(conn, "CHECK SPAMC/1.5\r\nContent-length: %d\r\n\r\n", len(raw)); err != nil {
+		return 0, false, fmt.Errorf("writing to spamd: %w", err)
+	}
+	if _, err := conn.Write(raw); err != nil {
+		return 0, false, fmt.Errorf("writing to spamd: %w", err)
+	}
+
+	rd := bufio.NewReader(conn)
+	status, err := rd.ReadString('\n')
+	if err != nil {
+		return 0, false, fmt.Errorf("reading spamd response: %w", err)
+	}
+	if !strings.Contains(status, "EX_OK") {
+		return 0, false, fmt.Errorf("spamd error: %s", strings.TrimSpace(status))
+	}
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, false, fmt.Errorf("reading spamd response: %w", err)
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Spam") {
+			return parseSpamdResult(value)
+		}
+		if err == io.EOF || strings.TrimSpace(line) == "" {
+			break
+		}
+	}
+	return 0, false, fmt.Errorf("spamd response has no Spam header")
+}
+
+// parseSpamdResult parses the value of a spamd Spam header, e.g. "True ; 15.3 / 5.0".
+func parseSpamdResult(value string) (float64, bool, error) {
+	flag, scores, ok := strings.Cut(value, ";")
+	if !ok {
+		return 0, false, fmt.Errorf("invalid spamd result: %q", value)
+	}
+	score, _, _ := strings.Cut(scores, "/")
+	s, err := strconv.ParseFloat(strings.TrimSpace(score), 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid spamd score: %q", value)
+	}
+	spam := strings.EqualFold(strings.TrimSpace(flag), "true") || strings.EqualFold(strings.TrimSpace(flag), "yes")
+	return s, spam, nil
+}
+
+// rspamd is a client for the rspamd HTTP API.
+type rspamd struct {
+	url    string
+	client *http.Client
+}
+
+func newRspamd(address string, timeout time.Duration) *rspamd {
+	if address == "" {
+		address = "http://127.0.0.1:11333"
+	}
+	return &rspamd{
+		url:    strings.TrimRight(address, "/") + "/checkv2",
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Scan posts the message to rspamd. Messages rspamd would reject or tag as spam are spam.
+func (r *rspamd) Scan(ctx context.Context, raw []byte) (float64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(raw))
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("connecting to rspamd: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("rspamd returned status %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Score  float64 `json:"score"`
+		Action string  `json:"action"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, false, fmt.Errorf("decoding rspamd response: %w", err)
+	}
+	switch res.Action {
+	case "reject", "add header", "rewrite subject":
+		return res.Score, true, nil
+	}
+	return res.Score, false, nil
+}
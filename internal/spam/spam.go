@@ -0,0 +1,337 @@
+// Package spam scores incoming messages using sender allow/block lists, authentication and spam headers
+// added by upstream mail servers and an optional SpamAssassin or rspamd scanner.
+package spam
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zerodha/logf"
+)
+
+const (
+	ScannerNone         = ""
+	ScannerSpamAssassin = "spamassassin"
+	ScannerRspamd       = "rspamd"
+
+	defaultThreshold      = 5.0
+	defaultScannerTimeout = 10 * time.Second
+)
+
+// Scanners is the list of supported scanners.
+var Scanners = []string{ScannerNone, ScannerSpamAssassin, ScannerRspamd}
+
+// authScores are the scores added for failed SPF, DKIM and DMARC checks in the Authentication-Results header.
+var authScores = map[string]map[string]float64{
+	"spf":   {"fail": 2, "softfail": 1, "permerror": 1},
+	"dkim":  {"fail": 2, "permerror": 1},
+	"dmarc": {"fail": 3},
+}
+
+// Opts contains the spam filter options.
+type Opts struct {
+	Enabled   bool
+	Threshold float64
+	// Allowlist and Blocklist contain sender addresses or domains.
+	Allowlist []string
+	Blocklist []string
+	// AuthservIDs are the authserv-ids of the Authentication-Results headers added by the receiving mail servers.
+	// Only these headers are trusted to authenticate allowlisted senders, others may be forged by the sender.
+	AuthservIDs    []string
+	Scanner        string
+	ScannerAddress string
+	ScannerTimeout time.Duration
+	Lo             *logf.Logger
+}
+
+// Message is a message to be checked.
+type Message struct {
+	From    string
+	Headers textproto.MIMEHeader
+	Raw     []byte
+}
+
+// Verdict is the result of a spam check.
+type Verdict struct {
+	Spam    bool
+	Score   float64
+	Reasons []string
+}
+
+// scanner scans a raw message and returns its score and whether the scanner considers it spam.
+type scanner interface {
+	Scan(ctx context.Context, raw []byte) (float64, bool, error)
+}
+
+// Filter checks incoming messages for spam.
+type Filter struct {
+	mu        sync.RWMutex
+	enabled   bool
+	threshold float64
+	allowlist map[string]struct{}
+	blocklist map[string]struct{}
+	authserv  map[string]struct{}
+	scanner   scanner
+	lo        *logf.Logger
+}
+
+// authResult is the result of a method in an Authentication-Results header.
+type authResult struct {
+	method string
+	result string
+	// props are the properties of the result, e.g. header.d for DKIM.
+	props map[string]string
+}
+
+// New returns a new spam filter.
+func New(opts Opts) *Filter {
+	f := &Filter{lo: opts.Lo}
+	f.Reload(opts)
+	return f
+}
+
+// Reload replaces the filter options, the logger is kept.
+func (f *Filter) Reload(opts Opts) {
+	var sc scanner
+	timeout := opts.ScannerTimeout
+	if timeout <= 0 {
+		timeout = defaultScannerTimeout
+	}
+	switch opts.Scanner {
+	case ScannerSpamAssassin:
+		sc = newSpamAssassin(opts.ScannerAddress, timeout)
+	case ScannerRspamd:
+		sc = newRspamd(opts.ScannerAddress, timeout)
+	}
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enabled = opts.Enabled
+	f.threshold = threshold
+	f.allowlist = toSet(opts.Allowlist)
+	f.blocklist = toSet(opts.Blocklist)
+	f.authserv = toSet(opts.AuthservIDs)
+	f.scanner = sc
+}
+
+// Check returns the spam verdict for a message. Blocklisted senders are always spam, allowlisted senders are never
+// spam if a trusted Authentication-Results header shows they pass DMARC or DKIM aligned with the From domain, so
+// spoofed allowlisted addresses are scored like any other sender. Scanner errors are logged and the message is
+// scored on its headers alone.
+func (f *Filter) Check(ctx context.Context, msg Message) Verdict {
+	// Copy the options so the lock isn't held while scanning.
+	f.mu.RLock()
+	var (
+		enabled   = f.enabled
+		threshold = f.threshold
+		allowlist = f.allowlist
+		blocklist = f.blocklist
+		authserv  = f.authserv
+		sc        = f.scanner
+	)
+	f.mu.RUnlock()
+
+	var v Verdict
+	if !enabled {
+		return v
+	}
+	if matchSender(blocklist, msg.From) {
+		v.Spam = true
+		v.Reasons = append(v.Reasons, "sender blocklisted")
+		return v
+	}
+
+	if matchSender(allowlist, msg.From) && authenticated(msg.From, authResults(msg.Headers, authserv)) {
+		return v
+	}
+
+	score, flagged, reasons := scoreHeaders(msg.Headers)
+	v.Score += score
+	v.Reasons = append(v.Reasons, reasons...)
+	if flagged {
+		v.Spam = true
+	}
+
+	if sc != nil && len(msg.Raw) > 0 {
+		score, spam, err := sc.Scan(ctx, msg.Raw)
+		if err != nil {
+			f.lo.Error("error scanning message for spam", "error", err)
+		} else {
+			v.Score += score
+			if spam {
+				v.Spam = true
+				v.Reasons = append(v.Reasons, fmt.Sprintf("scanner score %.1f", score))
+			}
+		}
+	}
+
+	if v.Score >= threshold {
+		v.Spam = true
+		v.Reasons = append(v.Reasons, fmt.Sprintf("score %.1f above threshold %.1f", v.Score, threshold))
+	}
+	return v
+}
+
+// scoreHeaders scores the failed checks in the Authentication-Results headers and the score headers of upstream
+// spam filters. flagged is true if an upstream filter marked the message as spam. Failures are scored from all
+// Authentication-Results headers as a forged failure only hurts the sender, the worst result of a method counts.
+func scoreHeaders(h textproto.MIMEHeader) (score float64, flagged bool, reasons []string) {
+	worst := map[string]string{}
+	for _, r := range authResults(h, nil) {
+		if authScores[r.method][r.result] > authScores[r.method][worst[r.method]] {
+			worst[r.method] = r.result
+		}
+	}
+	for method, result := range worst {
+		score += authScores[method][result]
+		reasons = append(reasons, method+"="+result)
+	}
+
+	if strings.EqualFold(strings.TrimSpace(h.Get("X-Spam-Flag")), "yes") ||
+		strings.EqualFold(strings.TrimSpace(h.Get("X-Spam")), "yes") ||
+		hasPrefixFold(strings.TrimSpace(h.Get("X-Spam-Status")), "yes") {
+		flagged = true
+		reasons = append(reasons, "flagged by upstream filter")
+	}
+	for _, key := range []string{"X-Spam-Score", "X-Rspamd-Score"} {
+		if s, err := strconv.ParseFloat(strings.TrimSpace(h.Get(key)), 64); err == nil && s > 0 {
+			score += s
+			reasons = append(reasons, fmt.Sprintf("%s %.1f", strings.ToLower(key), s))
+			break
+		}
+	}
+	return score, flagged, reasons
+}
+
+// authResults returns the results in the Authentication-Results headers added by the servers with the authserv-ids
+// in the set, or in all headers if the set is nil.
+func authResults(h textproto.MIMEHeader, authserv map[string]struct{}) []authResult {
+	var out []authResult
+	for _, ar := range h.Values("Authentication-Results") {
+		id, results := parseAuthResults(ar)
+		if authserv != nil {
+			if _, ok := authserv[id]; !ok {
+				continue
+			}
+		}
+		out = append(out, results...)
+	}
+	return out
+}
+
+// authenticated returns true if no check failed and the sender passed DMARC or has a passing DKIM signature of the
+// domain of the From address. SPF alone is not enough as it checks the envelope sender and not the From address.
+func authenticated(from string, results []authResult) bool {
+	for _, r := range results {
+		if authScores[r.method][r.result] > 0 {
+			return false
+		}
+	}
+	_, fromDomain, _ := strings.Cut(strings.ToLower(strings.TrimSpace(from)), "@")
+	for _, r := range results {
+		if r.result != "pass" {
+			continue
+		}
+		if r.method == "dmarc" {
+			return true
+		}
+		// Relaxed alignment, the signing domain may be a parent domain of the From domain.
+		if d := strings.TrimSuffix(r.props["header.d"], "."); r.method == "dkim" && d != "" && fromDomain != "" &&
+			(fromDomain == d || strings.HasSuffix(fromDomain, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAuthResults returns the authserv-id and the results of an Authentication-Results header, e.g.
+// "mx.example.com; spf=pass smtp.mailfrom=a@b.com; dkim=fail header.d=b.com" returns "mx.example.com" and the
+// results spf=pass and dkim=fail with their properties. Comments in parentheses are dropped.
+func parseAuthResults(header string) (string, []authResult) {
+	parts := strings.Split(stripComments(header), ";")
+	// The authserv-id may be followed by a version.
+	var id string
+	if f := strings.Fields(parts[0]); len(f) > 0 {
+		id = strings.ToLower(f[0])
+	}
+
+	var out []authResult
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		method, result, ok := strings.Cut(fields[0], "=")
+		if !ok {
+			continue
+		}
+		r := authResult{method: strings.ToLower(method), result: strings.ToLower(result), props: map[string]string{}}
+		for _, f := range fields[1:] {
+			if k, v, ok := strings.Cut(f, "="); ok {
+				r.props[strings.ToLower(k)] = strings.ToLower(strings.Trim(v, `"`))
+			}
+		}
+		out = append(out, r)
+	}
+	return id, out
+}
+
+// stripComments removes the comments in parentheses from a header value.
+func stripComments(s string) string {
+	var (
+		b     strings.Builder
+		depth int
+	)
+	for _, c := range s {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// matchSender returns true if the sender address or its domain is in the set.
+func matchSender(set map[string]struct{}, from string) bool {
+	from = strings.ToLower(strings.TrimSpace(from))
+	if from == "" || len(set) == 0 {
+		return false
+	}
+	if _, ok := set[from]; ok {
+		return true
+	}
+	if _, domain, ok := strings.Cut(from, "@"); ok {
+		_, ok := set[domain]
+		return ok
+	}
+	return false
+}
+
+// toSet returns a set of the lowercased entries, a leading "@" on domains is dropped.
+func toSet(entries []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		e = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(e)), "@")
+		if e != "" {
+			set[e] = struct{}{}
+		}
+	}
+	return set
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,125 @@
+package spam
+
+import (
+	"context"
+	"net/textproto"
+	"testing"
+)
+
+func TestParseAuthResults(t *testing.T) {
+	id, got := parseAuthResults("MX.example.com 1; spf=pass smtp.mailfrom=a@example.com; DKIM=Fail (bad; signature) header.d=Example.com; dmarc=fail (p=reject)")
+	if id != "mx.example.com" {
+		t.Errorf("parseAuthResults() authserv-id = %q, want mx.example.com", id)
+	}
+	want := []authResult{
+		{method: "spf", result: "pass", props: map[string]string{"smtp.mailfrom": "a@example.com"}},
+		{method: "dkim", result: "fail", props: map[string]string{"header.d": "example.com"}},
+		{method: "dmarc", result: "fail", props: map[string]string{}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parseAuthResults() = %v, want %v", got, want)
+	}
+	for i, w := range want {
+		if got[i].method != w.method || got[i].result != w.result || len(got[i].props) != len(w.props) {
+			t.Errorf("parseAuthResults()[%d] = %v, want %v", i, got[i], w)
+		}
+		for k, v := range w.props {
+			if got[i].props[k] != v {
+				t.Errorf("parseAuthResults()[%d].props[%q] = %q, want %q", i, k, got[i].props[k], v)
+			}
+		}
+	}
+}
+
+func TestParseSpamdResult(t *testing.T) {
+	tests := []struct {
+		value string
+		score float64
+		spam  bool
+		err   bool
+	}{
+		{value: " True ; 15.3 / 5.0", score: 15.3, spam: true},
+		{value: " False ; 1.2 / 5.0", score: 1.2, spam: false},
+		{value: " True", err: true},
+	}
+	for _, tt := range tests {
+		score, spam, err := parseSpamdResult(tt.value)
+		if (err != nil) != tt.err {
+			t.Fatalf("parseSpamdResult(%q) error = %v, want error %v", tt.value, err, tt.err)
+		}
+		if score != tt.score || spam != tt.spam {
+			t.Errorf("parseSpamdResult(%q) = %v, %v, want %v, %v", tt.value, score, spam, tt.score, tt.spam)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	f := New(Opts{
+		Enabled:     true,
+		Threshold:   5,
+		Allowlist:   []string{"trusted.com"},
+		Blocklist:   []string{"spammer@example.com", "@junk.com"},
+		AuthservIDs: []string{"mx.example.com"},
+	})
+
+	failedAuth := textproto.MIMEHeader{
+		"Authentication-Results": {"mx.example.com; spf=fail; dkim=fail; dmarc=fail"},
+	}
+	passedAuth := textproto.MIMEHeader{
+		"Authentication-Results": {"mx.example.com; spf=pass; dkim=pass; dmarc=pass"},
+		"X-Spam-Flag":            {"YES"},
+	}
+	tests := []struct {
+		name    string
+		from    string
+		headers textproto.MIMEHeader
+		spam    bool
+	}{
+		{name: "clean", from: "user@example.com", headers: textproto.MIMEHeader{
+			"Authentication-Results": {"mx.example.com; spf=pass; dkim=pass; dmarc=pass"},
+		}},
+		{name: "blocklisted address", from: "Spammer@Example.com", spam: true},
+		{name: "blocklisted domain", from: "user@junk.com", spam: true},
+		{name: "failed authentication", from: "user@example.com", headers: failedAuth, spam: true},
+		{name: "allowlisted authenticated sender", from: "user@trusted.com", headers: passedAuth},
+		{name: "allowlisted sender failing authentication", from: "user@trusted.com", headers: failedAuth, spam: true},
+		{name: "allowlisted sender without authentication results", from: "user@trusted.com", headers: textproto.MIMEHeader{"X-Spam-Flag": {"YES"}}, spam: true},
+		{name: "allowlisted sender with forged authentication results", from: "user@trusted.com", headers: textproto.MIMEHeader{
+			"Authentication-Results": {"mx.attacker.com; dmarc=pass"},
+			"X-Spam-Flag":            {"YES"},
+		}, spam: true},
+		{name: "allowlisted sender passing only SPF", from: "user@trusted.com", headers: textproto.MIMEHeader{
+			"Authentication-Results": {"mx.example.com; spf=pass smtp.mailfrom=attacker.com"},
+			"X-Spam-Flag":            {"YES"},
+		}, spam: true},
+		{name: "allowlisted sender with unaligned DKIM", from: "user@trusted.com", headers: textproto.MIMEHeader{
+			"Authentication-Results": {"mx.example.com; dkim=pass header.d=attacker.com"},
+			"X-Spam-Flag":            {"YES"},
+		}, spam: true},
+		{name: "allowlisted sender with aligned DKIM", from: "user@trusted.com", headers: textproto.MIMEHeader{
+			"Authentication-Results": {"mx.example.com; dkim=pass header.d=trusted.com"},
+			"X-Spam-Flag":            {"YES"},
+		}},
+		{name: "forged pass does not hide a failure", from: "user@example.com", headers: textproto.MIMEHeader{
+			"Authentication-Results": {"mx.attacker.com; dmarc=pass", "mx.example.com; dmarc=fail; spf=fail"},
+		}, spam: true},
+		{name: "upstream flag", from: "user@example.com", headers: textproto.MIMEHeader{"X-Spam-Flag": {"YES"}}, spam: true},
+		{name: "upstream score", from: "user@example.com", headers: textproto.MIMEHeader{"X-Spam-Score": {"7.5"}}, spam: true},
+		{name: "softfail below threshold", from: "user@example.com", headers: textproto.MIMEHeader{
+			"Authentication-Results": {"mx.example.com; spf=softfail"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := f.Check(context.Background(), Message{From: tt.from, Headers: tt.headers})
+			if v.Spam != tt.spam {
+				t.Errorf("Check() spam = %v, want %v (score %.1f, reasons %v)", v.Spam, tt.spam, v.Score, v.Reasons)
+			}
+		})
+	}
+
+	f.Reload(Opts{Enabled: false})
+	if v := f.Check(context.Background(), Message{From: "spammer@example.com"}); v.Spam {
+		t.Errorf("Check() on a disabled filter = %v, want not spam", v)
+	}
+}
@@ -20,6 +20,9 @@ const (
 	TmplResetPassword = "reset-password"
 	TmplWelcome       = "welcome"
 
+	// Built-in web templates stored in `static` directory.
+	TmplConversationTranscript = "conversation-transcript"
+
 	// Template names for rendering.
 	TmplBase    = "base"
 	TmplContent = "content"
@@ -133,6 +136,17 @@ func (m *Manager) RenderInMemoryTemplate(name string, data interface{}) (string,
 	return buf.String(), nil
 }
 
+// RenderWebTemplate executes a web template with data and returns the rendered content.
+func (m *Manager) RenderWebTemplate(name string, data any) (string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var buf bytes.Buffer
+	if err := m.webTpls.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("executing web template %q: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
 // RenderWebPage renders a template to the http.ResponseWriter with data.
 func (m *Manager) RenderWebPage(ctx *fasthttp.RequestCtx, tmplFile string, data map[string]interface{}) error {
 	m.mutex.RLock()
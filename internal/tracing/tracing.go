@@ -0,0 +1,139 @@
+// Package tracing sets up OpenTelemetry tracing exported over OTLP, and carries trace context through the
+// queues between the stages of the message pipelines so their spans end up in a single trace.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/abhinavxd/libredesk"
+
+// Opts contains options for initializing tracing.
+type Opts struct {
+	// Endpoint is the host:port of the OTLP/HTTP collector.
+	Endpoint string
+	Insecure bool
+	Headers  map[string]string
+	// SampleRatio is the share of traces that are sampled. Requests continuing the trace of a caller, e.g. with a
+	// traceparent header, are sampled at the same ratio whatever the caller decided.
+	SampleRatio float64
+	ServiceName string
+	Version     string
+}
+
+// Init sets up the global tracer provider exporting spans to the OTLP collector. The returned function flushes
+// pending spans and must be called on shutdown. Trace context is propagated with W3C Trace Context headers.
+func Init(ctx context.Context, opts Opts) (func(context.Context) error, error) {
+	exOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exOpts = append(exOpts, otlptracehttp.WithInsecure())
+	}
+	if len(opts.Headers) > 0 {
+		exOpts = append(exOpts, otlptracehttp.WithHeaders(opts.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, exOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", opts.ServiceName),
+		attribute.String("service.version", opts.Version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(newSampler(opts.SampleRatio)),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp.Shutdown, nil
+}
+
+// newSampler returns a sampler sampling new traces at the ratio and following the decision of local parents. Remote
+// parents come from untrusted callers, e.g. the traceparent header of a public request, so the caller can't force
+// sampling, their spans are sampled at the ratio too.
+func newSampler(ratio float64) sdktrace.Sampler {
+	remote := remoteRatioSampler{ratio: ratio}
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio),
+		sdktrace.WithRemoteParentSampled(remote),
+		sdktrace.WithRemoteParentNotSampled(remote),
+	)
+}
+
+// remoteRatioSampler samples spans with a remote parent at a ratio. The decision is random instead of based on the
+// trace ID like TraceIDRatioBased, as the trace ID is picked by the caller too.
+type remoteRatioSampler struct {
+	ratio float64
+}
+
+func (s remoteRatioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.Drop
+	if rand.Float64() < s.ratio {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{Decision: decision, Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState()}
+}
+
+func (s remoteRatioSampler) Description() string {
+	return fmt.Sprintf("RemoteRatioSampler{%g}", s.ratio)
+}
+
+// Tracer returns the tracer spans are started with. Spans are no-ops when tracing is not initialized.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start starts a span, a shorthand for Tracer().Start.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	SetError(span, err)
+	span.End()
+}
+
+// SetError records err on the span and marks it as failed, if err is not nil.
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Inject returns the trace context of ctx to be carried along with a queued task, nil if there is none.
+func Inject(ctx context.Context) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract returns ctx with the trace context carried along with a queued task, so spans started from it
+// continue the trace of the task's producer.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSamplerIgnoresRemoteSampledFlag(t *testing.T) {
+	// A caller picking a trace ID sampled by any ratio and setting the sampled flag.
+	remote := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	local := remote.WithRemote(false)
+
+	tests := []struct {
+		name   string
+		ratio  float64
+		parent trace.SpanContext
+		want   bool
+	}{
+		{name: "sampled remote parent at ratio 0", ratio: 0, parent: remote, want: false},
+		{name: "not sampled remote parent at ratio 1", ratio: 1, parent: remote.WithTraceFlags(0), want: true},
+		{name: "sampled local parent at ratio 0", ratio: 0, parent: local, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(newSampler(tt.ratio)))
+			ctx := trace.ContextWithSpanContext(context.Background(), tt.parent)
+			_, span := tp.Tracer("test").Start(ctx, "request")
+			if got := span.SpanContext().IsSampled(); got != tt.want {
+				t.Errorf("sampled = %v, want %v", got, tt.want)
+			}
+			if span.SpanContext().TraceID() != tt.parent.TraceID() {
+				t.Error("span does not continue the trace of its parent")
+			}
+		})
+	}
+}
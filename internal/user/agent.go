@@ -91,7 +91,24 @@ func (u *Manager) CreateAgent(firstName, lastName, email string, roles []string)
 		u.lo.Error("error generating password", "error", err)
 		return models.User{}, envelope.NewError(envelope.GeneralError, u.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.user}"), nil)
 	}
+	return u.createAgent(firstName, lastName, email, roles, password)
+}
+
+// CreateAgentWithPassword creates a new agent user that can log in with the password.
+func (u *Manager) CreateAgentWithPassword(firstName, lastName, email string, roles []string, password string) (models.User, error) {
+	if !IsStrongPassword(password) {
+		return models.User{}, envelope.NewError(envelope.InputError, PasswordHint, nil)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		u.lo.Error("error generating bcrypt password", "error", err)
+		return models.User{}, envelope.NewError(envelope.GeneralError, u.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.user}"), nil)
+	}
+	return u.createAgent(firstName, lastName, email, roles, hashedPassword)
+}
 
+// createAgent inserts an agent user with the bcrypt hash of its password.
+func (u *Manager) createAgent(firstName, lastName, email string, roles []string, password []byte) (models.User, error) {
 	var id = 0
 	avatarURL := null.String{}
 	email = strings.TrimSpace(strings.ToLower(email))
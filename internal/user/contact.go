@@ -1,6 +1,8 @@
 package user
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -9,6 +11,13 @@ import (
 	"github.com/volatiletech/null/v9"
 )
 
+const (
+	// erasedContactName replaces the name of erased contacts.
+	erasedContactName = "Erased contact"
+	// redactedContent replaces the content of messages of erased contacts.
+	redactedContent = "[redacted]"
+)
+
 // CreateContact creates a new contact user.
 func (u *Manager) CreateContact(user *models.User) error {
 	password, err := u.generatePassword()
@@ -27,6 +36,35 @@ func (u *Manager) CreateContact(user *models.User) error {
 	return nil
 }
 
+// UpsertContact creates a contact, or updates the existing contact with the same email address, without a contact channel.
+// Emails of contacts merged into another contact update that contact.
+// Empty fields don't overwrite existing values and custom attributes are merged into the existing ones. Returns
+// the contact ID and whether the contact was created.
+func (u *Manager) UpsertContact(user models.User, customAttributes map[string]any) (int, bool, error) {
+	password, err := u.generatePassword()
+	if err != nil {
+		return 0, false, fmt.Errorf("generating password: %w", err)
+	}
+	if customAttributes == nil {
+		customAttributes = map[string]any{}
+	}
+	attributesJSON, err := json.Marshal(customAttributes)
+	if err != nil {
+		return 0, false, fmt.Errorf("marshalling custom attributes: %w", err)
+	}
+
+	var res struct {
+		ID      int  `db:"id"`
+		Created bool `db:"created"`
+	}
+	email := strings.ToLower(user.Email.String)
+	if err := u.q.UpsertContact.Get(&res, email, user.FirstName, user.LastName, password, user.PhoneNumber, user.PhoneNumberCountryCode, attributesJSON); err != nil {
+		u.lo.Error("error upserting contact", "error", err)
+		return 0, false, fmt.Errorf("upsert contact: %w", err)
+	}
+	return res.ID, res.Created, nil
+}
+
 // UpdateContact updates a contact in the database.
 func (u *Manager) UpdateContact(id int, user models.User) error {
 	if _, err := u.q.UpdateContact.Exec(id, user.FirstName, user.LastName, user.Email, user.AvatarURL, user.PhoneNumber, user.PhoneNumberCountryCode); err != nil {
@@ -36,6 +74,15 @@ func (u *Manager) UpdateContact(id int, user models.User) error {
 	return nil
 }
 
+// SetOrganization links a contact to an organization, or unlinks it if organizationID is not valid.
+func (u *Manager) SetOrganization(id int, organizationID null.Int) error {
+	if _, err := u.q.SetOrganization.Exec(id, organizationID); err != nil {
+		u.lo.Error("error setting contact organization", "contact_id", id, "error", err)
+		return envelope.NewError(envelope.GeneralError, u.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.contact}"), nil)
+	}
+	return nil
+}
+
 // GetContact retrieves a contact by ID.
 func (u *Manager) GetContact(id int, email string) (models.User, error) {
 	return u.Get(id, email, models.UserTypeContact)
@@ -54,3 +101,85 @@ func (u *Manager) GetContacts(page, pageSize int, order, orderBy string, filters
 	}
 	return u.GetAllUsers(page, pageSize, models.UserTypeContact, order, orderBy, filtersJSON)
 }
+
+// GetContactDuplicates returns contacts that share an email address, a phone number or a full name and email domain
+// with the given contact.
+func (u *Manager) GetContactDuplicates(id int) ([]models.ContactDuplicate, error) {
+	var duplicates = make([]models.ContactDuplicate, 0)
+	if err := u.q.GetContactDuplicates.Select(&duplicates, id); err != nil {
+		u.lo.Error("error fetching contact duplicates", "contact_id", id, "error", err)
+		return duplicates, envelope.NewError(envelope.GeneralError, u.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.contact}"), nil)
+	}
+	return duplicates, nil
+}
+
+// MergeContacts merges the secondary contact into the primary contact. Conversations, contact channels and notes are
+// moved to the primary contact, missing details of the primary contact are filled in from the secondary contact and
+// the secondary contact is soft deleted. Conflicting custom attributes keep the primary contact's value unless
+// overridden in `attributes`. Future messages from the secondary contact's email address land on the primary contact.
+func (u *Manager) MergeContacts(primaryID, secondaryID int, attributes map[string]any) (models.User, error) {
+	if primaryID == secondaryID {
+		return models.User{}, envelope.NewError(envelope.InputError, u.i18n.T("contact.cannotMergeIntoItself"), nil)
+	}
+	primary, err := u.GetContact(primaryID, "")
+	if err != nil {
+		return primary, err
+	}
+	secondary, err := u.GetContact(secondaryID, "")
+	if err != nil {
+		return primary, err
+	}
+
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	attributesJSON, err := json.Marshal(attributes)
+	if err != nil {
+		return primary, envelope.NewError(envelope.InputError, u.i18n.Ts("globals.messages.invalid", "name", "`custom_attributes`"), nil)
+	}
+
+	tx, err := u.db.BeginTxx(context.Background(), nil)
+	if err != nil {
+		u.lo.Error("error beginning merge contacts transaction", "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, u.i18n.T("contact.errorMerging"), nil)
+	}
+	defer tx.Rollback()
+
+	// Channels go first so every moved conversation finds a channel of the primary contact in its inbox,
+	// the leftover channels of the secondary contact are no longer referenced after that.
+	if _, err := tx.Stmtx(u.q.MoveContactChannels).Exec(secondary.ID, primary.ID); err != nil {
+		u.lo.Error("error moving contact channels", "primary_id", primary.ID, "secondary_id", secondary.ID, "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, u.i18n.T("contact.errorMerging"), nil)
+	}
+	if _, err := tx.Stmtx(u.q.MoveContactConvs).Exec(secondary.ID, primary.ID); err != nil {
+		u.lo.Error("error moving contact conversations", "primary_id", primary.ID, "secondary_id", secondary.ID, "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, u.i18n.T("contact.errorMerging"), nil)
+	}
+	if _, err := tx.Stmtx(u.q.DeleteContactChannels).Exec(secondary.ID); err != nil {
+		u.lo.Error("error deleting contact channels", "contact_id", secondary.ID, "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, u.i18n.T("contact.errorMerging"), nil)
+	}
+	if _, err := tx.Stmtx(u.q.MergeContact).Exec(secondary.ID, primary.ID, attributesJSON); err != nil {
+		u.lo.Error("error merging contacts", "primary_id", primary.ID, "secondary_id", secondary.ID, "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, u.i18n.T("contact.errorMerging"), nil)
+	}
+	if err := tx.Commit(); err != nil {
+		u.lo.Error("error committing merge contacts transaction", "error", err)
+		return primary, envelope.NewError(envelope.GeneralError, u.i18n.T("contact.errorMerging"), nil)
+	}
+
+	u.lo.Info("merged contacts", "primary_id", primary.ID, "secondary_id", secondary.ID)
+	return u.GetContact(primary.ID, "")
+}
+
+// EraseContact anonymizes a contact and the contacts merged into it, redacts the messages of their conversations and
+// deletes their notes and CSAT feedback. Conversations and their metrics are kept so reports stay intact.
+// Media is not deleted here, the caller has to remove it from the media store.
+func (u *Manager) EraseContact(id int) error {
+	if _, err := u.q.EraseContact.Exec(id, redactedContent, erasedContactName); err != nil {
+		u.lo.Error("error erasing contact", "contact_id", id, "error", err)
+		return envelope.NewError(envelope.GeneralError, u.i18n.T("contact.errorErasing"), nil)
+	}
+	u.lo.Info("erased contact", "contact_id", id)
+	return nil
+}
@@ -65,6 +65,7 @@ type User struct {
 	CustomAttributes       json.RawMessage      `db:"custom_attributes" json:"custom_attributes"`
 	Teams                  tmodels.TeamsCompact `db:"teams" json:"teams"`
 	ContactChannelID       int                  `db:"contact_channel_id" json:"contact_channel_id,omitempty"`
+	OrganizationID         null.Int             `db:"organization_id" json:"organization_id"`
 	NewPassword            string               `db:"-" json:"new_password,omitempty"`
 	SendWelcomeEmail       bool                 `db:"-" json:"send_welcome_email,omitempty"`
 	InboxID                int                  `json:"-"`
@@ -77,6 +78,14 @@ type User struct {
 	APISecret        null.String `db:"api_secret" json:"-"`
 }
 
+// ContactDuplicate is a contact that possibly duplicates another contact, with the reasons it matched.
+type ContactDuplicate struct {
+	UserCompact
+	PhoneNumberCountryCode null.String    `db:"phone_number_country_code" json:"phone_number_country_code"`
+	PhoneNumber            null.String    `db:"phone_number" json:"phone_number"`
+	MatchReasons           pq.StringArray `db:"match_reasons" json:"match_reasons"`
+}
+
 type Note struct {
 	ID        int         `db:"id" json:"id"`
 	CreatedAt time.Time   `db:"created_at" json:"created_at"`
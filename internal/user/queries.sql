@@ -44,6 +44,7 @@ SELECT
     u.api_key,
     u.api_key_last_used_at,
     u.api_secret,
+    u.organization_id,
     array_agg(DISTINCT r.name) FILTER (WHERE r.name IS NOT NULL) AS roles,
     COALESCE(
         (SELECT json_agg(json_build_object('id', t.id, 'name', t.name, 'emoji', t.emoji))
@@ -144,24 +145,85 @@ JOIN roles r ON r.name = role_name
 RETURNING user_id;
 
 -- name: insert-contact
-WITH contact AS (
-   INSERT INTO users (email, type, first_name, last_name, "password", avatar_url)
-   VALUES ($1, 'contact', $2, $3, $4, $5)
+WITH merged AS (
+   -- Emails of contacts that were merged into another contact resolve to that contact.
+   SELECT u.merged_into_id AS id
+   FROM users u
+   WHERE u.email = $1 AND u.type = 'contact' AND u.merged_into_id IS NOT NULL
+   ORDER BY u.deleted_at DESC
+   LIMIT 1
+),
+inserted AS (
+   -- Link new contacts to the organization that claims their email domain.
+   INSERT INTO users (email, type, first_name, last_name, "password", avatar_url, organization_id)
+   SELECT $1, 'contact', $2, $3, $4, $5,
+      (SELECT o.id FROM organizations o WHERE split_part($1, '@', 2) = ANY(o.domains) ORDER BY o.id LIMIT 1)
+   WHERE NOT EXISTS (SELECT 1 FROM merged)
    ON CONFLICT (email, type) WHERE deleted_at IS NULL
-   DO UPDATE SET updated_at = now()
+   DO UPDATE SET updated_at = now(), organization_id = COALESCE(users.organization_id, EXCLUDED.organization_id)
    RETURNING id
+),
+contact AS (
+   SELECT id FROM merged
+   UNION ALL
+   SELECT id FROM inserted
 )
 INSERT INTO contact_channels (contact_id, inbox_id, identifier)
-VALUES ((SELECT id FROM contact), $6, $7)
+VALUES ((SELECT id FROM contact LIMIT 1), $6, $7)
 ON CONFLICT (contact_id, inbox_id) DO UPDATE SET updated_at = now()
 RETURNING contact_id, id;
 
+-- name: upsert-contact
+-- Creates a contact or updates the contact with the same email. Empty values don't overwrite existing ones
+-- and custom attributes are merged into the existing custom attributes.
+WITH merged AS (
+   -- Emails of contacts that were merged into another contact resolve to that contact.
+   SELECT u.merged_into_id AS id
+   FROM users u
+   WHERE u.email = $1 AND u.type = 'contact' AND u.merged_into_id IS NOT NULL
+   ORDER BY u.deleted_at DESC
+   LIMIT 1
+),
+updated_merged AS (
+   UPDATE users SET first_name = COALESCE(NULLIF($2, ''), users.first_name),
+      last_name = COALESCE(NULLIF($3, ''), users.last_name),
+      phone_number = COALESCE($5, users.phone_number),
+      phone_number_country_code = COALESCE($6, users.phone_number_country_code),
+      custom_attributes = users.custom_attributes || $7,
+      updated_at = now()
+   WHERE users.id = (SELECT id FROM merged)
+   RETURNING id, false AS created
+),
+upserted AS (
+   INSERT INTO users (email, type, first_name, last_name, "password", phone_number, phone_number_country_code, custom_attributes, organization_id)
+   SELECT $1, 'contact', COALESCE(NULLIF($2, ''), split_part($1, '@', 1)), NULLIF($3, ''), $4, $5, $6, $7,
+      (SELECT o.id FROM organizations o WHERE split_part($1, '@', 2) = ANY(o.domains) ORDER BY o.id LIMIT 1)
+   WHERE NOT EXISTS (SELECT 1 FROM merged)
+   ON CONFLICT (email, type) WHERE deleted_at IS NULL
+   DO UPDATE SET first_name = COALESCE(NULLIF($2, ''), users.first_name),
+      last_name = COALESCE(NULLIF($3, ''), users.last_name),
+      phone_number = COALESCE($5, users.phone_number),
+      phone_number_country_code = COALESCE($6, users.phone_number_country_code),
+      custom_attributes = users.custom_attributes || $7,
+      organization_id = COALESCE(users.organization_id, EXCLUDED.organization_id),
+      updated_at = now()
+   RETURNING id, (xmax = 0) AS created
+)
+SELECT id, created FROM updated_merged
+UNION ALL
+SELECT id, created FROM upserted;
+
 -- name: update-last-login-at
 UPDATE users
 SET last_login_at = now(),
 updated_at = now()
 WHERE id = $1;
 
+-- name: set-organization
+UPDATE users
+SET organization_id = $2, updated_at = NOW()
+WHERE id = $1 AND type = 'contact';
+
 -- name: toggle-enable
 UPDATE users
 SET enabled = $3, updated_at = NOW()
@@ -267,4 +329,201 @@ WHERE id = $1;
 -- name: update-api-key-last-used
 UPDATE users 
 SET api_key_last_used_at = now()
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: get-contact-duplicates
+-- Returns contacts that share an email address (ignoring case and plus-address tags), a phone number
+-- or a full name and email domain with the given contact.
+WITH target AS (
+    SELECT id,
+        split_part(LOWER(email), '@', 2) AS domain,
+        regexp_replace(split_part(LOWER(email), '@', 1), '\+.*$', '') AS local_part,
+        NULLIF(regexp_replace(COALESCE(phone_number, ''), '[^0-9]', '', 'g'), '') AS phone,
+        LOWER(TRIM(first_name)) AS first_name,
+        LOWER(TRIM(COALESCE(last_name, ''))) AS last_name
+    FROM users
+    WHERE id = $1 AND type = 'contact' AND deleted_at IS NULL
+),
+candidates AS (
+    SELECT u.*,
+        split_part(LOWER(u.email), '@', 2) AS domain,
+        regexp_replace(split_part(LOWER(u.email), '@', 1), '\+.*$', '') AS local_part,
+        NULLIF(regexp_replace(COALESCE(u.phone_number, ''), '[^0-9]', '', 'g'), '') AS phone
+    FROM users u
+    WHERE u.type = 'contact' AND u.deleted_at IS NULL AND u.id != $1
+)
+SELECT c.id, c.type, c.created_at, c.updated_at, c.first_name, c.last_name, c.email, c.enabled, c.avatar_url,
+    c.phone_number_country_code, c.phone_number,
+    ARRAY_REMOVE(ARRAY[
+        CASE WHEN t.domain != '' AND c.domain = t.domain AND c.local_part = t.local_part THEN 'email' END,
+        CASE WHEN c.phone = t.phone THEN 'phone_number' END,
+        CASE WHEN t.domain != '' AND c.domain = t.domain AND t.first_name != ''
+            AND LOWER(TRIM(c.first_name)) = t.first_name AND LOWER(TRIM(COALESCE(c.last_name, ''))) = t.last_name THEN 'name_domain' END
+    ], NULL) AS match_reasons
+FROM candidates c, target t
+WHERE (t.domain != '' AND c.domain = t.domain AND c.local_part = t.local_part)
+    OR c.phone = t.phone
+    OR (t.domain != '' AND c.domain = t.domain AND t.first_name != ''
+        AND LOWER(TRIM(c.first_name)) = t.first_name AND LOWER(TRIM(COALESCE(c.last_name, ''))) = t.last_name)
+ORDER BY c.created_at
+LIMIT 50;
+
+-- name: move-contact-channels
+-- Moves channels of the secondary contact to the primary contact for inboxes the primary contact has no channel in.
+UPDATE contact_channels
+SET contact_id = $2, updated_at = NOW()
+WHERE contact_id = $1
+AND inbox_id NOT IN (SELECT inbox_id FROM contact_channels WHERE contact_id = $2);
+
+-- name: move-contact-conversations
+WITH moved_conversations AS (
+    UPDATE conversations c
+    SET contact_id = $2,
+        contact_channel_id = (SELECT cc.id FROM contact_channels cc WHERE cc.contact_id = $2 AND cc.inbox_id = c.inbox_id),
+        updated_at = NOW()
+    WHERE c.contact_id = $1
+    RETURNING c.id
+),
+moved_messages AS (
+    UPDATE conversation_messages
+    SET sender_id = $2
+    WHERE sender_id = $1 AND sender_type = 'contact'
+    RETURNING id
+),
+copied_participants AS (
+    INSERT INTO conversation_participants (conversation_id, user_id)
+    SELECT conversation_id, $2 FROM conversation_participants WHERE user_id = $1
+    ON CONFLICT DO NOTHING
+    RETURNING id
+),
+deleted_participants AS (
+    DELETE FROM conversation_participants WHERE user_id = $1
+    RETURNING id
+),
+moved_notes AS (
+    UPDATE contact_notes
+    SET contact_id = $2, updated_at = NOW()
+    WHERE contact_id = $1
+    RETURNING id
+)
+SELECT COUNT(*) FROM moved_conversations;
+
+-- name: delete-contact-channels
+DELETE FROM contact_channels WHERE contact_id = $1;
+
+-- name: merge-contact
+-- Fills in missing details of the primary contact, merges custom attributes ($3 wins over the primary contact's values,
+-- which win over the secondary contact's values) and soft deletes the secondary contact with a pointer to the primary.
+WITH secondary AS (
+    SELECT * FROM users WHERE id = $1 AND type = 'contact'
+),
+updated_primary AS (
+    UPDATE users p
+    SET last_name = COALESCE(NULLIF(p.last_name, ''), s.last_name),
+        phone_number = COALESCE(p.phone_number, s.phone_number),
+        phone_number_country_code = CASE WHEN p.phone_number IS NULL THEN s.phone_number_country_code ELSE p.phone_number_country_code END,
+        country = COALESCE(p.country, s.country),
+        avatar_url = COALESCE(p.avatar_url, s.avatar_url),
+        organization_id = COALESCE(p.organization_id, s.organization_id),
+        custom_attributes = s.custom_attributes || p.custom_attributes || $3::jsonb,
+        updated_at = NOW()
+    FROM secondary s
+    WHERE p.id = $2 AND p.type = 'contact'
+    RETURNING p.id
+),
+repointed AS (
+    -- Contacts previously merged into the secondary contact now point to the primary contact.
+    UPDATE users
+    SET merged_into_id = $2, updated_at = NOW()
+    WHERE merged_into_id = $1
+    RETURNING id
+)
+UPDATE users
+SET deleted_at = NOW(), merged_into_id = $2, updated_at = NOW()
+WHERE id = $1 AND type = 'contact';
+
+-- name: erase-contact
+-- Anonymizes a contact and the contacts merged into it, redacts the messages of their conversations and removes
+-- raw emails, notes, drafts and CSAT feedback. Conversations, messages, statuses, timestamps and CSAT ratings are kept so
+-- reports are unaffected. Dead-lettered and blocked messages from their addresses are redacted, only their message IDs
+-- are kept so they are not fetched again.
+WITH contacts AS (
+    SELECT id FROM users WHERE (id = $1 OR merged_into_id = $1) AND type = 'contact'
+),
+addresses AS (
+    SELECT LOWER(email) AS address FROM users WHERE id IN (SELECT id FROM contacts) AND email IS NOT NULL
+    UNION
+    SELECT LOWER(identifier) FROM contact_channels WHERE contact_id IN (SELECT id FROM contacts)
+),
+redacted_dead_letters AS (
+    UPDATE dead_letter_messages
+    SET sender = '',
+        subject = '',
+        error = '',
+        payload = NULL,
+        status = 'discarded',
+        updated_at = NOW()
+    WHERE LOWER(sender) IN (SELECT address FROM addresses)
+),
+redacted_blocked_messages AS (
+    UPDATE blocked_messages
+    SET sender = ''
+    WHERE LOWER(sender) IN (SELECT address FROM addresses)
+),
+convs AS (
+    SELECT id FROM conversations WHERE contact_id = $1
+),
+redacted_messages AS (
+    UPDATE conversation_messages
+    SET content = $2,
+        text_content = $2,
+        meta = COALESCE(meta, '{}'::jsonb) - 'from' - 'to' - 'cc' - 'bcc' - 'subject',
+        updated_at = NOW()
+    WHERE type != 'activity'
+      AND (conversation_id IN (SELECT id FROM convs) OR sender_id IN (SELECT id FROM contacts))
+),
+deleted_sources AS (
+    DELETE FROM message_sources
+    WHERE message_id IN (
+        SELECT id FROM conversation_messages
+        WHERE conversation_id IN (SELECT id FROM convs) OR sender_id IN (SELECT id FROM contacts)
+    )
+),
+redacted_conversations AS (
+    UPDATE conversations
+    SET subject = CASE WHEN subject IS NULL THEN NULL ELSE $2 END,
+        last_message = CASE WHEN last_message IS NULL THEN NULL ELSE $2 END,
+        last_interaction = CASE WHEN last_interaction IS NULL THEN NULL ELSE $2 END,
+        updated_at = NOW()
+    WHERE id IN (SELECT id FROM convs)
+),
+deleted_drafts AS (
+    DELETE FROM conversation_drafts WHERE conversation_id IN (SELECT id FROM convs)
+),
+redacted_csat AS (
+    UPDATE csat_responses
+    SET feedback = NULL,
+        updated_at = NOW()
+    WHERE conversation_id IN (SELECT id FROM convs) AND feedback IS NOT NULL
+),
+deleted_notes AS (
+    DELETE FROM contact_notes WHERE contact_id IN (SELECT id FROM contacts)
+),
+anonymized_channels AS (
+    UPDATE contact_channels
+    SET identifier = 'erased-' || contact_id,
+        updated_at = NOW()
+    WHERE contact_id IN (SELECT id FROM contacts)
+)
+UPDATE users
+SET first_name = $3,
+    last_name = NULL,
+    email = NULL,
+    phone_number = NULL,
+    phone_number_country_code = NULL,
+    country = NULL,
+    avatar_url = NULL,
+    custom_attributes = '{}'::jsonb,
+    organization_id = NULL,
+    updated_at = NOW()
+WHERE id IN (SELECT id FROM contacts);
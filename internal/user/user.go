@@ -80,8 +80,16 @@ type queries struct {
 	DeleteNote             *sqlx.Stmt `query:"delete-note"`
 	InsertAgent            *sqlx.Stmt `query:"insert-agent"`
 	InsertContact          *sqlx.Stmt `query:"insert-contact"`
+	UpsertContact          *sqlx.Stmt `query:"upsert-contact"`
 	InsertNote             *sqlx.Stmt `query:"insert-note"`
 	ToggleEnable           *sqlx.Stmt `query:"toggle-enable"`
+	SetOrganization        *sqlx.Stmt `query:"set-organization"`
+	GetContactDuplicates   *sqlx.Stmt `query:"get-contact-duplicates"`
+	MoveContactChannels    *sqlx.Stmt `query:"move-contact-channels"`
+	MoveContactConvs       *sqlx.Stmt `query:"move-contact-conversations"`
+	DeleteContactChannels  *sqlx.Stmt `query:"delete-contact-channels"`
+	MergeContact           *sqlx.Stmt `query:"merge-contact"`
+	EraseContact           *sqlx.Stmt `query:"erase-contact"`
 	// API key queries
 	GetUserByAPIKey      *sqlx.Stmt `query:"get-user-by-api-key"`
 	SetAPIKey            *sqlx.Stmt `query:"set-api-key"`
@@ -1,13 +1,14 @@
 package webhook
 
 import (
-	"github.com/abhinavxd/libredesk/internal/crypto"
+	"fmt"
+
 	"github.com/abhinavxd/libredesk/internal/webhook/models"
 )
 
 // encryptSecret encrypts webhook secret if present.
 func (m *Manager) encryptSecret(secret string) (string, error) {
-	encrypted, err := crypto.Encrypt(secret, m.encryptionKey)
+	encrypted, err := m.keyring.Encrypt(secret)
 	if err != nil {
 		m.lo.Error("error encrypting webhook secret", "error", err)
 		return "", err
@@ -17,7 +18,7 @@ func (m *Manager) encryptSecret(secret string) (string, error) {
 
 // decryptWebhook decrypts webhook secret in-place.
 func (m *Manager) decryptWebhook(webhook *models.Webhook) error {
-	decrypted, err := crypto.Decrypt(webhook.Secret, m.encryptionKey)
+	decrypted, err := m.keyring.Decrypt(webhook.Secret)
 	if err != nil {
 		m.lo.Error("error decrypting webhook secret", "webhook_id", webhook.ID, "error", err)
 		return err
@@ -36,3 +37,30 @@ func (m *Manager) decryptWebhooks(webhooks []models.Webhook) {
 		}
 	}
 }
+
+// ReEncrypt re-encrypts the secrets of all webhooks that are not encrypted with the primary key and returns the number
+// of webhooks updated.
+func (m *Manager) ReEncrypt() (int, error) {
+	var webhooks []models.Webhook
+	if err := m.q.GetAllWebhooks.Select(&webhooks); err != nil {
+		m.lo.Error("error fetching webhooks", "error", err)
+		return 0, fmt.Errorf("fetching webhooks: %w", err)
+	}
+
+	var n int
+	for _, webhook := range webhooks {
+		secret, changed, err := m.keyring.ReEncrypt(webhook.Secret)
+		if err != nil {
+			return n, fmt.Errorf("re-encrypting secret of webhook %d: %w", webhook.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		if _, err := m.q.UpdateSecret.Exec(webhook.ID, secret); err != nil {
+			m.lo.Error("error updating webhook secret", "webhook_id", webhook.ID, "error", err)
+			return n, fmt.Errorf("updating secret of webhook %d: %w", webhook.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
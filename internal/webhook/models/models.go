@@ -32,6 +32,7 @@ const (
 	// Message events
 	EventMessageCreated WebhookEvent = "message.created"
 	EventMessageUpdated WebhookEvent = "message.updated"
+	EventMessageDeleted WebhookEvent = "message.deleted"
 
 	// Test event
 	EventWebhookTest WebhookEvent = "webhook.test"
@@ -103,3 +103,6 @@ SET
 WHERE
     id = $1
 RETURNING *;
+
+-- name: update-webhook-secret
+UPDATE webhooks SET secret = $2 WHERE id = $1;
@@ -17,15 +17,20 @@ import (
 	"sync"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/abhinavxd/libredesk/internal/crypto"
 	"github.com/abhinavxd/libredesk/internal/dbutil"
 	"github.com/abhinavxd/libredesk/internal/envelope"
+	"github.com/abhinavxd/libredesk/internal/tracing"
 	"github.com/abhinavxd/libredesk/internal/version"
 	"github.com/abhinavxd/libredesk/internal/webhook/models"
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/go-i18n"
 	"github.com/lib/pq"
 	"github.com/zerodha/logf"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 var (
@@ -45,24 +50,26 @@ type Manager struct {
 	closed        bool
 	closedMu      sync.RWMutex
 	wg            sync.WaitGroup
-	encryptionKey string
+	keyring       *crypto.Keyring
 }
 
 // Opts contains options for initializing the Manager.
 type Opts struct {
-	DB            *sqlx.DB
-	Lo            *logf.Logger
-	I18n          *i18n.I18n
-	Workers       int
-	QueueSize     int
-	Timeout       time.Duration
-	EncryptionKey string
+	DB        *sqlx.DB
+	Lo        *logf.Logger
+	I18n      *i18n.I18n
+	Workers   int
+	QueueSize int
+	Timeout   time.Duration
+	Keyring   *crypto.Keyring
 }
 
 // DeliveryTask represents a webhook delivery task
 type DeliveryTask struct {
 	Event   models.WebhookEvent
 	Payload any
+	// TraceContext carries the trace of the trigger to the delivery.
+	TraceContext map[string]string
 }
 
 // queries contains prepared SQL queries.
@@ -76,6 +83,7 @@ type queries struct {
 	UpdateWebhook      *sqlx.Stmt `query:"update-webhook"`
 	DeleteWebhook      *sqlx.Stmt `query:"delete-webhook"`
 	ToggleWebhook      *sqlx.Stmt `query:"toggle-webhook"`
+	UpdateSecret       *sqlx.Stmt `query:"update-webhook-secret"`
 }
 
 // New creates and returns a new instance of the Manager.
@@ -103,8 +111,8 @@ func New(opts Opts) (*Manager, error) {
 				ResponseHeaderTimeout: 3 * time.Second,
 			},
 		},
-		workers:       opts.Workers,
-		encryptionKey: opts.EncryptionKey,
+		workers: opts.Workers,
+		keyring: opts.Keyring,
 	}, nil
 }
 
@@ -228,7 +236,7 @@ func (m *Manager) SendTestWebhook(id int) error {
 		return envelope.NewError(envelope.NotFoundError, m.i18n.Ts("globals.messages.notFound", "name", "webhook"), nil)
 	}
 
-	m.deliverSingleWebhook(webhook, DeliveryTask{
+	m.deliverSingleWebhook(context.Background(), webhook, DeliveryTask{
 		Event: models.EventWebhookTest,
 		Payload: map[string]any{
 			"id":   webhook.ID,
@@ -241,6 +249,11 @@ func (m *Manager) SendTestWebhook(id int) error {
 
 // TriggerEvent triggers webhooks for a specific event with the provided data.
 func (m *Manager) TriggerEvent(event models.WebhookEvent, data any) {
+	m.TriggerEventContext(context.Background(), event, data)
+}
+
+// TriggerEventContext is TriggerEvent continuing the trace of ctx in the deliveries.
+func (m *Manager) TriggerEventContext(ctx context.Context, event models.WebhookEvent, data any) {
 	m.closedMu.RLock()
 	defer m.closedMu.RUnlock()
 	if m.closed {
@@ -249,8 +262,9 @@ func (m *Manager) TriggerEvent(event models.WebhookEvent, data any) {
 
 	select {
 	case m.deliveryQueue <- DeliveryTask{
-		Event:   event,
-		Payload: data,
+		Event:        event,
+		Payload:      data,
+		TraceContext: tracing.Inject(ctx),
 	}:
 	default:
 		m.lo.Warn("webhook delivery queue is full, dropping webhook delivery", "event", event, "queue_size", len(m.deliveryQueue))
@@ -297,19 +311,29 @@ func (m *Manager) worker(ctx context.Context) {
 
 // deliverWebhook delivers webhooks for an event by making HTTP requests.
 func (m *Manager) deliverWebhook(task DeliveryTask) {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), task.TraceContext), "webhook.deliver",
+		attribute.String("webhook.event", string(task.Event)))
+	defer span.End()
+
 	webhooks, err := m.getWebhooksByEvent(string(task.Event))
 	if err != nil {
 		m.lo.Error("error fetching webhooks for event", "event", task.Event, "error", err)
+		tracing.SetError(span, err)
 		return
 	}
 
 	for _, webhook := range webhooks {
-		m.deliverSingleWebhook(webhook, task)
+		m.deliverSingleWebhook(ctx, webhook, task)
 	}
 }
 
-// deliverSingleWebhook delivers a webhook to a single endpoint.
-func (m *Manager) deliverSingleWebhook(webhook models.Webhook, task DeliveryTask) {
+// deliverSingleWebhook delivers a webhook to a single endpoint. The trace context of ctx is sent in the
+// `traceparent` header so receivers can continue the trace.
+func (m *Manager) deliverSingleWebhook(ctx context.Context, webhook models.Webhook, task DeliveryTask) {
+	ctx, span := tracing.Start(ctx, "webhook.deliver_single",
+		attribute.Int("webhook.id", webhook.ID), attribute.String("webhook.event", string(task.Event)))
+	defer span.End()
+
 	basePayload := map[string]any{
 		"event":     task.Event,
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
@@ -323,7 +347,7 @@ func (m *Manager) deliverSingleWebhook(webhook models.Webhook, task DeliveryTask
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(payloadBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, bytes.NewReader(payloadBytes))
 	if err != nil {
 		m.lo.Error("error creating webhook request", "webhook_id", webhook.ID, "url", webhook.URL, "event", task.Event, "error", err)
 		return
@@ -332,6 +356,7 @@ func (m *Manager) deliverSingleWebhook(webhook models.Webhook, task DeliveryTask
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Libredesk-Webhook/"+version.Version)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	// Add signature if secret is provided
 	if webhook.Secret != "" {
@@ -355,6 +380,8 @@ func (m *Manager) deliverSingleWebhook(webhook models.Webhook, task DeliveryTask
 			"url", webhook.URL,
 			"event", task.Event,
 			"error", err)
+		metrics.GetOrCreateCounter(`libredesk_webhook_deliveries_total{status="error"}`).Inc()
+		tracing.SetError(span, err)
 		return
 	}
 	defer resp.Body.Close()
@@ -368,14 +395,18 @@ func (m *Manager) deliverSingleWebhook(webhook models.Webhook, task DeliveryTask
 
 	// Check if delivery was successful (2xx status codes)
 	success := resp.StatusCode >= 200 && resp.StatusCode < 300
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	if success {
+		metrics.GetOrCreateCounter(`libredesk_webhook_deliveries_total{status="success"}`).Inc()
 		m.lo.Info("webhook delivered successfully",
 			"webhook_id", webhook.ID,
 			"event", task.Event,
 			"url", webhook.URL,
 			"status_code", resp.StatusCode)
 	} else {
+		metrics.GetOrCreateCounter(`libredesk_webhook_deliveries_total{status="failed"}`).Inc()
+		tracing.SetError(span, fmt.Errorf("webhook responded with status %d", resp.StatusCode))
 		m.lo.Error("webhook delivery failed",
 			"webhook_id", webhook.ID,
 			"event", task.Event,
@@ -0,0 +1,221 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhinavxd/libredesk/internal/ws/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// broadcastChannel is the Redis channel broadcasts are published to, every replica delivers them to its local clients.
+	broadcastChannel = "libredesk:ws:broadcast"
+
+	// presenceKey is a Redis sorted set of `userID:nodeID` members scored by the time they were last seen connected.
+	presenceKey = "libredesk:ws:presence"
+
+	// presenceInterval is how often a node refreshes the presence of its connected users, members not refreshed
+	// within presenceTTL, e.g. of a crashed node, are considered offline.
+	presenceInterval = 15 * time.Second
+	presenceTTL      = 3 * presenceInterval
+
+	redisTimeout = 5 * time.Second
+
+	// minResubscribeDelay and maxResubscribeDelay bound the exponential backoff between attempts to resubscribe
+	// to broadcasts after the subscription failed or was lost.
+	minResubscribeDelay = time.Second
+	maxResubscribeDelay = 30 * time.Second
+)
+
+// Run subscribes to broadcasts published by all replicas and delivers them to the local clients, and keeps the
+// presence of the local clients fresh. If the subscription fails or is lost, broadcasts are delivered to the local
+// clients only until resubscribing succeeds. It blocks until the context is cancelled.
+func (h *Hub) Run(ctx context.Context) {
+	if h.redis == nil {
+		return
+	}
+	defer h.clearPresence()
+
+	delay := minResubscribeDelay
+	for {
+		subscribed, err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			delay = minResubscribeDelay
+		}
+		h.lo.Error("websocket broadcast subscription lost, delivering to local clients only", "error", err, "retry_in", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxResubscribeDelay)
+	}
+}
+
+// listen subscribes to broadcasts and delivers them to the local clients until the context is cancelled or the
+// subscription fails. It returns whether subscribing succeeded and the error that ended the subscription.
+func (h *Hub) listen(ctx context.Context) (bool, error) {
+	sub := h.redis.Subscribe(ctx, broadcastChannel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return false, fmt.Errorf("subscribing: %w", err)
+	}
+	h.subscribed.Store(true)
+	defer h.subscribed.Store(false)
+
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+	h.refreshPresence()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case <-ticker.C:
+			h.refreshPresence()
+		case m, ok := <-ch:
+			if !ok {
+				return true, errors.New("subscription channel closed")
+			}
+			var msg models.BroadcastMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				h.lo.Error("error decoding websocket broadcast", "error", err)
+				continue
+			}
+			h.broadcastLocal(msg)
+		}
+	}
+}
+
+// OnlineUsers returns the IDs of the users connected to any replica.
+func (h *Hub) OnlineUsers(ctx context.Context) ([]int, error) {
+	if h.redis == nil {
+		h.clientsMutex.Lock()
+		defer h.clientsMutex.Unlock()
+		ids := make([]int, 0, len(h.clients))
+		for id := range h.clients {
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	members, err := h.redis.ZRangeByScore(ctx, presenceKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().Add(-presenceTTL).Unix(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("fetching presence: %w", err)
+	}
+
+	var (
+		ids  = make([]int, 0, len(members))
+		seen = make(map[int]struct{}, len(members))
+	)
+	for _, m := range members {
+		userID, _, _ := strings.Cut(m, ":")
+		id, err := strconv.Atoi(userID)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// publish publishes a broadcast to the hubs of all replicas.
+func (h *Hub) publish(msg models.BroadcastMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return h.redis.Publish(ctx, broadcastChannel, b).Err()
+}
+
+// setPresence marks a user as connected to this node.
+func (h *Hub) setPresence(userID int) {
+	if h.redis == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := h.redis.ZAdd(ctx, presenceKey, redis.Z{Score: float64(time.Now().Unix()), Member: h.presenceMember(userID)}).Err(); err != nil {
+		h.lo.Error("error setting websocket presence", "user_id", userID, "error", err)
+	}
+}
+
+// removePresence marks a user as no longer connected to this node.
+func (h *Hub) removePresence(userID int) {
+	if h.redis == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := h.redis.ZRem(ctx, presenceKey, h.presenceMember(userID)).Err(); err != nil {
+		h.lo.Error("error removing websocket presence", "user_id", userID, "error", err)
+	}
+}
+
+// refreshPresence refreshes the presence of the users connected to this node and removes stale members.
+func (h *Hub) refreshPresence() {
+	h.clientsMutex.Lock()
+	var (
+		now     = float64(time.Now().Unix())
+		members = make([]redis.Z, 0, len(h.clients))
+	)
+	for id := range h.clients {
+		members = append(members, redis.Z{Score: now, Member: h.presenceMember(id)})
+	}
+	h.clientsMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	pipe := h.redis.Pipeline()
+	if len(members) > 0 {
+		pipe.ZAdd(ctx, presenceKey, members...)
+	}
+	pipe.ZRemRangeByScore(ctx, presenceKey, "-inf", strconv.FormatInt(time.Now().Add(-presenceTTL).Unix(), 10))
+	if _, err := pipe.Exec(ctx); err != nil {
+		h.lo.Error("error refreshing websocket presence", "error", err)
+	}
+}
+
+// clearPresence removes the presence of all users connected to this node, used on shutdown.
+func (h *Hub) clearPresence() {
+	h.clientsMutex.Lock()
+	members := make([]any, 0, len(h.clients))
+	for id := range h.clients {
+		members = append(members, h.presenceMember(id))
+	}
+	h.clientsMutex.Unlock()
+	if len(members) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := h.redis.ZRem(ctx, presenceKey, members...).Err(); err != nil {
+		h.lo.Error("error clearing websocket presence", "error", err)
+	}
+}
+
+// presenceMember returns the presence set member of a user connected to this node.
+func (h *Hub) presenceMember(userID int) string {
+	return strconv.Itoa(userID) + ":" + h.nodeID
+}
@@ -5,6 +5,7 @@ const (
 	MessageTypeMessagePropUpdate          = "message_prop_update"
 	MessageTypeConversationPropertyUpdate = "conversation_prop_update"
 	MessageTypeNewMessage                 = "new_message"
+	MessageTypeMessageDeleted             = "message_deleted"
 	MessageTypeNewConversation            = "new_conversation"
 	MessageTypeNewNotification            = "new_notification"
 	MessageTypeError                      = "error"
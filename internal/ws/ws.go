@@ -3,9 +3,13 @@ package ws
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/abhinavxd/libredesk/internal/ws/models"
 	"github.com/fasthttp/websocket"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/zerodha/logf"
 )
 
 // Hub maintains the set of registered websockets clients.
@@ -15,32 +19,62 @@ type Hub struct {
 	clientsMutex sync.Mutex
 
 	userStore userStore
+
+	// redis is used to fan out broadcasts to the hubs of all replicas and to track presence across them.
+	// Broadcasts are delivered to local clients only when it is nil.
+	redis  *redis.Client
+	nodeID string
+	lo     *logf.Logger
+
+	// subscribed is set once the hub is subscribed to the broadcast channel, until then broadcasts are
+	// delivered to local clients directly.
+	subscribed atomic.Bool
 }
 
 type userStore interface {
 	UpdateLastActive(userID int) error
 }
 
+// Opts contains options for initializing the Hub.
+type Opts struct {
+	Redis *redis.Client
+	Lo    *logf.Logger
+}
+
 // NewHub creates a new websocket hub.
-func NewHub(userStore userStore) *Hub {
+func NewHub(userStore userStore, opts Opts) *Hub {
 	return &Hub{
 		clients:      make(map[int][]*Client, 10000),
 		clientsMutex: sync.Mutex{},
 		userStore:    userStore,
+		redis:        opts.Redis,
+		nodeID:       uuid.NewString(),
+		lo:           opts.Lo,
 	}
 }
 
 // AddClient adds a new client to the hub.
 func (h *Hub) AddClient(client *Client) {
 	h.clientsMutex.Lock()
-	defer h.clientsMutex.Unlock()
 	h.clients[client.ID] = append(h.clients[client.ID], client)
+	h.clientsMutex.Unlock()
+	h.setPresence(client.ID)
+}
+
+// ConnectionCount returns the number of websocket connections to this node.
+func (h *Hub) ConnectionCount() int {
+	h.clientsMutex.Lock()
+	defer h.clientsMutex.Unlock()
+	var n int
+	for _, clients := range h.clients {
+		n += len(clients)
+	}
+	return n
 }
 
 // RemoveClient removes a client from the hub.
 func (h *Hub) RemoveClient(client *Client) {
 	h.clientsMutex.Lock()
-	defer h.clientsMutex.Unlock()
 	if clients, ok := h.clients[client.ID]; ok {
 		for i, c := range clients {
 			if c == client {
@@ -48,12 +82,34 @@ func (h *Hub) RemoveClient(client *Client) {
 				break
 			}
 		}
+		if len(h.clients[client.ID]) == 0 {
+			delete(h.clients, client.ID)
+		}
+	}
+	_, connected := h.clients[client.ID]
+	h.clientsMutex.Unlock()
+
+	// The user is offline on this node once its last client is gone.
+	if !connected {
+		h.removePresence(client.ID)
 	}
 }
 
-// BroadcastMessage broadcasts a message to the specified users.
+// BroadcastMessage broadcasts a message to the specified users on all replicas.
 // If no users are specified, the message is broadcast to all users.
 func (h *Hub) BroadcastMessage(msg models.BroadcastMessage) {
+	if h.redis == nil || !h.subscribed.Load() {
+		h.broadcastLocal(msg)
+		return
+	}
+	if err := h.publish(msg); err != nil {
+		h.lo.Error("error publishing websocket broadcast, delivering to local clients only", "error", err)
+		h.broadcastLocal(msg)
+	}
+}
+
+// broadcastLocal broadcasts a message to the specified users connected to this hub.
+func (h *Hub) broadcastLocal(msg models.BroadcastMessage) {
 	h.clientsMutex.Lock()
 	defer h.clientsMutex.Unlock()
 
@@ -19,9 +19,14 @@ DROP TYPE IF EXISTS "applied_sla_status" CASCADE; CREATE TYPE "applied_sla_statu
 DROP TYPE IF EXISTS "sla_event_status" CASCADE; CREATE TYPE "sla_event_status" AS ENUM ('pending', 'breached', 'met');
 DROP TYPE IF EXISTS "sla_metric" CASCADE; CREATE TYPE "sla_metric" AS ENUM ('first_response', 'resolution', 'next_response');
 DROP TYPE IF EXISTS "sla_notification_type" CASCADE; CREATE TYPE "sla_notification_type" AS ENUM ('warning', 'breach');
-DROP TYPE IF EXISTS "activity_log_type" CASCADE; CREATE TYPE "activity_log_type" AS ENUM ('agent_login', 'agent_logout', 'agent_away', 'agent_away_reassigned', 'agent_online', 'agent_password_set', 'agent_role_permissions_changed');
+DROP TYPE IF EXISTS "activity_log_type" CASCADE; CREATE TYPE "activity_log_type" AS ENUM ('agent_login', 'agent_logout', 'agent_away', 'agent_away_reassigned', 'agent_online', 'agent_password_set', 'agent_role_permissions_changed', 'contact_data_exported', 'contact_data_erased');
+DROP TYPE IF EXISTS "retention_action" CASCADE; CREATE TYPE "retention_action" AS ENUM ('delete_conversations', 'anonymize_conversations', 'purge_private_notes', 'purge_attachments', 'redact_message_records');
+DROP TYPE IF EXISTS "block_rule_type" CASCADE; CREATE TYPE "block_rule_type" AS ENUM ('domain', 'wildcard', 'regex');
+DROP TYPE IF EXISTS "block_rule_action" CASCADE; CREATE TYPE "block_rule_action" AS ENUM ('drop', 'close');
+DROP TYPE IF EXISTS "dead_letter_status" CASCADE; CREATE TYPE "dead_letter_status" AS ENUM ('pending', 'discarded');
+DROP TYPE IF EXISTS "export_job_status" CASCADE; CREATE TYPE "export_job_status" AS ENUM ('running', 'completed', 'failed');
 DROP TYPE IF EXISTS "macro_visible_when" CASCADE; CREATE TYPE "macro_visible_when" AS ENUM ('replying', 'starting_conversation', 'adding_private_note');
-DROP TYPE IF EXISTS "user_notification_type" CASCADE; CREATE TYPE "user_notification_type" AS ENUM ('mention', 'assignment', 'sla_warning', 'sla_breach');
+DROP TYPE IF EXISTS "user_notification_type" CASCADE; CREATE TYPE "user_notification_type" AS ENUM ('mention', 'assignment', 'sla_warning', 'sla_breach', 'dead_letter');
 DROP TYPE IF EXISTS "webhook_event" CASCADE; CREATE TYPE webhook_event AS ENUM (
 	'conversation.created',
 	'conversation.status_changed',
@@ -29,7 +34,8 @@ DROP TYPE IF EXISTS "webhook_event" CASCADE; CREATE TYPE webhook_event AS ENUM (
 	'conversation.assigned',
 	'conversation.unassigned',
 	'message.created',
-	'message.updated'
+	'message.updated',
+	'message.deleted'
 );
 
 -- Sequence to generate reference number for conversations.
@@ -120,6 +126,19 @@ CREATE TABLE roles (
 	CONSTRAINT constraint_roles_on_description CHECK (length(description) <= 300)
 );
 
+DROP TABLE IF EXISTS organizations CASCADE;
+CREATE TABLE organizations (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	updated_at TIMESTAMPTZ DEFAULT NOW(),
+	"name" TEXT NOT NULL,
+	-- Contacts with an email address on one of these domains are linked to the organization.
+	domains TEXT[] DEFAULT '{}'::TEXT[] NOT NULL,
+	custom_attributes JSONB DEFAULT '{}'::jsonb NOT NULL,
+	CONSTRAINT constraint_organizations_on_name CHECK (length("name") <= 140)
+);
+CREATE INDEX index_organizations_on_domains ON organizations USING GIN (domains);
+
 DROP TABLE IF EXISTS users CASCADE;
 CREATE TABLE users (
     id BIGSERIAL PRIMARY KEY,
@@ -146,6 +165,9 @@ CREATE TABLE users (
 	api_key TEXT NULL,
 	api_secret TEXT NULL,
 	api_key_last_used_at TIMESTAMPTZ NULL,
+	-- Contacts merged into another contact are soft deleted and point to the contact they were merged into.
+	merged_into_id BIGINT REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+	organization_id INT REFERENCES organizations(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
     CONSTRAINT constraint_users_on_country CHECK (LENGTH(country) <= 140),
     CONSTRAINT constraint_users_on_phone_number CHECK (LENGTH(phone_number) <= 20),
 	CONSTRAINT constraint_users_on_phone_number_country_code CHECK (LENGTH(phone_number_country_code) <= 10),
@@ -157,6 +179,8 @@ CREATE UNIQUE INDEX index_unique_users_on_email_and_type_when_deleted_at_is_null
 WHERE deleted_at IS NULL;
 CREATE INDEX index_tgrm_users_on_email ON users USING GIN (email gin_trgm_ops);
 CREATE INDEX index_users_on_api_key ON users(api_key);
+CREATE INDEX index_users_on_merged_into_id ON users(merged_into_id);
+CREATE INDEX index_users_on_organization_id ON users(organization_id);
 
 DROP TABLE IF EXISTS user_roles CASCADE;
 CREATE TABLE user_roles (
@@ -245,7 +269,13 @@ CREATE TABLE conversations (
 	last_interaction_sender message_sender_type NULL,
 	last_interaction_at TIMESTAMPTZ NULL,
 	next_sla_deadline_at TIMESTAMPTZ NULL,
-	snoozed_until TIMESTAMPTZ NULL
+	snoozed_until TIMESTAMPTZ NULL,
+
+	-- Set when this conversation is merged into another one, set to NULL if the primary conversation is deleted.
+	merged_into_id BIGINT REFERENCES conversations(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+
+	-- Set when the messages of this conversation are redacted by a retention policy.
+	anonymized_at TIMESTAMPTZ NULL
 );
 CREATE INDEX index_conversations_on_assigned_user_id ON conversations (assigned_user_id);
 CREATE INDEX index_conversations_on_assigned_team_id ON conversations (assigned_team_id);
@@ -259,6 +289,7 @@ CREATE INDEX index_conversations_on_last_message_at ON conversations (last_messa
 CREATE INDEX index_conversations_on_last_interaction_at ON conversations (last_interaction_at);
 CREATE INDEX index_conversations_on_next_sla_deadline_at ON conversations (next_sla_deadline_at);
 CREATE INDEX index_conversations_on_waiting_since ON conversations (waiting_since);
+CREATE INDEX index_conversations_on_merged_into_id ON conversations (merged_into_id);
 
 DROP TABLE IF EXISTS conversation_messages CASCADE;
 CREATE TABLE conversation_messages (
@@ -276,14 +307,30 @@ CREATE TABLE conversation_messages (
     source_id TEXT NULL,
  	sender_id BIGINT REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
     sender_type message_sender_type NOT NULL,
-    meta JSONB DEFAULT '{}'::JSONB NULL
+    meta JSONB DEFAULT '{}'::JSONB NULL,
+    -- Pending outgoing messages are not picked up for sending before this time, used for scheduled and undo-send replies.
+    send_at TIMESTAMPTZ NULL,
+    -- Pending outgoing messages are claimed by a single instance for sending until the lease expires, the instance
+    -- keeps extending it while the message is in flight so claims of crashed instances are picked up by others.
+    claimed_by TEXT NULL,
+    claim_expires_at TIMESTAMPTZ NULL
 );
 CREATE INDEX index_trgm_conversation_messages_on_text_content ON conversation_messages USING GIN (text_content gin_trgm_ops);
 CREATE INDEX index_conversation_messages_on_conversation_id ON conversation_messages (conversation_id);
 CREATE INDEX index_conversation_messages_on_created_at ON conversation_messages (created_at);
 CREATE INDEX index_conversation_messages_on_source_id ON conversation_messages (source_id);
+-- An incoming message is stored once, even if it is processed concurrently, e.g. after its queue visibility expired.
+CREATE UNIQUE INDEX index_uniq_conversation_messages_on_source_id_incoming ON conversation_messages (source_id) WHERE "type" = 'incoming';
 CREATE INDEX index_conversation_messages_on_status ON conversation_messages (status);
 
+DROP TABLE IF EXISTS message_sources CASCADE;
+CREATE TABLE message_sources (
+    -- The original raw email (RFC 5322) of a message, kept to download it and to reprocess the message.
+    message_id BIGINT PRIMARY KEY REFERENCES conversation_messages(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    raw BYTEA NOT NULL
+);
+
 DROP TABLE IF EXISTS automation_rules CASCADE;
 CREATE TABLE automation_rules (
     id SERIAL PRIMARY KEY,
@@ -621,6 +668,17 @@ CREATE TABLE contact_notes (
 );
 CREATE INDEX index_contact_notes_on_contact_id_created_at ON contact_notes (contact_id, created_at);
 
+DROP TABLE IF EXISTS organization_notes CASCADE;
+CREATE TABLE organization_notes (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	updated_at TIMESTAMPTZ DEFAULT NOW(),
+	organization_id INT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+	note TEXT NOT NULL,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX index_organization_notes_on_organization_id_created_at ON organization_notes (organization_id, created_at);
+
 DROP TABLE IF EXISTS activity_logs CASCADE;
 CREATE TABLE activity_logs (
 	id BIGSERIAL PRIMARY KEY,
@@ -675,6 +733,128 @@ CREATE INDEX index_user_notifications_on_user_id_is_read ON user_notifications(u
 CREATE INDEX index_user_notifications_on_created_at ON user_notifications(created_at);
 CREATE INDEX index_user_notifications_on_conversation_id ON user_notifications(conversation_id);
 
+DROP TABLE IF EXISTS retention_policies CASCADE;
+CREATE TABLE retention_policies (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	updated_at TIMESTAMPTZ DEFAULT NOW(),
+	"name" TEXT NOT NULL,
+	enabled BOOLEAN DEFAULT FALSE NOT NULL,
+	"action" retention_action NOT NULL,
+	-- Applies to all inboxes when NULL, cascade deletes when inbox is deleted.
+	inbox_id INT REFERENCES inboxes(id) ON DELETE CASCADE ON UPDATE CASCADE NULL,
+	days INT NOT NULL,
+	last_run_at TIMESTAMPTZ NULL,
+	CONSTRAINT constraint_retention_policies_on_name CHECK (length("name") <= 140),
+	CONSTRAINT constraint_retention_policies_on_days CHECK (days > 0)
+);
+
+DROP TABLE IF EXISTS retention_logs CASCADE;
+CREATE TABLE retention_logs (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	-- Policy details are copied so the log outlives changes to the policy.
+	policy_id INT REFERENCES retention_policies(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+	policy_name TEXT NOT NULL,
+	"action" retention_action NOT NULL,
+	inbox_id INT NULL,
+	days INT NOT NULL,
+	-- Number of purged conversations, private notes or attachments.
+	purged_count INT DEFAULT 0 NOT NULL,
+	-- Reference numbers of the affected conversations.
+	conversation_refs TEXT[] DEFAULT '{}'::TEXT[] NOT NULL
+);
+CREATE INDEX index_retention_logs_on_created_at ON retention_logs (created_at);
+CREATE INDEX index_retention_logs_on_policy_id ON retention_logs (policy_id);
+
+DROP TABLE IF EXISTS sender_block_rules CASCADE;
+CREATE TABLE sender_block_rules (
+	id SERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	updated_at TIMESTAMPTZ DEFAULT NOW(),
+	"name" TEXT NOT NULL,
+	enabled BOOLEAN DEFAULT TRUE NOT NULL,
+	"type" block_rule_type NOT NULL,
+	pattern TEXT NOT NULL,
+	"action" block_rule_action NOT NULL,
+	-- Number of messages blocked by this rule.
+	blocked_count INT DEFAULT 0 NOT NULL,
+	last_blocked_at TIMESTAMPTZ NULL,
+	CONSTRAINT constraint_sender_block_rules_on_name CHECK (length("name") <= 140),
+	CONSTRAINT constraint_sender_block_rules_on_pattern CHECK (length(pattern) <= 1000)
+);
+
+DROP TABLE IF EXISTS blocked_messages CASCADE;
+CREATE TABLE blocked_messages (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	-- Kept when the rule is deleted so dropped messages are not fetched again.
+	rule_id INT REFERENCES sender_block_rules(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+	"action" block_rule_action NOT NULL,
+	inbox_id INT NULL,
+	-- Message ID of the blocked message, a message is recorded once.
+	source_id TEXT NULL UNIQUE,
+	sender TEXT NOT NULL
+);
+CREATE INDEX index_blocked_messages_on_rule_id ON blocked_messages (rule_id);
+
+DROP TABLE IF EXISTS dead_letter_messages CASCADE;
+CREATE TABLE dead_letter_messages (
+	-- Incoming messages that failed processing, kept with their encoded payload to be retried or discarded.
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	updated_at TIMESTAMPTZ DEFAULT NOW(),
+	status dead_letter_status DEFAULT 'pending' NOT NULL,
+	inbox_id INT REFERENCES inboxes(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+	source_id TEXT NULL UNIQUE,
+	sender TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	error TEXT NOT NULL,
+	attempts INT DEFAULT 1 NOT NULL,
+	last_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+	payload BYTEA NULL
+);
+CREATE INDEX index_dead_letter_messages_on_status ON dead_letter_messages (status);
+
+DROP TABLE IF EXISTS incoming_message_queue CASCADE;
+CREATE TABLE incoming_message_queue (
+	-- Incoming messages fetched from inboxes waiting to be processed, kept here so they survive restarts.
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	inbox_id INT REFERENCES inboxes(id) ON DELETE CASCADE ON UPDATE CASCADE NULL,
+	source_id TEXT NULL UNIQUE,
+	payload BYTEA NOT NULL,
+	attempts INT DEFAULT 0 NOT NULL,
+	last_error TEXT NULL,
+	-- A message is picked up by a worker once visible, a worker processing it pushes this forward by the visibility
+	-- timeout so it is picked up again if the worker dies.
+	visible_at TIMESTAMPTZ DEFAULT NOW() NOT NULL
+);
+CREATE INDEX index_incoming_message_queue_on_visible_at ON incoming_message_queue (visible_at);
+
+DROP TABLE IF EXISTS export_jobs CASCADE;
+CREATE TABLE export_jobs (
+	-- Background export jobs, the exported file is kept in the media store.
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	-- Refreshed while the job runs, a running job not updated for a while was interrupted by a crash.
+	updated_at TIMESTAMPTZ DEFAULT NOW(),
+	"uuid" UUID DEFAULT gen_random_uuid() NOT NULL UNIQUE,
+	kind TEXT NOT NULL,
+	user_id INT REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE NOT NULL,
+	status export_job_status DEFAULT 'running' NOT NULL,
+	logs TEXT[] DEFAULT '{}'::TEXT[] NOT NULL,
+	total INT DEFAULT 0 NOT NULL,
+	success INT DEFAULT 0 NOT NULL,
+	errors INT DEFAULT 0 NOT NULL,
+	file_name TEXT NOT NULL,
+	media_id INT REFERENCES media(id) ON DELETE SET NULL ON UPDATE CASCADE NULL,
+	ended_at TIMESTAMPTZ NULL
+);
+-- A user can only run one export job of a kind at a time.
+CREATE UNIQUE INDEX index_uniq_export_jobs_on_kind_user_id_running ON export_jobs (kind, user_id) WHERE status = 'running';
+CREATE INDEX index_export_jobs_on_ended_at ON export_jobs (ended_at);
+
 INSERT INTO ai_providers
 ("name", provider, config, is_default)
 VALUES('openai', 'openai', '{"api_key": ""}'::jsonb, true);
@@ -713,7 +893,15 @@ VALUES
 	('notification.email.hello_hostname', '""'::jsonb),
     ('notification.email.email_address', '"admin@yourcompany.com"'::jsonb),
     ('notification.email.max_msg_retries', '3'::jsonb),
-    ('notification.email.enabled', 'false'::jsonb);
+    ('notification.email.enabled', 'false'::jsonb),
+    ('spam.enabled', 'false'::jsonb),
+    ('spam.threshold', '5'::jsonb),
+    ('spam.allowlist', '[]'::jsonb),
+    ('spam.blocklist', '[]'::jsonb),
+    ('spam.authserv_ids', '[]'::jsonb),
+    ('spam.scanner', '""'::jsonb),
+    ('spam.scanner_address', '""'::jsonb),
+    ('spam.scanner_timeout', '"10s"'::jsonb);
 
 -- Default conversation priorities
 INSERT INTO conversation_priorities (name) VALUES
@@ -726,7 +914,8 @@ INSERT INTO conversation_statuses (name) VALUES
 ('Open'),
 ('Snoozed'),
 ('Resolved'),
-('Closed');
+('Closed'),
+('Quarantined');
 
 -- Default roles
 INSERT INTO
@@ -744,7 +933,7 @@ VALUES
 	(
 		'Admin',
 		'Role for users who have complete access to everything.',
-		'{webhooks:manage,activity_logs:manage,custom_attributes:manage,contacts:read_all,contacts:read,contacts:write,contacts:block,contact_notes:read,contact_notes:write,contact_notes:delete,conversations:write,ai:manage,general_settings:manage,notification_settings:manage,oidc:manage,conversations:read_all,conversations:read_unassigned,conversations:read_assigned,conversations:read_team_inbox,conversations:read_team_all,conversations:read,conversations:update_user_assignee,conversations:update_team_assignee,conversations:update_priority,conversations:update_status,conversations:update_tags,messages:read,messages:write,view:manage,shared_views:manage,status:manage,tags:manage,macros:manage,users:manage,teams:manage,automations:manage,inboxes:manage,roles:manage,reports:manage,templates:manage,business_hours:manage,sla:manage}'
+		'{webhooks:manage,activity_logs:manage,custom_attributes:manage,contacts:read_all,contacts:read,contacts:write,contacts:block,contacts:export,contacts:erase,contact_notes:read,contact_notes:write,contact_notes:delete,conversations:write,conversations:merge,ai:manage,general_settings:manage,notification_settings:manage,oidc:manage,conversations:read_all,conversations:read_unassigned,conversations:read_assigned,conversations:read_team_inbox,conversations:read_team_all,conversations:read,conversations:update_user_assignee,conversations:update_team_assignee,conversations:update_priority,conversations:update_status,conversations:update_tags,messages:read,messages:write,view:manage,shared_views:manage,status:manage,tags:manage,macros:manage,users:manage,teams:manage,automations:manage,inboxes:manage,roles:manage,reports:manage,templates:manage,business_hours:manage,sla:manage,retention:manage,block_rules:manage,messages:reprocess,dead_letters:manage}'
 	);
 
 